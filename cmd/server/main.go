@@ -1,10 +1,11 @@
 package main
 
 import (
+    "context"
     "log"
     "os"
+    "time"
     "card-vault/internal/config"
-    "card-vault/internal/crypto"
     "card-vault/internal/handlers"
     "card-vault/internal/middleware"
     "card-vault/internal/repository"
@@ -25,17 +26,62 @@ func main() {
     db := config.InitDatabase()
 
     // Inicializar gestión de claves y cifrado
-    keyManager := crypto.NewKeyManager()
-    currentKey, _ := keyManager.GetCurrentKey()
-    encryptionService, err := crypto.NewEncryptionService(currentKey)
-    if err != nil {
-        log.Fatal("Failed to initialize encryption service:", err)
-    }
+    keyManager := config.InitKeyManager()
+    sealManager := config.InitSealManager(keyManager)
+    kmsProvider := config.InitKMSProvider(keyManager)
 
     // Inicializar capas
     cardRepo := repository.NewCardRepository(db)
-    cardService := service.NewCardService(cardRepo, encryptionService, keyManager)
+    rotationJobRepo := repository.NewRotationJobRepository(db)
+    auditRepo := repository.NewAuditRepository(db)
+    tokenRepo := repository.NewTokenRepository(db)
+    cvvStore := config.InitCVVStore(context.Background())
+    binDatabase := config.InitBINDatabase()
+    panMasking := config.LoadPANMasking()
+    cardService := service.NewCardServiceWithOptions(cardRepo, rotationJobRepo, keyManager, kmsProvider, service.CardServiceOptions{
+        Duplicates:     config.LoadDuplicatePolicy(),
+        Tokenization:   config.LoadTokenizationConfig(),
+        Tokens:         tokenRepo,
+        CVVs:           cvvStore,
+        BINs:           binDatabase,
+        AddressMasking: config.LoadAddressMasking(),
+        PANMasking:     panMasking.Default,
+        Audit:          auditRepo,
+    })
     cardHandler := handlers.NewCardHandler(cardService)
+    keyHandler := handlers.NewKeyHandler(service.NewKeyService(cardRepo, keyManager))
+    sysHandler := handlers.NewSysHandler(sealManager)
+    binHandler := handlers.NewBINHandler(binDatabase)
+    erasureHandler := handlers.NewErasureHandler(service.NewErasureService(cardRepo, tokenRepo, auditRepo, cvvStore, keyManager))
+
+    // Reanudar rotaciones interrumpidas por un reinicio, en cuanto haya claves
+    resumeRotationJobs := func() {
+        if err := cardService.ResumeRotationJobs(); err != nil {
+            log.Printf("Warning: failed to resume key rotation jobs: %v", err)
+        }
+    }
+    sealManager.OnUnseal(resumeRotationJobs)
+    if !sealManager.Sealed() {
+        resumeRotationJobs()
+    }
+    // Los trabajos de una réplica caída se reanudan cuando vence su lease
+    go func() {
+        for range time.Tick(service.RotationJobLease / 5) {
+            if !sealManager.Sealed() {
+                resumeRotationJobs()
+            }
+        }
+    }()
+
+    // Rotación automática según la política KEY_ROTATION_*
+    rotationScheduler := config.InitRotationScheduler(cardService, kmsProvider)
+    rotationScheduler.Start(context.Background())
+    rotationHandler := handlers.NewRotationHandler(rotationScheduler)
+
+    // Caducidad de tarjetas vencidas (CARD_EXPIRY_CRON, diaria por defecto)
+    expiryScheduler := config.InitExpiryScheduler(cardService)
+    expiryScheduler.Start(context.Background())
+    expiryHandler := handlers.NewExpiryHandler(expiryScheduler)
 
     // Configurar rate limiter
     rateLimiter := middleware.NewIPRateLimiter(rate.Limit(100), 20) // 100 requests per second, burst of 20
@@ -58,24 +104,63 @@ func main() {
         c.JSON(200, gin.H{"status": "healthy"})
     })
 
-    // API routes con autenticación
+    // Sellado y ceremonia de apertura
+    sys := r.Group("/sys")
+    {
+        sys.GET("/seal-status", sysHandler.SealStatus)
+        sys.POST("/init", sysHandler.Initialize)
+        sys.POST("/unseal", sysHandler.Unseal)
+        sys.POST("/seal", middleware.AuthMiddleware(), middleware.RequireScope(middleware.ScopeAdmin), sysHandler.Seal)
+    }
+
+    // API routes con autenticación; no disponibles mientras el keyring esté sellado
     api := r.Group("/api/v1")
     api.Use(middleware.AuthMiddleware())
+    api.Use(middleware.RequireUnsealed(sealManager.Sealed))
+    api.Use(middleware.PANMasking(panMasking))
     {
         cards := api.Group("/cards")
         {
             cards.POST("", cardHandler.CreateCard)
             cards.GET("", cardHandler.GetUserCards)
+            cards.GET("/expiring", cardHandler.GetExpiringCards)
             cards.GET("/:id", cardHandler.GetCard)
             cards.PUT("/:id", cardHandler.UpdateCard)
             cards.DELETE("/:id", cardHandler.DeleteCard)
             cards.PATCH("/batch-update", cardHandler.BatchUpdateCards)
+            cards.POST("/:id/token", cardHandler.TokenizeCard)
+            cards.PUT("/:id/preferred-network", cardHandler.SetPreferredNetwork)
+            cards.POST("/:id/freeze", cardHandler.FreezeCard)
+            cards.POST("/:id/unfreeze", cardHandler.UnfreezeCard)
+            cards.POST("/:id/close", cardHandler.CloseCard)
+            cards.POST("/:id/replace", cardHandler.ReplaceCard)
+            cards.GET("/:id/status-history", cardHandler.GetStatusHistory)
+            cards.PUT("/:id/cvv", cardHandler.StoreCVV)
+            cards.POST("/:id/cvv/use", middleware.RequireScope(middleware.ScopeUseCVV), cardHandler.UseCVV)
+            cards.POST("/:id/reveal", middleware.RequireScope(middleware.ScopeRevealCard), cardHandler.RevealCard)
         }
 
-        // Endpoint administrativo para rotación de claves
-        admin := api.Group("/admin")
+        // Detokenización, solo con el scope tokens:detokenize
+        api.POST("/tokens/detokenize", middleware.RequireScope(middleware.ScopeDetokenize), cardHandler.Detokenize)
+
+        // Endpoints administrativos para rotación y gestión de claves, solo con el scope admin
+        admin := api.Group("/admin", middleware.RequireScope(middleware.ScopeAdmin))
         {
             admin.POST("/cards/rotate-keys", cardHandler.RotateKeys)
+            admin.GET("/rotation-jobs/:id", cardHandler.GetRotationJob)
+            admin.GET("/rotation-schedule", rotationHandler.GetSchedule)
+            admin.POST("/cards/bind-associated-data", cardHandler.BindAssociatedData)
+            admin.POST("/cards/lookup", cardHandler.LookupCards)
+            admin.POST("/cards/reindex-fingerprints", cardHandler.ReindexFingerprints)
+            admin.GET("/cards/expiring", cardHandler.GetAllExpiringCards)
+            admin.POST("/cards/expire", expiryHandler.Run)
+            admin.GET("/expiry-schedule", expiryHandler.GetSchedule)
+            admin.GET("/keys", keyHandler.ListKeys)
+            admin.GET("/fingerprint-keys", keyHandler.ListFingerprintKeys)
+            admin.PUT("/keys/:version/state", keyHandler.UpdateKeyState)
+            admin.POST("/users/:id/shred", erasureHandler.ShredUser)
+            admin.GET("/bin-table", binHandler.GetStatus)
+            admin.POST("/bin-table/reload", binHandler.Reload)
         }
     }
 
@@ -0,0 +1,44 @@
+package main
+
+import (
+    "flag"
+    "log"
+    "net/http"
+    "os"
+    "path/filepath"
+    "card-vault/internal/config"
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+)
+
+// Sustituto local de Vault transit para desarrollo sin Docker ni red.
+// Con -keyring-dir las claves se persisten cifradas bajo MASTER_KEY; sin él viven en memoria.
+func main() {
+    addr := flag.String("addr", "127.0.0.1:8200", "listen address")
+    keyringDir := flag.String("keyring-dir", "", "directory for persistent keyrings (in-memory if empty)")
+    flag.Parse()
+
+    token := os.Getenv("TRANSIT_TOKEN")
+    if token == "" {
+        log.Fatal("TRANSIT_TOKEN must be set")
+    }
+
+    var newKeyring func(name string) (*crypto.KeyManager, error)
+    if *keyringDir != "" {
+        masterKey, err := config.LoadMasterKey()
+        if err != nil {
+            log.Fatal("Failed to load master key:", err)
+        }
+
+        newKeyring = func(name string) (*crypto.KeyManager, error) {
+            store, err := crypto.NewFileKeyStore(filepath.Join(*keyringDir, filepath.Base(name)+".json"), masterKey)
+            if err != nil {
+                return nil, err
+            }
+            return crypto.NewKeyManager(store)
+        }
+    }
+
+    log.Printf("Transit stand-in listening on %s", *addr)
+    log.Fatal(http.ListenAndServe(*addr, kms.NewTransitServer(token, newKeyring)))
+}
\ No newline at end of file
@@ -0,0 +1,153 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/base64"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+    "card-vault/internal/config"
+    "card-vault/internal/shamir"
+)
+
+// Cliente de operador para la ceremonia de sellado y apertura del servidor.
+//
+//  vaultctl status
+//  vaultctl init -shares 5
+//  vaultctl unseal [share]      (si no se indica, se lee de la entrada estándar)
+//  vaultctl unseal -reset
+//  vaultctl seal -token <jwt>
+//  vaultctl split -shares 5 -threshold 3   (parte offline la MASTER_KEY actual)
+func main() {
+    log.SetFlags(0)
+    if len(os.Args) < 2 {
+        usage()
+    }
+
+    addr := os.Getenv("CARD_VAULT_ADDR")
+    if addr == "" {
+        addr = "http://127.0.0.1:8080"
+    }
+
+    flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+    flags.StringVar(&addr, "addr", addr, "server address")
+
+    switch os.Args[1] {
+    case "status":
+        flags.Parse(os.Args[2:])
+        call(addr, http.MethodGet, "/sys/seal-status", "", nil)
+
+    case "init":
+        shares := flags.Int("shares", 5, "number of key shares to generate")
+        flags.Parse(os.Args[2:])
+        call(addr, http.MethodPost, "/sys/init", "", map[string]int{"shares": *shares})
+
+    case "unseal":
+        reset := flags.Bool("reset", false, "discard the shares submitted so far")
+        flags.Parse(os.Args[2:])
+        if *reset {
+            call(addr, http.MethodPost, "/sys/unseal", "", map[string]bool{"reset": true})
+            return
+        }
+        call(addr, http.MethodPost, "/sys/unseal", "", map[string]string{"key": readShare(flags.Arg(0))})
+
+    case "seal":
+        token := flags.String("token", os.Getenv("CARD_VAULT_TOKEN"), "JWT used to authorize the seal")
+        flags.Parse(os.Args[2:])
+        call(addr, http.MethodPost, "/sys/seal", *token, nil)
+
+    case "split":
+        shares := flags.Int("shares", 5, "number of key shares to generate")
+        threshold := flags.Int("threshold", 3, "shares required to unseal")
+        flags.Parse(os.Args[2:])
+        split(*shares, *threshold)
+
+    default:
+        usage()
+    }
+}
+
+func usage() {
+    log.Fatal("usage: vaultctl <status|init|unseal|seal|split> [flags]")
+}
+
+// split reparte la master key de MASTER_KEY/MASTER_KEY_FILE en partes, para pasar
+// de un despliegue con auto-unseal a la ceremonia sin volver a cifrar el keyring.
+func split(shares, threshold int) {
+    masterKey, err := config.LoadMasterKey()
+    if err != nil {
+        log.Fatal("Failed to load master key: ", err)
+    }
+
+    parts, err := shamir.Split(masterKey, shares, threshold)
+    if err != nil {
+        log.Fatal("Failed to split master key: ", err)
+    }
+    for _, part := range parts {
+        fmt.Println(base64.StdEncoding.EncodeToString(part))
+    }
+}
+
+func readShare(arg string) string {
+    if arg != "" {
+        return arg
+    }
+
+    fmt.Fprint(os.Stderr, "Key share: ")
+    line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+    if err != nil && err != io.EOF {
+        log.Fatal("Failed to read key share: ", err)
+    }
+    return strings.TrimSpace(line)
+}
+
+func call(addr, method, path, token string, body interface{}) {
+    var reader io.Reader
+    if body != nil {
+        data, err := json.Marshal(body)
+        if err != nil {
+            log.Fatal(err)
+        }
+        reader = bytes.NewReader(data)
+    }
+
+    req, err := http.NewRequest(method, strings.TrimRight(addr, "/")+path, reader)
+    if err != nil {
+        log.Fatal(err)
+    }
+    if body != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    if token != "" {
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+
+    client := &http.Client{Timeout: 30 * time.Second}
+    resp, err := client.Do(req)
+    if err != nil {
+        log.Fatal("Request failed: ", err)
+    }
+    defer resp.Body.Close()
+
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        log.Fatal("Failed to read response: ", err)
+    }
+
+    var out bytes.Buffer
+    if json.Indent(&out, data, "", "  ") == nil {
+        data = out.Bytes()
+    }
+    fmt.Println(string(data))
+
+    if resp.StatusCode >= 300 {
+        os.Exit(1)
+    }
+}
\ No newline at end of file
@@ -0,0 +1,102 @@
+package address
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+    "strings"
+    "card-vault/internal/models"
+
+    "github.com/go-playground/validator/v10"
+)
+
+var (
+    ErrInvalidCountry    = errors.New("invalid country code")
+    ErrInvalidPostalCode = errors.New("invalid postal code")
+)
+
+// Formato del código postal de los países más habituales. El resto de países
+// solo se comprueba con genericPostalCode.
+var postalCodes = map[string]*regexp.Regexp{
+    "AT": regexp.MustCompile(`^\d{4}$`),
+    "AU": regexp.MustCompile(`^\d{4}$`),
+    "BE": regexp.MustCompile(`^\d{4}$`),
+    "BR": regexp.MustCompile(`^\d{5}-?\d{3}$`),
+    "CA": regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`),
+    "CH": regexp.MustCompile(`^\d{4}$`),
+    "CN": regexp.MustCompile(`^\d{6}$`),
+    "DE": regexp.MustCompile(`^\d{5}$`),
+    "DK": regexp.MustCompile(`^\d{4}$`),
+    "ES": regexp.MustCompile(`^\d{5}$`),
+    "FI": regexp.MustCompile(`^\d{5}$`),
+    "FR": regexp.MustCompile(`^\d{5}$`),
+    "GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+    "IE": regexp.MustCompile(`^[A-Z]\d[\dW] ?[A-Z\d]{4}$`),
+    "IN": regexp.MustCompile(`^\d{6}$`),
+    "IT": regexp.MustCompile(`^\d{5}$`),
+    "JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+    "MX": regexp.MustCompile(`^\d{5}$`),
+    "NL": regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`),
+    "NO": regexp.MustCompile(`^\d{4}$`),
+    "PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+    "PT": regexp.MustCompile(`^\d{4}-\d{3}$`),
+    "SE": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+    "US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+}
+
+var genericPostalCode = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{1,9}$`)
+
+// Países sin códigos postales de uso general: en ellos el código es opcional.
+var withoutPostalCodes = map[string]bool{
+    "AE": true, "AG": true, "AO": true, "BS": true, "BZ": true, "FJ": true,
+    "GH": true, "HK": true, "JM": true, "MO": true, "QA": true, "ZW": true,
+}
+
+var countryValidator = validator.New()
+
+// Normalize limpia los espacios de la dirección, pasa país y código postal a
+// mayúsculas y comprueba el país (ISO 3166-1 alfa-2) y el formato del código
+// postal. Una dirección nil no es un error.
+func Normalize(addr *models.BillingAddress) (*models.BillingAddress, error) {
+    if addr == nil {
+        return nil, nil
+    }
+
+    normalized := &models.BillingAddress{
+        City:       strings.TrimSpace(addr.City),
+        Region:     strings.TrimSpace(addr.Region),
+        PostalCode: strings.ToUpper(strings.Join(strings.Fields(addr.PostalCode), " ")),
+        Country:    strings.ToUpper(strings.TrimSpace(addr.Country)),
+    }
+    for _, line := range addr.Lines {
+        if line = strings.TrimSpace(line); line != "" {
+            normalized.Lines = append(normalized.Lines, line)
+        }
+    }
+
+    if countryValidator.Var(normalized.Country, "iso3166_1_alpha2") != nil {
+        return nil, fmt.Errorf("%w: %q", ErrInvalidCountry, addr.Country)
+    }
+    if err := validatePostalCode(normalized.PostalCode, normalized.Country); err != nil {
+        return nil, err
+    }
+    return normalized, nil
+}
+
+func validatePostalCode(code, country string) error {
+    if code == "" {
+        if withoutPostalCodes[country] {
+            return nil
+        }
+        return fmt.Errorf("%w: required for %s", ErrInvalidPostalCode, country)
+    }
+
+    pattern, ok := postalCodes[country]
+    if !ok {
+        pattern = genericPostalCode
+    }
+    if !pattern.MatchString(code) {
+        return fmt.Errorf("%w: %q is not a valid %s postal code", ErrInvalidPostalCode, code, country)
+    }
+    return nil
+}
\ No newline at end of file
@@ -0,0 +1,79 @@
+package address
+
+import (
+    "fmt"
+    "strings"
+    "card-vault/internal/models"
+)
+
+// Masking indica cuánto de la dirección cifrada (calle y código postal) se
+// muestra en las respuestas. Ciudad, región y país se muestran siempre.
+type Masking string
+
+const (
+    // MaskNone muestra la dirección completa
+    MaskNone Masking = "none"
+    // MaskPartial deja ver los primeros caracteres de cada línea y del código postal
+    MaskPartial Masking = "partial"
+    // MaskFull oculta por completo calle y código postal
+    MaskFull Masking = "full"
+)
+
+// Caracteres que MaskPartial deja sin enmascarar
+const (
+    visibleLineChars   = 3
+    visiblePostalChars = 2
+)
+
+func ParseMasking(name string) (Masking, error) {
+    switch masking := Masking(strings.ToLower(name)); masking {
+    case MaskNone, MaskPartial, MaskFull:
+        return masking, nil
+    }
+    return "", fmt.Errorf("unknown billing address masking %q", name)
+}
+
+// Mask devuelve una copia de addr con la calle y el código postal enmascarados.
+func Mask(addr *models.BillingAddress, masking Masking) *models.BillingAddress {
+    if addr == nil {
+        return nil
+    }
+
+    visibleLine, visiblePostal := visibleLineChars, visiblePostalChars
+    switch masking {
+    case MaskNone:
+        masked := *addr
+        masked.Lines = append([]string(nil), addr.Lines...)
+        return &masked
+    case MaskFull:
+        visibleLine, visiblePostal = 0, 0
+    }
+
+    masked := *addr
+    masked.Lines = make([]string, len(addr.Lines))
+    for i, line := range addr.Lines {
+        masked.Lines[i] = maskText(line, visibleLine)
+    }
+    masked.PostalCode = maskText(addr.PostalCode, visiblePostal)
+    return &masked
+}
+
+// maskText sustituye por * los caracteres que no son espacios a partir de los
+// visible primeros, de modo que se conserva la forma del texto.
+func maskText(text string, visible int) string {
+    var b strings.Builder
+    seen := 0
+    for _, r := range text {
+        if r == ' ' {
+            b.WriteRune(r)
+            continue
+        }
+        if seen < visible {
+            b.WriteRune(r)
+        } else {
+            b.WriteRune('*')
+        }
+        seen++
+    }
+    return b.String()
+}
\ No newline at end of file
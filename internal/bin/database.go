@@ -0,0 +1,156 @@
+package bin
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+)
+
+var ErrNoSource = errors.New("no BIN table file configured")
+
+// Status describe la tabla cargada.
+type Status struct {
+    Path     string     `json:"path,omitempty"`
+    Ranges   int        `json:"ranges"`
+    LoadedAt *time.Time `json:"loaded_at,omitempty"`
+}
+
+// Database es la tabla de BIN en uso, recargable en caliente desde su fichero. Una
+// recarga fallida deja la tabla anterior.
+type Database struct {
+    path     string
+    table    *Table
+    loadedAt *time.Time
+    mu       sync.RWMutex
+}
+
+// Open carga la tabla de path (.csv o .json). Sin path la tabla queda vacía.
+func Open(path string) (*Database, error) {
+    empty, _ := NewTable(nil)
+    d := &Database{path: path, table: empty}
+    if path == "" {
+        return d, nil
+    }
+    if _, err := d.Reload(); err != nil {
+        return nil, err
+    }
+    return d, nil
+}
+
+// Reload vuelve a leer el fichero y sustituye la tabla de una vez.
+func (d *Database) Reload() (Status, error) {
+    if d.path == "" {
+        return d.Status(), ErrNoSource
+    }
+
+    table, err := LoadFile(d.path)
+    if err != nil {
+        return d.Status(), err
+    }
+
+    now := time.Now()
+    d.mu.Lock()
+    d.table, d.loadedAt = table, &now
+    d.mu.Unlock()
+    return d.Status(), nil
+}
+
+func (d *Database) Lookup(pan string) (Info, bool) {
+    d.mu.RLock()
+    table := d.table
+    d.mu.RUnlock()
+    return table.Lookup(pan)
+}
+
+func (d *Database) Status() Status {
+    d.mu.RLock()
+    defer d.mu.RUnlock()
+    return Status{Path: d.path, Ranges: d.table.Len(), LoadedAt: d.loadedAt}
+}
+
+// LoadFile lee una tabla en CSV o JSON según la extensión del fichero.
+func LoadFile(path string) (*Table, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open BIN table: %w", err)
+    }
+    defer f.Close()
+
+    var ranges []Range
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".csv":
+        ranges, err = ParseCSV(f)
+    case ".json":
+        err = json.NewDecoder(f).Decode(&ranges)
+    default:
+        return nil, fmt.Errorf("unsupported BIN table format %q (use .csv or .json)", filepath.Ext(path))
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse BIN table: %w", err)
+    }
+
+    return NewTable(ranges)
+}
+
+// ParseCSV lee rangos de un CSV cuya primera fila es la cabecera, con las columnas
+// start, end, issuer, country, funding, segment, level y networks (separadas por
+// "|") en cualquier orden. Solo start es obligatoria.
+func ParseCSV(r io.Reader) ([]Range, error) {
+    reader := csv.NewReader(r)
+    reader.Comment = '#'
+    reader.TrimLeadingSpace = true
+
+    header, err := reader.Read()
+    if err != nil {
+        return nil, err
+    }
+
+    index := make(map[string]int)
+    for i, name := range header {
+        index[strings.ToLower(strings.TrimSpace(name))] = i
+    }
+    if _, ok := index["start"]; !ok {
+        return nil, errors.New("missing start column")
+    }
+
+    var ranges []Range
+    for {
+        record, err := reader.Read()
+        if err == io.EOF {
+            return ranges, nil
+        }
+        if err != nil {
+            return nil, err
+        }
+
+        field := func(name string) string {
+            if i, ok := index[name]; ok && i < len(record) {
+                return strings.TrimSpace(record[i])
+            }
+            return ""
+        }
+
+        r := Range{
+            Start: field("start"),
+            End:   field("end"),
+            Info: Info{
+                Issuer:  field("issuer"),
+                Country: field("country"),
+                Funding: field("funding"),
+                Segment: field("segment"),
+                Level:   field("level"),
+            },
+        }
+        if networks := field("networks"); networks != "" {
+            r.Networks = strings.Split(networks, "|")
+        }
+        ranges = append(ranges, r)
+    }
+}
\ No newline at end of file
@@ -0,0 +1,143 @@
+package bin
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+    "card-vault/internal/brand"
+)
+
+const (
+    FundingCredit  = "credit"
+    FundingDebit   = "debit"
+    FundingPrepaid = "prepaid"
+
+    SegmentConsumer   = "consumer"
+    SegmentCommercial = "commercial"
+
+    minPrefixLength = 4
+    maxPrefixLength = 11
+)
+
+var ErrInvalidRange = errors.New("invalid BIN range")
+
+// Info son los datos del emisor asociados a un rango de BIN. Networks lista las
+// redes de las tarjetas co-badged del rango.
+type Info struct {
+    Issuer   string   `json:"issuer"`
+    Country  string   `json:"country"`
+    Funding  string   `json:"funding"`
+    Segment  string   `json:"segment"`
+    Level    string   `json:"level"`
+    Networks []string `json:"networks,omitempty"`
+}
+
+// Range cubre los PAN cuyos primeros len(Start) dígitos están entre Start y End,
+// ambos incluidos y de la misma longitud (p. ej. 411111-411111 o 45717360-45717369).
+// Sin End el rango es solo el prefijo Start.
+type Range struct {
+    Start string `json:"start"`
+    End   string `json:"end"`
+    Info
+}
+
+func (r Range) validate() error {
+    if len(r.Start) < minPrefixLength || len(r.Start) > maxPrefixLength || len(r.End) != len(r.Start) {
+        return fmt.Errorf("%w %s-%s: bounds must have the same length of %d to %d digits", ErrInvalidRange, r.Start, r.End, minPrefixLength, maxPrefixLength)
+    }
+    if !digits(r.Start) || !digits(r.End) || r.Start > r.End {
+        return fmt.Errorf("%w %s-%s: bounds must be digits with start <= end", ErrInvalidRange, r.Start, r.End)
+    }
+
+    switch r.Funding {
+    case "", FundingCredit, FundingDebit, FundingPrepaid:
+    default:
+        return fmt.Errorf("%w %s-%s: unknown funding type %q", ErrInvalidRange, r.Start, r.End, r.Funding)
+    }
+    switch r.Segment {
+    case "", SegmentConsumer, SegmentCommercial:
+    default:
+        return fmt.Errorf("%w %s-%s: unknown segment %q", ErrInvalidRange, r.Start, r.End, r.Segment)
+    }
+    if r.Country != "" && (len(r.Country) != 2 || strings.ToUpper(r.Country) != r.Country) {
+        return fmt.Errorf("%w %s-%s: country must be an ISO 3166-1 alpha-2 code", ErrInvalidRange, r.Start, r.End)
+    }
+    return nil
+}
+
+// Table es una tabla de rangos inmutable. Si varios rangos cubren un PAN gana el
+// de prefijo más largo, que es el más específico.
+type Table struct {
+    // Rangos por longitud de prefijo, ordenados por Start
+    byLength map[int][]Range
+    lengths  []int
+    size     int
+}
+
+// NewTable valida los rangos y construye la tabla. Los rangos de la misma
+// longitud no pueden solaparse.
+func NewTable(ranges []Range) (*Table, error) {
+    t := &Table{byLength: make(map[int][]Range), size: len(ranges)}
+    for _, r := range ranges {
+        if r.End == "" {
+            r.End = r.Start
+        }
+        r.Country = strings.ToUpper(r.Country)
+        r.Funding = strings.ToLower(r.Funding)
+        r.Segment = strings.ToLower(r.Segment)
+        if err := r.validate(); err != nil {
+            return nil, err
+        }
+
+        networks, err := brand.CanonicalNetworks(r.Networks)
+        if err != nil {
+            return nil, fmt.Errorf("%w %s-%s: %v", ErrInvalidRange, r.Start, r.End, err)
+        }
+        r.Networks = networks
+        t.byLength[len(r.Start)] = append(t.byLength[len(r.Start)], r)
+    }
+
+    for length, rs := range t.byLength {
+        sort.Slice(rs, func(i, j int) bool { return rs[i].Start < rs[j].Start })
+        for i := 1; i < len(rs); i++ {
+            if rs[i].Start <= rs[i-1].End {
+                return nil, fmt.Errorf("%w: %s-%s overlaps %s-%s", ErrInvalidRange, rs[i].Start, rs[i].End, rs[i-1].Start, rs[i-1].End)
+            }
+        }
+        t.lengths = append(t.lengths, length)
+    }
+    sort.Sort(sort.Reverse(sort.IntSlice(t.lengths)))
+    return t, nil
+}
+
+// Lookup devuelve los datos del rango más específico que cubre pan.
+func (t *Table) Lookup(pan string) (Info, bool) {
+    for _, length := range t.lengths {
+        if len(pan) < length {
+            continue
+        }
+        prefix := pan[:length]
+        rs := t.byLength[length]
+
+        // Último rango con Start <= prefix
+        i := sort.Search(len(rs), func(i int) bool { return rs[i].Start > prefix }) - 1
+        if i >= 0 && prefix <= rs[i].End {
+            return rs[i].Info, true
+        }
+    }
+    return Info{}, false
+}
+
+func (t *Table) Len() int {
+    return t.size
+}
+
+func digits(s string) bool {
+    for _, r := range s {
+        if r < '0' || r > '9' {
+            return false
+        }
+    }
+    return true
+}
\ No newline at end of file
@@ -0,0 +1,208 @@
+package brand
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+)
+
+// Reglas que puede incumplir un número de tarjeta o un CVV.
+const (
+    RuleDigits    = "digits"
+    RuleLength    = "length"
+    RuleLuhn      = "luhn"
+    RuleCVVLength = "cvv_length"
+)
+
+var (
+    ErrInvalidDigits    = errors.New("card number must contain only digits")
+    ErrInvalidLength    = errors.New("card number length is not valid for its brand")
+    ErrLuhnCheck        = errors.New("card number fails the Luhn check")
+    ErrInvalidCVVLength = errors.New("CVV length is not valid for the card brand")
+)
+
+// ValidationError indica qué regla de qué marca ha fallado. Es comparable con
+// errors.Is contra el error de su regla (ErrInvalidLength, ErrLuhnCheck...).
+type ValidationError struct {
+    Rule    string
+    Brand   string
+    Message string
+    err     error
+}
+
+func (e *ValidationError) Error() string {
+    return e.Message
+}
+
+func (e *ValidationError) Unwrap() error {
+    return e.err
+}
+
+// Brand describe una marca: los prefijos (IIN) que le pertenecen, las longitudes
+// de PAN que admite, la longitud de su CVV y si sus números cumplen Luhn.
+type Brand struct {
+    Name      string
+    Lengths   []int
+    CVVLength int
+    // Algunos rangos de UnionPay emiten números que no cumplen Luhn
+    LuhnOptional bool
+    ranges       []prefixRange
+}
+
+// Unknown se aplica a los PAN de ningún rango conocido: 12 a 19 dígitos y Luhn.
+var Unknown = Brand{Name: "Unknown", Lengths: lengthRange(12, 19)}
+
+type prefixRange struct {
+    lo, hi string
+}
+
+func (b Brand) AcceptsLength(n int) bool {
+    for _, l := range b.Lengths {
+        if l == n {
+            return true
+        }
+    }
+    return false
+}
+
+// Detect devuelve la marca del PAN. Si varios rangos coinciden gana el prefijo más
+// largo, que es el más específico (p. ej. Elo 627780 dentro de UnionPay 62).
+func Detect(pan string) Brand {
+    best, bestLength := Unknown, 0
+    for _, b := range catalogue {
+        for _, r := range b.ranges {
+            n := len(r.lo)
+            if n <= bestLength || len(pan) < n {
+                continue
+            }
+            if prefix := pan[:n]; prefix >= r.lo && prefix <= r.hi {
+                best, bestLength = b, n
+            }
+        }
+    }
+    return best
+}
+
+// Lookup busca una marca por su nombre (el que se guarda como tipo de tarjeta).
+func Lookup(name string) (Brand, bool) {
+    for _, b := range catalogue {
+        if strings.EqualFold(b.Name, name) {
+            return b, true
+        }
+    }
+    return Unknown, strings.EqualFold(name, Unknown.Name)
+}
+
+// Catalogue devuelve los nombres de las marcas reconocidas.
+func Catalogue() []string {
+    names := make([]string, len(catalogue))
+    for i, b := range catalogue {
+        names[i] = b.Name
+    }
+    return names
+}
+
+// Validate comprueba el PAN contra las reglas de su marca y, si se indica, la
+// longitud del CVV. Devuelve la marca detectada y un *ValidationError con la
+// primera regla incumplida.
+func Validate(pan, cvv string) (Brand, error) {
+    if pan == "" || !digits(pan) {
+        return Unknown, &ValidationError{Rule: RuleDigits, Brand: Unknown.Name, Message: ErrInvalidDigits.Error(), err: ErrInvalidDigits}
+    }
+
+    b := Detect(pan)
+    if !b.AcceptsLength(len(pan)) {
+        return b, &ValidationError{
+            Rule:    RuleLength,
+            Brand:   b.Name,
+            Message: fmt.Sprintf("%s card numbers must have %s digits, got %d", b.Name, describeLengths(b.Lengths), len(pan)),
+            err:     ErrInvalidLength,
+        }
+    }
+    if !b.LuhnOptional && !LuhnValid(pan) {
+        return b, &ValidationError{
+            Rule:    RuleLuhn,
+            Brand:   b.Name,
+            Message: fmt.Sprintf("%s card number fails the Luhn check", b.Name),
+            err:     ErrLuhnCheck,
+        }
+    }
+
+    if cvv != "" {
+        if err := b.ValidateCVV(cvv); err != nil {
+            return b, err
+        }
+    }
+    return b, nil
+}
+
+// ValidateCVV comprueba que el CVV tiene la longitud de la marca.
+func (b Brand) ValidateCVV(cvv string) error {
+    expected := b.CVVLength
+    if expected == 0 {
+        // Marca desconocida: se admite cualquier longitud habitual
+        if digits(cvv) && (len(cvv) == 3 || len(cvv) == 4) {
+            return nil
+        }
+        expected = 3
+    }
+    if digits(cvv) && len(cvv) == expected {
+        return nil
+    }
+    return &ValidationError{
+        Rule:    RuleCVVLength,
+        Brand:   b.Name,
+        Message: fmt.Sprintf("%s CVV must have %d digits", b.Name, expected),
+        err:     ErrInvalidCVVLength,
+    }
+}
+
+func LuhnValid(number string) bool {
+    sum := 0
+    double := false
+    for i := len(number) - 1; i >= 0; i-- {
+        d := int(number[i] - '0')
+        if double {
+            d *= 2
+            if d > 9 {
+                d -= 9
+            }
+        }
+        sum += d
+        double = !double
+    }
+    return sum%10 == 0
+}
+
+func digits(s string) bool {
+    for _, r := range s {
+        if r < '0' || r > '9' {
+            return false
+        }
+    }
+    return true
+}
+
+// describeLengths escribe las longitudes admitidas ("15", "16 or 19", "12 to 19").
+func describeLengths(lengths []int) string {
+    if len(lengths) == 1 {
+        return fmt.Sprint(lengths[0])
+    }
+    if lengths[len(lengths)-1]-lengths[0] == len(lengths)-1 {
+        return fmt.Sprintf("%d to %d", lengths[0], lengths[len(lengths)-1])
+    }
+
+    parts := make([]string, len(lengths))
+    for i, l := range lengths {
+        parts[i] = fmt.Sprint(l)
+    }
+    return strings.Join(parts[:len(parts)-1], ", ") + " or " + parts[len(parts)-1]
+}
+
+func lengthRange(from, to int) []int {
+    lengths := make([]int, 0, to-from+1)
+    for l := from; l <= to; l++ {
+        lengths = append(lengths, l)
+    }
+    return lengths
+}
\ No newline at end of file
@@ -0,0 +1,35 @@
+package brand
+
+// catalogue recoge los rangos de IIN publicados por cada red. Los rangos de
+// distinta longitud pueden solaparse; Detect elige el más largo.
+var catalogue = []Brand{
+    {Name: "Visa", Lengths: []int{13, 16, 19}, CVVLength: 3, ranges: prefixes("4")},
+    {Name: "Mastercard", Lengths: []int{16}, CVVLength: 3, ranges: []prefixRange{{"51", "55"}, {"2221", "2720"}}},
+    {Name: "Amex", Lengths: []int{15}, CVVLength: 4, ranges: prefixes("34", "37")},
+    {Name: "Discover", Lengths: lengthRange(16, 19), CVVLength: 3, ranges: []prefixRange{{"6011", "6011"}, {"644", "649"}, {"65", "65"}}},
+    {Name: "JCB", Lengths: lengthRange(16, 19), CVVLength: 3, ranges: []prefixRange{{"3528", "3589"}}},
+    {Name: "Diners Club", Lengths: lengthRange(14, 19), CVVLength: 3, ranges: []prefixRange{{"300", "305"}, {"3095", "3095"}, {"36", "36"}, {"38", "39"}}},
+    {Name: "UnionPay", Lengths: lengthRange(16, 19), CVVLength: 3, LuhnOptional: true, ranges: prefixes("62")},
+    {Name: "Maestro", Lengths: lengthRange(12, 19), CVVLength: 3, ranges: prefixes("5018", "5020", "5038", "5893", "6304", "6759", "6761", "6762", "6763")},
+    {Name: "Mir", Lengths: lengthRange(16, 19), CVVLength: 3, ranges: []prefixRange{{"2200", "2204"}}},
+    {Name: "RuPay", Lengths: []int{16}, CVVLength: 3, ranges: append(prefixes("60", "81", "82", "508"), prefixRange{"652150", "653149"})},
+    {Name: "Elo", Lengths: []int{16}, CVVLength: 3, ranges: append(
+        prefixes("401178", "401179", "431274", "438935", "451416", "457393", "457631", "457632", "504175", "627780", "636297", "636368"),
+        prefixRange{"506699", "506778"}, prefixRange{"509000", "509999"}, prefixRange{"650031", "650033"},
+        prefixRange{"650035", "650051"}, prefixRange{"650405", "650439"}, prefixRange{"650485", "650538"},
+        prefixRange{"650541", "650598"}, prefixRange{"650700", "650718"}, prefixRange{"650720", "650727"},
+        prefixRange{"650901", "650978"}, prefixRange{"651652", "651679"}, prefixRange{"655000", "655019"},
+        prefixRange{"655021", "655058"},
+    )},
+    {Name: "Hipercard", Lengths: []int{13, 16, 19}, CVVLength: 3, ranges: prefixes("384100", "384140", "384160", "606282", "637095", "637568", "637599", "637609", "637612")},
+    {Name: "Verve", Lengths: []int{16, 18, 19}, CVVLength: 3, ranges: []prefixRange{{"506099", "506198"}, {"507865", "507964"}, {"650002", "650027"}}},
+    {Name: "Troy", Lengths: []int{16}, CVVLength: 3, ranges: prefixes("9792")},
+}
+
+func prefixes(values ...string) []prefixRange {
+    ranges := make([]prefixRange, len(values))
+    for i, v := range values {
+        ranges[i] = prefixRange{v, v}
+    }
+    return ranges
+}
\ No newline at end of file
@@ -0,0 +1,72 @@
+package brand
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+)
+
+var ErrUnknownNetwork = errors.New("unknown card network")
+
+// Redes domésticas que suelen acompañar a una marca internacional en tarjetas
+// co-badged (p. ej. Cartes Bancaires + Visa). No se detectan por prefijo: vienen
+// de la tabla de BIN o del cliente.
+var domesticNetworks = []string{
+    "Cartes Bancaires",
+    "Bancontact",
+    "Girocard",
+    "Dankort",
+    "PagoBancomat",
+    "Interac",
+    "eftpos",
+    "STAR",
+    "NYCE",
+    "PULSE",
+    "Accel",
+}
+
+// Networks devuelve todas las redes admitidas: las marcas del catálogo y las domésticas.
+func Networks() []string {
+    return append(Catalogue(), domesticNetworks...)
+}
+
+// CanonicalNetwork devuelve el nombre canónico de una red, sin distinguir
+// mayúsculas, espacios, guiones ni guiones bajos ("cartes_bancaires").
+func CanonicalNetwork(name string) (string, error) {
+    key := networkKey(name)
+    for _, network := range Networks() {
+        if networkKey(network) == key {
+            return network, nil
+        }
+    }
+    return "", fmt.Errorf("%w %q", ErrUnknownNetwork, name)
+}
+
+// CanonicalNetworks normaliza una lista de redes y quita los duplicados
+// conservando el orden.
+func CanonicalNetworks(names []string) ([]string, error) {
+    var networks []string
+    for _, name := range names {
+        network, err := CanonicalNetwork(name)
+        if err != nil {
+            return nil, err
+        }
+        if !containsNetwork(networks, network) {
+            networks = append(networks, network)
+        }
+    }
+    return networks, nil
+}
+
+func containsNetwork(networks []string, network string) bool {
+    for _, n := range networks {
+        if n == network {
+            return true
+        }
+    }
+    return false
+}
+
+func networkKey(name string) string {
+    return strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(name))
+}
\ No newline at end of file
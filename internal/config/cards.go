@@ -0,0 +1,171 @@
+package config
+
+import (
+    "context"
+    "log"
+    "os"
+    "strconv"
+    "time"
+    "card-vault/internal/address"
+    "card-vault/internal/bin"
+    "card-vault/internal/cvv"
+    "card-vault/internal/masking"
+    "card-vault/internal/scheduler"
+    "card-vault/internal/service"
+    "card-vault/internal/tokenization"
+)
+
+// LoadDuplicatePolicy lee de DUPLICATE_CARD_POLICY qué hacer cuando un usuario
+// guarda un PAN que ya tiene: reject (por defecto), merge o allow.
+func LoadDuplicatePolicy() service.DuplicatePolicy {
+    name := os.Getenv("DUPLICATE_CARD_POLICY")
+    if name == "" {
+        return service.DuplicateReject
+    }
+
+    policy, err := service.ParseDuplicatePolicy(name)
+    if err != nil {
+        log.Fatal("Invalid DUPLICATE_CARD_POLICY:", err)
+    }
+    return policy
+}
+
+// LoadTokenizationConfig lee el modo de tokenización de TOKENIZATION_MODE
+// (deterministic por defecto, o random) y qué dígitos del PAN conserva el token:
+// TOKEN_PRESERVE_BIN (6 primeros) y TOKEN_PRESERVE_LAST4.
+func LoadTokenizationConfig() tokenization.Config {
+    cfg := tokenization.Config{Mode: tokenization.ModeDeterministic}
+
+    if name := os.Getenv("TOKENIZATION_MODE"); name != "" {
+        mode, err := tokenization.ParseMode(name)
+        if err != nil {
+            log.Fatal("Invalid TOKENIZATION_MODE:", err)
+        }
+        cfg.Mode = mode
+    }
+
+    cfg.PreserveBIN = loadBool("TOKEN_PRESERVE_BIN")
+    cfg.PreserveLast4 = loadBool("TOKEN_PRESERVE_LAST4")
+    return cfg
+}
+
+// InitExpiryScheduler crea el planificador que marca como caducadas las tarjetas
+// vencidas. CARD_EXPIRY_CRON indica cuándo se ejecuta (@daily por defecto); con
+// "off" solo se ejecuta a mano.
+func InitExpiryScheduler(expirer scheduler.Expirer) *scheduler.ExpiryScheduler {
+    spec := os.Getenv("CARD_EXPIRY_CRON")
+    if spec == "" {
+        spec = "@daily"
+    }
+
+    var schedule *scheduler.CronSchedule
+    if spec != "off" {
+        var err error
+        if schedule, err = scheduler.ParseCron(spec); err != nil {
+            log.Fatal("Invalid CARD_EXPIRY_CRON:", err)
+        }
+    }
+    return scheduler.NewExpiryScheduler(schedule, expirer, time.Minute)
+}
+
+// LoadAddressMasking lee de BILLING_ADDRESS_MASKING cuánto se enmascaran la calle
+// y el código postal en las respuestas: partial (por defecto), full o none.
+func LoadAddressMasking() address.Masking {
+    name := os.Getenv("BILLING_ADDRESS_MASKING")
+    if name == "" {
+        return address.MaskPartial
+    }
+
+    masking, err := address.ParseMasking(name)
+    if err != nil {
+        log.Fatal("Invalid BILLING_ADDRESS_MASKING:", err)
+    }
+    return masking
+}
+
+// LoadPANMasking lee cómo se enmascara el PAN en las respuestas: PAN_MASKING es la
+// política por defecto (last4, bin6_last4, bin8_last4 o full, con ";char=X" y
+// ";group=N" opcionales) y PAN_MASKING_ROLES y PAN_MASKING_CLIENTS la cambian por
+// rol o por cliente de la API, p. ej. "fraud=bin6_last4,support=last4".
+func LoadPANMasking() masking.Policies {
+    policies := masking.Policies{Default: masking.Default}
+
+    if spec := os.Getenv("PAN_MASKING"); spec != "" {
+        policy, err := masking.Parse(spec)
+        if err != nil {
+            log.Fatal("Invalid PAN_MASKING:", err)
+        }
+        policies.Default = policy
+    }
+
+    var err error
+    if policies.Roles, err = masking.ParseAssignments(os.Getenv("PAN_MASKING_ROLES")); err != nil {
+        log.Fatal("Invalid PAN_MASKING_ROLES:", err)
+    }
+    if policies.Clients, err = masking.ParseAssignments(os.Getenv("PAN_MASKING_CLIENTS")); err != nil {
+        log.Fatal("Invalid PAN_MASKING_CLIENTS:", err)
+    }
+    return policies
+}
+
+func loadBool(name string) bool {
+    value := os.Getenv(name)
+    if value == "" {
+        return false
+    }
+
+    enabled, err := strconv.ParseBool(value)
+    if err != nil {
+        log.Fatal("Invalid "+name+":", err)
+    }
+    return enabled
+}
+
+// InitCVVStore crea el almacén temporal de CVVs y arranca su barrido. CVV_TTL
+// (10m por defecto) es el tiempo máximo que se conserva un CVV sin usar. Los CVVs
+// solo existen en la memoria de este proceso, así que no arranca si REPLICAS
+// declara más de una réplica: la que recibe un CVV no sería la que lo usa.
+func InitCVVStore(ctx context.Context) *cvv.MemoryStore {
+    if value := os.Getenv("REPLICAS"); value != "" {
+        replicas, err := strconv.Atoi(value)
+        if err != nil || replicas < 1 {
+            log.Fatal("Invalid REPLICAS: ", value)
+        }
+        if replicas > 1 {
+            log.Fatalf("CVVs are held in process memory and require a single replica, REPLICAS is %d", replicas)
+        }
+    }
+
+    ttl := cvv.DefaultTTL
+    if value := os.Getenv("CVV_TTL"); value != "" {
+        var err error
+        if ttl, err = parseDuration(value); err != nil {
+            log.Fatal("Invalid CVV_TTL:", err)
+        }
+    }
+
+    store, err := cvv.NewMemoryStore(ttl)
+    if err != nil {
+        log.Fatal("Failed to initialize CVV store:", err)
+    }
+
+    // Barrido frecuente respecto al TTL para no alargar la vida de los CVVs
+    interval := min(ttl/10, time.Minute)
+    store.Start(ctx, max(interval, time.Second))
+    return store
+}
+
+// InitBINDatabase carga la tabla de BIN de BIN_TABLE_PATH (.csv o .json). Sin
+// fichero las tarjetas se guardan sin datos de emisor.
+func InitBINDatabase() *bin.Database {
+    path := os.Getenv("BIN_TABLE_PATH")
+    bins, err := bin.Open(path)
+    if err != nil {
+        log.Fatal("Failed to load BIN table:", err)
+    }
+
+    if path != "" {
+        log.Printf("Loaded %d BIN ranges from %s", bins.Status().Ranges, path)
+    }
+    return bins
+}
\ No newline at end of file
@@ -4,7 +4,10 @@ import (
     "fmt"
     "log"
     "os"
+    "strings"
     "card-vault/internal/models"
+    "card-vault/internal/service"
+    "card-vault/internal/vault"
     
     "gorm.io/driver/postgres"
     "gorm.io/gorm"
@@ -23,16 +26,143 @@ func InitDatabase() *gorm.DB {
     
     db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
         Logger: logger.Default.LogMode(logger.Info),
+        // Las violaciones de unicidad llegan como gorm.ErrDuplicatedKey
+        TranslateError: true,
     })
     if err != nil {
         log.Fatal("Failed to connect to database:", err)
     }
+
+    // Sellan y abren los campos cifrados en las sentencias con las claves del servicio
+    if err := vault.Register(db); err != nil {
+        log.Fatal("Failed to register encrypted field callbacks:", err)
+    }
     
+    if err := dropCVVColumn(db); err != nil {
+        log.Fatal("Failed to drop CVV column:", err)
+    }
+
+    if err := migrateCiphertextColumns(db); err != nil {
+        log.Fatal("Failed to migrate ciphertext columns:", err)
+    }
+
+    if err := migrateCardholderName(db); err != nil {
+        log.Fatal("Failed to migrate cardholder name:", err)
+    }
+
     // Auto migrate
-    err = db.AutoMigrate(&models.Card{})
+    err = db.AutoMigrate(&models.Card{}, &models.RotationJob{}, &models.AuditRecord{}, &models.CardToken{}, &models.CardStatusEvent{})
     if err != nil {
         log.Fatal("Failed to migrate database:", err)
     }
+
+    if err := migrateActiveFlag(db); err != nil {
+        log.Fatal("Failed to migrate card status:", err)
+    }
+
+    if err := ensureDuplicateCardIndex(db, LoadDuplicatePolicy()); err != nil {
+        log.Fatal("Failed to create unique card fingerprint index:", err)
+    }
     
     return db
+}
+
+// ensureDuplicateCardIndex impide en la base de datos que dos escrituras concurrentes
+// guarden el mismo PAN dos veces para un usuario, algo que la comprobación previa del
+// servicio no evita. Las tarjetas cerradas no cuentan; con la política allow se
+// permiten los duplicados y el índice se borra.
+// Cada versión de la clave de huellas da al mismo PAN una huella distinta, así que
+// el índice solo puede comparar huellas de la misma versión y la incluye. Tras
+// rotar la clave, una tarjeta con la huella antigua y otra con la nueva no chocan:
+// por eso el servicio no guarda PANs hasta que el recálculo retira las versiones
+// anteriores (ErrFingerprintReindexPending).
+func ensureDuplicateCardIndex(db *gorm.DB, policy service.DuplicatePolicy) error {
+    if policy == service.DuplicateAllow {
+        return db.Exec("DROP INDEX IF EXISTS idx_cards_user_fingerprint").Error
+    }
+    // Las sentencias DDL no admiten parámetros
+    return db.Exec(fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS idx_cards_user_fingerprint
+        ON cards (user_id, pan_fingerprint, fingerprint_version) WHERE status <> '%s'`, models.CardStatusClosed)).Error
+}
+
+// dropCVVColumn borra la columna cvv de versiones anteriores, y con ella los CVVs
+// guardados: ahora solo viven en el almacén temporal en memoria.
+func dropCVVColumn(db *gorm.DB) error {
+    if !db.Migrator().HasTable(&models.Card{}) || !db.Migrator().HasColumn(&models.Card{}, "cvv") {
+        return nil
+    }
+    log.Printf("Dropping persisted CVVs from cards table")
+    return db.Migrator().DropColumn(&models.Card{}, "cvv")
+}
+
+// migrateActiveFlag sustituye la columna is_active de versiones anteriores por el
+// estado: las tarjetas inactivas pasan a frozen, que el titular puede revertir.
+func migrateActiveFlag(db *gorm.DB) error {
+    if !db.Migrator().HasColumn(&models.Card{}, "is_active") {
+        return nil
+    }
+
+    return db.Transaction(func(tx *gorm.DB) error {
+        err := tx.Exec("UPDATE cards SET status = ?, status_reason = ? WHERE is_active = false",
+            models.CardStatusFrozen, "migrated from is_active").Error
+        if err != nil {
+            return err
+        }
+        return tx.Migrator().DropColumn(&models.Card{}, "is_active")
+    })
+}
+
+// migrateCardholderName aparta los titulares guardados en claro a la columna
+// legacy_cardholder_name; AutoMigrate crea después cardholder_name como bytea. El
+// servicio los cifra al volver a escribir cada tarjeta o en la siguiente rotación.
+func migrateCardholderName(db *gorm.DB) error {
+    if !db.Migrator().HasTable(&models.Card{}) || db.Migrator().HasColumn(&models.Card{}, "legacy_cardholder_name") {
+        return nil
+    }
+
+    columnTypes, err := db.Migrator().ColumnTypes(&models.Card{})
+    if err != nil {
+        return err
+    }
+    for _, column := range columnTypes {
+        if column.Name() == "cardholder_name" && strings.EqualFold(column.DatabaseTypeName(), "text") {
+            log.Printf("Moving plaintext cardholder names to legacy_cardholder_name")
+            return db.Migrator().RenameColumn(&models.Card{}, "cardholder_name", "legacy_cardholder_name")
+        }
+    }
+    return nil
+}
+
+// migrateCiphertextColumns pasa las columnas cifradas de base64 en texto a bytea.
+// Los valores antiguos quedan como nonce||ciphertext sin cabecera, que el servicio
+// sigue sabiendo leer. Las DEKs envueltas por transit ("vault:vN:...") se guardan tal cual.
+func migrateCiphertextColumns(db *gorm.DB) error {
+    if !db.Migrator().HasTable(&models.Card{}) {
+        return nil
+    }
+
+    columnTypes, err := db.Migrator().ColumnTypes(&models.Card{})
+    if err != nil {
+        return err
+    }
+
+    conversions := map[string]string{
+        "card_number": "decode(card_number, 'base64')",
+        "wrapped_dek": "CASE WHEN wrapped_dek LIKE 'vault:%' THEN convert_to(wrapped_dek, 'UTF8') ELSE decode(wrapped_dek, 'base64') END",
+    }
+
+    return db.Transaction(func(tx *gorm.DB) error {
+        for _, column := range columnTypes {
+            using, ok := conversions[column.Name()]
+            if !ok || !strings.EqualFold(column.DatabaseTypeName(), "text") {
+                continue
+            }
+
+            stmt := fmt.Sprintf("ALTER TABLE cards ALTER COLUMN %s TYPE bytea USING %s", column.Name(), using)
+            if err := tx.Exec(stmt).Error; err != nil {
+                return err
+            }
+        }
+        return nil
+    })
 }
\ No newline at end of file
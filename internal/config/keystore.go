@@ -0,0 +1,113 @@
+package config
+
+import (
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "strings"
+    "card-vault/internal/crypto"
+    "card-vault/internal/seal"
+)
+
+var ErrMasterKeyNotSet = errors.New("MASTER_KEY or MASTER_KEY_FILE must be set")
+
+// InitKeyManager crea el KeyManager sellado sobre el keyring de KEYSTORE_PATH. Si
+// hay MASTER_KEY o MASTER_KEY_FILE se abre directamente (auto-unseal); si no,
+// queda sellado hasta la ceremonia de apertura con partes de la master key.
+func InitKeyManager() *crypto.KeyManager {
+    path := os.Getenv("KEYSTORE_PATH")
+    if path == "" {
+        path = "data/keyring.json"
+    }
+
+    algorithm, err := LoadAlgorithm()
+    if err != nil {
+        log.Fatal("Failed to load encryption algorithm:", err)
+    }
+
+    keyManager, err := crypto.NewSealedKeyManager(func(masterKey []byte) (crypto.KeyStore, error) {
+        return crypto.NewFileKeyStore(path, masterKey)
+    }, algorithm)
+    if err != nil {
+        log.Fatal("Failed to initialize key manager:", err)
+    }
+
+    masterKey, err := LoadMasterKey()
+    if errors.Is(err, ErrMasterKeyNotSet) {
+        log.Printf("Starting sealed: submit key shares to /sys/unseal to open the keyring")
+        return keyManager
+    }
+    if err != nil {
+        log.Fatal("Failed to load master key:", err)
+    }
+
+    err = keyManager.Unseal(masterKey)
+    if errors.Is(err, crypto.ErrNotInitialized) {
+        err = keyManager.Initialize(masterKey)
+    }
+    if err != nil {
+        log.Fatal("Failed to unseal key manager:", err)
+    }
+
+    return keyManager
+}
+
+// InitSealManager crea el gestor de la ceremonia de apertura. UNSEAL_THRESHOLD
+// es el número de partes necesarias (3 por defecto).
+func InitSealManager(keyManager *crypto.KeyManager) *seal.Manager {
+    threshold := 3
+    if value := os.Getenv("UNSEAL_THRESHOLD"); value != "" {
+        n, err := strconv.Atoi(value)
+        if err != nil {
+            log.Fatal("Invalid UNSEAL_THRESHOLD:", err)
+        }
+        threshold = n
+    }
+
+    manager, err := seal.NewManager(keyManager, threshold)
+    if err != nil {
+        log.Fatal("Failed to initialize seal manager:", err)
+    }
+    return manager
+}
+
+// LoadAlgorithm lee de ENCRYPTION_ALGORITHM el algoritmo de las escrituras
+// nuevas (aes-256-gcm por defecto, aes-256-gcm-siv o xchacha20-poly1305).
+func LoadAlgorithm() (crypto.AlgorithmID, error) {
+    name := os.Getenv("ENCRYPTION_ALGORITHM")
+    if name == "" {
+        return crypto.DefaultAlgorithm, nil
+    }
+    return crypto.ParseAlgorithm(name)
+}
+
+// LoadMasterKey lee la master key (32 bytes en base64) de MASTER_KEY o del
+// fichero indicado en MASTER_KEY_FILE.
+func LoadMasterKey() ([]byte, error) {
+    encoded := os.Getenv("MASTER_KEY")
+    if file := os.Getenv("MASTER_KEY_FILE"); file != "" {
+        data, err := os.ReadFile(file)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read master key file: %w", err)
+        }
+        encoded = string(data)
+    }
+
+    encoded = strings.TrimSpace(encoded)
+    if encoded == "" {
+        return nil, ErrMasterKeyNotSet
+    }
+
+    key, err := base64.StdEncoding.DecodeString(encoded)
+    if err != nil {
+        return nil, fmt.Errorf("master key is not valid base64: %w", err)
+    }
+    if len(key) != 32 {
+        return nil, crypto.ErrInvalidMasterKey
+    }
+
+    return key, nil
+}
\ No newline at end of file
@@ -0,0 +1,34 @@
+package config
+
+import (
+    "log"
+    "os"
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+)
+
+// InitKMSProvider elige quién envuelve las DEKs según KMS_PROVIDER (local por defecto).
+func InitKMSProvider(keyManager *crypto.KeyManager) kms.Provider {
+    switch os.Getenv("KMS_PROVIDER") {
+    case "", kms.LocalProviderName:
+        return kms.NewLocalProvider(keyManager)
+    case kms.TransitProviderName:
+        keyName := os.Getenv("TRANSIT_KEY_NAME")
+        if keyName == "" {
+            keyName = "card-vault"
+        }
+
+        provider, err := kms.NewTransitProvider(kms.TransitConfig{
+            Address: os.Getenv("TRANSIT_ADDR"),
+            Token:   os.Getenv("TRANSIT_TOKEN"),
+            KeyName: keyName,
+        })
+        if err != nil {
+            log.Fatal("Failed to initialize transit KMS provider:", err)
+        }
+        return provider
+    default:
+        log.Fatalf("Unknown KMS_PROVIDER %q", os.Getenv("KMS_PROVIDER"))
+        return nil
+    }
+}
\ No newline at end of file
@@ -0,0 +1,80 @@
+package config
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+    "card-vault/internal/kms"
+    "card-vault/internal/scheduler"
+)
+
+// InitRotationScheduler crea el planificador de rotación automática. Sin ninguna
+// variable KEY_ROTATION_* configurada queda deshabilitado.
+func InitRotationScheduler(rotator scheduler.Rotator, provider kms.Provider) *scheduler.RotationScheduler {
+    policy, err := LoadRotationPolicy()
+    if err != nil {
+        log.Fatal("Failed to load key rotation policy:", err)
+    }
+
+    if policy.MaxEncryptions > 0 && provider.Name() != kms.LocalProviderName {
+        log.Printf("Warning: KEY_ROTATION_MAX_ENCRYPTIONS only counts encryptions made by this process with %s", provider.Name())
+    }
+
+    return scheduler.NewRotationScheduler(policy, rotator, provider)
+}
+
+// LoadRotationPolicy lee KEY_ROTATION_MAX_AGE (p.ej. 720h o 90d),
+// KEY_ROTATION_MAX_ENCRYPTIONS, KEY_ROTATION_CRON y KEY_ROTATION_CHECK_INTERVAL.
+func LoadRotationPolicy() (scheduler.Policy, error) {
+    var policy scheduler.Policy
+    var err error
+
+    if value := os.Getenv("KEY_ROTATION_MAX_AGE"); value != "" {
+        if policy.MaxAge, err = parseDuration(value); err != nil {
+            return policy, fmt.Errorf("invalid KEY_ROTATION_MAX_AGE: %w", err)
+        }
+    }
+
+    if value := os.Getenv("KEY_ROTATION_MAX_ENCRYPTIONS"); value != "" {
+        if policy.MaxEncryptions, err = strconv.ParseUint(value, 10, 64); err != nil {
+            return policy, fmt.Errorf("invalid KEY_ROTATION_MAX_ENCRYPTIONS: %w", err)
+        }
+    }
+
+    if value := os.Getenv("KEY_ROTATION_CRON"); value != "" {
+        if policy.Cron, err = scheduler.ParseCron(value); err != nil {
+            return policy, err
+        }
+    }
+
+    if value := os.Getenv("KEY_ROTATION_CHECK_INTERVAL"); value != "" {
+        if policy.CheckInterval, err = parseDuration(value); err != nil {
+            return policy, fmt.Errorf("invalid KEY_ROTATION_CHECK_INTERVAL: %w", err)
+        }
+    }
+
+    return policy, nil
+}
+
+// parseDuration acepta el formato de time.ParseDuration y además días ("90d").
+func parseDuration(value string) (time.Duration, error) {
+    if days, ok := strings.CutSuffix(value, "d"); ok {
+        n, err := strconv.Atoi(days)
+        if err != nil || n <= 0 {
+            return 0, fmt.Errorf("invalid duration %q", value)
+        }
+        return time.Duration(n) * 24 * time.Hour, nil
+    }
+
+    d, err := time.ParseDuration(value)
+    if err != nil {
+        return 0, err
+    }
+    if d <= 0 {
+        return 0, fmt.Errorf("invalid duration %q", value)
+    }
+    return d, nil
+}
\ No newline at end of file
@@ -0,0 +1,82 @@
+package crypto
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "errors"
+    "fmt"
+    "strings"
+
+    "golang.org/x/crypto/chacha20poly1305"
+)
+
+// AlgorithmID identifica el AEAD de un sobre. Es el byte que se escribe en la
+// cabecera, así que los valores asignados no pueden cambiar.
+type AlgorithmID byte
+
+const (
+    AlgAES256GCM         AlgorithmID = 1
+    AlgAES256GCMSIV      AlgorithmID = 2
+    AlgXChaCha20Poly1305 AlgorithmID = 3
+)
+
+// DefaultAlgorithm es el algoritmo de los ciphertexts legacy sin cabecera y de
+// los keyrings guardados antes de registrar el algoritmo por versión.
+const DefaultAlgorithm = AlgAES256GCM
+
+var ErrUnknownAlgorithm = errors.New("unknown encryption algorithm")
+
+// Algorithm describe un AEAD del registro. New recibe una clave de 32 bytes.
+type Algorithm struct {
+    ID   AlgorithmID
+    Name string
+    New  func(key []byte) (cipher.AEAD, error)
+}
+
+var algorithms = map[AlgorithmID]Algorithm{
+    AlgAES256GCM:         {ID: AlgAES256GCM, Name: "aes-256-gcm", New: newAESGCM},
+    AlgAES256GCMSIV:      {ID: AlgAES256GCMSIV, Name: "aes-256-gcm-siv", New: NewGCMSIV},
+    AlgXChaCha20Poly1305: {ID: AlgXChaCha20Poly1305, Name: "xchacha20-poly1305", New: chacha20poly1305.NewX},
+}
+
+func LookupAlgorithm(id AlgorithmID) (Algorithm, error) {
+    alg, ok := algorithms[id]
+    if !ok {
+        return Algorithm{}, fmt.Errorf("%w: %d", ErrUnknownAlgorithm, id)
+    }
+    return alg, nil
+}
+
+// ParseAlgorithm busca un algoritmo por nombre (p.ej. "xchacha20-poly1305").
+func ParseAlgorithm(name string) (AlgorithmID, error) {
+    name = strings.ToLower(strings.TrimSpace(name))
+    for id, alg := range algorithms {
+        if alg.Name == name {
+            return id, nil
+        }
+    }
+    return 0, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, name)
+}
+
+func (id AlgorithmID) String() string {
+    if alg, ok := algorithms[id]; ok {
+        return alg.Name
+    }
+    return fmt.Sprintf("unknown(%d)", byte(id))
+}
+
+func newAEAD(id AlgorithmID, key []byte) (cipher.AEAD, error) {
+    alg, err := LookupAlgorithm(id)
+    if err != nil {
+        return nil, err
+    }
+    return alg.New(key)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
\ No newline at end of file
@@ -0,0 +1,86 @@
+package crypto
+
+import (
+    "fmt"
+)
+
+var dataKeyAssociatedData = []byte("card-vault/dek")
+
+// GenerateDataKey crea una clave de datos (DEK) y la devuelve junto con su
+// versión envuelta por la clave activa.
+func (km *KeyManager) GenerateDataKey() ([]byte, []byte, int, error) {
+    dek, err := generateKey()
+    if err != nil {
+        return nil, nil, 0, err
+    }
+
+    wrapped, version, err := km.WrapDataKey(dek)
+    if err != nil {
+        return nil, nil, 0, err
+    }
+
+    return dek, wrapped, version, nil
+}
+
+// WrapDataKey envuelve una DEK con la clave activa, usando el algoritmo de esa
+// versión, en un sobre que registra la versión usada. Clave y versión se leen
+// bajo el mismo lock, por lo que el par siempre es coherente, y la clave se copia
+// para que un Seal concurrente no la ponga a cero mientras se usa.
+func (km *KeyManager) WrapDataKey(dek []byte) ([]byte, int, error) {
+    km.mu.RLock()
+    active, version, sealed := km.keys[km.keyVersion], km.keyVersion, km.store == nil
+    active.Key = cloneKey(active.Key)
+    km.mu.RUnlock()
+
+    if sealed {
+        return nil, 0, ErrSealed
+    }
+
+    encSvc, err := NewEncryptionServiceWithAlgorithm(active.Algorithm, active.Key)
+    if err != nil {
+        return nil, 0, err
+    }
+
+    wrapped, err := encSvc.Seal(dek, version, dataKeyAssociatedData)
+    if err != nil {
+        return nil, 0, fmt.Errorf("failed to wrap data key: %w", err)
+    }
+
+    km.recordEncryption(version)
+    return wrapped, version, nil
+}
+
+// UnwrapDataKey recupera una DEK envuelta. La versión del keyring se toma de la
+// cabecera del sobre; version solo se usa para DEKs envueltas sin cabecera.
+func (km *KeyManager) UnwrapDataKey(wrapped []byte, version int) ([]byte, error) {
+    if header, ok := ParseEnvelopeHeader(wrapped); ok {
+        dek, err := km.unwrapDataKey(wrapped, int(header.KeyVersion))
+        if err == nil || int(header.KeyVersion) == version {
+            return dek, err
+        }
+    }
+    return km.unwrapDataKey(wrapped, version)
+}
+
+func (km *KeyManager) unwrapDataKey(wrapped []byte, version int) ([]byte, error) {
+    kek, err := km.GetKey(version)
+    if err != nil {
+        return nil, err
+    }
+
+    encSvc, err := NewEncryptionService(kek)
+    if err != nil {
+        return nil, err
+    }
+    return encSvc.Open(wrapped, dataKeyAssociatedData)
+}
+
+// RewrapDataKey vuelve a envolver una DEK con la clave activa sin tocar los
+// datos que protege.
+func (km *KeyManager) RewrapDataKey(wrapped []byte, version int) ([]byte, int, error) {
+    dek, err := km.UnwrapDataKey(wrapped, version)
+    if err != nil {
+        return nil, 0, err
+    }
+    return km.WrapDataKey(dek)
+}
\ No newline at end of file
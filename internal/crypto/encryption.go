@@ -1,7 +1,6 @@
 package crypto
 
 import (
-    "crypto/aes"
     "crypto/cipher"
     "crypto/rand"
     "encoding/base64"
@@ -10,49 +9,135 @@ import (
 )
 
 type EncryptionService struct {
-    gcm cipher.AEAD
+    key       []byte
+    aead      cipher.AEAD
+    algorithm AlgorithmID
 }
 
 func NewEncryptionService(key []byte) (*EncryptionService, error) {
-    block, err := aes.NewCipher(key)
-    if err != nil {
-        return nil, err
-    }
-    
-    gcm, err := cipher.NewGCM(block)
+    return NewEncryptionServiceWithAlgorithm(DefaultAlgorithm, key)
+}
+
+// NewEncryptionServiceWithAlgorithm cifra con el algoritmo indicado. Al descifrar
+// se usa siempre el algoritmo que figura en la cabecera del sobre.
+func NewEncryptionServiceWithAlgorithm(algorithm AlgorithmID, key []byte) (*EncryptionService, error) {
+    aead, err := newAEAD(algorithm, key)
     if err != nil {
         return nil, err
     }
     
-    return &EncryptionService{gcm: gcm}, nil
+    return &EncryptionService{key: key, aead: aead, algorithm: algorithm}, nil
+}
+
+func (e *EncryptionService) Algorithm() AlgorithmID {
+    return e.algorithm
 }
 
 func (e *EncryptionService) Encrypt(plaintext string) (string, error) {
-    nonce := make([]byte, e.gcm.NonceSize())
-    if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+    return e.EncryptWithAD(plaintext, nil)
+}
+
+// EncryptWithAD cifra ligando el resultado a additionalData: Decrypt solo tiene
+// éxito si recibe exactamente los mismos datos asociados.
+func (e *EncryptionService) EncryptWithAD(plaintext string, additionalData []byte) (string, error) {
+    ciphertext, err := e.seal(e.aead, nil, []byte(plaintext), additionalData)
+    if err != nil {
         return "", err
     }
-    
-    ciphertext := e.gcm.Seal(nonce, nonce, []byte(plaintext), nil)
     return base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
 func (e *EncryptionService) Decrypt(ciphertext string) (string, error) {
+    return e.DecryptWithAD(ciphertext, nil)
+}
+
+func (e *EncryptionService) DecryptWithAD(ciphertext string, additionalData []byte) (string, error) {
     data, err := base64.StdEncoding.DecodeString(ciphertext)
     if err != nil {
         return "", err
     }
     
-    nonceSize := e.gcm.NonceSize()
-    if len(data) < nonceSize {
-        return "", errors.New("ciphertext too short")
-    }
-    
-    nonce, ciphertextBytes := data[:nonceSize], data[nonceSize:]
-    plaintext, err := e.gcm.Open(nil, nonce, ciphertextBytes, nil)
+    plaintext, err := e.open(e.aead, data, additionalData)
     if err != nil {
         return "", err
     }
     
     return string(plaintext), nil
+}
+
+// Seal cifra plaintext y devuelve un sobre binario autodescriptivo con el
+// algoritmo y la versión de clave en la cabecera.
+func (e *EncryptionService) Seal(plaintext []byte, keyVersion int, additionalData []byte) ([]byte, error) {
+    header := EnvelopeHeader{
+        Format:     EnvelopeFormatV1,
+        Algorithm:  e.algorithm,
+        KeyVersion: uint32(keyVersion),
+    }.marshal()
+
+    return e.seal(e.aead, header, plaintext, envelopeAssociatedData(header, additionalData))
+}
+
+// Open descifra un sobre generado por Seal con el algoritmo de su cabecera, de
+// modo que los datos existentes siguen abriéndose aunque cambie el configurado.
+// Los valores sin cabecera se tratan como ciphertext legacy (nonce||ciphertext)
+// cifrado con AES-256-GCM y la misma clave.
+func (e *EncryptionService) Open(data []byte, additionalData []byte) ([]byte, error) {
+    header, ok := ParseEnvelopeHeader(data)
+    if !ok {
+        return e.openLegacy(data, additionalData)
+    }
+
+    if header.Format != EnvelopeFormatV1 {
+        return nil, ErrEnvelopeFormat
+    }
+
+    aead, err := e.aeadFor(header.Algorithm)
+    if err != nil {
+        return nil, err
+    }
+
+    plaintext, err := e.open(aead, data[envelopeHeaderSize:], envelopeAssociatedData(data[:envelopeHeaderSize], additionalData))
+    if err != nil {
+        // Un valor legacy puede empezar por los bytes mágicos por azar
+        if legacy, legacyErr := e.openLegacy(data, additionalData); legacyErr == nil {
+            return legacy, nil
+        }
+        return nil, err
+    }
+    return plaintext, nil
+}
+
+func (e *EncryptionService) aeadFor(algorithm AlgorithmID) (cipher.AEAD, error) {
+    if algorithm == e.algorithm {
+        return e.aead, nil
+    }
+    return newAEAD(algorithm, e.key)
+}
+
+func (e *EncryptionService) openLegacy(data, additionalData []byte) ([]byte, error) {
+    aead, err := e.aeadFor(DefaultAlgorithm)
+    if err != nil {
+        return nil, err
+    }
+    return e.open(aead, data, additionalData)
+}
+
+func (e *EncryptionService) seal(aead cipher.AEAD, prefix, plaintext, additionalData []byte) ([]byte, error) {
+    nonce := make([]byte, aead.NonceSize())
+    if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+        return nil, err
+    }
+
+    out := append(prefix, nonce...)
+    return aead.Seal(out, nonce, plaintext, additionalData), nil
+}
+
+func (e *EncryptionService) open(aead cipher.AEAD, data, additionalData []byte) ([]byte, error) {
+    nonceSize := aead.NonceSize()
+    if len(data) < nonceSize {
+        return nil, errors.New("ciphertext too short")
+    }
+    
+    nonce, ciphertextBytes := data[:nonceSize], data[nonceSize:]
+    return aead.Open(nil, nonce, ciphertextBytes, additionalData)
 }
\ No newline at end of file
@@ -0,0 +1,61 @@
+package crypto
+
+import (
+    "encoding/binary"
+    "errors"
+)
+
+// Formato del sobre binario:
+//
+//   magic "CV" | formato (1 byte) | algoritmo (1 byte) | versión de clave (uint32 BE) | nonce | ciphertext+tag
+//
+// La cabecera va autenticada como parte de los datos asociados. KeyVersion es la
+// versión del keyring que cifró el contenido; 0 indica que lo cifró la DEK del
+// propio registro.
+const (
+    EnvelopeFormatV1   byte = 1
+    envelopeHeaderSize      = 8
+)
+
+var envelopeMagic = [2]byte{'C', 'V'}
+
+// RecordKeyVersion marca los sobres cifrados con la DEK del registro en lugar
+// de con una versión del keyring.
+const RecordKeyVersion = 0
+
+var ErrEnvelopeFormat = errors.New("unsupported envelope format")
+
+type EnvelopeHeader struct {
+    Format     byte
+    Algorithm  AlgorithmID
+    KeyVersion uint32
+}
+
+func (h EnvelopeHeader) marshal() []byte {
+    buf := make([]byte, envelopeHeaderSize)
+    buf[0], buf[1] = envelopeMagic[0], envelopeMagic[1]
+    buf[2] = h.Format
+    buf[3] = byte(h.Algorithm)
+    binary.BigEndian.PutUint32(buf[4:], h.KeyVersion)
+    return buf
+}
+
+// ParseEnvelopeHeader lee la cabecera de un sobre. ok es false si el valor no
+// tiene cabecera (ciphertext legacy nonce||ciphertext).
+func ParseEnvelopeHeader(data []byte) (EnvelopeHeader, bool) {
+    if len(data) < envelopeHeaderSize || data[0] != envelopeMagic[0] || data[1] != envelopeMagic[1] {
+        return EnvelopeHeader{}, false
+    }
+
+    return EnvelopeHeader{
+        Format:     data[2],
+        Algorithm:  AlgorithmID(data[3]),
+        KeyVersion: binary.BigEndian.Uint32(data[4:envelopeHeaderSize]),
+    }, true
+}
+
+func envelopeAssociatedData(header, additionalData []byte) []byte {
+    ad := make([]byte, 0, len(header)+len(additionalData))
+    ad = append(ad, header...)
+    return append(ad, additionalData...)
+}
\ No newline at end of file
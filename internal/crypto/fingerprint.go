@@ -0,0 +1,75 @@
+package crypto
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "sort"
+)
+
+// Fingerprint es la huella HMAC de un dato calculada con una versión de la clave.
+type Fingerprint struct {
+    Version int
+    Value   []byte
+}
+
+// Fingerprint calcula la huella de data con la versión activa de la clave de
+// huellas, creando la primera versión si el keyring aún no tiene ninguna.
+func (km *KeyManager) Fingerprint(data []byte) ([]byte, int, error) {
+    key, version, err := km.activePurposeKey(KeyPurposeFingerprint)
+    if err != nil {
+        return nil, 0, err
+    }
+    return fingerprint(key, data), version, nil
+}
+
+// Fingerprints calcula la huella de data con todas las versiones no destruidas,
+// para encontrar también las filas que aún conservan huellas de versiones antiguas.
+func (km *KeyManager) Fingerprints(data []byte) ([]Fingerprint, error) {
+    if err := km.ensurePurposeKey(KeyPurposeFingerprint); err != nil {
+        return nil, err
+    }
+
+    km.mu.RLock()
+    defer km.mu.RUnlock()
+
+    out := make([]Fingerprint, 0, len(km.purposeKeys[KeyPurposeFingerprint]))
+    for _, k := range km.purposeKeys[KeyPurposeFingerprint] {
+        if k.State == KeyStateDestroyed {
+            continue
+        }
+        out = append(out, Fingerprint{Version: k.Version, Value: fingerprint(k.Key, data)})
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+    return out, nil
+}
+
+// ActiveFingerprintVersion devuelve la versión activa de la clave de huellas,
+// creándola si aún no existe.
+func (km *KeyManager) ActiveFingerprintVersion() (int, error) {
+    _, version, err := km.activePurposeKey(KeyPurposeFingerprint)
+    return version, err
+}
+
+// FingerprintKeys lista las versiones de la clave de huellas sin exponer su material.
+func (km *KeyManager) FingerprintKeys() []KeyInfo {
+    return km.purposeKeyInfos(KeyPurposeFingerprint, "hmac-sha256")
+}
+
+// RotateFingerprintKey crea una versión activa nueva de la clave de huellas. La
+// anterior pasa a decrypt_only: ya no calcula huellas nuevas pero sigue sirviendo
+// para buscar hasta que se recalculen las existentes.
+func (km *KeyManager) RotateFingerprintKey() error {
+    return km.rotatePurposeKey(KeyPurposeFingerprint)
+}
+
+// DestroyFingerprintKey borra una versión retirada de la clave de huellas. Las
+// huellas calculadas con ella dejan de encontrarse.
+func (km *KeyManager) DestroyFingerprintKey(version int) error {
+    return km.destroyPurposeKey(KeyPurposeFingerprint, version)
+}
+
+func fingerprint(key, data []byte) []byte {
+    mac := hmac.New(sha256.New, key)
+    mac.Write(data)
+    return mac.Sum(nil)
+}
\ No newline at end of file
@@ -0,0 +1,231 @@
+package crypto
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/subtle"
+    "encoding/binary"
+    "errors"
+)
+
+// AES-256-GCM-SIV (RFC 8452). Resistente al mal uso del nonce: repetir un nonce
+// solo revela si dos mensajes son idénticos, en lugar de romper el cifrado.
+const (
+    gcmSIVNonceSize = 12
+    gcmSIVTagSize   = 16
+    gcmSIVMaxInput  = 1 << 36
+)
+
+var errGCMSIVOpen = errors.New("cipher: message authentication failed")
+
+type gcmSIV struct {
+    block cipher.Block
+}
+
+// NewGCMSIV devuelve un AEAD AES-256-GCM-SIV para una clave de 32 bytes.
+func NewGCMSIV(key []byte) (cipher.AEAD, error) {
+    if len(key) != 32 {
+        return nil, errors.New("gcm-siv: key must be 32 bytes")
+    }
+
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return &gcmSIV{block: block}, nil
+}
+
+func (g *gcmSIV) NonceSize() int { return gcmSIVNonceSize }
+
+func (g *gcmSIV) Overhead() int { return gcmSIVTagSize }
+
+func (g *gcmSIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+    if len(nonce) != gcmSIVNonceSize {
+        panic("gcm-siv: incorrect nonce length")
+    }
+    if uint64(len(plaintext)) > gcmSIVMaxInput || uint64(len(additionalData)) > gcmSIVMaxInput {
+        panic("gcm-siv: message too large")
+    }
+
+    authKey, encBlock := g.deriveKeys(nonce)
+    tag := g.tag(authKey, encBlock, nonce, plaintext, additionalData)
+
+    ret, out := sliceForAppend(dst, len(plaintext)+gcmSIVTagSize)
+    gcmSIVCTR(encBlock, tag, out[:len(plaintext)], plaintext)
+    copy(out[len(plaintext):], tag[:])
+    return ret
+}
+
+func (g *gcmSIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+    if len(nonce) != gcmSIVNonceSize {
+        panic("gcm-siv: incorrect nonce length")
+    }
+    if len(ciphertext) < gcmSIVTagSize || uint64(len(ciphertext)) > gcmSIVMaxInput+gcmSIVTagSize {
+        return nil, errGCMSIVOpen
+    }
+
+    var tag [16]byte
+    copy(tag[:], ciphertext[len(ciphertext)-gcmSIVTagSize:])
+    ciphertext = ciphertext[:len(ciphertext)-gcmSIVTagSize]
+
+    authKey, encBlock := g.deriveKeys(nonce)
+
+    ret, out := sliceForAppend(dst, len(ciphertext))
+    gcmSIVCTR(encBlock, tag, out, ciphertext)
+
+    expected := g.tag(authKey, encBlock, nonce, out, additionalData)
+    if subtle.ConstantTimeCompare(expected[:], tag[:]) != 1 {
+        for i := range out {
+            out[i] = 0
+        }
+        return nil, errGCMSIVOpen
+    }
+    return ret, nil
+}
+
+// deriveKeys obtiene las claves de autenticación y cifrado propias del nonce
+// (sección 4 del RFC).
+func (g *gcmSIV) deriveKeys(nonce []byte) ([16]byte, cipher.Block) {
+    var input, output [16]byte
+    copy(input[4:], nonce)
+
+    var derived [48]byte
+    for i := 0; i < 6; i++ {
+        binary.LittleEndian.PutUint32(input[:4], uint32(i))
+        g.block.Encrypt(output[:], input[:])
+        copy(derived[i*8:], output[:8])
+    }
+
+    var authKey [16]byte
+    copy(authKey[:], derived[:16])
+
+    // La clave de cifrado es de 32 bytes, así que aes.NewCipher no puede fallar
+    encBlock, _ := aes.NewCipher(derived[16:48])
+    return authKey, encBlock
+}
+
+func (g *gcmSIV) tag(authKey [16]byte, encBlock cipher.Block, nonce, plaintext, additionalData []byte) [16]byte {
+    p := newPolyval(authKey)
+    p.update(additionalData)
+    p.update(plaintext)
+
+    var lengths [16]byte
+    binary.LittleEndian.PutUint64(lengths[:8], uint64(len(additionalData))*8)
+    binary.LittleEndian.PutUint64(lengths[8:], uint64(len(plaintext))*8)
+    p.update(lengths[:])
+
+    s := p.sum()
+    for i := 0; i < gcmSIVNonceSize; i++ {
+        s[i] ^= nonce[i]
+    }
+    s[15] &= 0x7f
+
+    var tag [16]byte
+    encBlock.Encrypt(tag[:], s[:])
+    return tag
+}
+
+// gcmSIVCTR cifra en modo contador partiendo del tag con el bit alto a 1; el
+// contador son los 32 bits bajos en little-endian.
+func gcmSIVCTR(block cipher.Block, tag [16]byte, dst, src []byte) {
+    counter := tag
+    counter[15] |= 0x80
+
+    var keystream [16]byte
+    for len(src) > 0 {
+        block.Encrypt(keystream[:], counter[:])
+        n := subtle.XORBytes(dst, src, keystream[:])
+        dst, src = dst[n:], src[n:]
+
+        binary.LittleEndian.PutUint32(counter[:4], binary.LittleEndian.Uint32(counter[:4])+1)
+    }
+}
+
+// polyval implementa POLYVAL sobre GF(2^128) con el polinomio
+// x^128 + x^127 + x^126 + x^121 + 1. Los elementos son enteros de 128 bits en
+// little-endian; la multiplicación es de tiempo constante respecto a la clave.
+type polyval struct {
+    h   fieldElement
+    acc fieldElement
+}
+
+type fieldElement struct {
+    lo, hi uint64
+}
+
+// xInv128 es x^-128 en el cuerpo: dot(a, b) = a * b * x^-128.
+var xInv128 = func() fieldElement {
+    // x^-1 = x^127 + x^126 + x^125 + x^120, porque x * x^-1 = 1 módulo el polinomio
+    e := fieldElement{hi: 1<<63 | 1<<62 | 1<<61 | 1<<56}
+    for i := 0; i < 7; i++ {
+        e = fieldMul(e, e)
+    }
+    return e
+}()
+
+func newPolyval(key [16]byte) *polyval {
+    return &polyval{h: loadFieldElement(key[:])}
+}
+
+// update procesa data rellenando con ceros hasta un múltiplo de 16 bytes.
+func (p *polyval) update(data []byte) {
+    var block [16]byte
+    for len(data) > 0 {
+        n := copy(block[:], data)
+        for i := n; i < 16; i++ {
+            block[i] = 0
+        }
+        data = data[n:]
+
+        x := loadFieldElement(block[:])
+        p.acc = fieldMul(fieldMul(fieldElement{p.acc.lo ^ x.lo, p.acc.hi ^ x.hi}, p.h), xInv128)
+    }
+}
+
+func (p *polyval) sum() [16]byte {
+    var out [16]byte
+    binary.LittleEndian.PutUint64(out[:8], p.acc.lo)
+    binary.LittleEndian.PutUint64(out[8:], p.acc.hi)
+    return out
+}
+
+func loadFieldElement(b []byte) fieldElement {
+    return fieldElement{lo: binary.LittleEndian.Uint64(b[:8]), hi: binary.LittleEndian.Uint64(b[8:16])}
+}
+
+// fieldMul multiplica a*b módulo el polinomio de POLYVAL.
+func fieldMul(a, b fieldElement) fieldElement {
+    var r fieldElement
+    for i := 127; i >= 0; i-- {
+        // r = r * x
+        carry := r.hi >> 63
+        r.hi = r.hi<<1 | r.lo>>63
+        r.lo <<= 1
+        reduce := -carry
+        r.hi ^= reduce & (1<<63 | 1<<62 | 1<<57)
+        r.lo ^= reduce & 1
+
+        var bit uint64
+        if i >= 64 {
+            bit = b.hi >> uint(i-64) & 1
+        } else {
+            bit = b.lo >> uint(i) & 1
+        }
+        mask := -bit
+        r.lo ^= a.lo & mask
+        r.hi ^= a.hi & mask
+    }
+    return r
+}
+
+func sliceForAppend(in []byte, n int) ([]byte, []byte) {
+    total := len(in) + n
+    var head []byte
+    if cap(in) >= total {
+        head = in[:total]
+    } else {
+        head = make([]byte, total)
+        copy(head, in)
+    }
+    return head, head[len(in):]
+}
\ No newline at end of file
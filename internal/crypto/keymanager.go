@@ -2,54 +2,384 @@ package crypto
 
 import (
     "crypto/rand"
+    "errors"
+    "fmt"
+    "sort"
     "sync"
     "time"
 )
 
+var (
+    ErrKeyNotFound          = errors.New("key version not found")
+    ErrKeyDisabled          = errors.New("key version is disabled")
+    ErrKeyDestroyed         = errors.New("key version has been destroyed")
+    ErrInvalidKeyTransition = errors.New("invalid key state transition")
+    ErrSealed               = errors.New("keyring is sealed")
+)
+
+// KeyState es el estado de una versión del keyring. Solo la versión activa
+// cifra; las demás versiones no destruidas ni deshabilitadas descifran.
+type KeyState string
+
+const (
+    KeyStateActive      KeyState = "active"
+    KeyStateDecryptOnly KeyState = "decrypt_only"
+    KeyStateDisabled    KeyState = "disabled"
+    KeyStateDestroyed   KeyState = "destroyed"
+)
+
+// KeyInfo describe una versión del keyring sin exponer el material de clave.
+type KeyInfo struct {
+    Version     int       `json:"version"`
+    State       KeyState  `json:"state"`
+    Algorithm   string    `json:"algorithm"`
+    Encryptions uint64    `json:"encryptions"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
 type KeyManager struct {
-    currentKey    []byte
-    previousKey   []byte
+    store         KeyStore
+    newStore      func(masterKey []byte) (KeyStore, error)
+    keys          map[int]StoredKey
+    userKeys      map[string]StoredKey
     keyVersion    int
     rotationTime  time.Time
+    algorithm     AlgorithmID
     mu           sync.RWMutex
+
+    // Claves de otros usos (huellas, tokenización), cada uso con sus versiones
+    purposeKeys    map[KeyPurpose]map[int]StoredKey
+    purposeVersion map[KeyPurpose]int
+
+    // Cifrados hechos por este proceso aún no persistidos, por versión
+    pendingUsage  map[int]uint64
+    usageMu       sync.Mutex
 }
 
-func NewKeyManager() *KeyManager {
-    key := make([]byte, 32)
-    rand.Read(key)
-    
-    return &KeyManager{
-        currentKey:   key,
-        keyVersion:   1,
-        rotationTime: time.Now(),
+func NewKeyManager(store KeyStore) (*KeyManager, error) {
+    return NewKeyManagerWithAlgorithm(store, DefaultAlgorithm)
+}
+
+// NewKeyManagerWithAlgorithm crea el KeyManager indicando el algoritmo de las
+// versiones nuevas y de los datos cifrados a partir de ahora. Las versiones
+// existentes conservan el algoritmo con el que se crearon.
+func NewKeyManagerWithAlgorithm(store KeyStore, algorithm AlgorithmID) (*KeyManager, error) {
+    if _, err := LookupAlgorithm(algorithm); err != nil {
+        return nil, err
     }
+
+    km := &KeyManager{store: store, algorithm: algorithm, pendingUsage: make(map[int]uint64)}
+
+    keys, err := store.Load()
+    if err != nil {
+        return nil, fmt.Errorf("failed to load keyring: %w", err)
+    }
+
+    if len(keys) == 0 {
+        if keys, err = km.initialKeyring(store); err != nil {
+            return nil, err
+        }
+    }
+
+    km.apply(keys)
+    return km, nil
+}
+
+// initialKeyring crea y guarda la versión 1 de un keyring vacío.
+func (km *KeyManager) initialKeyring(store KeyStore) ([]StoredKey, error) {
+    key, err := generateKey()
+    if err != nil {
+        return nil, err
+    }
+
+    keys := []StoredKey{{Version: 1, Key: key, State: KeyStateActive, Algorithm: km.algorithm, CreatedAt: time.Now()}}
+    if err := store.Save(keys); err != nil {
+        return nil, fmt.Errorf("failed to save keyring: %w", err)
+    }
+    return keys, nil
 }
 
+// GetCurrentKey devuelve una copia de la versión activa, la única que debe usarse
+// para cifrar.
 func (km *KeyManager) GetCurrentKey() ([]byte, int) {
     km.mu.RLock()
     defer km.mu.RUnlock()
-    return km.currentKey, km.keyVersion
+    return cloneKey(km.keys[km.keyVersion].Key), km.keyVersion
+}
+
+// GetKey devuelve una copia de la clave de una versión para descifrar. Si no se
+// conoce, recarga el keyring por si otra réplica la ha rotado.
+func (km *KeyManager) GetKey(version int) ([]byte, error) {
+    km.mu.RLock()
+    key, ok := km.keys[version]
+    km.mu.RUnlock()
+
+    if !ok {
+        if err := km.Reload(); err != nil {
+            return nil, err
+        }
+
+        km.mu.RLock()
+        key, ok = km.keys[version]
+        km.mu.RUnlock()
+        if !ok {
+            return nil, ErrKeyNotFound
+        }
+    }
+
+    switch key.State {
+    case KeyStateDisabled:
+        return nil, ErrKeyDisabled
+    case KeyStateDestroyed:
+        return nil, ErrKeyDestroyed
+    }
+    return cloneKey(key.Key), nil
+}
+
+// cloneKey copia el material de clave que sale del KeyManager. Seal pone a cero las
+// claves en memoria, y quien aún use la copia no debe acabar cifrando con ceros.
+func cloneKey(key []byte) []byte {
+    return append([]byte(nil), key...)
+}
+
+// Algorithm devuelve el algoritmo configurado para las escrituras nuevas.
+func (km *KeyManager) Algorithm() AlgorithmID {
+    return km.algorithm
+}
+
+func (km *KeyManager) ListKeys() []KeyInfo {
+    km.mu.RLock()
+    defer km.mu.RUnlock()
+
+    infos := make([]KeyInfo, 0, len(km.keys))
+    for _, k := range km.keys {
+        infos = append(infos, km.keyInfo(k))
+    }
+    sort.Slice(infos, func(i, j int) bool { return infos[i].Version < infos[j].Version })
+    return infos
+}
+
+// ActiveKeyUsage persiste los contadores de uso pendientes y devuelve la versión
+// activa con su antigüedad y el total de cifrados de todas las réplicas.
+func (km *KeyManager) ActiveKeyUsage() (KeyInfo, error) {
+    if err := km.FlushUsage(); err != nil {
+        return KeyInfo{}, err
+    }
+
+    km.mu.RLock()
+    defer km.mu.RUnlock()
+    if km.store == nil {
+        return KeyInfo{}, ErrSealed
+    }
+    return km.keyInfo(km.keys[km.keyVersion]), nil
 }
 
+// FlushUsage suma al keyring persistido los cifrados contados en memoria. Cada
+// réplica aporta solo su delta, así el total es la suma de todas.
+func (km *KeyManager) FlushUsage() error {
+    km.usageMu.Lock()
+    pending := km.pendingUsage
+    km.pendingUsage = make(map[int]uint64)
+    km.usageMu.Unlock()
+
+    if len(pending) == 0 {
+        return nil
+    }
+
+    err := km.update(func(keys []StoredKey) ([]StoredKey, error) {
+        for i := range keys {
+            keys[i].Encryptions += pending[keys[i].Version]
+        }
+        return keys, nil
+    })
+    if err != nil {
+        // Se conservan para el siguiente intento
+        km.usageMu.Lock()
+        for version, count := range pending {
+            km.pendingUsage[version] += count
+        }
+        km.usageMu.Unlock()
+    }
+    return err
+}
+
+func (km *KeyManager) recordEncryption(version int) {
+    km.usageMu.Lock()
+    km.pendingUsage[version]++
+    km.usageMu.Unlock()
+}
+
+// keyInfo debe llamarse con km.mu tomado.
+func (km *KeyManager) keyInfo(k StoredKey) KeyInfo {
+    km.usageMu.Lock()
+    encryptions := k.Encryptions + km.pendingUsage[k.Version]
+    km.usageMu.Unlock()
+
+    return KeyInfo{
+        Version:     k.Version,
+        State:       k.State,
+        Algorithm:   k.Algorithm.String(),
+        Encryptions: encryptions,
+        CreatedAt:   k.CreatedAt,
+    }
+}
+
+func (km *KeyManager) Reload() error {
+    km.mu.RLock()
+    store := km.store
+    km.mu.RUnlock()
+    if store == nil {
+        return ErrSealed
+    }
+
+    keys, err := store.Load()
+    if err != nil {
+        return fmt.Errorf("failed to load keyring: %w", err)
+    }
+
+    km.mu.Lock()
+    defer km.mu.Unlock()
+    km.apply(keys)
+    return nil
+}
+
+// RotateKey crea una nueva versión activa con el algoritmo configurado y deja la
+// anterior en decrypt_only.
 func (km *KeyManager) RotateKey() error {
+    return km.update(func(keys []StoredKey) ([]StoredKey, error) {
+        newKey, err := generateKey()
+        if err != nil {
+            return nil, err
+        }
+
+        latest := 0
+        for i := range keys {
+            if !keys[i].isEncryptionKey() {
+                continue
+            }
+            if keys[i].State == KeyStateActive {
+                keys[i].State = KeyStateDecryptOnly
+            }
+            if keys[i].Version > latest {
+                latest = keys[i].Version
+            }
+        }
+
+        return append(keys, StoredKey{
+            Version:   latest + 1,
+            Key:       newKey,
+            State:     KeyStateActive,
+            Algorithm: km.algorithm,
+            CreatedAt: time.Now(),
+        }), nil
+    })
+}
+
+// SetKeyState cambia el estado de una versión no activa. La versión activa solo
+// se retira rotando, y una versión destruida no puede recuperarse.
+func (km *KeyManager) SetKeyState(version int, state KeyState) error {
+    return km.update(func(keys []StoredKey) ([]StoredKey, error) {
+        for i := range keys {
+            if !keys[i].isEncryptionKey() || keys[i].Version != version {
+                continue
+            }
+
+            if !validKeyTransition(keys[i].State, state) {
+                return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidKeyTransition, keys[i].State, state)
+            }
+
+            keys[i].State = state
+            if state == KeyStateDestroyed {
+                for j := range keys[i].Key {
+                    keys[i].Key[j] = 0
+                }
+                keys[i].Key = nil
+            }
+            return keys, nil
+        }
+        return nil, ErrKeyNotFound
+    })
+}
+
+func validKeyTransition(from, to KeyState) bool {
+    switch from {
+    case KeyStateDecryptOnly:
+        return to == KeyStateDisabled || to == KeyStateDestroyed
+    case KeyStateDisabled:
+        return to == KeyStateDecryptOnly || to == KeyStateDestroyed
+    }
+    return false
+}
+
+// update aplica fn sobre el estado persistido (no sobre la copia en memoria)
+// para no pisar cambios hechos por otras réplicas.
+func (km *KeyManager) update(fn func(keys []StoredKey) ([]StoredKey, error)) error {
     km.mu.Lock()
     defer km.mu.Unlock()
-    
-    newKey := make([]byte, 32)
-    if _, err := rand.Read(newKey); err != nil {
+
+    if km.store == nil {
+        return ErrSealed
+    }
+
+    keys, err := km.store.Load()
+    if err != nil {
+        return fmt.Errorf("failed to load keyring: %w", err)
+    }
+
+    keys, err = fn(keys)
+    if err != nil {
         return err
     }
-    
-    km.previousKey = km.currentKey
-    km.currentKey = newKey
-    km.keyVersion++
-    km.rotationTime = time.Now()
-    
+
+    if err := km.store.Save(keys); err != nil {
+        return fmt.Errorf("failed to save keyring: %w", err)
+    }
+
+    km.apply(keys)
     return nil
 }
 
-func (km *KeyManager) GetPreviousKey() []byte {
-    km.mu.RLock()
-    defer km.mu.RUnlock()
-    return km.previousKey
+// apply reconstruye el estado en memoria a partir del keyring persistido.
+// Debe llamarse con km.mu tomado (o antes de publicar el KeyManager).
+func (km *KeyManager) apply(keys []StoredKey) {
+    km.keys = make(map[int]StoredKey, len(keys))
+    km.userKeys = make(map[string]StoredKey)
+    km.purposeKeys = make(map[KeyPurpose]map[int]StoredKey)
+    km.purposeVersion = make(map[KeyPurpose]int)
+    km.keyVersion = 0
+
+    for _, k := range keys {
+        switch {
+        case k.Owner != "":
+            km.userKeys[k.Owner] = k
+            continue
+        case k.Purpose != "":
+            if km.purposeKeys[k.Purpose] == nil {
+                km.purposeKeys[k.Purpose] = make(map[int]StoredKey)
+            }
+            km.purposeKeys[k.Purpose][k.Version] = k
+            if k.State == KeyStateActive {
+                km.purposeVersion[k.Purpose] = k.Version
+            }
+            continue
+        }
+        km.keys[k.Version] = k
+        if k.State == KeyStateActive {
+            km.keyVersion = k.Version
+            km.rotationTime = k.CreatedAt
+        }
+    }
+}
+
+// isEncryptionKey indica si k es una versión de la KEK (ni de usuario ni de otro uso).
+func (k StoredKey) isEncryptionKey() bool {
+    return k.Owner == "" && k.Purpose == ""
+}
+
+func generateKey() ([]byte, error) {
+    key := make([]byte, 32)
+    if _, err := rand.Read(key); err != nil {
+        return nil, err
+    }
+    return key, nil
 }
\ No newline at end of file
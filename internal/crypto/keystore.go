@@ -0,0 +1,320 @@
+package crypto
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sync"
+    "syscall"
+    "time"
+)
+
+const keyringFileVersion = 1
+
+var (
+    ErrInvalidMasterKey = errors.New("master key must be 32 bytes")
+    ErrWrongMasterKey   = errors.New("master key does not match the keyring")
+)
+
+// StoredKey es una versión del keyring tal como la persiste un KeyStore. Las
+// claves por usuario se guardan en el mismo keyring con Owner y sin versión, y las
+// de otros usos (Purpose) con su propia secuencia de versiones.
+type StoredKey struct {
+    Version     int
+    Owner       string
+    Purpose     KeyPurpose
+    Key         []byte
+    State       KeyState
+    Algorithm   AlgorithmID
+    Encryptions uint64
+    CreatedAt   time.Time
+}
+
+// KeyStore persiste todas las versiones de clave del KeyManager.
+type KeyStore interface {
+    Load() ([]StoredKey, error)
+    Save(keys []StoredKey) error
+}
+
+type MemoryKeyStore struct {
+    keys []StoredKey
+    mu   sync.Mutex
+}
+
+func NewMemoryKeyStore() *MemoryKeyStore {
+    return &MemoryKeyStore{}
+}
+
+func (s *MemoryKeyStore) Load() ([]StoredKey, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return cloneStoredKeys(s.keys), nil
+}
+
+func (s *MemoryKeyStore) Save(keys []StoredKey) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.keys = cloneStoredKeys(keys)
+    return nil
+}
+
+// FileKeyStore guarda el keyring en un fichero JSON con cada clave
+// cifrada (AES-256-GCM) bajo la master key.
+type FileKeyStore struct {
+    path string
+    gcm  cipher.AEAD
+}
+
+type keyringFile struct {
+    Version int            `json:"version"`
+    Keys    []wrappedEntry `json:"keys"`
+}
+
+type wrappedEntry struct {
+    Version     int         `json:"version"`
+    Owner       string      `json:"owner,omitempty"`
+    Purpose     KeyPurpose  `json:"purpose,omitempty"`
+    State       KeyState    `json:"state,omitempty"`
+    Algorithm   AlgorithmID `json:"algorithm,omitempty"`
+    Encryptions uint64      `json:"encryptions,omitempty"`
+    WrappedKey  []byte      `json:"wrapped_key,omitempty"`
+    CreatedAt   time.Time   `json:"created_at"`
+}
+
+func NewFileKeyStore(path string, masterKey []byte) (*FileKeyStore, error) {
+    if len(masterKey) != 32 {
+        return nil, ErrInvalidMasterKey
+    }
+
+    block, err := aes.NewCipher(masterKey)
+    if err != nil {
+        return nil, err
+    }
+
+    gcm, err := cipher.NewGCM(block)
+    if err != nil {
+        return nil, err
+    }
+
+    return &FileKeyStore{path: path, gcm: gcm}, nil
+}
+
+func (s *FileKeyStore) Load() ([]StoredKey, error) {
+    unlock, err := s.lock(syscall.LOCK_SH)
+    if err != nil {
+        return nil, err
+    }
+    defer unlock()
+
+    data, err := os.ReadFile(s.path)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read keyring: %w", err)
+    }
+
+    var file keyringFile
+    if err := json.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("failed to parse keyring: %w", err)
+    }
+    if file.Version != keyringFileVersion {
+        return nil, fmt.Errorf("unsupported keyring file version %d", file.Version)
+    }
+
+    keys := make([]StoredKey, 0, len(file.Keys))
+    for _, entry := range file.Keys {
+        var key []byte
+        if entry.State != KeyStateDestroyed {
+            key, err = s.unwrap(entry)
+            if err != nil {
+                return nil, fmt.Errorf("failed to unwrap key %s: %w", entryName(entry.Version, entry.Owner, entry.Purpose), ErrWrongMasterKey)
+            }
+        }
+        keys = append(keys, StoredKey{
+            Version:     entry.Version,
+            Owner:       entry.Owner,
+            Purpose:     entry.Purpose,
+            Key:         key,
+            State:       entry.State,
+            Algorithm:   entry.Algorithm,
+            Encryptions: entry.Encryptions,
+            CreatedAt:   entry.CreatedAt,
+        })
+    }
+
+    upgradeLegacyStates(keys)
+    upgradeLegacyAlgorithms(keys)
+    return keys, nil
+}
+
+func (s *FileKeyStore) Save(keys []StoredKey) error {
+    unlock, err := s.lock(syscall.LOCK_EX)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+
+    file := keyringFile{Version: keyringFileVersion}
+    for _, key := range keys {
+        entry := wrappedEntry{
+            Version:     key.Version,
+            Owner:       key.Owner,
+            Purpose:     key.Purpose,
+            State:       key.State,
+            Algorithm:   key.Algorithm,
+            Encryptions: key.Encryptions,
+            CreatedAt:   key.CreatedAt,
+        }
+        if key.State != KeyStateDestroyed {
+            wrapped, err := s.wrap(key)
+            if err != nil {
+                return fmt.Errorf("failed to wrap key %s: %w", entryName(key.Version, key.Owner, key.Purpose), err)
+            }
+            entry.WrappedKey = wrapped
+        }
+        file.Keys = append(file.Keys, entry)
+    }
+
+    data, err := json.MarshalIndent(file, "", "  ")
+    if err != nil {
+        return err
+    }
+
+    // Escritura atómica: fichero temporal en el mismo directorio + rename
+    tmp, err := os.CreateTemp(filepath.Dir(s.path), ".keyring-*")
+    if err != nil {
+        return fmt.Errorf("failed to write keyring: %w", err)
+    }
+    defer os.Remove(tmp.Name())
+
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        return fmt.Errorf("failed to write keyring: %w", err)
+    }
+    if err := tmp.Sync(); err != nil {
+        tmp.Close()
+        return fmt.Errorf("failed to write keyring: %w", err)
+    }
+    if err := tmp.Close(); err != nil {
+        return fmt.Errorf("failed to write keyring: %w", err)
+    }
+
+    return os.Rename(tmp.Name(), s.path)
+}
+
+// lock serializa el acceso entre procesos (varias réplicas sobre el mismo volumen)
+func (s *FileKeyStore) lock(how int) (func(), error) {
+    if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+        return nil, fmt.Errorf("failed to create keyring directory: %w", err)
+    }
+
+    f, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open keyring lock: %w", err)
+    }
+
+    if err := syscall.Flock(int(f.Fd()), how); err != nil {
+        f.Close()
+        return nil, fmt.Errorf("failed to lock keyring: %w", err)
+    }
+
+    return func() {
+        syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+        f.Close()
+    }, nil
+}
+
+func (s *FileKeyStore) wrap(key StoredKey) ([]byte, error) {
+    nonce := make([]byte, s.gcm.NonceSize())
+    if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+        return nil, err
+    }
+    return s.gcm.Seal(nonce, nonce, key.Key, keyAssociatedData(key.Version, key.Owner, key.Purpose)), nil
+}
+
+func (s *FileKeyStore) unwrap(entry wrappedEntry) ([]byte, error) {
+    nonceSize := s.gcm.NonceSize()
+    if len(entry.WrappedKey) < nonceSize {
+        return nil, errors.New("wrapped key too short")
+    }
+
+    nonce, sealed := entry.WrappedKey[:nonceSize], entry.WrappedKey[nonceSize:]
+    return s.gcm.Open(nil, nonce, sealed, keyAssociatedData(entry.Version, entry.Owner, entry.Purpose))
+}
+
+// keyAssociatedData impide intercambiar claves entre versiones, usos o usuarios
+// dentro del fichero
+func keyAssociatedData(version int, owner string, purpose KeyPurpose) []byte {
+    switch {
+    case owner != "":
+        return []byte("card-vault/keyring/user/" + owner)
+    case purpose != "":
+        return []byte(fmt.Sprintf("card-vault/keyring/%s/v%d", purpose, version))
+    }
+    return []byte(fmt.Sprintf("card-vault/keyring/v%d", version))
+}
+
+func entryName(version int, owner string, purpose KeyPurpose) string {
+    switch {
+    case owner != "":
+        return "of user " + owner
+    case purpose != "":
+        return fmt.Sprintf("%s version %d", purpose, version)
+    }
+    return fmt.Sprintf("version %d", version)
+}
+
+// upgradeLegacyStates asigna estados a keyrings guardados antes de que
+// existieran: la versión más alta queda activa y el resto en decrypt_only.
+func upgradeLegacyStates(keys []StoredKey) {
+    for _, k := range keys {
+        if k.State != "" {
+            return
+        }
+    }
+
+    latest := -1
+    for i := range keys {
+        keys[i].State = KeyStateDecryptOnly
+        if latest < 0 || keys[i].Version > keys[latest].Version {
+            latest = i
+        }
+    }
+    if latest >= 0 {
+        keys[latest].State = KeyStateActive
+    }
+}
+
+// upgradeLegacyAlgorithms marca como AES-256-GCM las versiones guardadas antes
+// de registrar el algoritmo, que era el único disponible.
+func upgradeLegacyAlgorithms(keys []StoredKey) {
+    for i := range keys {
+        if keys[i].Algorithm == 0 {
+            keys[i].Algorithm = DefaultAlgorithm
+        }
+    }
+}
+
+func cloneStoredKeys(keys []StoredKey) []StoredKey {
+    out := make([]StoredKey, len(keys))
+    for i, k := range keys {
+        out[i] = StoredKey{
+            Version:     k.Version,
+            Owner:       k.Owner,
+            Purpose:     k.Purpose,
+            Key:         append([]byte(nil), k.Key...),
+            State:       k.State,
+            Algorithm:   k.Algorithm,
+            Encryptions: k.Encryptions,
+            CreatedAt:   k.CreatedAt,
+        }
+    }
+    return out
+}
\ No newline at end of file
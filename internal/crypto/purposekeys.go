@@ -0,0 +1,165 @@
+package crypto
+
+import (
+    "sort"
+    "time"
+)
+
+// KeyPurpose distingue las claves del keyring que no son versiones de la KEK.
+// Cada uso tiene su propia secuencia de versiones y su propia rotación.
+type KeyPurpose string
+
+const (
+    KeyPurposeFingerprint  KeyPurpose = "fingerprint"
+    KeyPurposeTokenization KeyPurpose = "tokenization"
+)
+
+// activePurposeKey devuelve la versión activa de la clave de un uso, creando la
+// versión 1 si el keyring aún no tiene ninguna.
+func (km *KeyManager) activePurposeKey(purpose KeyPurpose) ([]byte, int, error) {
+    if err := km.ensurePurposeKey(purpose); err != nil {
+        return nil, 0, err
+    }
+
+    km.mu.RLock()
+    defer km.mu.RUnlock()
+    version := km.purposeVersion[purpose]
+    return cloneKey(km.purposeKeys[purpose][version].Key), version, nil
+}
+
+// purposeKey devuelve una versión concreta de la clave de un uso.
+func (km *KeyManager) purposeKey(purpose KeyPurpose, version int) ([]byte, error) {
+    if err := km.ensurePurposeKey(purpose); err != nil {
+        return nil, err
+    }
+
+    km.mu.RLock()
+    key, ok := km.purposeKeys[purpose][version]
+    km.mu.RUnlock()
+
+    switch {
+    case !ok:
+        return nil, ErrKeyNotFound
+    case key.State == KeyStateDestroyed:
+        return nil, ErrKeyDestroyed
+    case key.State == KeyStateDisabled:
+        return nil, ErrKeyDisabled
+    }
+    return cloneKey(key.Key), nil
+}
+
+func (km *KeyManager) purposeKeyInfos(purpose KeyPurpose, algorithm string) []KeyInfo {
+    km.mu.RLock()
+    defer km.mu.RUnlock()
+
+    infos := make([]KeyInfo, 0, len(km.purposeKeys[purpose]))
+    for _, k := range km.purposeKeys[purpose] {
+        infos = append(infos, KeyInfo{Version: k.Version, State: k.State, Algorithm: algorithm, CreatedAt: k.CreatedAt})
+    }
+    sort.Slice(infos, func(i, j int) bool { return infos[i].Version < infos[j].Version })
+    return infos
+}
+
+// rotatePurposeKey crea una versión activa nueva de la clave de un uso y deja la
+// anterior en decrypt_only.
+func (km *KeyManager) rotatePurposeKey(purpose KeyPurpose) error {
+    return km.update(func(keys []StoredKey) ([]StoredKey, error) {
+        newKey, err := generateKey()
+        if err != nil {
+            return nil, err
+        }
+
+        latest := 0
+        for i := range keys {
+            if keys[i].Owner != "" || keys[i].Purpose != purpose {
+                continue
+            }
+            if keys[i].State == KeyStateActive {
+                keys[i].State = KeyStateDecryptOnly
+            }
+            if keys[i].Version > latest {
+                latest = keys[i].Version
+            }
+        }
+
+        return append(keys, StoredKey{
+            Version:   latest + 1,
+            Purpose:   purpose,
+            Key:       newKey,
+            State:     KeyStateActive,
+            CreatedAt: time.Now(),
+        }), nil
+    })
+}
+
+// destroyPurposeKey borra una versión retirada de la clave de un uso.
+func (km *KeyManager) destroyPurposeKey(purpose KeyPurpose, version int) error {
+    return km.update(func(keys []StoredKey) ([]StoredKey, error) {
+        for i := range keys {
+            if keys[i].Owner != "" || keys[i].Purpose != purpose || keys[i].Version != version {
+                continue
+            }
+            if !validKeyTransition(keys[i].State, KeyStateDestroyed) {
+                return nil, ErrInvalidKeyTransition
+            }
+            for j := range keys[i].Key {
+                keys[i].Key[j] = 0
+            }
+            keys[i].Key = nil
+            keys[i].State = KeyStateDestroyed
+            return keys, nil
+        }
+        return nil, ErrKeyNotFound
+    })
+}
+
+// ensurePurposeKey crea la versión 1 de la clave de un uso si no existe,
+// comprobando antes el keyring persistido por si la creó otra réplica.
+func (km *KeyManager) ensurePurposeKey(purpose KeyPurpose) error {
+    km.mu.RLock()
+    version, sealed := km.purposeVersion[purpose], km.store == nil
+    km.mu.RUnlock()
+
+    switch {
+    case sealed:
+        return ErrSealed
+    case version != 0:
+        return nil
+    }
+
+    return km.update(func(keys []StoredKey) ([]StoredKey, error) {
+        for _, k := range keys {
+            if k.Owner == "" && k.Purpose == purpose && k.State == KeyStateActive {
+                return keys, nil
+            }
+        }
+
+        newKey, err := generateKey()
+        if err != nil {
+            return nil, err
+        }
+        return append(keys, StoredKey{Version: 1, Purpose: purpose, Key: newKey, State: KeyStateActive, CreatedAt: time.Now()}), nil
+    })
+}
+
+// TokenizationKey devuelve la versión activa de la clave de tokenización.
+func (km *KeyManager) TokenizationKey() ([]byte, int, error) {
+    return km.activePurposeKey(KeyPurposeTokenization)
+}
+
+// TokenizationKeyVersion devuelve una versión concreta de la clave de
+// tokenización, para revertir tokens emitidos con ella.
+func (km *KeyManager) TokenizationKeyVersion(version int) ([]byte, error) {
+    return km.purposeKey(KeyPurposeTokenization, version)
+}
+
+// TokenizationKeys lista las versiones de la clave de tokenización sin exponer su material.
+func (km *KeyManager) TokenizationKeys() []KeyInfo {
+    return km.purposeKeyInfos(KeyPurposeTokenization, "ff1-aes256")
+}
+
+// RotateTokenizationKey crea una versión activa nueva de la clave de tokenización.
+// La anterior pasa a decrypt_only: ya no emite tokens pero sigue revirtiéndolos.
+func (km *KeyManager) RotateTokenizationKey() error {
+    return km.rotatePurposeKey(KeyPurposeTokenization)
+}
\ No newline at end of file
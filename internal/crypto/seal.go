@@ -0,0 +1,127 @@
+package crypto
+
+import (
+    "errors"
+    "fmt"
+)
+
+var (
+    ErrNotInitialized     = errors.New("keyring has not been initialized")
+    ErrAlreadyInitialized = errors.New("keyring is already initialized")
+)
+
+// NewSealedKeyManager crea un KeyManager sellado: no tiene claves en memoria hasta
+// que Unseal recibe la master key. newStore abre el keyring con esa master key.
+func NewSealedKeyManager(newStore func(masterKey []byte) (KeyStore, error), algorithm AlgorithmID) (*KeyManager, error) {
+    if _, err := LookupAlgorithm(algorithm); err != nil {
+        return nil, err
+    }
+
+    return &KeyManager{
+        newStore:       newStore,
+        keys:           make(map[int]StoredKey),
+        userKeys:       make(map[string]StoredKey),
+        purposeKeys:    make(map[KeyPurpose]map[int]StoredKey),
+        purposeVersion: make(map[KeyPurpose]int),
+        algorithm:      algorithm,
+        pendingUsage:   make(map[int]uint64),
+    }, nil
+}
+
+func (km *KeyManager) Sealed() bool {
+    km.mu.RLock()
+    defer km.mu.RUnlock()
+    return km.store == nil
+}
+
+// Unseal abre el keyring existente con masterKey. Una master key incorrecta
+// devuelve ErrWrongMasterKey y el KeyManager sigue sellado.
+func (km *KeyManager) Unseal(masterKey []byte) error {
+    return km.open(masterKey, false)
+}
+
+// Initialize crea un keyring nuevo protegido por masterKey y deja el KeyManager
+// abierto. Falla si ya existe un keyring.
+func (km *KeyManager) Initialize(masterKey []byte) error {
+    return km.open(masterKey, true)
+}
+
+func (km *KeyManager) open(masterKey []byte, initialize bool) error {
+    if km.newStore == nil {
+        return errors.New("key manager was not created sealed")
+    }
+
+    km.mu.Lock()
+    defer km.mu.Unlock()
+
+    if km.store != nil {
+        if initialize {
+            return ErrAlreadyInitialized
+        }
+        return nil
+    }
+
+    store, err := km.newStore(masterKey)
+    if err != nil {
+        return err
+    }
+
+    keys, err := store.Load()
+    switch {
+    case initialize && (errors.Is(err, ErrWrongMasterKey) || err == nil && len(keys) > 0):
+        // Un keyring que no abre con esta clave también existe ya
+        return ErrAlreadyInitialized
+    case err != nil:
+        return fmt.Errorf("failed to load keyring: %w", err)
+    case initialize:
+        if keys, err = km.initialKeyring(store); err != nil {
+            return err
+        }
+    case len(keys) == 0:
+        return ErrNotInitialized
+    }
+
+    km.store = store
+    km.apply(keys)
+    return nil
+}
+
+// Seal descarta de memoria el material de clave y el acceso al keyring. Hasta el
+// siguiente Unseal no se puede cifrar ni descifrar.
+func (km *KeyManager) Seal() error {
+    if km.newStore == nil {
+        return errors.New("key manager was not created sealed")
+    }
+
+    // Los contadores pendientes se intentan guardar; si falla se conservan en memoria
+    km.FlushUsage()
+
+    km.mu.Lock()
+    defer km.mu.Unlock()
+
+    // Los getters devuelven copias, así que estas claves ya no las comparte nadie
+    for version, k := range km.keys {
+        for i := range k.Key {
+            k.Key[i] = 0
+        }
+        delete(km.keys, version)
+    }
+    for owner, k := range km.userKeys {
+        for i := range k.Key {
+            k.Key[i] = 0
+        }
+        delete(km.userKeys, owner)
+    }
+    for purpose, keys := range km.purposeKeys {
+        for _, k := range keys {
+            for i := range k.Key {
+                k.Key[i] = 0
+            }
+        }
+        delete(km.purposeKeys, purpose)
+        delete(km.purposeVersion, purpose)
+    }
+    km.keyVersion = 0
+    km.store = nil
+    return nil
+}
\ No newline at end of file
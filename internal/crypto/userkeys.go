@@ -0,0 +1,103 @@
+package crypto
+
+import (
+    "crypto/sha256"
+    "errors"
+    "io"
+    "time"
+
+    "golang.org/x/crypto/hkdf"
+)
+
+var ErrUserKeyDestroyed = errors.New("user key has been destroyed")
+
+var userDataKeyInfo = []byte("card-vault/user-dek")
+
+// UserKey devuelve la clave del usuario owner. Si no se conoce, recarga el keyring
+// por si otra réplica la ha creado; si no existe devuelve ErrKeyNotFound.
+func (km *KeyManager) UserKey(owner string) ([]byte, error) {
+    km.mu.RLock()
+    key, ok := km.userKeys[owner]
+    sealed := km.store == nil
+    km.mu.RUnlock()
+
+    if sealed {
+        return nil, ErrSealed
+    }
+
+    if !ok {
+        if err := km.Reload(); err != nil {
+            return nil, err
+        }
+
+        km.mu.RLock()
+        key, ok = km.userKeys[owner]
+        km.mu.RUnlock()
+        if !ok {
+            return nil, ErrKeyNotFound
+        }
+    }
+
+    if key.State == KeyStateDestroyed {
+        return nil, ErrUserKeyDestroyed
+    }
+    return cloneKey(key.Key), nil
+}
+
+// EnsureUserKey devuelve la clave del usuario owner y la crea si aún no tiene.
+// A un usuario cuya clave se destruyó no se le crea otra.
+func (km *KeyManager) EnsureUserKey(owner string) ([]byte, error) {
+    key, err := km.UserKey(owner)
+    if !errors.Is(err, ErrKeyNotFound) {
+        return key, err
+    }
+
+    err = km.update(func(keys []StoredKey) ([]StoredKey, error) {
+        for _, k := range keys {
+            if k.Owner == owner {
+                // Creada por otra réplica entretanto
+                return keys, nil
+            }
+        }
+
+        newKey, err := generateKey()
+        if err != nil {
+            return nil, err
+        }
+        return append(keys, StoredKey{Owner: owner, Key: newKey, State: KeyStateActive, CreatedAt: time.Now()}), nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return km.UserKey(owner)
+}
+
+// DestroyUserKey borra de forma irreversible la clave del usuario owner. En el
+// keyring queda solo una marca de que fue destruida, sin material de clave.
+func (km *KeyManager) DestroyUserKey(owner string) error {
+    return km.update(func(keys []StoredKey) ([]StoredKey, error) {
+        for i := range keys {
+            if keys[i].Owner != owner {
+                continue
+            }
+            for j := range keys[i].Key {
+                keys[i].Key[j] = 0
+            }
+            keys[i].Key = nil
+            keys[i].State = KeyStateDestroyed
+            return keys, nil
+        }
+        return append(keys, StoredKey{Owner: owner, State: KeyStateDestroyed, CreatedAt: time.Now()}), nil
+    })
+}
+
+// DeriveUserDataKey combina la DEK de una tarjeta con la clave de su usuario. La
+// clave resultante no se puede obtener sin ambas, de modo que destruir la clave
+// del usuario deja ilegibles todas sus tarjetas, también en copias de seguridad.
+func DeriveUserDataKey(dek, userKey []byte) ([]byte, error) {
+    key := make([]byte, 32)
+    if _, err := io.ReadFull(hkdf.New(sha256.New, dek, userKey, userDataKeyInfo), key); err != nil {
+        return nil, err
+    }
+    return key, nil
+}
\ No newline at end of file
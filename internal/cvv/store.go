@@ -0,0 +1,168 @@
+package cvv
+
+import (
+    "context"
+    "crypto/rand"
+    "errors"
+    "sync"
+    "time"
+    "card-vault/internal/crypto"
+
+    "github.com/google/uuid"
+)
+
+// TTL por defecto: el CVV solo hace falta hasta autorizar el primer cargo.
+const DefaultTTL = 10 * time.Minute
+
+var ErrNotFound = errors.New("CVV not present or expired")
+
+// Store guarda CVVs durante un tiempo limitado. Nunca se persisten: la única
+// copia vive en memoria, cifrada con una clave del proceso que no sale de él.
+type Store interface {
+    // Put guarda el CVV de una tarjeta, sustituyendo el anterior, y devuelve cuándo caduca.
+    Put(cardID uuid.UUID, cvv string) (time.Time, error)
+    // Take devuelve el CVV y lo borra: cada CVV se puede usar una sola vez.
+    Take(cardID uuid.UUID) (string, error)
+    // ExpiresAt indica si hay un CVV vigente para la tarjeta y hasta cuándo.
+    ExpiresAt(cardID uuid.UUID) (time.Time, bool)
+    Delete(cardID uuid.UUID)
+}
+
+type entry struct {
+    sealed    []byte
+    expiresAt time.Time
+}
+
+// MemoryStore es un Store en memoria con caducidad. Las entradas caducadas se
+// descartan al consultarlas y las borra Sweep, que Start ejecuta periódicamente.
+// Cada proceso tiene el suyo, así que solo sirve con una única réplica.
+type MemoryStore struct {
+    ttl     time.Duration
+    encSvc  *crypto.EncryptionService
+    entries map[uuid.UUID]entry
+    mu      sync.Mutex
+}
+
+func NewMemoryStore(ttl time.Duration) (*MemoryStore, error) {
+    if ttl <= 0 {
+        ttl = DefaultTTL
+    }
+
+    // Clave efímera: al reiniciar el proceso los CVVs dejan de existir
+    key := make([]byte, 32)
+    if _, err := rand.Read(key); err != nil {
+        return nil, err
+    }
+    encSvc, err := crypto.NewEncryptionService(key)
+    if err != nil {
+        return nil, err
+    }
+
+    return &MemoryStore{ttl: ttl, encSvc: encSvc, entries: make(map[uuid.UUID]entry)}, nil
+}
+
+func (s *MemoryStore) TTL() time.Duration {
+    return s.ttl
+}
+
+func (s *MemoryStore) Put(cardID uuid.UUID, cvv string) (time.Time, error) {
+    sealed, err := s.encSvc.Seal([]byte(cvv), crypto.RecordKeyVersion, cardID[:])
+    if err != nil {
+        return time.Time{}, err
+    }
+
+    expiresAt := time.Now().Add(s.ttl)
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.remove(cardID)
+    s.entries[cardID] = entry{sealed: sealed, expiresAt: expiresAt}
+    return expiresAt, nil
+}
+
+func (s *MemoryStore) Take(cardID uuid.UUID) (string, error) {
+    s.mu.Lock()
+    e, ok := s.entries[cardID]
+    if ok {
+        delete(s.entries, cardID)
+    }
+    s.mu.Unlock()
+
+    if !ok || !time.Now().Before(e.expiresAt) {
+        if ok {
+            wipe(e.sealed)
+        }
+        return "", ErrNotFound
+    }
+
+    plaintext, err := s.encSvc.Open(e.sealed, cardID[:])
+    wipe(e.sealed)
+    if err != nil {
+        return "", err
+    }
+    return string(plaintext), nil
+}
+
+func (s *MemoryStore) ExpiresAt(cardID uuid.UUID) (time.Time, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    e, ok := s.entries[cardID]
+    if !ok || !time.Now().Before(e.expiresAt) {
+        return time.Time{}, false
+    }
+    return e.expiresAt, true
+}
+
+func (s *MemoryStore) Delete(cardID uuid.UUID) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.remove(cardID)
+}
+
+// Sweep borra los CVVs caducados en el instante now y devuelve cuántos eran.
+func (s *MemoryStore) Sweep(now time.Time) int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    purged := 0
+    for cardID, e := range s.entries {
+        if now.Before(e.expiresAt) {
+            continue
+        }
+        s.remove(cardID)
+        purged++
+    }
+    return purged
+}
+
+// Start ejecuta Sweep cada interval hasta que se cancele ctx.
+func (s *MemoryStore) Start(ctx context.Context, interval time.Duration) {
+    go func() {
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case now := <-ticker.C:
+                s.Sweep(now)
+            }
+        }
+    }()
+}
+
+// remove debe llamarse con s.mu tomado.
+func (s *MemoryStore) remove(cardID uuid.UUID) {
+    if e, ok := s.entries[cardID]; ok {
+        wipe(e.sealed)
+        delete(s.entries, cardID)
+    }
+}
+
+func wipe(b []byte) {
+    for i := range b {
+        b[i] = 0
+    }
+}
\ No newline at end of file
@@ -11,16 +11,32 @@ import (
 func GenerateTestToken(c *gin.Context) {
     // Solo para desarrollo - en producción usar un sistema de auth real
     userID := uuid.New()
-    
-    token, err := middleware.GenerateToken(userID)
+
+    // Cuerpo opcional: {"scopes": ["tokens:detokenize"], "role": "support", "client_id": "backoffice"}
+    var req struct {
+        Scopes   []string `json:"scopes"`
+        Role     string   `json:"role"`
+        ClientID string   `json:"client_id"`
+    }
+    if c.Request.ContentLength > 0 {
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+            return
+        }
+    }
+
+    token, err := middleware.SignToken(middleware.Claims{UserID: userID, Scopes: req.Scopes, Role: req.Role, ClientID: req.ClientID})
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
         return
     }
 
     c.JSON(http.StatusOK, gin.H{
-        "token":   token,
-        "user_id": userID,
-        "message": "Test token generated successfully",
+        "token":     token,
+        "user_id":   userID,
+        "scopes":    req.Scopes,
+        "role":      req.Role,
+        "client_id": req.ClientID,
+        "message":   "Test token generated successfully",
     })
 }
\ No newline at end of file
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "card-vault/internal/bin"
+
+    "github.com/gin-gonic/gin"
+)
+
+type BINHandler struct {
+    bins *bin.Database
+}
+
+func NewBINHandler(bins *bin.Database) *BINHandler {
+    return &BINHandler{bins: bins}
+}
+
+// GetStatus - muestra el fichero de la tabla de BIN, cuántos rangos tiene y cuándo se cargó
+func (h *BINHandler) GetStatus(c *gin.Context) {
+    c.JSON(http.StatusOK, h.bins.Status())
+}
+
+// Reload - vuelve a leer la tabla de BIN de su fichero; si falla se mantiene la anterior
+func (h *BINHandler) Reload(c *gin.Context) {
+    status, err := h.bins.Reload()
+    if errors.Is(err, bin.ErrNoSource) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "status": status})
+        return
+    }
+
+    c.JSON(http.StatusOK, status)
+}
\ No newline at end of file
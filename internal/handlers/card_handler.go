@@ -1,13 +1,22 @@
 package handlers
 
 import (
+    "errors"
     "net/http"
+    "strconv"
+    "card-vault/internal/address"
+    "card-vault/internal/brand"
+    "card-vault/internal/cvv"
+    "card-vault/internal/masking"
+    "card-vault/internal/middleware"
     "card-vault/internal/models"
     "card-vault/internal/service"
+    "card-vault/internal/tokenization"
 
     "github.com/gin-gonic/gin"
     "github.com/go-playground/validator/v10"
     "github.com/google/uuid"
+    "gorm.io/gorm"
 )
 
 type CardHandler struct {
@@ -22,6 +31,15 @@ func NewCardHandler(cardService service.CardService) *CardHandler {
     }
 }
 
+// cards devuelve el servicio con la política de enmascarado que PANMasking eligió
+// para el llamante, o con la de por defecto si no hay ninguna.
+func (h *CardHandler) cards(c *gin.Context) service.CardService {
+    if policy, ok := c.Value("pan_masking").(masking.Policy); ok {
+        return h.cardService.WithMasking(policy)
+    }
+    return h.cardService
+}
+
 // CreateCard - crea una tarjeta nueva
 func (h *CardHandler) CreateCard(c *gin.Context) {
     userID, exists := c.Get("user_id")
@@ -41,7 +59,20 @@ func (h *CardHandler) CreateCard(c *gin.Context) {
         return
     }
 
-    card, err := h.cardService.CreateCard(userID.(uuid.UUID), &req)
+    card, err := h.cards(c).CreateCard(userID.(uuid.UUID), &req)
+    if invalidCard(c, err) {
+        return
+    }
+    // Con DUPLICATE_CARD_POLICY=merge la tarjeta existente puede cambiar de estado entretanto
+    if errors.Is(err, service.ErrDuplicateCard) || errors.Is(err, service.ErrInvalidStatusTransition) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    // Se puede reintentar cuando termine el recálculo de huellas
+    if errors.Is(err, service.ErrFingerprintReindexPending) {
+        c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+        return
+    }
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
@@ -65,7 +96,7 @@ func (h *CardHandler) GetCard(c *gin.Context) {
         return
     }
 
-    card, err := h.cardService.GetCard(cardID, userID.(uuid.UUID))
+    card, err := h.cards(c).GetCard(cardID, userID.(uuid.UUID))
     if err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
         return
@@ -74,7 +105,7 @@ func (h *CardHandler) GetCard(c *gin.Context) {
     c.JSON(http.StatusOK, card)
 }
 
-// GetUserCards - obtiene todas las tarjetas de un usuario  
+// GetUserCards - lista las tarjetas del usuario por páginas, con filtros y orden
 func (h *CardHandler) GetUserCards(c *gin.Context) {
     userID, exists := c.Get("user_id")
     if !exists {
@@ -82,13 +113,36 @@ func (h *CardHandler) GetUserCards(c *gin.Context) {
         return
     }
 
-    cards, err := h.cardService.GetUserCards(userID.(uuid.UUID))
+    var query models.CardListQuery
+    if err := c.ShouldBindQuery(&query); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+        return
+    }
+
+    page, err := h.cards(c).GetUserCards(userID.(uuid.UUID), &query)
     if err != nil {
+        if errors.Is(err, service.ErrInvalidCardQuery) || errors.Is(err, service.ErrInvalidCursor) {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
-    c.JSON(http.StatusOK, cards)
+    setPageLinks(c, &page.Pagination)
+    c.JSON(http.StatusOK, page)
+}
+
+// setPageLinks enlaza esta página y la siguiente, con los mismos filtros.
+func setPageLinks(c *gin.Context, pagination *models.Pagination) {
+    pagination.Links.Self = c.Request.URL.RequestURI()
+    if pagination.NextCursor != "" {
+        next := *c.Request.URL
+        params := next.Query()
+        params.Set("cursor", pagination.NextCursor)
+        next.RawQuery = params.Encode()
+        pagination.Links.Next = next.RequestURI()
+    }
 }
 
 // UpdateCard - actualiza una tarjeta existente
@@ -117,7 +171,19 @@ func (h *CardHandler) UpdateCard(c *gin.Context) {
         return
     }
 
-    updatedCard, err := h.cardService.UpdateCard(cardID, userID.(uuid.UUID), &req)
+    updatedCard, err := h.cards(c).UpdateCard(cardID, userID.(uuid.UUID), &req)
+    if invalidCard(c, err) {
+        return
+    }
+    if errors.Is(err, service.ErrDuplicateCard) || errors.Is(err, service.ErrCardNotActive) ||
+        errors.Is(err, service.ErrInvalidStatusTransition) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    if errors.Is(err, service.ErrFingerprintReindexPending) {
+        c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+        return
+    }
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
@@ -141,7 +207,7 @@ func (h *CardHandler) DeleteCard(c *gin.Context) {
         return
     }
 
-    if err := h.cardService.DeleteCard(cardID, userID.(uuid.UUID)); err != nil {
+    if err := h.cards(c).DeleteCard(cardID, userID.(uuid.UUID)); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
@@ -168,7 +234,7 @@ func (h *CardHandler) BatchUpdateCards(c *gin.Context) {
         return
     }
 
-    results, err := h.cardService.BatchUpdateCards(userID.(uuid.UUID), &req)
+    results, err := h.cards(c).BatchUpdateCards(userID.(uuid.UUID), &req)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
@@ -177,13 +243,527 @@ func (h *CardHandler) BatchUpdateCards(c *gin.Context) {
     c.JSON(http.StatusOK, gin.H{"results": results})
 }
 
-// RotateKeys - rota las claves de cifrado
+// RotateKeys - lanza la rotación de claves en segundo plano y devuelve el ID del trabajo
 func (h *CardHandler) RotateKeys(c *gin.Context) {
-    results, err := h.cardService.RotateKeys()
+    job, err := h.cards(c).RotateKeys()
+    if errors.Is(err, service.ErrRotationInProgress) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusAccepted, gin.H{"job_id": job.ID, "status": job.Status})
+}
+
+// GetRotationJob - consulta el progreso de un trabajo de rotación
+func (h *CardHandler) GetRotationJob(c *gin.Context) {
+    jobID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+        return
+    }
+
+    job, err := h.cards(c).GetRotationJob(jobID)
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Rotation job not found"})
+        return
+    }
+
+    c.JSON(http.StatusOK, job)
+}
+
+// BindAssociatedData - liga los datos cifrados de tarjetas antiguas a su registro
+func (h *CardHandler) BindAssociatedData(c *gin.Context) {
+    results, err := h.cards(c).BindAssociatedData()
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
     c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// LookupCards - busca por huella qué tarjetas (de cualquier usuario) tienen un PAN
+func (h *CardHandler) LookupCards(c *gin.Context) {
+    var req models.CardLookupRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+        return
+    }
+
+    if err := h.validator.Struct(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    cards, err := h.cards(c).LookupByPAN(req.CardNumber)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"cards": cards})
+}
+
+// ReindexFingerprints - recalcula las huellas de PAN, rotando antes su clave si ?rotate=true
+func (h *CardHandler) ReindexFingerprints(c *gin.Context) {
+    result, err := h.cards(c).ReindexFingerprints(c.Query("rotate") == "true")
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, result)
+}
+
+// TokenizeCard - emite un token con formato de número de tarjeta para una tarjeta
+func (h *CardHandler) TokenizeCard(c *gin.Context) {
+    userID, exists := c.Get("user_id")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+        return
+    }
+
+    cardID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID"})
+        return
+    }
+
+    token, err := h.cards(c).TokenizeCard(cardID, userID.(uuid.UUID))
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        return
+    }
+    if errors.Is(err, service.ErrCardNotActive) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    // La tarjeta guardada no admite un token: no es un fallo del servicio
+    if errors.Is(err, tokenization.ErrInvalidPAN) || errors.Is(err, tokenization.ErrDomainTooSmall) {
+        c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, token)
+}
+
+// Detokenize - recupera el PAN de un token (requiere el scope tokens:detokenize)
+func (h *CardHandler) Detokenize(c *gin.Context) {
+    var req models.DetokenizeRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+        return
+    }
+
+    if err := h.validator.Struct(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    result, err := h.cards(c).Detokenize(req.Token)
+    if errors.Is(err, service.ErrTokenNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        return
+    }
+    if errors.Is(err, service.ErrCardNotActive) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, result)
+}
+
+// StoreCVV - guarda un CVV nuevo para la tarjeta durante el TTL configurado
+func (h *CardHandler) StoreCVV(c *gin.Context) {
+    userID, exists := c.Get("user_id")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+        return
+    }
+
+    cardID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID"})
+        return
+    }
+
+    var req models.CVVRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+        return
+    }
+
+    if err := h.validator.Struct(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    card, err := h.cards(c).StoreCVV(cardID, userID.(uuid.UUID), req.CVV)
+    if invalidCard(c, err) {
+        return
+    }
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        return
+    }
+    if errors.Is(err, service.ErrCardNotActive) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, card)
+}
+
+// UseCVV - entrega el CVV de la tarjeta una sola vez y lo borra
+func (h *CardHandler) UseCVV(c *gin.Context) {
+    userID, exists := c.Get("user_id")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+        return
+    }
+
+    cardID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID"})
+        return
+    }
+
+    result, err := h.cards(c).UseCVV(cardID, userID.(uuid.UUID))
+    if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, cvv.ErrNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        return
+    }
+    if errors.Is(err, service.ErrCardNotActive) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, result)
+}
+
+// RevealCard - devuelve el PAN completo de una tarjeta de cualquier usuario para el
+// motivo de X-Reveal-Purpose; el llamante queda auditado como actor
+func (h *CardHandler) RevealCard(c *gin.Context) {
+    actorID, exists := c.Get("user_id")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+        return
+    }
+
+    cardID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID"})
+        return
+    }
+
+    purpose := c.GetHeader("X-Reveal-Purpose")
+    if purpose == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "X-Reveal-Purpose header required"})
+        return
+    }
+
+    // El CVV solo se entrega a quien además puede usarlo
+    includeCVV := middleware.HasScope(c, middleware.ScopeUseCVV)
+    result, err := h.cards(c).RevealCard(cardID, actorID.(uuid.UUID), purpose, c.GetHeader("X-Reveal-Reason"), includeCVV)
+    if errors.Is(err, service.ErrInvalidRevealPurpose) || errors.Is(err, service.ErrRevealReasonTooLong) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        return
+    }
+    if errors.Is(err, service.ErrCardNotActive) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.Header("Cache-Control", "no-store")
+    c.JSON(http.StatusOK, result)
+}
+
+// SetPreferredNetwork - elige la red preferida de una tarjeta co-badged
+func (h *CardHandler) SetPreferredNetwork(c *gin.Context) {
+    userID, exists := c.Get("user_id")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+        return
+    }
+
+    cardID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID"})
+        return
+    }
+
+    var req models.PreferredNetworkRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+        return
+    }
+
+    if err := h.validator.Struct(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    card, err := h.cards(c).SetPreferredNetwork(cardID, userID.(uuid.UUID), req.Network)
+    if invalidCard(c, err) {
+        return
+    }
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        return
+    }
+    if errors.Is(err, service.ErrCardNotActive) || errors.Is(err, service.ErrInvalidStatusTransition) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, card)
+}
+
+// FreezeCard - bloquea temporalmente una tarjeta
+func (h *CardHandler) FreezeCard(c *gin.Context) {
+    h.changeStatus(c, h.cards(c).FreezeCard)
+}
+
+// UnfreezeCard - vuelve a activar una tarjeta bloqueada
+func (h *CardHandler) UnfreezeCard(c *gin.Context) {
+    h.changeStatus(c, h.cards(c).UnfreezeCard)
+}
+
+// CloseCard - da de baja una tarjeta de forma definitiva
+func (h *CardHandler) CloseCard(c *gin.Context) {
+    h.changeStatus(c, h.cards(c).CloseCard)
+}
+
+// ReplaceCard - sustituye una tarjeta por una nueva y enlaza la anterior con ella
+func (h *CardHandler) ReplaceCard(c *gin.Context) {
+    userID, exists := c.Get("user_id")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+        return
+    }
+
+    cardID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID"})
+        return
+    }
+
+    var req models.ReplaceCardRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+        return
+    }
+
+    if err := h.validator.Struct(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    card, err := h.cards(c).ReplaceCard(cardID, userID.(uuid.UUID), &req.CardRequest, req.Reason)
+    if invalidCard(c, err) {
+        return
+    }
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        return
+    }
+    if errors.Is(err, service.ErrDuplicateCard) || errors.Is(err, service.ErrInvalidStatusTransition) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    if errors.Is(err, service.ErrFingerprintReindexPending) {
+        c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusCreated, card)
+}
+
+// GetStatusHistory - historial de cambios de estado de una tarjeta
+func (h *CardHandler) GetStatusHistory(c *gin.Context) {
+    userID, exists := c.Get("user_id")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+        return
+    }
+
+    cardID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID"})
+        return
+    }
+
+    events, err := h.cards(c).GetStatusHistory(cardID, userID.(uuid.UUID))
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"history": events})
+}
+
+// changeStatus atiende las peticiones de cambio de estado. El cuerpo, con el
+// motivo del cambio, es opcional.
+func (h *CardHandler) changeStatus(c *gin.Context, change func(cardID, userID uuid.UUID, reason string) (*models.CardResponse, error)) {
+    userID, exists := c.Get("user_id")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+        return
+    }
+
+    cardID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID"})
+        return
+    }
+
+    var req models.CardStatusRequest
+    if c.Request.ContentLength > 0 {
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+            return
+        }
+    }
+
+    if err := h.validator.Struct(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    card, err := change(cardID, userID.(uuid.UUID), req.Reason)
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        return
+    }
+    if errors.Is(err, service.ErrInvalidStatusTransition) {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, card)
+}
+
+// GetExpiringCards - tarjetas del usuario que caducan en los próximos ?days= días (30 por defecto)
+func (h *CardHandler) GetExpiringCards(c *gin.Context) {
+    userID, exists := c.Get("user_id")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+        return
+    }
+
+    days, ok := expiringDays(c)
+    if !ok {
+        return
+    }
+
+    cards, err := h.cards(c).GetExpiringCards(userID.(uuid.UUID), days)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, cards)
+}
+
+// GetAllExpiringCards - página de las tarjetas de todos los usuarios que caducan en los próximos ?days= días
+func (h *CardHandler) GetAllExpiringCards(c *gin.Context) {
+    days, ok := expiringDays(c)
+    if !ok {
+        return
+    }
+
+    var query models.PageQuery
+    if err := c.ShouldBindQuery(&query); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+        return
+    }
+
+    page, err := h.cards(c).GetAllExpiringCards(days, &query)
+    if err != nil {
+        if errors.Is(err, service.ErrInvalidCardQuery) || errors.Is(err, service.ErrInvalidCursor) {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    setPageLinks(c, &page.Pagination)
+    c.JSON(http.StatusOK, page)
+}
+
+// expiringDays lee el parámetro days (entre 1 y 3650, 30 por defecto) y responde
+// 400 si no es válido.
+func expiringDays(c *gin.Context) (int, bool) {
+    value := c.DefaultQuery("days", "30")
+    days, err := strconv.Atoi(value)
+    if err != nil || days < 1 || days > 3650 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a number between 1 and 3650"})
+        return 0, false
+    }
+    return days, true
+}
+
+// invalidCard responde 400 si err es un error de validación: la regla de la marca
+// que incumple el número o el CVV, una red desconocida o que la tarjeta no admite,
+// una fecha de caducidad vencida o demasiado lejana, o una dirección con un país
+// o un código postal no válidos.
+func invalidCard(c *gin.Context, err error) bool {
+    var invalid *brand.ValidationError
+    if errors.As(err, &invalid) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Message, "rule": invalid.Rule, "brand": invalid.Brand})
+        return true
+    }
+
+    if errors.Is(err, brand.ErrUnknownNetwork) || errors.Is(err, service.ErrUnsupportedNetwork) ||
+        errors.Is(err, service.ErrInvalidExpiry) || errors.Is(err, address.ErrInvalidCountry) ||
+        errors.Is(err, address.ErrInvalidPostalCode) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return true
+    }
+    return false
 }
\ No newline at end of file
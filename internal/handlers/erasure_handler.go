@@ -0,0 +1,40 @@
+package handlers
+
+import (
+    "net/http"
+    "card-vault/internal/service"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+)
+
+type ErasureHandler struct {
+    erasureService service.ErasureService
+}
+
+func NewErasureHandler(erasureService service.ErasureService) *ErasureHandler {
+    return &ErasureHandler{erasureService: erasureService}
+}
+
+// ShredUser - destruye la clave de un usuario y borra sus tarjetas de forma irreversible
+func (h *ErasureHandler) ShredUser(c *gin.Context) {
+    actorID, exists := c.Get("user_id")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+        return
+    }
+
+    userID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        return
+    }
+
+    record, err := h.erasureService.ShredUser(actorID.(uuid.UUID), userID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "audit": record})
+        return
+    }
+
+    c.JSON(http.StatusOK, record)
+}
\ No newline at end of file
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+    "net/http"
+    "time"
+    "card-vault/internal/scheduler"
+
+    "github.com/gin-gonic/gin"
+)
+
+type ExpiryHandler struct {
+    scheduler *scheduler.ExpiryScheduler
+}
+
+func NewExpiryHandler(scheduler *scheduler.ExpiryScheduler) *ExpiryHandler {
+    return &ExpiryHandler{scheduler: scheduler}
+}
+
+// GetSchedule - muestra cuándo se ejecuta el trabajo de caducidad y su último resultado
+func (h *ExpiryHandler) GetSchedule(c *gin.Context) {
+    c.JSON(http.StatusOK, h.scheduler.Status())
+}
+
+// Run - marca ahora como caducadas las tarjetas vencidas
+func (h *ExpiryHandler) Run(c *gin.Context) {
+    result, err := h.scheduler.Run(time.Now())
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, result)
+}
\ No newline at end of file
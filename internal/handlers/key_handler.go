@@ -0,0 +1,62 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+    "card-vault/internal/crypto"
+    "card-vault/internal/service"
+
+    "github.com/gin-gonic/gin"
+)
+
+type KeyHandler struct {
+    keyService service.KeyService
+}
+
+func NewKeyHandler(keyService service.KeyService) *KeyHandler {
+    return &KeyHandler{keyService: keyService}
+}
+
+type keyStateRequest struct {
+    State crypto.KeyState `json:"state" binding:"required,oneof=decrypt_only disabled destroyed"`
+}
+
+// ListKeys - lista las versiones del keyring y su estado
+func (h *KeyHandler) ListKeys(c *gin.Context) {
+    c.JSON(http.StatusOK, gin.H{"keys": h.keyService.ListKeys()})
+}
+
+// ListFingerprintKeys - lista las versiones de la clave HMAC de huellas de PAN
+func (h *KeyHandler) ListFingerprintKeys(c *gin.Context) {
+    c.JSON(http.StatusOK, gin.H{"keys": h.keyService.ListFingerprintKeys()})
+}
+
+// UpdateKeyState - cambia el estado de una versión de clave
+func (h *KeyHandler) UpdateKeyState(c *gin.Context) {
+    version, err := strconv.Atoi(c.Param("version"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key version"})
+        return
+    }
+
+    var req keyStateRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+        return
+    }
+
+    if err := h.keyService.SetKeyState(version, req.State); err != nil {
+        switch {
+        case errors.Is(err, crypto.ErrKeyNotFound):
+            c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        case errors.Is(err, crypto.ErrInvalidKeyTransition), errors.Is(err, service.ErrKeyInUse):
+            c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        default:
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        }
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"version": version, "state": req.State})
+}
\ No newline at end of file
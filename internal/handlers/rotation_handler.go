@@ -0,0 +1,21 @@
+package handlers
+
+import (
+    "net/http"
+    "card-vault/internal/scheduler"
+
+    "github.com/gin-gonic/gin"
+)
+
+type RotationHandler struct {
+    scheduler *scheduler.RotationScheduler
+}
+
+func NewRotationHandler(scheduler *scheduler.RotationScheduler) *RotationHandler {
+    return &RotationHandler{scheduler: scheduler}
+}
+
+// GetSchedule - muestra la política de rotación automática, la próxima rotación prevista y el último resultado
+func (h *RotationHandler) GetSchedule(c *gin.Context) {
+    c.JSON(http.StatusOK, h.scheduler.Status())
+}
\ No newline at end of file
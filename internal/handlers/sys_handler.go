@@ -0,0 +1,114 @@
+package handlers
+
+import (
+    "encoding/base64"
+    "errors"
+    "net/http"
+    "card-vault/internal/crypto"
+    "card-vault/internal/seal"
+    "card-vault/internal/shamir"
+
+    "github.com/gin-gonic/gin"
+)
+
+type SysHandler struct {
+    sealMgr *seal.Manager
+}
+
+func NewSysHandler(sealMgr *seal.Manager) *SysHandler {
+    return &SysHandler{sealMgr: sealMgr}
+}
+
+type initRequest struct {
+    Shares int `json:"shares" binding:"required,min=2,max=255"`
+}
+
+type unsealRequest struct {
+    Key   string `json:"key"`
+    Reset bool   `json:"reset"`
+}
+
+// SealStatus - indica si el keyring está sellado y cuántas partes se han enviado
+func (h *SysHandler) SealStatus(c *gin.Context) {
+    c.JSON(http.StatusOK, h.sealMgr.Status())
+}
+
+// Initialize - crea el keyring con una master key nueva y devuelve sus partes (solo una vez)
+func (h *SysHandler) Initialize(c *gin.Context) {
+    var req initRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+        return
+    }
+
+    parts, err := h.sealMgr.Initialize(req.Shares)
+    if err != nil {
+        switch {
+        case errors.Is(err, crypto.ErrAlreadyInitialized):
+            c.JSON(http.StatusConflict, gin.H{"error": "Keyring is already initialized"})
+        case errors.Is(err, shamir.ErrInvalidParameters):
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Shares must be at least the unseal threshold"})
+        default:
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize keyring"})
+        }
+        return
+    }
+
+    keys := make([]string, len(parts))
+    for i, part := range parts {
+        keys[i] = base64.StdEncoding.EncodeToString(part)
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "keys":      keys,
+        "threshold": h.sealMgr.Status().Threshold,
+    })
+}
+
+// Unseal - recibe una parte de la master key; al alcanzar el umbral abre el keyring
+func (h *SysHandler) Unseal(c *gin.Context) {
+    var req unsealRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+        return
+    }
+
+    if req.Reset {
+        c.JSON(http.StatusOK, h.sealMgr.ResetProgress())
+        return
+    }
+
+    share, err := base64.StdEncoding.DecodeString(req.Key)
+    if err != nil || len(share) == 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Key share must be base64"})
+        return
+    }
+
+    status, err := h.sealMgr.Unseal(share)
+    if err != nil {
+        switch {
+        case errors.Is(err, seal.ErrNotSealed):
+            c.JSON(http.StatusOK, status)
+        case errors.Is(err, seal.ErrInvalidShare), errors.Is(err, seal.ErrDuplicateShare):
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        case errors.Is(err, seal.ErrUnsealFailed):
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Key shares do not match the keyring; progress has been reset"})
+        case errors.Is(err, crypto.ErrNotInitialized):
+            c.JSON(http.StatusConflict, gin.H{"error": "Keyring has not been initialized"})
+        default:
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unseal"})
+        }
+        return
+    }
+
+    c.JSON(http.StatusOK, status)
+}
+
+// Seal - sella el keyring de inmediato (respuesta a incidentes)
+func (h *SysHandler) Seal(c *gin.Context) {
+    if err := h.sealMgr.Seal(); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to seal"})
+        return
+    }
+    c.JSON(http.StatusOK, h.sealMgr.Status())
+}
\ No newline at end of file
@@ -0,0 +1,48 @@
+package kms
+
+import (
+    "time"
+    "card-vault/internal/crypto"
+)
+
+// Provider envuelve y desenvuelve las claves de datos (DEK) de las tarjetas.
+// La clave que envuelve (KEK) nunca sale del proveedor.
+type Provider interface {
+    Name() string
+    GenerateDataKey() (dek []byte, wrapped []byte, version int, err error)
+    WrapDataKey(dek []byte) (wrapped []byte, version int, err error)
+    UnwrapDataKey(wrapped []byte, version int) ([]byte, error)
+    RewrapDataKey(wrapped []byte, version int) ([]byte, int, error)
+    RotateKey() error
+    KeyUsage() (KeyUsage, error)
+}
+
+// KeyUsage describe la versión activa de la KEK para las políticas de rotación.
+type KeyUsage struct {
+    Version     int       `json:"version"`
+    CreatedAt   time.Time `json:"created_at"`
+    Encryptions uint64    `json:"encryptions"`
+}
+
+const LocalProviderName = "local"
+
+// LocalProvider usa el keyring local del proceso como KEK.
+type LocalProvider struct {
+    *crypto.KeyManager
+}
+
+func NewLocalProvider(keyMgr *crypto.KeyManager) *LocalProvider {
+    return &LocalProvider{KeyManager: keyMgr}
+}
+
+func (p *LocalProvider) Name() string {
+    return LocalProviderName
+}
+
+func (p *LocalProvider) KeyUsage() (KeyUsage, error) {
+    info, err := p.ActiveKeyUsage()
+    if err != nil {
+        return KeyUsage{}, err
+    }
+    return KeyUsage{Version: info.Version, CreatedAt: info.CreatedAt, Encryptions: info.Encryptions}, nil
+}
\ No newline at end of file
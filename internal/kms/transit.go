@@ -0,0 +1,233 @@
+package kms
+
+import (
+    "bytes"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+const TransitProviderName = "transit"
+
+var ErrInvalidTransitCiphertext = errors.New("invalid transit ciphertext")
+
+// TransitProvider delega la KEK en un servidor con API compatible con el
+// secrets engine transit de Vault.
+type TransitProvider struct {
+    address string
+    token   string
+    keyName string
+    client  *http.Client
+
+    // transit no expone cuántas veces se ha cifrado con cada versión: se cuentan
+    // los cifrados de este proceso
+    usage   map[int]uint64
+    usageMu sync.Mutex
+}
+
+type TransitConfig struct {
+    Address string
+    Token   string
+    KeyName string
+    Timeout time.Duration
+}
+
+type transitResponse struct {
+    Data   map[string]interface{} `json:"data"`
+    Errors []string               `json:"errors"`
+}
+
+func NewTransitProvider(cfg TransitConfig) (*TransitProvider, error) {
+    if cfg.Address == "" || cfg.KeyName == "" {
+        return nil, errors.New("transit address and key name are required")
+    }
+    if cfg.Timeout == 0 {
+        cfg.Timeout = 10 * time.Second
+    }
+
+    return &TransitProvider{
+        address: strings.TrimRight(cfg.Address, "/"),
+        token:   cfg.Token,
+        keyName: cfg.KeyName,
+        client:  &http.Client{Timeout: cfg.Timeout},
+        usage:   make(map[int]uint64),
+    }, nil
+}
+
+func (p *TransitProvider) Name() string {
+    return TransitProviderName
+}
+
+func (p *TransitProvider) GenerateDataKey() ([]byte, []byte, int, error) {
+    resp, err := p.call("/v1/transit/datakey/plaintext/"+url.PathEscape(p.keyName), map[string]interface{}{"bits": 256})
+    if err != nil {
+        return nil, nil, 0, fmt.Errorf("failed to generate data key: %w", err)
+    }
+
+    dek, err := decodeField(resp, "plaintext")
+    if err != nil {
+        return nil, nil, 0, err
+    }
+
+    wrapped, version, err := p.ciphertextField(resp)
+    if err != nil {
+        return nil, nil, 0, err
+    }
+
+    return dek, wrapped, version, nil
+}
+
+func (p *TransitProvider) WrapDataKey(dek []byte) ([]byte, int, error) {
+    resp, err := p.call("/v1/transit/encrypt/"+url.PathEscape(p.keyName), map[string]interface{}{
+        "plaintext": base64.StdEncoding.EncodeToString(dek),
+    })
+    if err != nil {
+        return nil, 0, fmt.Errorf("failed to wrap data key: %w", err)
+    }
+    return p.ciphertextField(resp)
+}
+
+func (p *TransitProvider) UnwrapDataKey(wrapped []byte, version int) ([]byte, error) {
+    if _, err := ParseTransitVersion(string(wrapped)); err != nil {
+        return nil, err
+    }
+
+    resp, err := p.call("/v1/transit/decrypt/"+url.PathEscape(p.keyName), map[string]interface{}{
+        "ciphertext": string(wrapped),
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+    }
+    return decodeField(resp, "plaintext")
+}
+
+func (p *TransitProvider) RewrapDataKey(wrapped []byte, version int) ([]byte, int, error) {
+    resp, err := p.call("/v1/transit/rewrap/"+url.PathEscape(p.keyName), map[string]interface{}{
+        "ciphertext": string(wrapped),
+    })
+    if err != nil {
+        return nil, 0, fmt.Errorf("failed to rewrap data key: %w", err)
+    }
+    return p.ciphertextField(resp)
+}
+
+func (p *TransitProvider) RotateKey() error {
+    if _, err := p.call("/v1/transit/keys/"+url.PathEscape(p.keyName)+"/rotate", nil); err != nil {
+        return fmt.Errorf("failed to rotate transit key: %w", err)
+    }
+    return nil
+}
+
+// KeyUsage lee la versión activa y su fecha de creación de la clave en transit.
+func (p *TransitProvider) KeyUsage() (KeyUsage, error) {
+    resp, err := p.do(http.MethodGet, "/v1/transit/keys/"+url.PathEscape(p.keyName), nil)
+    if err != nil {
+        return KeyUsage{}, fmt.Errorf("failed to read transit key: %w", err)
+    }
+
+    latest, ok := resp.Data["latest_version"].(float64)
+    if !ok {
+        return KeyUsage{}, errors.New("transit response missing latest_version")
+    }
+    version := int(latest)
+
+    keys, _ := resp.Data["keys"].(map[string]interface{})
+    created, ok := keys[strconv.Itoa(version)].(float64)
+    if !ok {
+        return KeyUsage{}, fmt.Errorf("transit response missing creation time of version %d", version)
+    }
+
+    p.usageMu.Lock()
+    encryptions := p.usage[version]
+    p.usageMu.Unlock()
+
+    return KeyUsage{Version: version, CreatedAt: time.Unix(int64(created), 0), Encryptions: encryptions}, nil
+}
+
+func (p *TransitProvider) call(path string, body interface{}) (*transitResponse, error) {
+    return p.do(http.MethodPost, path, body)
+}
+
+func (p *TransitProvider) do(method, path string, body interface{}) (*transitResponse, error) {
+    var payload []byte
+    if body != nil {
+        var err error
+        if payload, err = json.Marshal(body); err != nil {
+            return nil, err
+        }
+    }
+
+    req, err := http.NewRequest(method, p.address+path, bytes.NewReader(payload))
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("X-Vault-Token", p.token)
+
+    res, err := p.client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer res.Body.Close()
+
+    var out transitResponse
+    if res.ContentLength != 0 {
+        if err := json.NewDecoder(res.Body).Decode(&out); err != nil && res.StatusCode < 300 {
+            return nil, fmt.Errorf("invalid transit response: %w", err)
+        }
+    }
+
+    if res.StatusCode >= 300 {
+        if len(out.Errors) > 0 {
+            return nil, fmt.Errorf("transit returned %d: %s", res.StatusCode, strings.Join(out.Errors, "; "))
+        }
+        return nil, fmt.Errorf("transit returned %d", res.StatusCode)
+    }
+
+    return &out, nil
+}
+
+func decodeField(resp *transitResponse, field string) ([]byte, error) {
+    value, _ := resp.Data[field].(string)
+    if value == "" {
+        return nil, fmt.Errorf("transit response missing %s", field)
+    }
+    return base64.StdEncoding.DecodeString(value)
+}
+
+// ciphertextField extrae el ciphertext de la respuesta y cuenta el cifrado
+// contra su versión.
+func (p *TransitProvider) ciphertextField(resp *transitResponse) ([]byte, int, error) {
+    ciphertext, _ := resp.Data["ciphertext"].(string)
+    version, err := ParseTransitVersion(ciphertext)
+    if err != nil {
+        return nil, 0, err
+    }
+
+    p.usageMu.Lock()
+    p.usage[version]++
+    p.usageMu.Unlock()
+
+    return []byte(ciphertext), version, nil
+}
+
+// ParseTransitVersion extrae la versión de clave de un ciphertext "vault:vN:...".
+func ParseTransitVersion(ciphertext string) (int, error) {
+    parts := strings.SplitN(ciphertext, ":", 3)
+    if len(parts) != 3 || parts[0] != "vault" || !strings.HasPrefix(parts[1], "v") {
+        return 0, ErrInvalidTransitCiphertext
+    }
+
+    version, err := strconv.Atoi(parts[1][1:])
+    if err != nil || version < 1 {
+        return 0, ErrInvalidTransitCiphertext
+    }
+    return version, nil
+}
\ No newline at end of file
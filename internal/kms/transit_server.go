@@ -0,0 +1,258 @@
+package kms
+
+import (
+    "crypto/rand"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strconv"
+    "sync"
+    "card-vault/internal/crypto"
+)
+
+// TransitServer es un sustituto local del secrets engine transit de Vault para
+// desarrollo y tests sin red. Cada clave con nombre es un keyring propio.
+type TransitServer struct {
+    token      string
+    newKeyring func(name string) (*crypto.KeyManager, error)
+    keys       map[string]*crypto.KeyManager
+    mu         sync.Mutex
+    mux        *http.ServeMux
+}
+
+// NewTransitServer crea el servidor. Si newKeyring es nil las claves viven solo en memoria.
+func NewTransitServer(token string, newKeyring func(name string) (*crypto.KeyManager, error)) *TransitServer {
+    if newKeyring == nil {
+        newKeyring = func(string) (*crypto.KeyManager, error) {
+            return crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+        }
+    }
+
+    s := &TransitServer{
+        token:      token,
+        newKeyring: newKeyring,
+        keys:       make(map[string]*crypto.KeyManager),
+        mux:        http.NewServeMux(),
+    }
+
+    s.mux.HandleFunc("POST /v1/transit/keys/{name}", s.handleCreateKey)
+    s.mux.HandleFunc("GET /v1/transit/keys/{name}", s.handleReadKey)
+    s.mux.HandleFunc("POST /v1/transit/keys/{name}/rotate", s.handleRotate)
+    s.mux.HandleFunc("POST /v1/transit/encrypt/{name}", s.handleEncrypt)
+    s.mux.HandleFunc("POST /v1/transit/decrypt/{name}", s.handleDecrypt)
+    s.mux.HandleFunc("POST /v1/transit/rewrap/{name}", s.handleRewrap)
+    s.mux.HandleFunc("POST /v1/transit/datakey/{type}/{name}", s.handleDataKey)
+
+    return s
+}
+
+func (s *TransitServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Vault-Token")), []byte(s.token)) != 1 {
+        writeTransitError(w, http.StatusForbidden, "permission denied")
+        return
+    }
+    s.mux.ServeHTTP(w, r)
+}
+
+func (s *TransitServer) keyring(name string) (*crypto.KeyManager, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if km, ok := s.keys[name]; ok {
+        return km, nil
+    }
+
+    km, err := s.newKeyring(name)
+    if err != nil {
+        return nil, err
+    }
+    s.keys[name] = km
+    return km, nil
+}
+
+func (s *TransitServer) handleCreateKey(w http.ResponseWriter, r *http.Request) {
+    if _, err := s.keyring(r.PathValue("name")); err != nil {
+        writeTransitError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *TransitServer) handleReadKey(w http.ResponseWriter, r *http.Request) {
+    km, err := s.keyring(r.PathValue("name"))
+    if err != nil {
+        writeTransitError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    versions := map[string]int64{}
+    for _, info := range km.ListKeys() {
+        versions[strconv.Itoa(info.Version)] = info.CreatedAt.Unix()
+    }
+    _, latest := km.GetCurrentKey()
+
+    writeTransitData(w, map[string]interface{}{
+        "name":           r.PathValue("name"),
+        "type":           "aes256-gcm96",
+        "latest_version": latest,
+        "keys":           versions,
+    })
+}
+
+func (s *TransitServer) handleRotate(w http.ResponseWriter, r *http.Request) {
+    km, err := s.keyring(r.PathValue("name"))
+    if err != nil {
+        writeTransitError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    if err := km.RotateKey(); err != nil {
+        writeTransitError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *TransitServer) handleEncrypt(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Plaintext string `json:"plaintext"`
+    }
+    if !decodeTransitRequest(w, r, &req) {
+        return
+    }
+
+    plaintext, err := base64.StdEncoding.DecodeString(req.Plaintext)
+    if err != nil {
+        writeTransitError(w, http.StatusBadRequest, "plaintext must be base64")
+        return
+    }
+
+    s.encrypt(w, r.PathValue("name"), plaintext, nil)
+}
+
+func (s *TransitServer) handleDecrypt(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Ciphertext string `json:"ciphertext"`
+    }
+    if !decodeTransitRequest(w, r, &req) {
+        return
+    }
+
+    plaintext, ok := s.decrypt(w, r.PathValue("name"), req.Ciphertext)
+    if !ok {
+        return
+    }
+
+    writeTransitData(w, map[string]interface{}{
+        "plaintext": base64.StdEncoding.EncodeToString(plaintext),
+    })
+}
+
+func (s *TransitServer) handleRewrap(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Ciphertext string `json:"ciphertext"`
+    }
+    if !decodeTransitRequest(w, r, &req) {
+        return
+    }
+
+    plaintext, ok := s.decrypt(w, r.PathValue("name"), req.Ciphertext)
+    if !ok {
+        return
+    }
+
+    s.encrypt(w, r.PathValue("name"), plaintext, nil)
+}
+
+func (s *TransitServer) handleDataKey(w http.ResponseWriter, r *http.Request) {
+    keyType := r.PathValue("type")
+    if keyType != "plaintext" && keyType != "wrapped" {
+        writeTransitError(w, http.StatusBadRequest, "data key type must be plaintext or wrapped")
+        return
+    }
+
+    dek := make([]byte, 32)
+    if _, err := rand.Read(dek); err != nil {
+        writeTransitError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    extra := map[string]interface{}{}
+    if keyType == "plaintext" {
+        extra["plaintext"] = base64.StdEncoding.EncodeToString(dek)
+    }
+
+    s.encrypt(w, r.PathValue("name"), dek, extra)
+}
+
+func (s *TransitServer) encrypt(w http.ResponseWriter, name string, plaintext []byte, extra map[string]interface{}) {
+    km, err := s.keyring(name)
+    if err != nil {
+        writeTransitError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    wrapped, version, err := km.WrapDataKey(plaintext)
+    if err != nil {
+        writeTransitError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    data := map[string]interface{}{
+        "ciphertext":  fmt.Sprintf("vault:v%d:%s", version, base64.StdEncoding.EncodeToString(wrapped)),
+        "key_version": version,
+    }
+    for k, v := range extra {
+        data[k] = v
+    }
+    writeTransitData(w, data)
+}
+
+func (s *TransitServer) decrypt(w http.ResponseWriter, name, ciphertext string) ([]byte, bool) {
+    version, err := ParseTransitVersion(ciphertext)
+    if err != nil {
+        writeTransitError(w, http.StatusBadRequest, err.Error())
+        return nil, false
+    }
+
+    km, err := s.keyring(name)
+    if err != nil {
+        writeTransitError(w, http.StatusInternalServerError, err.Error())
+        return nil, false
+    }
+
+    // ciphertext = "vault:vN:" + payload
+    payload, err := base64.StdEncoding.DecodeString(ciphertext[len(fmt.Sprintf("vault:v%d:", version)):])
+    if err != nil {
+        writeTransitError(w, http.StatusBadRequest, "invalid ciphertext encoding")
+        return nil, false
+    }
+
+    plaintext, err := km.UnwrapDataKey(payload, version)
+    if err != nil {
+        writeTransitError(w, http.StatusBadRequest, "cipher: message authentication failed")
+        return nil, false
+    }
+    return plaintext, true
+}
+
+func decodeTransitRequest(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+    if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+        writeTransitError(w, http.StatusBadRequest, "invalid request body")
+        return false
+    }
+    return true
+}
+
+func writeTransitData(w http.ResponseWriter, data map[string]interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func writeTransitError(w http.ResponseWriter, status int, msg string) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{msg}})
+}
\ No newline at end of file
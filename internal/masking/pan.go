@@ -0,0 +1,159 @@
+package masking
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+var ErrInvalidPolicy = errors.New("invalid masking policy")
+
+// Políticas con nombre. Ninguna muestra más de lo que permite PCI DSS: como mucho
+// los 6 u 8 primeros dígitos y los 4 últimos.
+const (
+    Last4     = "last4"
+    BIN6Last4 = "bin6_last4"
+    BIN8Last4 = "bin8_last4"
+    Full      = "full"
+)
+
+const (
+    // Dígitos que quedan siempre ocultos, aunque el PAN sea corto
+    minMaskedDigits = 4
+    // Por debajo de esta longitud PCI DSS solo permite mostrar los 6 primeros
+    bin8MinLength = 16
+    maxGroupSize  = 8
+)
+
+// Policy decide qué dígitos de un PAN se muestran en las respuestas.
+type Policy struct {
+    Name      string
+    Leading   int  // dígitos iniciales visibles (BIN)
+    Trailing  int  // dígitos finales visibles
+    MaskChar  rune // * si no se indica
+    GroupSize int  // separa el resultado en grupos de este tamaño; 0 no agrupa
+}
+
+var named = map[string]Policy{
+    Last4:     {Name: Last4, Trailing: 4},
+    BIN6Last4: {Name: BIN6Last4, Leading: 6, Trailing: 4},
+    BIN8Last4: {Name: BIN8Last4, Leading: 8, Trailing: 4},
+    Full:      {Name: Full},
+}
+
+// Default muestra solo los 4 últimos dígitos.
+var Default = named[Last4]
+
+// Parse lee una política: su nombre seguido opcionalmente de ";char=X" (carácter
+// de máscara) y ";group=N" (grupos de N caracteres separados por espacios). Por
+// ejemplo "bin6_last4;char=#;group=4".
+func Parse(spec string) (Policy, error) {
+    options := strings.Split(strings.TrimSpace(spec), ";")
+    policy, ok := named[strings.ToLower(strings.TrimSpace(options[0]))]
+    if !ok {
+        return Policy{}, fmt.Errorf("%w: unknown policy %q", ErrInvalidPolicy, options[0])
+    }
+
+    for _, option := range options[1:] {
+        key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+        switch key {
+        case "char":
+            r, size := utf8.DecodeRuneInString(value)
+            if size == 0 || size != len(value) || (r >= '0' && r <= '9') || r == ' ' || r == ',' || r == ';' {
+                return Policy{}, fmt.Errorf("%w: mask character must be a single non-digit character, got %q", ErrInvalidPolicy, value)
+            }
+            policy.MaskChar = r
+        case "group":
+            size, err := strconv.Atoi(value)
+            if err != nil || size < 0 || size > maxGroupSize {
+                return Policy{}, fmt.Errorf("%w: group size must be between 0 and %d, got %q", ErrInvalidPolicy, maxGroupSize, value)
+            }
+            policy.GroupSize = size
+        default:
+            return Policy{}, fmt.Errorf("%w: unknown option %q", ErrInvalidPolicy, key)
+        }
+    }
+    return policy, nil
+}
+
+// Mask enmascara un PAN según la política. Con PANs cortos se muestran menos
+// dígitos para que nunca queden menos de minMaskedDigits ocultos.
+func (p Policy) Mask(pan string) string {
+    if pan == "" {
+        return ""
+    }
+
+    leading, trailing := p.Leading, p.Trailing
+    if leading > 6 && len(pan) < bin8MinLength {
+        leading = 6
+    }
+    if excess := leading + trailing - (len(pan) - minMaskedDigits); excess > 0 {
+        cut := min(excess, leading)
+        leading -= cut
+        trailing = max(trailing-(excess-cut), 0)
+    }
+
+    maskChar := p.MaskChar
+    if maskChar == 0 {
+        maskChar = '*'
+    }
+
+    var b strings.Builder
+    for i, digit := range pan {
+        if p.GroupSize > 0 && i > 0 && i%p.GroupSize == 0 {
+            b.WriteByte(' ')
+        }
+        if i < leading || i >= len(pan)-trailing {
+            b.WriteRune(digit)
+        } else {
+            b.WriteRune(maskChar)
+        }
+    }
+    return b.String()
+}
+
+// Policies elige la política de cada llamante: la de su cliente de la API si
+// tiene una, si no la de su rol y, si tampoco, la de por defecto.
+type Policies struct {
+    Default Policy
+    Roles   map[string]Policy
+    Clients map[string]Policy
+}
+
+func (p Policies) Resolve(role, client string) Policy {
+    if policy, ok := p.Clients[client]; ok && client != "" {
+        return policy
+    }
+    if policy, ok := p.Roles[role]; ok && role != "" {
+        return policy
+    }
+    if p.Default.Name == "" {
+        return Default
+    }
+    return p.Default
+}
+
+// ParseAssignments lee una lista "nombre=política,..." como la de
+// PAN_MASKING_ROLES, p. ej. "support=last4,fraud=bin6_last4;group=4".
+func ParseAssignments(value string) (map[string]Policy, error) {
+    policies := make(map[string]Policy)
+    for _, entry := range strings.Split(value, ",") {
+        if strings.TrimSpace(entry) == "" {
+            continue
+        }
+        name, spec, ok := strings.Cut(entry, "=")
+        name = strings.TrimSpace(name)
+        if !ok || name == "" {
+            return nil, fmt.Errorf("%w: expected name=policy, got %q", ErrInvalidPolicy, entry)
+        }
+
+        policy, err := Parse(spec)
+        if err != nil {
+            return nil, err
+        }
+        policies[name] = policy
+    }
+    return policies, nil
+}
\ No newline at end of file
@@ -3,6 +3,7 @@ package middleware
 import (
     "net/http"
     "os"
+    "slices"
     "strings"
     "time"
 
@@ -11,8 +12,23 @@ import (
     "github.com/google/uuid"
 )
 
+const (
+    // ScopeDetokenize permite recuperar el PAN a partir de un token.
+    ScopeDetokenize = "tokens:detokenize"
+    // ScopeUseCVV permite leer (y consumir) el CVV temporal de una tarjeta.
+    ScopeUseCVV = "cards:cvv"
+    // ScopeRevealCard permite leer el PAN completo de una tarjeta, con un motivo auditado.
+    ScopeRevealCard = "cards:reveal"
+    // ScopeAdmin permite usar los endpoints administrativos (claves, rotaciones, borrado de usuarios).
+    ScopeAdmin = "admin"
+)
+
 type Claims struct {
-    UserID uuid.UUID `json:"user_id"`
+    UserID   uuid.UUID `json:"user_id"`
+    Scopes   []string  `json:"scopes,omitempty"`
+    // Rol del llamante y cliente de la API que emite la petición (p. ej. para el enmascarado)
+    Role     string    `json:"role,omitempty"`
+    ClientID string    `json:"client_id,omitempty"`
     jwt.RegisteredClaims
 }
 
@@ -46,17 +62,46 @@ func AuthMiddleware() gin.HandlerFunc {
         }
 
         c.Set("user_id", claims.UserID)
+        c.Set("scopes", claims.Scopes)
+        c.Set("role", claims.Role)
+        c.Set("client_id", claims.ClientID)
+        c.Next()
+    }
+}
+
+// RequireScope exige que el token autenticado incluya scope; va después de AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if !HasScope(c, scope) {
+            c.JSON(http.StatusForbidden, gin.H{"error": "Missing required scope: " + scope})
+            c.Abort()
+            return
+        }
         c.Next()
     }
 }
 
+// HasScope indica si el token autenticado incluye scope.
+func HasScope(c *gin.Context, scope string) bool {
+    scopes, _ := c.Get("scopes")
+    granted, _ := scopes.([]string)
+    return slices.Contains(granted, scope)
+}
+
 func GenerateToken(userID uuid.UUID) (string, error) {
-    claims := Claims{
-        UserID: userID,
-        RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
-        },
+    return GenerateTokenWithScopes(userID, nil)
+}
+
+// GenerateTokenWithScopes emite un token con permisos adicionales (p. ej. ScopeDetokenize).
+func GenerateTokenWithScopes(userID uuid.UUID, scopes []string) (string, error) {
+    return SignToken(Claims{UserID: userID, Scopes: scopes})
+}
+
+// SignToken emite un token con las claims indicadas, válido durante 24 horas.
+func SignToken(claims Claims) (string, error) {
+    claims.RegisteredClaims = jwt.RegisteredClaims{
+        ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+        IssuedAt:  jwt.NewNumericDate(time.Now()),
     }
 
     token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
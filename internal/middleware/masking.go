@@ -0,0 +1,16 @@
+package middleware
+
+import (
+    "card-vault/internal/masking"
+
+    "github.com/gin-gonic/gin"
+)
+
+// PANMasking elige la política de enmascarado del llamante según su cliente de la
+// API o su rol; va después de AuthMiddleware.
+func PANMasking(policies masking.Policies) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        c.Set("pan_masking", policies.Resolve(c.GetString("role"), c.GetString("client_id")))
+        c.Next()
+    }
+}
\ No newline at end of file
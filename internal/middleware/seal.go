@@ -0,0 +1,19 @@
+package middleware
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+)
+
+// RequireUnsealed rechaza con 503 las peticiones mientras el keyring esté sellado.
+func RequireUnsealed(sealed func() bool) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if sealed() {
+            c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Vault is sealed"})
+            c.Abort()
+            return
+        }
+        c.Next()
+    }
+}
\ No newline at end of file
@@ -0,0 +1,24 @@
+package models
+
+import (
+    "time"
+    "github.com/google/uuid"
+)
+
+const (
+    AuditActionShredUser  = "user.shred"
+    AuditActionRevealCard = "card.reveal"
+)
+
+// AuditRecord es una entrada del registro de auditoría de operaciones sensibles.
+// Solo se insertan; nunca se modifican ni se borran.
+type AuditRecord struct {
+    ID        uuid.UUID  `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+    Action    string     `json:"action" gorm:"not null;index"`
+    ActorID   uuid.UUID  `json:"actor_id" gorm:"type:uuid;not null"`
+    SubjectID uuid.UUID  `json:"subject_id" gorm:"type:uuid;not null;index"`
+    // Propietario del sujeto, p. ej. el de la tarjeta revelada, que no tiene por qué ser el actor
+    OwnerID   *uuid.UUID `json:"owner_id,omitempty" gorm:"type:uuid;index"`
+    Details   string     `json:"details,omitempty"`
+    CreatedAt time.Time  `json:"created_at"`
+}
\ No newline at end of file
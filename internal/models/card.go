@@ -2,43 +2,130 @@ package models
 
 import (
     "time"
+    "card-vault/internal/vault"
+
     "github.com/google/uuid"
 )
 
 type Card struct {
-    ID              uuid.UUID `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-    UserID          uuid.UUID `json:"user_id" gorm:"not null;index"`
-    CardholderName  string    `json:"cardholder_name" gorm:"not null" validate:"required,min=1,max=100"`
-    CardNumber      string    `json:"-" gorm:"not null"`
-    ExpiryMonth     int       `json:"expiry_month" validate:"required,min=1,max=12"`
-    ExpiryYear      int       `json:"expiry_year" validate:"required,min=2024"`
-    CVV             string    `json:"-" gorm:"not null"`
-    CardType        string    `json:"card_type" gorm:"not null"`
-    IsActive        bool      `json:"is_active" gorm:"default:true"`
-    KeyVersion      int       `json:"-" gorm:"not null;default:1"`
-    CreatedAt       time.Time `json:"created_at"`
-    UpdatedAt       time.Time `json:"updated_at"`
+    ID                   uuid.UUID   `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+    UserID               uuid.UUID   `json:"user_id" gorm:"not null;index;index:idx_cards_user_created,priority:1"`
+    CardholderName       vault.Field `json:"-" gorm:"type:bytea;serializer:vault" vault:"encrypt,purpose=cardholder_name"`
+    CardNumber           vault.Field `json:"-" gorm:"type:bytea;not null;serializer:vault" vault:"encrypt,purpose=pan"`
+    ExpiryMonth          int         `json:"expiry_month" validate:"required,min=1,max=12"`
+    ExpiryYear           int         `json:"expiry_year" validate:"required,min=1000,max=9999"`
+    CardType             string      `json:"card_type" gorm:"not null"`
+    Networks             []string    `json:"networks" gorm:"type:jsonb;serializer:json"`
+    PreferredNetwork     string      `json:"preferred_network"`
+    IssuerName           string      `json:"issuer_name"`
+    IssuerCountry        string      `json:"issuer_country" gorm:"size:2"`
+    FundingType          string      `json:"funding_type"`
+    CardSegment          string      `json:"card_segment"`
+    ProductLevel         string      `json:"product_level"`
+    BillingStreet        vault.Field `json:"-" gorm:"type:bytea;serializer:vault" vault:"encrypt,purpose=billing_street"`
+    BillingPostalCode    vault.Field `json:"-" gorm:"type:bytea;serializer:vault" vault:"encrypt,purpose=billing_postal_code"`
+    BillingCity          string      `json:"billing_city"`
+    BillingRegion        string      `json:"billing_region"`
+    BillingCountry       string      `json:"billing_country" gorm:"size:2"`
+    Status               CardStatus  `json:"status" gorm:"not null;default:active;index"`
+    StatusReason         string      `json:"status_reason"`
+    StatusChangedAt      *time.Time  `json:"status_changed_at"`
+    // Tarjeta que sustituye a esta; solo en las tarjetas replaced
+    ReplacedByID         *uuid.UUID  `json:"replaced_by_id" gorm:"type:uuid"`
+    WrappedDEK           []byte      `json:"-" gorm:"type:bytea"`
+    KeyProvider          string      `json:"-" gorm:"not null;default:local"`
+    KeyVersion           int         `json:"-" gorm:"not null;default:1"`
+    AADVersion           int         `json:"-" gorm:"column:aad_version;not null;default:0"`
+    UserKeyed            bool        `json:"-" gorm:"not null;default:false"`
+    PANFingerprint       []byte      `json:"-" gorm:"column:pan_fingerprint;type:bytea;index"`
+    FingerprintVersion   int         `json:"-" gorm:"not null;default:0"`
+    // Últimos 4 dígitos del PAN, en claro como permite PCI DSS, para filtrar sin descifrar
+    Last4                string      `json:"-" gorm:"column:last4;size:4;index"`
+    // Titular en claro de las tarjetas anteriores a su cifrado; se vacía al volver a cifrarlas
+    LegacyCardholderName string      `json:"-"`
+    CreatedAt            time.Time   `json:"created_at" gorm:"index:idx_cards_user_created,priority:2"`
+    UpdatedAt            time.Time   `json:"updated_at"`
 }
 
 type CardResponse struct {
-    ID             uuid.UUID `json:"id"`
-    UserID         uuid.UUID `json:"user_id"`
-    CardholderName string    `json:"cardholder_name"`
-    MaskedNumber   string    `json:"masked_number"`
-    ExpiryMonth    int       `json:"expiry_month"`
-    ExpiryYear     int       `json:"expiry_year"`
-    CardType       string    `json:"card_type"`
-    IsActive       bool      `json:"is_active"`
-    CreatedAt      time.Time `json:"created_at"`
-    UpdatedAt      time.Time `json:"updated_at"`
+    ID               uuid.UUID       `json:"id"`
+    UserID           uuid.UUID       `json:"user_id"`
+    CardholderName   string          `json:"cardholder_name"`
+    MaskedNumber     string          `json:"masked_number"`
+    ExpiryMonth      int             `json:"expiry_month"`
+    ExpiryYear       int             `json:"expiry_year"`
+    CardType         string          `json:"card_type"`
+    Networks         []string        `json:"networks"`
+    PreferredNetwork string          `json:"preferred_network,omitempty"`
+    IssuerName       string          `json:"issuer_name,omitempty"`
+    IssuerCountry    string          `json:"issuer_country,omitempty"`
+    FundingType      string          `json:"funding_type,omitempty"`
+    CardSegment      string          `json:"card_segment,omitempty"`
+    ProductLevel     string          `json:"product_level,omitempty"`
+    BillingAddress   *BillingAddress `json:"billing_address,omitempty"`
+    Status           CardStatus      `json:"status"`
+    StatusReason     string          `json:"status_reason,omitempty"`
+    StatusChangedAt  *time.Time      `json:"status_changed_at,omitempty"`
+    ReplacedByID     *uuid.UUID      `json:"replaced_by_id,omitempty"`
+    CVVPresent       bool            `json:"cvv_present"`
+    CVVExpiresAt     *time.Time      `json:"cvv_expires_at,omitempty"`
+    CreatedAt        time.Time       `json:"created_at"`
+    UpdatedAt        time.Time       `json:"updated_at"`
 }
 
 type CardRequest struct {
-    CardholderName string `json:"cardholder_name" validate:"required,min=1,max=100"`
-    CardNumber     string `json:"card_number" validate:"required,min=13,max=19,numeric"`
-    ExpiryMonth    int    `json:"expiry_month" validate:"required,min=1,max=12"`
-    ExpiryYear     int    `json:"expiry_year" validate:"required,min=2024"`
-    CVV            string `json:"cvv" validate:"required,min=3,max=4,numeric"`
+    CardholderName   string          `json:"cardholder_name" validate:"required,min=1,max=100"`
+    CardNumber       string          `json:"card_number" validate:"required,min=12,max=19,numeric"`
+    ExpiryMonth      int             `json:"expiry_month" validate:"required,min=1,max=12"`
+    ExpiryYear       int             `json:"expiry_year" validate:"required,min=1000,max=9999"`
+    CVV              string          `json:"cvv" validate:"omitempty,min=3,max=4,numeric"`
+    // Redes adicionales de una tarjeta co-badged y la preferida por el titular
+    Networks         []string        `json:"networks,omitempty" validate:"max=8"`
+    PreferredNetwork string          `json:"preferred_network,omitempty"`
+    // Dirección de facturación para AVS; si se omite al actualizar se conserva la guardada
+    BillingAddress   *BillingAddress `json:"billing_address,omitempty"`
+}
+
+// BillingAddress es la dirección de facturación que se comprueba en AVS. Las
+// líneas de la calle y el código postal se guardan cifrados.
+type BillingAddress struct {
+    Lines      []string `json:"lines" validate:"required,min=1,max=3,dive,required,max=100"`
+    City       string   `json:"city" validate:"required,max=100"`
+    Region     string   `json:"region,omitempty" validate:"max=100"`
+    PostalCode string   `json:"postal_code,omitempty" validate:"max=20"`
+    Country    string   `json:"country" validate:"required,len=2"`
+}
+
+// ReplaceCardRequest trae los datos de la tarjeta que sustituye a otra y el motivo.
+type ReplaceCardRequest struct {
+    CardRequest
+    Reason string `json:"reason" validate:"max=255"`
+}
+
+type PreferredNetworkRequest struct {
+    Network string `json:"network" validate:"required"`
+}
+
+// CVVRequest aporta un CVV nuevo a una tarjeta ya guardada, p. ej. para un cargo.
+type CVVRequest struct {
+    CVV string `json:"cvv" validate:"required,min=3,max=4,numeric"`
+}
+
+type CVVResponse struct {
+    CVV string `json:"cvv"`
+}
+
+// RevealResponse lleva el PAN completo de una tarjeta; el CVV solo si se pidió y aún se guarda.
+type RevealResponse struct {
+    CardID      uuid.UUID `json:"card_id"`
+    CardNumber  string    `json:"card_number"`
+    ExpiryMonth int       `json:"expiry_month"`
+    ExpiryYear  int       `json:"expiry_year"`
+    CVV         string    `json:"cvv,omitempty"`
+}
+
+type CardLookupRequest struct {
+    CardNumber string `json:"card_number" validate:"required,min=12,max=19,numeric"`
 }
 
 type BatchUpdateRequest struct {
@@ -49,7 +136,7 @@ type BatchCardUpdate struct {
     ID             uuid.UUID `json:"id" validate:"required"`
     CardholderName *string   `json:"cardholder_name,omitempty"`
     ExpiryMonth    *int      `json:"expiry_month,omitempty" validate:"omitempty,min=1,max=12"`
-    ExpiryYear     *int      `json:"expiry_year,omitempty" validate:"omitempty,min=2024"`
+    ExpiryYear     *int      `json:"expiry_year,omitempty" validate:"omitempty,min=1000,max=9999"`
 }
 
 type BatchUpdateResponse struct {
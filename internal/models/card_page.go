@@ -0,0 +1,70 @@
+package models
+
+import (
+    "github.com/google/uuid"
+)
+
+// Órdenes del listado de tarjetas
+const (
+    CardSortCreatedAt = "created_at"
+    CardSortExpiry    = "expiry"
+)
+
+// CardListQuery son los parámetros de GET /cards. Los filtros de estado y tipo
+// admiten varios valores separados por comas; los rangos de caducidad (YYYY-MM)
+// incluyen ambos extremos y los de creación (RFC 3339) excluyen el final.
+type CardListQuery struct {
+    CardType    string `form:"card_type"`
+    Status      string `form:"status"`
+    ExpiryFrom  string `form:"expiry_from"`
+    ExpiryTo    string `form:"expiry_to"`
+    CreatedFrom string `form:"created_from"`
+    CreatedTo   string `form:"created_to"`
+    Last4       string `form:"last4"`
+    Sort        string `form:"sort"`
+    Order       string `form:"order"`
+    Limit       int    `form:"limit"`
+    Cursor      string `form:"cursor"`
+}
+
+type CardPage struct {
+    Cards      []CardResponse `json:"cards"`
+    Pagination Pagination     `json:"pagination"`
+}
+
+type Pagination struct {
+    Limit      int       `json:"limit"`
+    Sort       string    `json:"sort"`
+    Order      string    `json:"order"`
+    HasMore    bool      `json:"has_more"`
+    NextCursor string    `json:"next_cursor,omitempty"`
+    Links      PageLinks `json:"links"`
+}
+
+type PageLinks struct {
+    Self string `json:"self"`
+    Next string `json:"next,omitempty"`
+}
+
+// PageQuery son los parámetros de paginación de los listados que solo admiten límite y cursor.
+type PageQuery struct {
+    Limit  int    `form:"limit"`
+    Cursor string `form:"cursor"`
+}
+
+// ExpiringCard es una tarjeta del informe de caducidades de todos los usuarios.
+// Solo lleva datos guardados en claro, de modo que no se descifra ninguna tarjeta.
+type ExpiringCard struct {
+    ID          uuid.UUID  `json:"id"`
+    UserID      uuid.UUID  `json:"user_id"`
+    Last4       string     `json:"last4,omitempty"`
+    CardType    string     `json:"card_type"`
+    ExpiryMonth int        `json:"expiry_month"`
+    ExpiryYear  int        `json:"expiry_year"`
+    Status      CardStatus `json:"status"`
+}
+
+type ExpiringCardPage struct {
+    Cards      []ExpiringCard `json:"cards"`
+    Pagination Pagination     `json:"pagination"`
+}
\ No newline at end of file
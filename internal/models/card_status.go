@@ -0,0 +1,33 @@
+package models
+
+import (
+    "time"
+    "github.com/google/uuid"
+)
+
+// CardStatus es el estado del ciclo de vida de una tarjeta. Solo las tarjetas
+// activas pueden revelarse, tokenizarse o usar su CVV.
+type CardStatus string
+
+const (
+    CardStatusActive   CardStatus = "active"
+    CardStatusFrozen   CardStatus = "frozen"
+    CardStatusExpired  CardStatus = "expired"
+    CardStatusReplaced CardStatus = "replaced"
+    CardStatusClosed   CardStatus = "closed"
+)
+
+// CardStatusEvent es una entrada del historial de estados de una tarjeta. Solo
+// se insertan; el estado actual se guarda también en la propia tarjeta.
+type CardStatusEvent struct {
+    ID         uuid.UUID  `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+    CardID     uuid.UUID  `json:"card_id" gorm:"type:uuid;not null;index"`
+    FromStatus CardStatus `json:"from_status" gorm:"not null"`
+    ToStatus   CardStatus `json:"to_status" gorm:"not null"`
+    Reason     string     `json:"reason,omitempty"`
+    CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CardStatusRequest struct {
+    Reason string `json:"reason" validate:"max=255"`
+}
\ No newline at end of file
@@ -0,0 +1,37 @@
+package models
+
+import (
+    "time"
+    "github.com/google/uuid"
+)
+
+// CardToken registra un token aleatorio: la tarjeta a la que sustituye y el tweak,
+// la versión de clave y el formato con que se emitió, para revertirlo aunque la
+// configuración cambie después. Los tokens deterministas no se guardan.
+type CardToken struct {
+    Token         string    `json:"token" gorm:"primaryKey"`
+    CardID        uuid.UUID `json:"card_id" gorm:"type:uuid;not null;index"`
+    UserID        uuid.UUID `json:"user_id" gorm:"type:uuid;not null"`
+    KeyVersion    int       `json:"-" gorm:"not null"`
+    Tweak         []byte    `json:"-" gorm:"type:bytea;not null"`
+    Mode          string    `json:"-" gorm:"not null;default:''"`
+    PreserveBIN   bool      `json:"-" gorm:"not null;default:false"`
+    PreserveLast4 bool      `json:"-" gorm:"not null;default:false"`
+    CreatedAt     time.Time `json:"created_at"`
+}
+
+type TokenResponse struct {
+    Token   string    `json:"token"`
+    Mode    string    `json:"mode"`
+    CardID  uuid.UUID `json:"card_id"`
+    Network string    `json:"network,omitempty"`
+}
+
+type DetokenizeRequest struct {
+    Token string `json:"token" validate:"required,min=12,max=19,numeric"`
+}
+
+type DetokenizeResponse struct {
+    CardNumber string      `json:"card_number"`
+    CardIDs    []uuid.UUID `json:"card_ids"`
+}
\ No newline at end of file
@@ -0,0 +1,33 @@
+package models
+
+import (
+    "time"
+    "github.com/google/uuid"
+)
+
+const (
+    RotationJobRunning   = "running"
+    RotationJobCompleted = "completed"
+    RotationJobFailed    = "failed"
+)
+
+// RotationJob guarda el progreso de una rotación de claves. LastCardID es el
+// checkpoint: las tarjetas se recorren por ID y la rotación se reanuda a partir de él.
+// Solo puede haber un trabajo en curso a la vez, y lo ejecuta la réplica Owner
+// mientras no venza su LeaseExpiresAt.
+type RotationJob struct {
+    ID             uuid.UUID  `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+    Status         string     `json:"status" gorm:"not null;index:idx_rotation_jobs_running,unique,where:status = 'running'"`
+    KeyProvider    string     `json:"key_provider" gorm:"not null"`
+    KeyRotated     bool       `json:"key_rotated" gorm:"not null;default:false"`
+    TargetVersion  int        `json:"target_version,omitempty" gorm:"not null;default:0"`
+    Owner          string     `json:"owner,omitempty"`
+    LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+    LastCardID     uuid.UUID  `json:"last_card_id" gorm:"type:uuid"`
+    ProcessedCards int        `json:"processed_cards" gorm:"not null;default:0"`
+    FailedCards    int        `json:"failed_cards" gorm:"not null;default:0"`
+    LastError      string     `json:"last_error,omitempty"`
+    CreatedAt      time.Time  `json:"created_at"`
+    UpdatedAt      time.Time  `json:"updated_at"`
+    CompletedAt    *time.Time `json:"completed_at,omitempty"`
+}
\ No newline at end of file
@@ -0,0 +1,31 @@
+package repository
+
+import (
+    "card-vault/internal/models"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+// AuditRepository es de solo inserción: el registro de auditoría no se edita.
+type AuditRepository interface {
+    Create(record *models.AuditRecord) error
+    GetBySubject(subjectID uuid.UUID) ([]models.AuditRecord, error)
+}
+
+type auditRepository struct {
+    db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+    return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Create(record *models.AuditRecord) error {
+    return r.db.Create(record).Error
+}
+
+func (r *auditRepository) GetBySubject(subjectID uuid.UUID) ([]models.AuditRecord, error) {
+    var records []models.AuditRecord
+    err := r.db.Where("subject_id = ?", subjectID).Order("created_at").Find(&records).Error
+    return records, err
+}
\ No newline at end of file
@@ -1,7 +1,11 @@
 package repository
 
 import (
+    "context"
+    "fmt"
+    "time"
     "card-vault/internal/models"
+    "card-vault/internal/vault"
     "github.com/google/uuid"
     "gorm.io/gorm"
 )
@@ -15,14 +19,72 @@ type CardRepository interface {
     BatchUpdate(cards []models.Card) error
     GetAllCards() ([]models.Card, error)
     UpdateKeyVersion(cardID uuid.UUID, version int) error
+    CountByKeyVersion(providers []string, version int) (int64, error)
+    GetAllByAADVersion(version int) ([]models.Card, error)
+    GetPageAfter(afterID uuid.UUID, limit int) ([]models.Card, error)
+    FindByID(id uuid.UUID) (*models.Card, error)
+    UpdateDetails(card *models.Card) error
+    SwapKeyMaterial(card *models.Card, expectedDEK []byte) (bool, error)
+    DeleteByUserID(userID uuid.UUID) (int64, error)
+    ListIDsByUserID(userID uuid.UUID) ([]uuid.UUID, error)
+    FindByFingerprints(fingerprints [][]byte) ([]models.Card, error)
+    UpdateFingerprint(card *models.Card) (bool, error)
+    CountByFingerprintVersion(version int) (int64, error)
+    UpdatePreferredNetwork(card *models.Card, status models.CardStatus) (bool, error)
+    UpdateStatus(card *models.Card, from models.CardStatus, event *models.CardStatusEvent) (bool, error)
+    UpdateIfStatus(card *models.Card, status models.CardStatus) (bool, error)
+    GetStatusHistory(cardID uuid.UUID) ([]models.CardStatusEvent, error)
+    GetByExpiryRange(userID uuid.UUID, fromMonth, toMonth int) ([]models.Card, error)
+    ListExpiring(fromMonth, toMonth int, after *CardCursor, limit int) ([]models.Card, error)
+    ListByUserID(userID uuid.UUID, filter CardListFilter) ([]models.Card, error)
+    WithKeys(keys vault.Keys) CardRepository
+}
+
+// CardListFilter selecciona y ordena una página de las tarjetas de un usuario.
+// Los valores cero no filtran; los meses se numeran como año*12 + mes-1.
+type CardListFilter struct {
+    CardTypes   []string
+    Statuses    []models.CardStatus
+    ExpiryFrom  int // inclusive
+    ExpiryTo    int // exclusive
+    CreatedFrom time.Time
+    CreatedTo   time.Time
+    Last4       string
+    Sort        string // models.CardSortCreatedAt o models.CardSortExpiry
+    Descending  bool
+    // Última tarjeta de la página anterior; se devuelven las que van detrás
+    After *CardCursor
+    Limit int
+}
+
+// CardCursor es la posición de una tarjeta en el orden del listado.
+type CardCursor struct {
+    ID        uuid.UUID
+    CreatedAt time.Time
+    Expiry    int
+}
+
+var cardSortColumns = map[string]string{
+    models.CardSortCreatedAt: "created_at",
+    models.CardSortExpiry:    "expiry_year * 12 + expiry_month - 1",
 }
 
 type cardRepository struct {
-    db *gorm.DB
+    db  *gorm.DB
+    // Sin Keys en el contexto: lee las tarjetas sin abrir sus campos cifrados
+    raw *gorm.DB
 }
 
 func NewCardRepository(db *gorm.DB) CardRepository {
-    return &cardRepository{db: db}
+    return &cardRepository{db: db, raw: db}
+}
+
+// WithKeys devuelve el repositorio sobre la misma conexión con keys en el contexto
+// de sus sentencias: los callbacks de vault sellan con ellas las tarjetas al
+// guardarlas y las abren al leerlas. Las lecturas que no necesitan los datos
+// cifrados (por huella, por páginas o solo columnas en claro) no las abren.
+func (r *cardRepository) WithKeys(keys vault.Keys) CardRepository {
+    return &cardRepository{db: r.raw.WithContext(vault.WithKeys(context.Background(), keys)), raw: r.raw}
 }
 
 func (r *cardRepository) Create(card *models.Card) error {
@@ -75,4 +137,255 @@ func (r *cardRepository) GetAllCards() ([]models.Card, error) {
 
 func (r *cardRepository) UpdateKeyVersion(cardID uuid.UUID, version int) error {
     return r.db.Model(&models.Card{}).Where("id = ?", cardID).Update("key_version", version).Error
+}
+
+// CountByKeyVersion cuenta las tarjetas cuya DEK envolvió uno de los proveedores
+// indicados con esa versión: cada proveedor numera sus versiones por separado.
+func (r *cardRepository) CountByKeyVersion(providers []string, version int) (int64, error) {
+    var count int64
+    err := r.db.Model(&models.Card{}).Where("key_provider IN ? AND key_version = ?", providers, version).Count(&count).Error
+    return count, err
+}
+
+func (r *cardRepository) GetAllByAADVersion(version int) ([]models.Card, error) {
+    var cards []models.Card
+    err := r.db.Where("aad_version = ?", version).Find(&cards).Error
+    return cards, err
+}
+
+// GetPageAfter devuelve hasta limit tarjetas con ID mayor que afterID, ordenadas
+// por ID, para recorrer la tabla por páginas sin cargarla entera. No las abre.
+func (r *cardRepository) GetPageAfter(afterID uuid.UUID, limit int) ([]models.Card, error) {
+    var cards []models.Card
+    err := r.raw.Where("id > ?", afterID).Order("id").Limit(limit).Find(&cards).Error
+    return cards, err
+}
+
+// FindByID busca una tarjeta sin filtrar por usuario (uso interno del servicio).
+func (r *cardRepository) FindByID(id uuid.UUID) (*models.Card, error) {
+    var card models.Card
+    err := r.db.Where("id = ?", id).First(&card).Error
+    return &card, err
+}
+
+// UpdateDetails guarda solo los datos no cifrados, sin pisar el material de
+// clave que pueda haber cambiado una rotación concurrente.
+func (r *cardRepository) UpdateDetails(card *models.Card) error {
+    return r.db.Model(card).
+        Select("expiry_month", "expiry_year", "updated_at").
+        Updates(card).Error
+}
+
+// keyMaterialFields son los campos que cambian al volver a cifrar una tarjeta,
+// además de los marcados como cifrados en el modelo.
+var keyMaterialFields = []string{"WrappedDEK", "KeyProvider", "KeyVersion", "AADVersion", "UserKeyed", "LegacyCardholderName", "UpdatedAt"}
+
+// SwapKeyMaterial guarda los campos cifrados y la DEK de la tarjeta solo si la
+// DEK guardada sigue siendo expectedDEK (compare-and-swap). Devuelve false si otra
+// escritura la cambió entretanto; en ese caso no se modifica nada.
+func (r *cardRepository) SwapKeyMaterial(card *models.Card, expectedDEK []byte) (bool, error) {
+    fields, err := vault.Fields(card)
+    if err != nil {
+        return false, err
+    }
+
+    result := whereWrappedDEK(r.db.Model(card), expectedDEK).Select(append(fields, keyMaterialFields...)).Updates(card)
+    return result.RowsAffected == 1, result.Error
+}
+
+// whereWrappedDEK filtra por la DEK envuelta guardada. Las tarjetas anteriores a
+// las DEKs la tienen a NULL, que no coincide con ningún "wrapped_dek = ?".
+func whereWrappedDEK(query *gorm.DB, wrappedDEK []byte) *gorm.DB {
+    if len(wrappedDEK) == 0 {
+        return query.Where("wrapped_dek IS NULL OR octet_length(wrapped_dek) = 0")
+    }
+    return query.Where("wrapped_dek = ?", wrappedDEK)
+}
+
+// DeleteByUserID borra todas las tarjetas de un usuario y devuelve cuántas había.
+func (r *cardRepository) DeleteByUserID(userID uuid.UUID) (int64, error) {
+    result := r.db.Where("user_id = ?", userID).Delete(&models.Card{})
+    return result.RowsAffected, result.Error
+}
+
+// ListIDsByUserID devuelve los IDs de las tarjetas de un usuario sin leer nada más.
+func (r *cardRepository) ListIDsByUserID(userID uuid.UUID) ([]uuid.UUID, error) {
+    var ids []uuid.UUID
+    err := r.raw.Model(&models.Card{}).Where("user_id = ?", userID).Pluck("id", &ids).Error
+    return ids, err
+}
+
+// FindByFingerprints devuelve las tarjetas de cualquier usuario cuya huella de PAN
+// sea una de las indicadas, sin abrirlas.
+func (r *cardRepository) FindByFingerprints(fingerprints [][]byte) ([]models.Card, error) {
+    var cards []models.Card
+    if len(fingerprints) == 0 {
+        return cards, nil
+    }
+    err := r.raw.Where("pan_fingerprint IN ?", fingerprints).Find(&cards).Error
+    return cards, err
+}
+
+// UpdateFingerprint guarda la huella y los últimos 4 dígitos de la tarjeta solo si
+// su DEK no ha cambiado desde que se leyó; si cambió, el PAN es otro y la escritura
+// ya calculó su huella.
+func (r *cardRepository) UpdateFingerprint(card *models.Card) (bool, error) {
+    result := whereWrappedDEK(r.db.Model(&models.Card{}).Where("id = ?", card.ID), card.WrappedDEK).
+        Updates(map[string]interface{}{
+            "pan_fingerprint":     card.PANFingerprint,
+            "fingerprint_version": card.FingerprintVersion,
+            "last4":               card.Last4,
+        })
+    return result.RowsAffected == 1, result.Error
+}
+
+func (r *cardRepository) CountByFingerprintVersion(version int) (int64, error) {
+    var count int64
+    err := r.db.Model(&models.Card{}).Where("fingerprint_version = ?", version).Count(&count).Error
+    return count, err
+}
+
+// UpdatePreferredNetwork guarda solo la red preferida, sin tocar el material de clave.
+// Como UpdateIfStatus, solo escribe si el estado guardado sigue siendo status.
+func (r *cardRepository) UpdatePreferredNetwork(card *models.Card, status models.CardStatus) (bool, error) {
+    result := r.db.Model(&models.Card{}).
+        Where("id = ? AND status = ?", card.ID, status).
+        Updates(map[string]interface{}{
+            "preferred_network": card.PreferredNetwork,
+            "updated_at":        time.Now(),
+        })
+    return result.RowsAffected == 1, result.Error
+}
+
+// UpdateStatus guarda el estado de la tarjeta solo si el guardado sigue siendo
+// from, y registra event en el historial en la misma transacción. Devuelve false
+// si otra escritura cambió el estado entretanto; en ese caso no se modifica nada.
+func (r *cardRepository) UpdateStatus(card *models.Card, from models.CardStatus, event *models.CardStatusEvent) (bool, error) {
+    updated := false
+    values := map[string]interface{}{
+        "status":            card.Status,
+        "status_reason":     card.StatusReason,
+        "status_changed_at": card.StatusChangedAt,
+        "updated_at":        time.Now(),
+    }
+    // El enlace a la sustituta se escribe al pasar a replaced y ya no cambia
+    if card.ReplacedByID != nil {
+        values["replaced_by_id"] = card.ReplacedByID
+    }
+
+    err := r.db.Transaction(func(tx *gorm.DB) error {
+        result := tx.Model(&models.Card{}).
+            Where("id = ? AND status = ?", card.ID, from).
+            Updates(values)
+        if result.Error != nil || result.RowsAffected != 1 {
+            return result.Error
+        }
+
+        updated = true
+        return tx.Create(event).Error
+    })
+    return updated && err == nil, err
+}
+
+// statusColumns solo se escriben con UpdateStatus, que además registra el cambio.
+var statusColumns = []string{"status", "status_reason", "status_changed_at", "replaced_by_id"}
+
+// UpdateIfStatus guarda todos los campos de la tarjeta salvo su estado, y solo si
+// el estado guardado sigue siendo status. Devuelve false si otra escritura lo
+// cambió entretanto; en ese caso no se modifica nada.
+func (r *cardRepository) UpdateIfStatus(card *models.Card, status models.CardStatus) (bool, error) {
+    result := r.db.Model(card).
+        Where("status = ?", status).
+        Select("*").
+        Omit(statusColumns...).
+        Updates(card)
+    return result.RowsAffected == 1, result.Error
+}
+
+// GetStatusHistory devuelve los cambios de estado de una tarjeta, del más antiguo al más reciente.
+func (r *cardRepository) GetStatusHistory(cardID uuid.UUID) ([]models.CardStatusEvent, error) {
+    var events []models.CardStatusEvent
+    err := r.db.Where("card_id = ?", cardID).Order("created_at").Find(&events).Error
+    return events, err
+}
+
+// GetByExpiryRange devuelve las tarjetas activas o bloqueadas cuyo mes de caducidad,
+// numerado como año*12 + mes-1, está en [fromMonth, toMonth). Con userID uuid.Nil
+// busca en las de todos los usuarios.
+func (r *cardRepository) GetByExpiryRange(userID uuid.UUID, fromMonth, toMonth int) ([]models.Card, error) {
+    query := r.db.Where("status IN ?", []models.CardStatus{models.CardStatusActive, models.CardStatusFrozen}).
+        Where("expiry_year * 12 + expiry_month - 1 >= ? AND expiry_year * 12 + expiry_month - 1 < ?", fromMonth, toMonth)
+    if userID != uuid.Nil {
+        query = query.Where("user_id = ?", userID)
+    }
+
+    var cards []models.Card
+    err := query.Order("expiry_year, expiry_month, id").Find(&cards).Error
+    return cards, err
+}
+
+// ListExpiring devuelve una página de las tarjetas activas o bloqueadas de todos los
+// usuarios cuyo mes de caducidad está en [fromMonth, toMonth), ordenadas por
+// caducidad e ID y a partir de after. Solo carga las columnas en claro.
+func (r *cardRepository) ListExpiring(fromMonth, toMonth int, after *CardCursor, limit int) ([]models.Card, error) {
+    expiry := cardSortColumns[models.CardSortExpiry]
+    query := r.raw.Select("id", "user_id", "last4", "card_type", "expiry_month", "expiry_year", "status").
+        Where("status IN ?", []models.CardStatus{models.CardStatusActive, models.CardStatusFrozen}).
+        Where(fmt.Sprintf("%s >= ? AND %s < ?", expiry, expiry), fromMonth, toMonth)
+    if after != nil {
+        query = query.Where(fmt.Sprintf("(%s, id) > (?, ?)", expiry), after.Expiry, after.ID)
+    }
+
+    var cards []models.Card
+    err := query.Order(fmt.Sprintf("%s, id", expiry)).Limit(limit).Find(&cards).Error
+    return cards, err
+}
+
+// ListByUserID devuelve una página de las tarjetas del usuario con paginación por
+// cursor: filtra y ordena en la base de datos por la columna elegida y el ID, y
+// continúa tras filter.After sin recorrer las páginas anteriores.
+func (r *cardRepository) ListByUserID(userID uuid.UUID, filter CardListFilter) ([]models.Card, error) {
+    column, ok := cardSortColumns[filter.Sort]
+    if !ok {
+        return nil, fmt.Errorf("unknown card sort %q", filter.Sort)
+    }
+
+    query := r.db.Where("user_id = ?", userID)
+    if len(filter.CardTypes) > 0 {
+        query = query.Where("card_type IN ?", filter.CardTypes)
+    }
+    if len(filter.Statuses) > 0 {
+        query = query.Where("status IN ?", filter.Statuses)
+    }
+    if filter.ExpiryFrom != 0 {
+        query = query.Where("expiry_year * 12 + expiry_month - 1 >= ?", filter.ExpiryFrom)
+    }
+    if filter.ExpiryTo != 0 {
+        query = query.Where("expiry_year * 12 + expiry_month - 1 < ?", filter.ExpiryTo)
+    }
+    if !filter.CreatedFrom.IsZero() {
+        query = query.Where("created_at >= ?", filter.CreatedFrom)
+    }
+    if !filter.CreatedTo.IsZero() {
+        query = query.Where("created_at < ?", filter.CreatedTo)
+    }
+    if filter.Last4 != "" {
+        query = query.Where("last4 = ?", filter.Last4)
+    }
+
+    direction, compare := "ASC", ">"
+    if filter.Descending {
+        direction, compare = "DESC", "<"
+    }
+    if after := filter.After; after != nil {
+        var value interface{} = after.CreatedAt
+        if filter.Sort == models.CardSortExpiry {
+            value = after.Expiry
+        }
+        query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, compare), value, after.ID)
+    }
+
+    var cards []models.Card
+    err := query.Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).Limit(filter.Limit).Find(&cards).Error
+    return cards, err
 }
\ No newline at end of file
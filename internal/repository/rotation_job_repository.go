@@ -0,0 +1,57 @@
+package repository
+
+import (
+    "time"
+    "card-vault/internal/models"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+type RotationJobRepository interface {
+    Create(job *models.RotationJob) error
+    GetByID(id uuid.UUID) (*models.RotationJob, error)
+    GetByStatus(status string) ([]models.RotationJob, error)
+    Claim(id uuid.UUID, owner string, until time.Time) (bool, error)
+    Checkpoint(job *models.RotationJob) (bool, error)
+}
+
+type rotationJobRepository struct {
+    db *gorm.DB
+}
+
+func NewRotationJobRepository(db *gorm.DB) RotationJobRepository {
+    return &rotationJobRepository{db: db}
+}
+
+func (r *rotationJobRepository) Create(job *models.RotationJob) error {
+    return r.db.Create(job).Error
+}
+
+func (r *rotationJobRepository) GetByID(id uuid.UUID) (*models.RotationJob, error) {
+    var job models.RotationJob
+    err := r.db.Where("id = ?", id).First(&job).Error
+    return &job, err
+}
+
+func (r *rotationJobRepository) GetByStatus(status string) ([]models.RotationJob, error) {
+    var jobs []models.RotationJob
+    err := r.db.Where("status = ?", status).Order("created_at").Find(&jobs).Error
+    return jobs, err
+}
+
+// Claim asigna a owner un trabajo en curso cuyo lease ha vencido (o que nunca lo
+// tuvo). Devuelve false si otra réplica lo tiene o si ya terminó.
+func (r *rotationJobRepository) Claim(id uuid.UUID, owner string, until time.Time) (bool, error) {
+    result := r.db.Model(&models.RotationJob{}).
+        Where("id = ? AND status = ?", id, models.RotationJobRunning).
+        Where("lease_expires_at IS NULL OR lease_expires_at < ?", time.Now()).
+        Updates(map[string]interface{}{"owner": owner, "lease_expires_at": until})
+    return result.RowsAffected == 1, result.Error
+}
+
+// Checkpoint guarda el trabajo solo si sigue siendo de job.Owner. Devuelve false
+// si otra réplica lo reclamó tras vencer el lease.
+func (r *rotationJobRepository) Checkpoint(job *models.RotationJob) (bool, error) {
+    result := r.db.Model(job).Where("owner = ?", job.Owner).Select("*").Updates(job)
+    return result.RowsAffected == 1, result.Error
+}
\ No newline at end of file
@@ -0,0 +1,40 @@
+package repository
+
+import (
+    "card-vault/internal/models"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+type TokenRepository interface {
+    Create(token *models.CardToken) error
+    GetByToken(token string) (*models.CardToken, error)
+    DeleteByCardIDs(cardIDs []uuid.UUID) (int64, error)
+}
+
+type tokenRepository struct {
+    db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) TokenRepository {
+    return &tokenRepository{db: db}
+}
+
+func (r *tokenRepository) Create(token *models.CardToken) error {
+    return r.db.Create(token).Error
+}
+
+func (r *tokenRepository) GetByToken(token string) (*models.CardToken, error) {
+    var record models.CardToken
+    err := r.db.Where("token = ?", token).First(&record).Error
+    return &record, err
+}
+
+// DeleteByCardIDs borra los tokens emitidos para esas tarjetas y devuelve cuántos había.
+func (r *tokenRepository) DeleteByCardIDs(cardIDs []uuid.UUID) (int64, error) {
+    if len(cardIDs) == 0 {
+        return 0, nil
+    }
+    result := r.db.Where("card_id IN ?", cardIDs).Delete(&models.CardToken{})
+    return result.RowsAffected, result.Error
+}
\ No newline at end of file
@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// CronSchedule es una expresión cron de cinco campos (minuto, hora, día del mes,
+// mes, día de la semana). Admite *, valores, rangos a-b, pasos */n y a-b/n, listas
+// separadas por comas y las abreviaturas @hourly, @daily, @weekly, @monthly y @yearly.
+type CronSchedule struct {
+    expr                            string
+    minute, hour, dom, month, dow   uint64
+    domRestricted, dowRestricted    bool
+}
+
+var cronMacros = map[string]string{
+    "@hourly":   "0 * * * *",
+    "@daily":    "0 0 * * *",
+    "@midnight": "0 0 * * *",
+    "@weekly":   "0 0 * * 0",
+    "@monthly":  "0 0 1 * *",
+    "@yearly":   "0 0 1 1 *",
+    "@annually": "0 0 1 1 *",
+}
+
+type cronField struct {
+    name     string
+    min, max int
+}
+
+var cronFields = [5]cronField{
+    {"minute", 0, 59},
+    {"hour", 0, 23},
+    {"day of month", 1, 31},
+    {"month", 1, 12},
+    {"day of week", 0, 7},
+}
+
+func ParseCron(expr string) (*CronSchedule, error) {
+    spec := strings.TrimSpace(expr)
+    if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+        spec = macro
+    }
+
+    parts := strings.Fields(spec)
+    if len(parts) != len(cronFields) {
+        return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields", expr)
+    }
+
+    var bits [5]uint64
+    for i, part := range parts {
+        b, err := parseCronField(part, cronFields[i])
+        if err != nil {
+            return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+        }
+        bits[i] = b
+    }
+
+    // 7 también es domingo
+    if bits[4]&(1<<7) != 0 {
+        bits[4] = bits[4]&^(1<<7) | 1
+    }
+
+    return &CronSchedule{
+        expr:          expr,
+        minute:        bits[0],
+        hour:          bits[1],
+        dom:           bits[2],
+        month:         bits[3],
+        dow:           bits[4],
+        domRestricted: !strings.HasPrefix(parts[2], "*"),
+        dowRestricted: !strings.HasPrefix(parts[4], "*"),
+    }, nil
+}
+
+func parseCronField(field string, spec cronField) (uint64, error) {
+    var bits uint64
+    for _, item := range strings.Split(field, ",") {
+        rangePart, step := item, 1
+        if i := strings.Index(item, "/"); i >= 0 {
+            n, err := strconv.Atoi(item[i+1:])
+            if err != nil || n < 1 {
+                return 0, fmt.Errorf("invalid step in %s field %q", spec.name, item)
+            }
+            rangePart, step = item[:i], n
+        }
+
+        lo, hi := spec.min, spec.max
+        if rangePart != "*" {
+            bounds := strings.SplitN(rangePart, "-", 2)
+            var err error
+            if lo, err = strconv.Atoi(bounds[0]); err != nil {
+                return 0, fmt.Errorf("invalid value in %s field %q", spec.name, item)
+            }
+            hi = lo
+            if len(bounds) == 2 {
+                if hi, err = strconv.Atoi(bounds[1]); err != nil {
+                    return 0, fmt.Errorf("invalid value in %s field %q", spec.name, item)
+                }
+            } else if step > 1 {
+                // "a/n" equivale a "a-max/n"
+                hi = spec.max
+            }
+        }
+
+        if lo < spec.min || hi > spec.max || lo > hi {
+            return 0, fmt.Errorf("%s field %q out of range %d-%d", spec.name, item, spec.min, spec.max)
+        }
+        for v := lo; v <= hi; v += step {
+            bits |= 1 << uint(v)
+        }
+    }
+    return bits, nil
+}
+
+func (c *CronSchedule) String() string {
+    return c.expr
+}
+
+// Next devuelve el primer instante estrictamente posterior a after que cumple la
+// expresión, en la zona horaria de after. Devuelve el instante cero si no hay
+// ninguno en los próximos cinco años (p.ej. "0 0 30 2 *").
+func (c *CronSchedule) Next(after time.Time) time.Time {
+    t := after.Truncate(time.Minute).Add(time.Minute)
+    limit := t.AddDate(5, 0, 0)
+
+    for t.Before(limit) {
+        if c.month&(1<<uint(t.Month())) == 0 {
+            t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+            continue
+        }
+        if !c.dayMatches(t) {
+            t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+            continue
+        }
+        if c.hour&(1<<uint(t.Hour())) == 0 {
+            t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+            continue
+        }
+        if c.minute&(1<<uint(t.Minute())) == 0 {
+            t = t.Add(time.Minute)
+            continue
+        }
+        return t
+    }
+    return time.Time{}
+}
+
+// dayMatches sigue la semántica clásica de cron: si se restringen tanto el día
+// del mes como el de la semana, basta con que coincida uno de los dos.
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+    domMatch := c.dom&(1<<uint(t.Day())) != 0
+    dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+
+    if c.domRestricted && c.dowRestricted {
+        return domMatch || dowMatch
+    }
+    return domMatch && dowMatch
+}
\ No newline at end of file
@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+    "context"
+    "log"
+    "sync"
+    "time"
+    "card-vault/internal/service"
+)
+
+// Expirer marca como caducadas las tarjetas vencidas (lo implementa service.CardService).
+type Expirer interface {
+    ExpireCards(now time.Time) (*service.ExpiryResult, error)
+}
+
+type ExpiryStatus struct {
+    Enabled bool                  `json:"enabled"`
+    Cron    string                `json:"cron,omitempty"`
+    NextRun *time.Time            `json:"next_run,omitempty"`
+    LastRun *service.ExpiryResult `json:"last_run,omitempty"`
+    Error   string                `json:"error,omitempty"`
+}
+
+// ExpiryScheduler ejecuta el trabajo de caducidad según una expresión cron (una
+// vez al día por defecto) y además al arrancar, para no perder ejecuciones si el
+// proceso estaba parado a la hora prevista.
+type ExpiryScheduler struct {
+    schedule      *CronSchedule
+    expirer       Expirer
+    checkInterval time.Duration
+
+    next    time.Time
+    lastRun *service.ExpiryResult
+    lastErr string
+    mu      sync.Mutex
+}
+
+// NewExpiryScheduler crea el planificador; con schedule nil queda deshabilitado,
+// aunque Run sigue pudiendo lanzarse a mano.
+func NewExpiryScheduler(schedule *CronSchedule, expirer Expirer, checkInterval time.Duration) *ExpiryScheduler {
+    if checkInterval <= 0 {
+        checkInterval = time.Minute
+    }
+
+    s := &ExpiryScheduler{schedule: schedule, expirer: expirer, checkInterval: checkInterval}
+    if schedule != nil {
+        s.next = schedule.Next(time.Now())
+    }
+    return s
+}
+
+// Start ejecuta el trabajo ahora y en cada instante previsto hasta que se cancele ctx.
+func (s *ExpiryScheduler) Start(ctx context.Context) {
+    if s.schedule == nil {
+        return
+    }
+
+    go func() {
+        ticker := time.NewTicker(s.checkInterval)
+        defer ticker.Stop()
+
+        s.Run(time.Now())
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case now := <-ticker.C:
+                s.Check(now)
+            }
+        }
+    }()
+}
+
+// Check lanza el trabajo si en now ya ha llegado la siguiente ejecución prevista.
+func (s *ExpiryScheduler) Check(now time.Time) {
+    s.mu.Lock()
+    due := s.schedule != nil && !s.next.IsZero() && !now.Before(s.next)
+    if due {
+        s.next = s.schedule.Next(now)
+    }
+    s.mu.Unlock()
+
+    if due {
+        s.Run(now)
+    }
+}
+
+// Run ejecuta el trabajo de caducidad en el instante now y guarda su resultado.
+func (s *ExpiryScheduler) Run(now time.Time) (*service.ExpiryResult, error) {
+    result, err := s.expirer.ExpireCards(now)
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if err != nil {
+        s.lastErr = err.Error()
+        log.Printf("Card expiry job: %v", err)
+        return nil, err
+    }
+
+    s.lastErr = ""
+    s.lastRun = result
+    if result.ExpiredCards > 0 || result.FailedCards > 0 {
+        log.Printf("Card expiry job: %d cards expired, %d failed", result.ExpiredCards, result.FailedCards)
+    }
+    return result, nil
+}
+
+func (s *ExpiryScheduler) Status() ExpiryStatus {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    status := ExpiryStatus{Enabled: s.schedule != nil, LastRun: s.lastRun, Error: s.lastErr}
+    if s.schedule != nil {
+        status.Cron = s.schedule.String()
+    }
+    if !s.next.IsZero() {
+        next := s.next
+        status.NextRun = &next
+    }
+    return status
+}
\ No newline at end of file
@@ -0,0 +1,243 @@
+package scheduler
+
+import (
+    "context"
+    "errors"
+    "log"
+    "sync"
+    "time"
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+    "card-vault/internal/models"
+    "card-vault/internal/service"
+
+    "github.com/google/uuid"
+)
+
+const (
+    TriggerMaxAge         = "max_age"
+    TriggerMaxEncryptions = "max_encryptions"
+    TriggerCron           = "cron"
+)
+
+// Policy decide cuándo rotar automáticamente. Cada criterio es opcional; basta
+// con que se cumpla uno.
+type Policy struct {
+    MaxAge         time.Duration
+    MaxEncryptions uint64
+    Cron           *CronSchedule
+    CheckInterval  time.Duration
+}
+
+func (p Policy) Enabled() bool {
+    return p.MaxAge > 0 || p.MaxEncryptions > 0 || p.Cron != nil
+}
+
+// Rotator lanza trabajos de rotación (lo implementa service.CardService).
+type Rotator interface {
+    RotateKeys() (*models.RotationJob, error)
+    GetRotationJob(id uuid.UUID) (*models.RotationJob, error)
+}
+
+type PolicyStatus struct {
+    MaxAge         string `json:"max_age,omitempty"`
+    MaxEncryptions uint64 `json:"max_encryptions,omitempty"`
+    Cron           string `json:"cron,omitempty"`
+}
+
+type RunStatus struct {
+    Trigger   string     `json:"trigger"`
+    StartedAt time.Time  `json:"started_at"`
+    JobID     *uuid.UUID `json:"job_id,omitempty"`
+    Status    string     `json:"status"`
+    Error     string     `json:"error,omitempty"`
+}
+
+type Status struct {
+    Enabled      bool          `json:"enabled"`
+    Policy       PolicyStatus  `json:"policy"`
+    ActiveKey    *kms.KeyUsage `json:"active_key,omitempty"`
+    NextRotation *time.Time    `json:"next_rotation,omitempty"`
+    NextTrigger  string        `json:"next_trigger,omitempty"`
+    LastCheck    *time.Time    `json:"last_check,omitempty"`
+    LastRun      *RunStatus    `json:"last_run,omitempty"`
+    Error        string        `json:"error,omitempty"`
+}
+
+// RotationScheduler comprueba la política periódicamente y lanza un trabajo de
+// rotación cuando se cumple. Si ya hay uno en curso, espera a la siguiente comprobación.
+// Todos los criterios se evalúan sobre el uso de la clave activa, que comparten las
+// réplicas: cuando una rota, la clave nueva deja de cumplirlos para las demás.
+type RotationScheduler struct {
+    policy   Policy
+    rotator  Rotator
+    provider kms.Provider
+
+    usage     *kms.KeyUsage
+    lastCheck *time.Time
+    lastRun   *RunStatus
+    lastErr   string
+    mu        sync.Mutex
+}
+
+func NewRotationScheduler(policy Policy, rotator Rotator, provider kms.Provider) *RotationScheduler {
+    if policy.CheckInterval <= 0 {
+        policy.CheckInterval = time.Minute
+    }
+
+    return &RotationScheduler{policy: policy, rotator: rotator, provider: provider}
+}
+
+// Start ejecuta las comprobaciones hasta que se cancele ctx.
+func (s *RotationScheduler) Start(ctx context.Context) {
+    if !s.policy.Enabled() {
+        return
+    }
+
+    go func() {
+        ticker := time.NewTicker(s.policy.CheckInterval)
+        defer ticker.Stop()
+
+        s.Check(time.Now())
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case now := <-ticker.C:
+                s.Check(now)
+            }
+        }
+    }()
+}
+
+// Check evalúa la política en el instante now y rota si corresponde.
+func (s *RotationScheduler) Check(now time.Time) {
+    usage, err := s.provider.KeyUsage()
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.lastCheck = &now
+    if err != nil {
+        s.lastErr = err.Error()
+        // Sellado no es un fallo: se vuelve a evaluar tras la apertura
+        if !errors.Is(err, crypto.ErrSealed) {
+            log.Printf("Key rotation scheduler: failed to read key usage: %v", err)
+        }
+        return
+    }
+    s.lastErr = ""
+    s.usage = &usage
+
+    trigger := s.due(now, usage)
+    if trigger == "" {
+        return
+    }
+
+    run := &RunStatus{Trigger: trigger, StartedAt: now}
+    job, err := s.rotator.RotateKeys()
+    switch {
+    case err == nil:
+        run.JobID = &job.ID
+        run.Status = job.Status
+        log.Printf("Key rotation scheduler: started job %s (%s)", job.ID, trigger)
+        // La próxima rotación se calcula ya sobre la clave nueva
+        if usage, err := s.provider.KeyUsage(); err == nil {
+            s.usage = &usage
+        }
+    case errors.Is(err, service.ErrRotationInProgress):
+        // Otra rotación en curso: se vuelve a evaluar en la siguiente comprobación
+        return
+    default:
+        run.Status = models.RotationJobFailed
+        run.Error = err.Error()
+        log.Printf("Key rotation scheduler: failed to start rotation (%s): %v", trigger, err)
+    }
+
+    s.lastRun = run
+}
+
+// due devuelve el criterio que obliga a rotar en now, o "" si ninguno.
+func (s *RotationScheduler) due(now time.Time, usage kms.KeyUsage) string {
+    if s.policy.MaxAge > 0 && !now.Before(usage.CreatedAt.Add(s.policy.MaxAge)) {
+        return TriggerMaxAge
+    }
+    if s.policy.MaxEncryptions > 0 && usage.Encryptions >= s.policy.MaxEncryptions {
+        return TriggerMaxEncryptions
+    }
+    if next := s.nextCron(now, usage); !next.IsZero() && !now.Before(next) {
+        return TriggerCron
+    }
+    return ""
+}
+
+// nextCron devuelve el primer instante de la expresión cron posterior a la creación
+// de la clave activa: si ya pasó, la clave es anterior al último instante y toca
+// rotar. No depende de ningún estado del proceso, así que varias réplicas no rotan
+// dos veces por el mismo instante.
+func (s *RotationScheduler) nextCron(now time.Time, usage kms.KeyUsage) time.Time {
+    if s.policy.Cron == nil {
+        return time.Time{}
+    }
+    return s.policy.Cron.Next(usage.CreatedAt.In(now.Location()))
+}
+
+func (s *RotationScheduler) Status() Status {
+    s.mu.Lock()
+    status := Status{
+        Enabled:   s.policy.Enabled(),
+        Policy:    s.policyStatus(),
+        ActiveKey: s.usage,
+        LastCheck: s.lastCheck,
+        Error:     s.lastErr,
+    }
+    if s.lastRun != nil {
+        run := *s.lastRun
+        status.LastRun = &run
+    }
+    next, trigger := s.nextRotation(time.Now())
+    s.mu.Unlock()
+
+    if !next.IsZero() {
+        status.NextRotation = &next
+        status.NextTrigger = trigger
+    }
+
+    // El resultado de la última ejecución es el del trabajo que lanzó
+    if status.LastRun != nil && status.LastRun.JobID != nil {
+        if job, err := s.rotator.GetRotationJob(*status.LastRun.JobID); err == nil {
+            status.LastRun.Status = job.Status
+            status.LastRun.Error = job.LastError
+        }
+    }
+    return status
+}
+
+// nextRotation calcula la próxima rotación prevista por tiempo; el límite de
+// cifrados no tiene fecha. Debe llamarse con s.mu tomado.
+func (s *RotationScheduler) nextRotation(now time.Time) (time.Time, string) {
+    var next time.Time
+    var trigger string
+    if s.usage == nil {
+        return next, trigger
+    }
+
+    if s.policy.MaxAge > 0 {
+        next, trigger = s.usage.CreatedAt.Add(s.policy.MaxAge), TriggerMaxAge
+    }
+    if cron := s.nextCron(now, *s.usage); !cron.IsZero() && (next.IsZero() || cron.Before(next)) {
+        next, trigger = cron, TriggerCron
+    }
+    return next, trigger
+}
+
+func (s *RotationScheduler) policyStatus() PolicyStatus {
+    status := PolicyStatus{MaxEncryptions: s.policy.MaxEncryptions}
+    if s.policy.MaxAge > 0 {
+        status.MaxAge = s.policy.MaxAge.String()
+    }
+    if s.policy.Cron != nil {
+        status.Cron = s.policy.Cron.String()
+    }
+    return status
+}
\ No newline at end of file
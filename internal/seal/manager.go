@@ -0,0 +1,175 @@
+package seal
+
+import (
+    "crypto/rand"
+    "errors"
+    "fmt"
+    "log"
+    "sync"
+    "card-vault/internal/crypto"
+    "card-vault/internal/shamir"
+)
+
+var (
+    ErrNotSealed      = errors.New("vault is not sealed")
+    ErrInvalidShare   = errors.New("invalid key share")
+    ErrDuplicateShare = errors.New("key share already submitted")
+    ErrUnsealFailed   = errors.New("key shares do not reconstruct the master key")
+)
+
+// Status es el estado del sellado que se expone a los operadores.
+type Status struct {
+    Sealed    bool `json:"sealed"`
+    Threshold int  `json:"threshold"`
+    Progress  int  `json:"progress"`
+}
+
+// Manager gestiona la ceremonia de apertura: reúne partes de Shamir de la master
+// key hasta alcanzar el umbral, reconstruye la clave y abre el keyring. Ningún
+// operador conoce por sí solo la master key.
+type Manager struct {
+    keyMgr    *crypto.KeyManager
+    threshold int
+    shares    [][]byte
+    onUnseal  []func()
+    mu        sync.Mutex
+}
+
+func NewManager(keyMgr *crypto.KeyManager, threshold int) (*Manager, error) {
+    if threshold < 2 {
+        return nil, fmt.Errorf("unseal threshold must be at least 2, got %d", threshold)
+    }
+    return &Manager{keyMgr: keyMgr, threshold: threshold}, nil
+}
+
+// OnUnseal registra fn para ejecutarse cada vez que el keyring se abre.
+func (m *Manager) OnUnseal(fn func()) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.onUnseal = append(m.onUnseal, fn)
+}
+
+func (m *Manager) Sealed() bool {
+    return m.keyMgr.Sealed()
+}
+
+func (m *Manager) Status() Status {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.status()
+}
+
+func (m *Manager) status() Status {
+    return Status{Sealed: m.keyMgr.Sealed(), Threshold: m.threshold, Progress: len(m.shares)}
+}
+
+// Initialize genera una master key nueva, crea con ella el keyring y devuelve
+// las partes para los operadores. La master key no se guarda en ningún sitio.
+func (m *Manager) Initialize(shares int) ([][]byte, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    masterKey := make([]byte, 32)
+    if _, err := rand.Read(masterKey); err != nil {
+        return nil, err
+    }
+    defer zero(masterKey)
+
+    parts, err := shamir.Split(masterKey, shares, m.threshold)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := m.keyMgr.Initialize(masterKey); err != nil {
+        return nil, err
+    }
+
+    m.reset()
+    m.runOnUnseal()
+    return parts, nil
+}
+
+// Unseal añade una parte. Al llegar al umbral reconstruye la master key y abre
+// el keyring; si no es la correcta se descartan todas las partes recibidas.
+func (m *Manager) Unseal(share []byte) (Status, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if !m.keyMgr.Sealed() {
+        return m.status(), ErrNotSealed
+    }
+
+    x, ok := shamir.X(share)
+    if !ok || (len(m.shares) > 0 && len(share) != len(m.shares[0])) {
+        return m.status(), ErrInvalidShare
+    }
+    for _, submitted := range m.shares {
+        if submitted[len(submitted)-1] == x {
+            return m.status(), ErrDuplicateShare
+        }
+    }
+
+    m.shares = append(m.shares, append([]byte(nil), share...))
+    if len(m.shares) < m.threshold {
+        return m.status(), nil
+    }
+
+    masterKey, err := shamir.Combine(m.shares)
+    m.reset()
+    if err != nil {
+        return m.status(), fmt.Errorf("%w: %v", ErrUnsealFailed, err)
+    }
+    defer zero(masterKey)
+
+    if err := m.keyMgr.Unseal(masterKey); err != nil {
+        if errors.Is(err, crypto.ErrWrongMasterKey) || errors.Is(err, crypto.ErrInvalidMasterKey) {
+            return m.status(), ErrUnsealFailed
+        }
+        return m.status(), err
+    }
+
+    log.Printf("Vault unsealed")
+    m.runOnUnseal()
+    return m.status(), nil
+}
+
+// ResetProgress descarta las partes enviadas hasta ahora.
+func (m *Manager) ResetProgress() Status {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.reset()
+    return m.status()
+}
+
+// Seal cierra el keyring (respuesta a incidentes). Para volver a operar hace
+// falta una nueva ceremonia de apertura.
+func (m *Manager) Seal() error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if err := m.keyMgr.Seal(); err != nil {
+        return err
+    }
+    m.reset()
+    log.Printf("Vault sealed")
+    return nil
+}
+
+func (m *Manager) reset() {
+    for _, share := range m.shares {
+        zero(share)
+    }
+    m.shares = nil
+}
+
+func (m *Manager) runOnUnseal() {
+    for _, fn := range m.onUnseal {
+        go fn()
+    }
+}
+
+func zero(b []byte) {
+    for i := range b {
+        b[i] = 0
+    }
+}
\ No newline at end of file
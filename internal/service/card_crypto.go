@@ -0,0 +1,318 @@
+package service
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+    "card-vault/internal/models"
+    "card-vault/internal/vault"
+
+    "gorm.io/gorm"
+)
+
+// Versión del formato de datos asociados; 0 indica tarjetas cifradas sin ellos.
+const associatedDataVersion = 1
+
+// Propósito del PAN en su etiqueta vault, para descifrarlo sin el resto de campos.
+const fieldPAN = "pan"
+
+// Reintentos de un compare-and-swap que pierde contra escrituras concurrentes.
+const maxSwapAttempts = 5
+
+var ErrConcurrentUpdate = errors.New("card kept changing during key update")
+
+// cardAssociatedData liga el cifrado de un campo a la tarjeta, su usuario y el
+// nombre del campo, de modo que un ciphertext copiado a otra fila o a otro campo
+// no se puede descifrar.
+func cardAssociatedData(card *models.Card, field string) []byte {
+    if card.AADVersion == 0 {
+        return nil
+    }
+    return []byte(fmt.Sprintf("card-vault/v%d|card=%s|user=%s|field=%s", card.AADVersion, card.ID, card.UserID, field))
+}
+
+// fieldCipher cifra los campos marcados de una tarjeta con la clave de su registro
+// y liga cada uno a la tarjeta con los datos asociados.
+type fieldCipher struct {
+    svc    *cardService
+    card   *models.Card
+    encSvc *crypto.EncryptionService
+}
+
+func (c fieldCipher) Seal(plaintext []byte, purpose string) ([]byte, error) {
+    return c.encSvc.Seal(plaintext, crypto.RecordKeyVersion, cardAssociatedData(c.card, purpose))
+}
+
+// Open descifra un campo según su propia cabecera: los sobres cifrados con una
+// versión del keyring se abren con ella y el resto con la clave del registro.
+func (c fieldCipher) Open(ciphertext []byte, purpose string) ([]byte, error) {
+    encSvc := c.encSvc
+    if header, ok := crypto.ParseEnvelopeHeader(ciphertext); ok && header.KeyVersion != crypto.RecordKeyVersion {
+        keyringSvc, err := c.svc.encryptionServiceFor(int(header.KeyVersion))
+        if err != nil {
+            return nil, err
+        }
+        encSvc = keyringSvc
+    }
+    return encSvc.Open(ciphertext, cardAssociatedData(c.card, purpose))
+}
+
+// cardKeys da a los callbacks de vault el Cipher de cada tarjeta que el
+// repositorio guarda o lee.
+type cardKeys struct {
+    svc *cardService
+}
+
+// SealCipher asigna a la tarjeta una DEK nueva envuelta por el proveedor activo y
+// ligada a la clave del usuario; card.ID y card.UserID deben estar asignados. Los
+// titulares guardados en claro pasan al campo cifrado.
+func (k cardKeys) SealCipher(record interface{}) (vault.Cipher, error) {
+    card, ok := record.(*models.Card)
+    if !ok {
+        return nil, fmt.Errorf("unexpected encrypted record %T", record)
+    }
+
+    userKey, err := k.svc.keyMgr.EnsureUserKey(card.UserID.String())
+    if err != nil {
+        return nil, fmt.Errorf("failed to get user key: %w", err)
+    }
+
+    encSvc, wrappedDEK, keyVersion, err := k.svc.newDataKey(userKey)
+    if err != nil {
+        return nil, err
+    }
+
+    if card.CardholderName.IsEmpty() && card.LegacyCardholderName != "" {
+        card.CardholderName.SetString(card.LegacyCardholderName)
+    }
+    card.LegacyCardholderName = ""
+    card.AADVersion = associatedDataVersion
+    card.UserKeyed = true
+    card.WrappedDEK = wrappedDEK
+    card.KeyProvider = k.svc.kms.Name()
+    card.KeyVersion = keyVersion
+    return fieldCipher{svc: k.svc, card: card, encSvc: encSvc}, nil
+}
+
+// OpenCipher devuelve el Cipher que descifra la tarjeta en cualquiera de los
+// formatos soportados.
+func (k cardKeys) OpenCipher(record interface{}) (vault.Cipher, error) {
+    card, ok := record.(*models.Card)
+    if !ok {
+        return nil, fmt.Errorf("unexpected encrypted record %T", record)
+    }
+
+    encSvc, err := k.svc.cardEncryptionService(card)
+    if err != nil {
+        return nil, fmt.Errorf("key version %d unavailable: %w", card.KeyVersion, err)
+    }
+    return fieldCipher{svc: k.svc, card: card, encSvc: encSvc}, nil
+}
+
+// setBillingAddress asigna la dirección de facturación: la calle (como JSON de sus
+// líneas) y el código postal van cifrados; ciudad, región y país en claro. Con
+// addr nil la tarjeta queda sin dirección.
+func setBillingAddress(card *models.Card, addr *models.BillingAddress) error {
+    if addr == nil {
+        card.BillingStreet.Set(nil)
+        card.BillingPostalCode.Set(nil)
+        card.BillingCity, card.BillingRegion, card.BillingCountry = "", "", ""
+        return nil
+    }
+
+    lines, err := json.Marshal(addr.Lines)
+    if err != nil {
+        return err
+    }
+
+    card.BillingStreet.Set(lines)
+    card.BillingPostalCode.SetString(addr.PostalCode)
+    card.BillingCity, card.BillingRegion, card.BillingCountry = addr.City, addr.Region, addr.Country
+    return nil
+}
+
+// billingAddress devuelve la dirección de una tarjeta abierta, o nil si no tiene.
+func billingAddress(card *models.Card) (*models.BillingAddress, error) {
+    if len(card.BillingStreet.Bytes()) == 0 {
+        return nil, nil
+    }
+
+    addr := &models.BillingAddress{
+        PostalCode: card.BillingPostalCode.String(),
+        City:       card.BillingCity,
+        Region:     card.BillingRegion,
+        Country:    card.BillingCountry,
+    }
+    if err := json.Unmarshal(card.BillingStreet.Bytes(), &addr.Lines); err != nil {
+        return nil, fmt.Errorf("failed to decode billing address: %w", err)
+    }
+    return addr, nil
+}
+
+// decryptCardNumber descifra solo el PAN de una tarjeta leída sin abrir, para los
+// usos que no necesitan el resto.
+func (s *cardService) decryptCardNumber(card *models.Card) (string, error) {
+    encSvc, err := s.cardEncryptionService(card)
+    if err != nil {
+        return "", fmt.Errorf("unable to decrypt card with available keys: %w", err)
+    }
+
+    cardNumber, err := fieldCipher{svc: s, card: card, encSvc: encSvc}.Open(card.CardNumber.Ciphertext(), fieldPAN)
+    if err != nil {
+        return "", err
+    }
+    return string(cardNumber), nil
+}
+
+// rewrapCard vuelve a envolver la DEK de la tarjeta con la clave activa del proveedor
+// configurado; los datos cifrados no cambian. Las DEKs de otro proveedor se
+// desenvuelven con él y se envuelven con el actual. Las tarjetas anteriores a las
+// DEKs, cifradas directamente con una versión del keyring, las anteriores a las
+// claves por usuario y las que aún tienen el titular en claro se migran a una DEK
+// nueva.
+func (s *cardService) rewrapCard(card *models.Card) error {
+    if len(card.WrappedDEK) == 0 || !card.UserKeyed || card.LegacyCardholderName != "" {
+        // Las páginas de la rotación se leen sin abrir: se relee abierta para que
+        // el repositorio la vuelva a sellar entera al guardarla
+        opened, err := s.repo.FindByID(card.ID)
+        if err != nil {
+            return err
+        }
+        *card = *opened
+        return vault.MarkDirty(card)
+    }
+
+    provider, err := s.providerFor(card)
+    if err != nil {
+        return err
+    }
+
+    var wrappedDEK []byte
+    var keyVersion int
+    if provider == s.kms {
+        wrappedDEK, keyVersion, err = provider.RewrapDataKey(card.WrappedDEK, card.KeyVersion)
+    } else {
+        var dek []byte
+        if dek, err = provider.UnwrapDataKey(card.WrappedDEK, card.KeyVersion); err == nil {
+            wrappedDEK, keyVersion, err = s.kms.WrapDataKey(dek)
+        }
+    }
+    if err != nil {
+        return fmt.Errorf("failed to rewrap data key: %w", err)
+    }
+
+    card.WrappedDEK = wrappedDEK
+    card.KeyProvider = s.kms.Name()
+    card.KeyVersion = keyVersion
+    return nil
+}
+
+// swapKeyMaterial aplica fn (rewrap o recifrado) a la tarjeta y guarda el resultado
+// con compare-and-swap sobre la DEK envuelta. Si una escritura concurrente cambió la
+// tarjeta entre la lectura y el guardado, se relee y se vuelve a aplicar fn, de modo
+// que nunca se pisa un PAN o una DEK más recientes con material antiguo.
+func (s *cardService) swapKeyMaterial(card *models.Card, fn func(card *models.Card) error) error {
+    for attempt := 0; attempt < maxSwapAttempts; attempt++ {
+        expectedDEK := card.WrappedDEK
+        if err := fn(card); err != nil {
+            return err
+        }
+
+        swapped, err := s.repo.SwapKeyMaterial(card, expectedDEK)
+        if err != nil {
+            return errors.New("failed to update card")
+        }
+        if swapped {
+            return nil
+        }
+
+        card, err = s.repo.FindByID(card.ID)
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            // Borrada mientras tanto: no queda nada que rotar
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("failed to reload card: %w", err)
+        }
+    }
+    return ErrConcurrentUpdate
+}
+
+// newDataKey genera la DEK de una tarjeta y devuelve su servicio de cifrado, con
+// el algoritmo configurado y la clave derivada de la DEK y la del usuario, junto
+// con la DEK envuelta y la versión de la clave que la envuelve.
+func (s *cardService) newDataKey(userKey []byte) (*crypto.EncryptionService, []byte, int, error) {
+    dek, wrappedDEK, keyVersion, err := s.kms.GenerateDataKey()
+    if err != nil {
+        return nil, nil, 0, fmt.Errorf("failed to generate data key: %w", err)
+    }
+
+    dataKey, err := crypto.DeriveUserDataKey(dek, userKey)
+    if err != nil {
+        return nil, nil, 0, fmt.Errorf("failed to derive data key: %w", err)
+    }
+
+    encSvc, err := crypto.NewEncryptionServiceWithAlgorithm(s.keyMgr.Algorithm(), dataKey)
+    if err != nil {
+        return nil, nil, 0, fmt.Errorf("failed to create encryption service: %w", err)
+    }
+
+    return encSvc, wrappedDEK, keyVersion, nil
+}
+
+// cardEncryptionService devuelve el servicio que descifra los datos de la tarjeta:
+// su DEK combinada con la clave del usuario, la DEK sola en tarjetas anteriores a
+// las claves por usuario, o la versión del keyring si es anterior a las DEKs.
+func (s *cardService) cardEncryptionService(card *models.Card) (*crypto.EncryptionService, error) {
+    if len(card.WrappedDEK) == 0 {
+        return s.encryptionServiceFor(card.KeyVersion)
+    }
+
+    provider, err := s.providerFor(card)
+    if err != nil {
+        return nil, err
+    }
+
+    dek, err := provider.UnwrapDataKey(card.WrappedDEK, card.KeyVersion)
+    if err != nil {
+        return nil, err
+    }
+
+    if !card.UserKeyed {
+        return crypto.NewEncryptionService(dek)
+    }
+
+    userKey, err := s.keyMgr.UserKey(card.UserID.String())
+    if err != nil {
+        return nil, fmt.Errorf("user key unavailable: %w", err)
+    }
+
+    dataKey, err := crypto.DeriveUserDataKey(dek, userKey)
+    if err != nil {
+        return nil, err
+    }
+    return crypto.NewEncryptionService(dataKey)
+}
+
+// providerFor devuelve el proveedor que envolvió la DEK de la tarjeta.
+func (s *cardService) providerFor(card *models.Card) (kms.Provider, error) {
+    switch card.KeyProvider {
+    case s.kms.Name():
+        return s.kms, nil
+    case kms.LocalProviderName, "":
+        return s.local, nil
+    }
+    return nil, fmt.Errorf("key provider %q is not configured", card.KeyProvider)
+}
+
+// encryptionServiceFor construye el servicio de cifrado de cualquier versión
+// del keyring que siga pudiendo descifrar.
+func (s *cardService) encryptionServiceFor(version int) (*crypto.EncryptionService, error) {
+    key, err := s.keyMgr.GetKey(version)
+    if err != nil {
+        return nil, err
+    }
+    return crypto.NewEncryptionService(key)
+}
\ No newline at end of file
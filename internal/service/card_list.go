@@ -0,0 +1,213 @@
+package service
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+    "card-vault/internal/brand"
+    "card-vault/internal/models"
+    "card-vault/internal/repository"
+
+    "github.com/google/uuid"
+)
+
+const (
+    defaultCardPageSize = 20
+    maxCardPageSize     = 100
+)
+
+var (
+    ErrInvalidCardQuery = errors.New("invalid card list query")
+    ErrInvalidCursor    = errors.New("invalid pagination cursor")
+)
+
+// pageCursor es la posición, codificada en el cursor, de la última tarjeta de una
+// página. Incluye el orden para rechazar cursores de otro listado.
+type pageCursor struct {
+    Sort      string    `json:"s"`
+    Order     string    `json:"o"`
+    ID        uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"c"`
+    Expiry    int       `json:"e"`
+}
+
+// GetUserCards devuelve una página de las tarjetas del usuario. Los filtros, el
+// orden y el límite se aplican en la base de datos y solo se descifran las
+// tarjetas de la página.
+func (s *cardService) GetUserCards(userID uuid.UUID, query *models.CardListQuery) (*models.CardPage, error) {
+    filter, err := cardListFilter(query)
+    if err != nil {
+        return nil, err
+    }
+    order := "asc"
+    if filter.Descending {
+        order = "desc"
+    }
+
+    if query.Cursor != "" {
+        cursor, err := decodeCursor(query.Cursor)
+        if err != nil || cursor.Sort != filter.Sort || cursor.Order != order {
+            return nil, ErrInvalidCursor
+        }
+        filter.After = &repository.CardCursor{ID: cursor.ID, CreatedAt: cursor.CreatedAt, Expiry: cursor.Expiry}
+    }
+
+    // Una tarjeta de más indica si hay otra página
+    limit := filter.Limit
+    filter.Limit++
+    cards, err := s.repo.ListByUserID(userID, filter)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get user cards: %w", err)
+    }
+
+    page := &models.CardPage{
+        Cards:      make([]models.CardResponse, 0, min(len(cards), limit)),
+        Pagination: models.Pagination{Limit: limit, Sort: filter.Sort, Order: order},
+    }
+    if len(cards) > limit {
+        cards = cards[:limit]
+        last := cards[limit-1]
+        page.Pagination.HasMore = true
+        page.Pagination.NextCursor = encodeCursor(pageCursor{
+            Sort:      filter.Sort,
+            Order:     order,
+            ID:        last.ID,
+            CreatedAt: last.CreatedAt,
+            Expiry:    last.ExpiryYear*12 + last.ExpiryMonth - 1,
+        })
+    }
+
+    for i := range cards {
+        page.Cards = append(page.Cards, *s.toCardResponse(&cards[i]))
+    }
+    return page, nil
+}
+
+// cardListFilter valida la consulta y la traduce al filtro del repositorio. Por
+// defecto lista primero las tarjetas más recientes, de 20 en 20.
+func cardListFilter(query *models.CardListQuery) (repository.CardListFilter, error) {
+    filter := repository.CardListFilter{Sort: models.CardSortCreatedAt, Descending: true, Limit: defaultCardPageSize}
+
+    if query.Sort != "" {
+        if query.Sort != models.CardSortCreatedAt && query.Sort != models.CardSortExpiry {
+            return filter, fmt.Errorf("%w: sort must be created_at or expiry", ErrInvalidCardQuery)
+        }
+        filter.Sort = query.Sort
+        // La caducidad se ordena por defecto de la más próxima a la más lejana
+        filter.Descending = query.Sort == models.CardSortCreatedAt
+    }
+    switch query.Order {
+    case "":
+    case "asc", "desc":
+        filter.Descending = query.Order == "desc"
+    default:
+        return filter, fmt.Errorf("%w: order must be asc or desc", ErrInvalidCardQuery)
+    }
+
+    if query.Limit != 0 {
+        if query.Limit < 1 || query.Limit > maxCardPageSize {
+            return filter, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidCardQuery, maxCardPageSize)
+        }
+        filter.Limit = query.Limit
+    }
+
+    for _, name := range splitList(query.CardType) {
+        cardBrand, ok := brand.Lookup(name)
+        if !ok {
+            return filter, fmt.Errorf("%w: unknown card type %q", ErrInvalidCardQuery, name)
+        }
+        filter.CardTypes = append(filter.CardTypes, cardBrand.Name)
+    }
+    for _, name := range splitList(query.Status) {
+        status := models.CardStatus(name)
+        switch status {
+        case models.CardStatusActive, models.CardStatusFrozen, models.CardStatusExpired, models.CardStatusReplaced, models.CardStatusClosed:
+        default:
+            return filter, fmt.Errorf("%w: unknown status %q", ErrInvalidCardQuery, name)
+        }
+        filter.Statuses = append(filter.Statuses, status)
+    }
+
+    var err error
+    if filter.ExpiryFrom, err = parseExpiryMonth(query.ExpiryFrom, "expiry_from"); err != nil {
+        return filter, err
+    }
+    if filter.ExpiryTo, err = parseExpiryMonth(query.ExpiryTo, "expiry_to"); err != nil {
+        return filter, err
+    }
+    // expiry_to incluye su mes
+    if filter.ExpiryTo != 0 {
+        filter.ExpiryTo++
+    }
+    if filter.CreatedFrom, err = parseTimestamp(query.CreatedFrom, "created_from"); err != nil {
+        return filter, err
+    }
+    if filter.CreatedTo, err = parseTimestamp(query.CreatedTo, "created_to"); err != nil {
+        return filter, err
+    }
+
+    if query.Last4 != "" {
+        if len(query.Last4) != 4 || strings.Trim(query.Last4, "0123456789") != "" {
+            return filter, fmt.Errorf("%w: last4 must be 4 digits", ErrInvalidCardQuery)
+        }
+        filter.Last4 = query.Last4
+    }
+    return filter, nil
+}
+
+func splitList(value string) []string {
+    var items []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// parseExpiryMonth lee un mes YYYY-MM y lo numera como año*12 + mes-1.
+func parseExpiryMonth(value, name string) (int, error) {
+    if value == "" {
+        return 0, nil
+    }
+    month, err := time.Parse("2006-01", value)
+    if err != nil {
+        return 0, fmt.Errorf("%w: %s must be a month as YYYY-MM", ErrInvalidCardQuery, name)
+    }
+    return monthIndex(month), nil
+}
+
+func parseTimestamp(value, name string) (time.Time, error) {
+    if value == "" {
+        return time.Time{}, nil
+    }
+    t, err := time.Parse(time.RFC3339, value)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("%w: %s must be an RFC 3339 timestamp", ErrInvalidCardQuery, name)
+    }
+    return t, nil
+}
+
+func encodeCursor(cursor pageCursor) string {
+    data, _ := json.Marshal(cursor)
+    return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (*pageCursor, error) {
+    data, err := base64.RawURLEncoding.DecodeString(value)
+    if err != nil {
+        return nil, err
+    }
+
+    var cursor pageCursor
+    if err := json.Unmarshal(data, &cursor); err != nil {
+        return nil, err
+    }
+    if cursor.ID == uuid.Nil {
+        return nil, errors.New("cursor has no card ID")
+    }
+    return &cursor, nil
+}
\ No newline at end of file
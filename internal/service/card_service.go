@@ -3,75 +3,208 @@ package service
 import (
     "errors"
     "fmt"
-    "regexp"
     "strings"
     "sync"
+    "time"
+    "card-vault/internal/address"
+    "card-vault/internal/bin"
+    "card-vault/internal/brand"
     "card-vault/internal/crypto"
+    "card-vault/internal/cvv"
+    "card-vault/internal/kms"
+    "card-vault/internal/masking"
     "card-vault/internal/models"
     "card-vault/internal/repository"
+    "card-vault/internal/tokenization"
+    "card-vault/internal/vault"
     
     "github.com/google/uuid"
+    "gorm.io/gorm"
 )
 
 type CardService interface {
     CreateCard(userID uuid.UUID, req *models.CardRequest) (*models.CardResponse, error)
     GetCard(cardID, userID uuid.UUID) (*models.CardResponse, error)
-    GetUserCards(userID uuid.UUID) ([]models.CardResponse, error)
+    GetUserCards(userID uuid.UUID, query *models.CardListQuery) (*models.CardPage, error)
     UpdateCard(cardID, userID uuid.UUID, req *models.CardRequest) (*models.CardResponse, error)
     DeleteCard(cardID, userID uuid.UUID) error
     BatchUpdateCards(userID uuid.UUID, req *models.BatchUpdateRequest) ([]models.BatchUpdateResponse, error)
-    RotateKeys() ([]models.BatchUpdateResponse, error)
+    RotateKeys() (*models.RotationJob, error)
+    GetRotationJob(id uuid.UUID) (*models.RotationJob, error)
+    ResumeRotationJobs() error
+    BindAssociatedData() ([]models.BatchUpdateResponse, error)
+    LookupByPAN(cardNumber string) ([]models.CardResponse, error)
+    ReindexFingerprints(rotate bool) (*FingerprintReindexResult, error)
+    TokenizeCard(cardID, userID uuid.UUID) (*models.TokenResponse, error)
+    Detokenize(token string) (*models.DetokenizeResponse, error)
+    StoreCVV(cardID, userID uuid.UUID, cvv string) (*models.CardResponse, error)
+    UseCVV(cardID, userID uuid.UUID) (*models.CVVResponse, error)
+    RevealCard(cardID, actorID uuid.UUID, purpose, reason string, includeCVV bool) (*models.RevealResponse, error)
+    SetPreferredNetwork(cardID, userID uuid.UUID, network string) (*models.CardResponse, error)
+    FreezeCard(cardID, userID uuid.UUID, reason string) (*models.CardResponse, error)
+    UnfreezeCard(cardID, userID uuid.UUID, reason string) (*models.CardResponse, error)
+    CloseCard(cardID, userID uuid.UUID, reason string) (*models.CardResponse, error)
+    ReplaceCard(cardID, userID uuid.UUID, req *models.CardRequest, reason string) (*models.CardResponse, error)
+    GetStatusHistory(cardID, userID uuid.UUID) ([]models.CardStatusEvent, error)
+    ExpireCards(now time.Time) (*ExpiryResult, error)
+    GetExpiringCards(userID uuid.UUID, days int) ([]models.CardResponse, error)
+    GetAllExpiringCards(days int, query *models.PageQuery) (*models.ExpiringCardPage, error)
+    WithMasking(policy masking.Policy) CardService
 }
 
 type cardService struct {
-    repo      repository.CardRepository
-    encSvc    *crypto.EncryptionService
-    keyMgr    *crypto.KeyManager
-    mu        sync.RWMutex
+    repo           repository.CardRepository
+    jobs           repository.RotationJobRepository
+    tokens         repository.TokenRepository
+    audit          repository.AuditRepository
+    cvvs           cvv.Store
+    bins           *bin.Database
+    keyMgr         *crypto.KeyManager
+    local          kms.Provider
+    kms            kms.Provider
+    duplicates     DuplicatePolicy
+    tokenCfg       tokenization.Config
+    addressMasking address.Masking
+    panMasking     masking.Policy
+    jobMu          *sync.Mutex
+    // Identifica a este proceso como dueño de los trabajos de rotación que ejecuta
+    owner          string
 }
 
-func NewCardService(repo repository.CardRepository, encSvc *crypto.EncryptionService, keyMgr *crypto.KeyManager) CardService {
-    return &cardService{
-        repo:   repo,
-        encSvc: encSvc,
-        keyMgr: keyMgr,
+// CardServiceOptions configura los comportamientos opcionales del servicio. Los
+// valores cero equivalen a la configuración por defecto.
+type CardServiceOptions struct {
+    // Qué hacer cuando un usuario guarda un PAN que ya tiene (reject por defecto)
+    Duplicates DuplicatePolicy
+    // Formato de los tokens (determinista por defecto) y dónde se guardan los aleatorios
+    Tokenization tokenization.Config
+    Tokens       repository.TokenRepository
+    // Almacén temporal de CVVs; sin él los CVV recibidos se descartan
+    CVVs cvv.Store
+    // Tabla de BIN con la que se completan emisor, país y tipo de producto
+    BINs *bin.Database
+    // Cuánto se enmascaran calle y código postal en las respuestas (partial por defecto)
+    AddressMasking address.Masking
+    // Qué dígitos del PAN se muestran en las respuestas (last4 por defecto)
+    PANMasking masking.Policy
+    // Registro de auditoría de las lecturas del PAN completo; sin él no se revelan
+    Audit repository.AuditRepository
+}
+
+// NewCardService crea el servicio. provider envuelve las DEKs nuevas; keyMgr es el
+// keyring local, que sigue abriendo las tarjetas envueltas o cifradas localmente.
+func NewCardService(repo repository.CardRepository, jobs repository.RotationJobRepository, keyMgr *crypto.KeyManager, provider kms.Provider) CardService {
+    return NewCardServiceWithOptions(repo, jobs, keyMgr, provider, CardServiceOptions{})
+}
+
+func NewCardServiceWithOptions(repo repository.CardRepository, jobs repository.RotationJobRepository, keyMgr *crypto.KeyManager, provider kms.Provider, opts CardServiceOptions) CardService {
+    if opts.Duplicates == "" {
+        opts.Duplicates = DuplicateReject
+    }
+    if opts.Tokenization.Mode == "" {
+        opts.Tokenization.Mode = tokenization.ModeDeterministic
     }
+    if opts.AddressMasking == "" {
+        opts.AddressMasking = address.MaskPartial
+    }
+    if opts.PANMasking.Name == "" {
+        opts.PANMasking = masking.Default
+    }
+
+    svc := &cardService{
+        jobs:           jobs,
+        tokens:         opts.Tokens,
+        audit:          opts.Audit,
+        cvvs:           opts.CVVs,
+        bins:           opts.BINs,
+        keyMgr:         keyMgr,
+        local:          kms.NewLocalProvider(keyMgr),
+        kms:            provider,
+        duplicates:     opts.Duplicates,
+        tokenCfg:       opts.Tokenization,
+        addressMasking: opts.AddressMasking,
+        panMasking:     opts.PANMasking,
+        jobMu:          &sync.Mutex{},
+        owner:          uuid.NewString(),
+    }
+    svc.repo = repo.WithKeys(cardKeys{svc: svc})
+    return svc
+}
+
+// WithMasking devuelve el mismo servicio, sobre los mismos repositorios y claves,
+// pero enmascarando el PAN de sus respuestas con policy (p. ej. la del llamante).
+func (s *cardService) WithMasking(policy masking.Policy) CardService {
+    masked := *s
+    masked.panMasking = policy
+    return &masked
 }
 
 func (s *cardService) CreateCard(userID uuid.UUID, req *models.CardRequest) (*models.CardResponse, error) {
+    return s.createCard(userID, req, s.duplicates == DuplicateMerge)
+}
+
+// createCard guarda una tarjeta nueva. Si el usuario ya tiene el mismo PAN, con
+// merge actualiza y devuelve esa tarjeta; si no, falla con ErrDuplicateCard.
+func (s *cardService) createCard(userID uuid.UUID, req *models.CardRequest, merge bool) (*models.CardResponse, error) {
     cardNumber := strings.ReplaceAll(req.CardNumber, " ", "")
-    if !s.isValidCardNumber(cardNumber) {
-        return nil, errors.New("invalid card number")
+    cardBrand, err := brand.Validate(cardNumber, req.CVV)
+    if err != nil {
+        return nil, err
     }
-
-    encryptedNumber, err := s.encSvc.Encrypt(cardNumber)
+    if err := validateExpiry(req.ExpiryMonth, req.ExpiryYear, time.Now()); err != nil {
+        return nil, err
+    }
+    billing, err := address.Normalize(req.BillingAddress)
     if err != nil {
-        return nil, fmt.Errorf("failed to encrypt card number: %w", err)
+        return nil, err
     }
 
-    encryptedCVV, err := s.encSvc.Encrypt(req.CVV)
+    duplicate, err := s.findDuplicate(userID, uuid.Nil, cardNumber)
     if err != nil {
-        return nil, fmt.Errorf("failed to encrypt CVV: %w", err)
+        return nil, err
+    }
+    if duplicate != nil {
+        if !merge {
+            return nil, ErrDuplicateCard
+        }
+        return s.mergeCard(duplicate, cardNumber, billing, req)
     }
 
-    _, keyVersion := s.keyMgr.GetCurrentKey()
-
+    // El ID se asigna antes de cifrar porque forma parte de los datos asociados
     card := &models.Card{
-        UserID:         userID,
-        CardholderName: req.CardholderName,
-        CardNumber:     encryptedNumber,
-        ExpiryMonth:    req.ExpiryMonth,
-        ExpiryYear:     req.ExpiryYear,
-        CVV:            encryptedCVV,
-        CardType:       s.detectCardType(cardNumber),
-        KeyVersion:     keyVersion,
+        ID:          uuid.New(),
+        UserID:      userID,
+        ExpiryMonth: req.ExpiryMonth,
+        ExpiryYear:  req.ExpiryYear,
+        CardType:    cardBrand.Name,
+        Status:      models.CardStatusActive,
+    }
+    card.CardNumber.SetString(cardNumber)
+    card.CardholderName.SetString(req.CardholderName)
+    if err := setBillingAddress(card, billing); err != nil {
+        return nil, err
+    }
+    info := s.enrichCard(card, cardNumber)
+    if err := s.assignNetworks(card, info, req.Networks, req.PreferredNetwork); err != nil {
+        return nil, err
+    }
+
+    if err := s.fingerprintCard(card, cardNumber); err != nil {
+        return nil, err
     }
 
     if err := s.repo.Create(card); err != nil {
+        // Otra petición guardó el mismo PAN entre la búsqueda y la inserción
+        if errors.Is(err, gorm.ErrDuplicatedKey) {
+            return nil, ErrDuplicateCard
+        }
         return nil, fmt.Errorf("failed to create card: %w", err)
     }
+    if err := s.putCVV(card.ID, req.CVV); err != nil {
+        return nil, err
+    }
 
-    return s.toCardResponse(card, cardNumber), nil
+    return s.toCardResponse(card), nil
 }
 
 func (s *cardService) GetCard(cardID, userID uuid.UUID) (*models.CardResponse, error) {
@@ -80,69 +213,130 @@ func (s *cardService) GetCard(cardID, userID uuid.UUID) (*models.CardResponse, e
         return nil, fmt.Errorf("card not found: %w", err)
     }
 
-    decryptedNumber, err := s.decryptCardNumber(card)
+    return s.toCardResponse(card), nil
+}
+
+func (s *cardService) UpdateCard(cardID, userID uuid.UUID, req *models.CardRequest) (*models.CardResponse, error) {
+    card, err := s.repo.GetByID(cardID, userID)
     if err != nil {
-        return nil, fmt.Errorf("failed to decrypt card data: %w", err)
+        return nil, fmt.Errorf("card not found: %w", err)
+    }
+    if err := requireOpen(card); err != nil {
+        return nil, err
     }
 
-    return s.toCardResponse(card, decryptedNumber), nil
-}
+    cardNumber := strings.ReplaceAll(req.CardNumber, " ", "")
+    cardBrand, err := brand.Validate(cardNumber, req.CVV)
+    if err != nil {
+        return nil, err
+    }
+    if err := validateExpiry(req.ExpiryMonth, req.ExpiryYear, time.Now()); err != nil {
+        return nil, err
+    }
+    billing, err := address.Normalize(req.BillingAddress)
+    if err != nil {
+        return nil, err
+    }
 
-func (s *cardService) GetUserCards(userID uuid.UUID) ([]models.CardResponse, error) {
-    cards, err := s.repo.GetAllByUserID(userID)
+    // Cambiar el PAN por el de otra tarjeta del usuario nunca fusiona
+    duplicate, err := s.findDuplicate(userID, card.ID, cardNumber)
     if err != nil {
-        return nil, fmt.Errorf("failed to get user cards: %w", err)
+        return nil, err
+    }
+    if duplicate != nil {
+        return nil, ErrDuplicateCard
     }
 
-    responses := make([]models.CardResponse, len(cards))
-    for i, card := range cards {
-        decryptedNumber, err := s.decryptCardNumber(&card)
-        if err != nil {
-            return nil, fmt.Errorf("failed to decrypt card data: %w", err)
-        }
-        responses[i] = *s.toCardResponse(&card, decryptedNumber)
+    panChanged, err := s.updateSecrets(card, cardNumber, req.CardholderName, billing)
+    if err != nil {
+        return nil, err
+    }
+    // El CVV guardado corresponde al PAN anterior
+    if panChanged {
+        s.deleteCVV(card.ID)
     }
 
-    return responses, nil
-}
+    if err := s.fingerprintCard(card, cardNumber); err != nil {
+        return nil, err
+    }
 
-func (s *cardService) UpdateCard(cardID, userID uuid.UUID, req *models.CardRequest) (*models.CardResponse, error) {
-    card, err := s.repo.GetByID(cardID, userID)
-    if err != nil {
-        return nil, fmt.Errorf("card not found: %w", err)
+    card.ExpiryMonth = req.ExpiryMonth
+    card.ExpiryYear = req.ExpiryYear
+    card.CardType = cardBrand.Name
+    info := s.enrichCard(card, cardNumber)
+    if err := s.assignNetworks(card, info, req.Networks, req.PreferredNetwork); err != nil {
+        return nil, err
     }
 
-    cardNumber := strings.ReplaceAll(req.CardNumber, " ", "")
-    if !s.isValidCardNumber(cardNumber) {
-        return nil, errors.New("invalid card number")
+    if err := s.saveCard(card); err != nil {
+        return nil, err
+    }
+    if err := s.putCVV(card.ID, req.CVV); err != nil {
+        return nil, err
     }
 
-    encryptedNumber, err := s.encSvc.Encrypt(cardNumber)
+    return s.toCardResponse(card), nil
+}
+
+// mergeCard actualiza con los datos de la petición la tarjeta que ya tenía el
+// usuario con ese PAN, en lugar de crear otra.
+func (s *cardService) mergeCard(duplicate *models.Card, cardNumber string, billing *models.BillingAddress, req *models.CardRequest) (*models.CardResponse, error) {
+    // La búsqueda por huella no abre la tarjeta
+    card, err := s.repo.GetByID(duplicate.ID, duplicate.UserID)
     if err != nil {
-        return nil, fmt.Errorf("failed to encrypt card number: %w", err)
+        return nil, fmt.Errorf("card not found: %w", err)
+    }
+    if err := requireOpen(card); err != nil {
+        return nil, err
+    }
+    if _, err := s.updateSecrets(card, cardNumber, req.CardholderName, billing); err != nil {
+        return nil, err
     }
 
-    encryptedCVV, err := s.encSvc.Encrypt(req.CVV)
-    if err != nil {
-        return nil, fmt.Errorf("failed to encrypt CVV: %w", err)
+    if err := s.fingerprintCard(card, cardNumber); err != nil {
+        return nil, err
     }
 
-    card.CardholderName = req.CardholderName
-    card.CardNumber = encryptedNumber
     card.ExpiryMonth = req.ExpiryMonth
     card.ExpiryYear = req.ExpiryYear
-    card.CVV = encryptedCVV
-    card.CardType = s.detectCardType(cardNumber)
+    info := s.enrichCard(card, cardNumber)
+    if err := s.assignNetworks(card, info, req.Networks, req.PreferredNetwork); err != nil {
+        return nil, err
+    }
+
+    if err := s.saveCard(card); err != nil {
+        return nil, err
+    }
+    if err := s.putCVV(card.ID, req.CVV); err != nil {
+        return nil, err
+    }
 
-    if err := s.repo.Update(card); err != nil {
-        return nil, fmt.Errorf("failed to update card: %w", err)
+    return s.toCardResponse(card), nil
+}
+
+// updateSecrets asigna a la tarjeta abierta el PAN, el titular y la dirección de
+// una petición: sin dirección en la petición se conserva la guardada. Devuelve si
+// el PAN cambia.
+func (s *cardService) updateSecrets(card *models.Card, cardNumber, cardholderName string, billing *models.BillingAddress) (bool, error) {
+    panChanged := card.CardNumber.String() != cardNumber
+
+    if billing != nil {
+        if err := setBillingAddress(card, billing); err != nil {
+            return false, err
+        }
     }
 
-    return s.toCardResponse(card, cardNumber), nil
+    card.CardNumber.SetString(cardNumber)
+    card.CardholderName.SetString(cardholderName)
+    return panChanged, nil
 }
 
 func (s *cardService) DeleteCard(cardID, userID uuid.UUID) error {
-    return s.repo.Delete(cardID, userID)
+    if err := s.repo.Delete(cardID, userID); err != nil {
+        return err
+    }
+    s.deleteCVV(cardID)
+    return nil
 }
 
 func (s *cardService) BatchUpdateCards(userID uuid.UUID, req *models.BatchUpdateRequest) ([]models.BatchUpdateResponse, error) {
@@ -163,18 +357,41 @@ func (s *cardService) BatchUpdateCards(userID uuid.UUID, req *models.BatchUpdate
                 }
                 return
             }
-
-            if cardUpdate.CardholderName != nil {
-                card.CardholderName = *cardUpdate.CardholderName
+            if err := requireOpen(card); err != nil {
+                responses[index] = models.BatchUpdateResponse{
+                    CardID: cardUpdate.ID,
+                    Status: "failed",
+                    Error:  err.Error(),
+                }
+                return
             }
+
             if cardUpdate.ExpiryMonth != nil {
                 card.ExpiryMonth = *cardUpdate.ExpiryMonth
             }
             if cardUpdate.ExpiryYear != nil {
                 card.ExpiryYear = *cardUpdate.ExpiryYear
             }
+            if err := validateExpiry(card.ExpiryMonth, card.ExpiryYear, time.Now()); err != nil {
+                responses[index] = models.BatchUpdateResponse{
+                    CardID: cardUpdate.ID,
+                    Status: "failed",
+                    Error:  err.Error(),
+                }
+                return
+            }
 
-            if err := s.repo.Update(card); err != nil {
+            // El titular va cifrado: cambiarlo vuelve a cifrar la tarjeta
+            if cardUpdate.CardholderName != nil {
+                err = s.swapKeyMaterial(card, func(card *models.Card) error {
+                    card.CardholderName.SetString(*cardUpdate.CardholderName)
+                    return nil
+                })
+            }
+            if err == nil {
+                err = s.repo.UpdateDetails(card)
+            }
+            if err != nil {
                 responses[index] = models.BatchUpdateResponse{
                     CardID: cardUpdate.ID,
                     Status: "failed",
@@ -194,76 +411,25 @@ func (s *cardService) BatchUpdateCards(userID uuid.UUID, req *models.BatchUpdate
     return responses, nil
 }
 
-func (s *cardService) RotateKeys() ([]models.BatchUpdateResponse, error) {
-    cards, err := s.repo.GetAllCards()
+// BindAssociatedData vuelve a cifrar las tarjetas guardadas sin datos asociados
+// para ligar cada campo a su tarjeta, usuario y nombre de campo.
+func (s *cardService) BindAssociatedData() ([]models.BatchUpdateResponse, error) {
+    cards, err := s.repo.GetAllByAADVersion(0)
     if err != nil {
         return nil, fmt.Errorf("failed to get cards: %w", err)
     }
 
-    if err := s.keyMgr.RotateKey(); err != nil {
-        return nil, fmt.Errorf("failed to rotate key: %w", err)
-    }
-
-    newKey, newVersion := s.keyMgr.GetCurrentKey()
-    newEncSvc, err := crypto.NewEncryptionService(newKey)
-    if err != nil {
-        return nil, fmt.Errorf("failed to create new encryption service: %w", err)
-    }
-
     responses := make([]models.BatchUpdateResponse, len(cards))
-    oldKey := s.keyMgr.GetPreviousKey()
-    oldEncSvc, _ := crypto.NewEncryptionService(oldKey)
 
     for i, card := range cards {
-        cardNumber, err := oldEncSvc.Decrypt(card.CardNumber)
-        if err != nil {
-            responses[i] = models.BatchUpdateResponse{
-                CardID: card.ID,
-                Status: "failed",
-                Error:  "failed to decrypt with old key",
-            }
-            continue
-        }
-
-        cvv, err := oldEncSvc.Decrypt(card.CVV)
-        if err != nil {
-            responses[i] = models.BatchUpdateResponse{
-                CardID: card.ID,
-                Status: "failed",
-                Error:  "failed to decrypt CVV with old key",
-            }
-            continue
-        }
-
-        newEncryptedNumber, err := newEncSvc.Encrypt(cardNumber)
+        err := s.swapKeyMaterial(&card, func(card *models.Card) error {
+            return vault.MarkDirty(card)
+        })
         if err != nil {
             responses[i] = models.BatchUpdateResponse{
                 CardID: card.ID,
                 Status: "failed",
-                Error:  "failed to encrypt with new key",
-            }
-            continue
-        }
-
-        newEncryptedCVV, err := newEncSvc.Encrypt(cvv)
-        if err != nil {
-            responses[i] = models.BatchUpdateResponse{
-                CardID: card.ID,
-                Status: "failed",
-                Error:  "failed to encrypt CVV with new key",
-            }
-            continue
-        }
-
-        card.CardNumber = newEncryptedNumber
-        card.CVV = newEncryptedCVV
-        card.KeyVersion = newVersion
-
-        if err := s.repo.Update(&card); err != nil {
-            responses[i] = models.BatchUpdateResponse{
-                CardID: card.ID,
-                Status: "failed",
-                Error:  "failed to update card",
+                Error:  err.Error(),
             }
             continue
         }
@@ -274,95 +440,69 @@ func (s *cardService) RotateKeys() ([]models.BatchUpdateResponse, error) {
         }
     }
 
-    s.mu.Lock()
-    s.encSvc = newEncSvc
-    s.mu.Unlock()
-
     return responses, nil
 }
 
-func (s *cardService) isValidCardNumber(cardNumber string) bool {
-    var sum int
-    alternate := false
-    
-    for i := len(cardNumber) - 1; i >= 0; i-- {
-        digit := int(cardNumber[i] - '0')
-        if digit < 0 || digit > 9 {
-            return false
-        }
-        
-        if alternate {
-            digit *= 2
-            if digit > 9 {
-                digit = digit%10 + digit/10
-            }
-        }
-        
-        sum += digit
-        alternate = !alternate
+// enrichCard completa la tarjeta con los datos del rango de BIN de su PAN y los
+// devuelve. Si el PAN no está en la tabla, los campos quedan vacíos.
+func (s *cardService) enrichCard(card *models.Card, cardNumber string) bin.Info {
+    var info bin.Info
+    if s.bins != nil {
+        info, _ = s.bins.Lookup(cardNumber)
     }
-    
-    return sum%10 == 0
-}
 
-func (s *cardService) detectCardType(cardNumber string) string {
-    patterns := map[string]*regexp.Regexp{
-        "Visa":       regexp.MustCompile(`^4[0-9]{12}(?:[0-9]{3})?$`),
-        "Mastercard": regexp.MustCompile(`^5[1-5][0-9]{14}$|^2(?:2(?:2[1-9]|[3-9][0-9])|[3-6][0-9][0-9]|7(?:[01][0-9]|20))[0-9]{12}$`),
-        "Amex":       regexp.MustCompile(`^3[47][0-9]{13}$`),
-        "Discover":   regexp.MustCompile(`^6(?:011|5[0-9]{2})[0-9]{12}$`),
-    }
-
-    for cardType, pattern := range patterns {
-        if pattern.MatchString(cardNumber) {
-            return cardType
-        }
-    }
-    
-    return "Unknown"
+    card.IssuerName = info.Issuer
+    card.IssuerCountry = info.Country
+    card.FundingType = info.Funding
+    card.CardSegment = info.Segment
+    card.ProductLevel = info.Level
+    return info
 }
 
 func (s *cardService) maskCardNumber(cardNumber string) string {
-    if len(cardNumber) < 4 {
-        return cardNumber
-    }
-    masked := strings.Repeat("*", len(cardNumber)-4)
-    return masked + cardNumber[len(cardNumber)-4:]
+    return s.panMasking.Mask(cardNumber)
 }
 
-func (s *cardService) toCardResponse(card *models.Card, decryptedNumber string) *models.CardResponse {
-    return &models.CardResponse{
-        ID:             card.ID,
-        UserID:         card.UserID,
-        CardholderName: card.CardholderName,
-        MaskedNumber:   s.maskCardNumber(decryptedNumber),
-        ExpiryMonth:    card.ExpiryMonth,
-        ExpiryYear:     card.ExpiryYear,
-        CardType:       card.CardType,
-        IsActive:       card.IsActive,
-        CreatedAt:      card.CreatedAt,
-        UpdatedAt:      card.UpdatedAt,
+// toCardResponse construye la respuesta de una tarjeta abierta o recién cifrada.
+func (s *cardService) toCardResponse(card *models.Card) *models.CardResponse {
+    // Una dirección que no se decodifica no se muestra
+    billing, _ := billingAddress(card)
+    response := &models.CardResponse{
+        ID:               card.ID,
+        UserID:           card.UserID,
+        CardholderName:   cardholderName(card),
+        MaskedNumber:     s.maskCardNumber(card.CardNumber.String()),
+        ExpiryMonth:      card.ExpiryMonth,
+        ExpiryYear:       card.ExpiryYear,
+        CardType:         card.CardType,
+        Networks:         card.Networks,
+        PreferredNetwork: card.PreferredNetwork,
+        IssuerName:       card.IssuerName,
+        IssuerCountry:    card.IssuerCountry,
+        FundingType:      card.FundingType,
+        CardSegment:      card.CardSegment,
+        ProductLevel:     card.ProductLevel,
+        BillingAddress:   address.Mask(billing, s.addressMasking),
+        Status:           cardStatus(card),
+        StatusReason:     card.StatusReason,
+        StatusChangedAt:  card.StatusChangedAt,
+        ReplacedByID:     card.ReplacedByID,
+        CreatedAt:        card.CreatedAt,
+        UpdatedAt:        card.UpdatedAt,
+    }
+    // Tarjetas guardadas antes de las redes: solo la de su marca
+    if len(response.Networks) == 0 && card.CardType != brand.Unknown.Name {
+        response.Networks = []string{card.CardType}
     }
+    response.CVVPresent, response.CVVExpiresAt = s.cvvStatus(card.ID)
+    return response
 }
 
-func (s *cardService) decryptCardNumber(card *models.Card) (string, error) {
-    s.mu.RLock()
-    defer s.mu.RUnlock()
-    
-    _, currentVersion := s.keyMgr.GetCurrentKey()
-    
-    if card.KeyVersion == currentVersion {
-        return s.encSvc.Decrypt(card.CardNumber)
+// cardholderName devuelve el titular de una tarjeta abierta, o el guardado en
+// claro si aún no se ha vuelto a sellar.
+func cardholderName(card *models.Card) string {
+    if name := card.CardholderName.String(); name != "" {
+        return name
     }
-    
-    previousKey := s.keyMgr.GetPreviousKey()
-    if previousKey != nil {
-        oldEncSvc, err := crypto.NewEncryptionService(previousKey)
-        if err != nil {
-            return "", err
-        }
-        return oldEncSvc.Decrypt(card.CardNumber)
-    }
-    
-    return "", errors.New("unable to decrypt card with available keys")
+    return card.LegacyCardholderName
 }
\ No newline at end of file
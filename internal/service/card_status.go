@@ -0,0 +1,171 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "time"
+    "card-vault/internal/models"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+var (
+    ErrCardNotActive           = errors.New("card is not active")
+    ErrInvalidStatusTransition = errors.New("invalid card status transition")
+)
+
+// FreezeCard bloquea temporalmente una tarjeta activa del usuario.
+func (s *cardService) FreezeCard(cardID, userID uuid.UUID, reason string) (*models.CardResponse, error) {
+    return s.changeStatus(cardID, userID, models.CardStatusFrozen, reason)
+}
+
+// UnfreezeCard vuelve a activar una tarjeta bloqueada.
+func (s *cardService) UnfreezeCard(cardID, userID uuid.UUID, reason string) (*models.CardResponse, error) {
+    return s.changeStatus(cardID, userID, models.CardStatusActive, reason)
+}
+
+// CloseCard da de baja una tarjeta. Es definitivo: una tarjeta cerrada no
+// puede volver a ningún otro estado.
+func (s *cardService) CloseCard(cardID, userID uuid.UUID, reason string) (*models.CardResponse, error) {
+    return s.changeStatus(cardID, userID, models.CardStatusClosed, reason)
+}
+
+// ReplaceCard sustituye una tarjeta activa, bloqueada o caducada por una nueva con
+// los datos de req: guarda la nueva y pasa la anterior a replaced con un enlace a
+// ella. La nueva nunca se fusiona con otra tarjeta, así que no puede tener el mismo
+// PAN que la sustituida. Devuelve la tarjeta nueva.
+func (s *cardService) ReplaceCard(cardID, userID uuid.UUID, req *models.CardRequest, reason string) (*models.CardResponse, error) {
+    card, err := s.repo.GetByID(cardID, userID)
+    if err != nil {
+        return nil, fmt.Errorf("card not found: %w", err)
+    }
+    if from := cardStatus(card); !validStatusTransition(from, models.CardStatusReplaced) {
+        return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, from, models.CardStatusReplaced)
+    }
+
+    replacement, err := s.createCard(userID, req, false)
+    if err != nil {
+        return nil, err
+    }
+
+    card.ReplacedByID = &replacement.ID
+    if err := s.transition(card, models.CardStatusReplaced, reason); err != nil {
+        // La anterior cambió de estado entretanto: no se deja la nueva sin sustituir nada
+        if delErr := s.repo.Delete(replacement.ID, userID); delErr != nil {
+            return nil, fmt.Errorf("%w (failed to delete replacement card %s: %v)", err, replacement.ID, delErr)
+        }
+        s.deleteCVV(replacement.ID)
+        return nil, err
+    }
+    return replacement, nil
+}
+
+func (s *cardService) GetStatusHistory(cardID, userID uuid.UUID) ([]models.CardStatusEvent, error) {
+    if _, err := s.repo.GetByID(cardID, userID); err != nil {
+        return nil, fmt.Errorf("card not found: %w", err)
+    }
+
+    events, err := s.repo.GetStatusHistory(cardID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get status history: %w", err)
+    }
+    return events, nil
+}
+
+func (s *cardService) changeStatus(cardID, userID uuid.UUID, to models.CardStatus, reason string) (*models.CardResponse, error) {
+    card, err := s.repo.GetByID(cardID, userID)
+    if err != nil {
+        return nil, fmt.Errorf("card not found: %w", err)
+    }
+
+    if err := s.transition(card, to, reason); err != nil {
+        return nil, err
+    }
+
+    return s.toCardResponse(card), nil
+}
+
+// transition lleva la tarjeta al estado to si la transición es válida y la
+// registra en el historial. Falla si otra petición cambió el estado entretanto.
+func (s *cardService) transition(card *models.Card, to models.CardStatus, reason string) error {
+    from := cardStatus(card)
+    if !validStatusTransition(from, to) {
+        return fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, from, to)
+    }
+
+    now := time.Now()
+    card.Status, card.StatusReason, card.StatusChangedAt = to, reason, &now
+    event := &models.CardStatusEvent{CardID: card.ID, FromStatus: from, ToStatus: to, Reason: reason, CreatedAt: now}
+
+    updated, err := s.repo.UpdateStatus(card, from, event)
+    if err != nil {
+        return fmt.Errorf("failed to update card status: %w", err)
+    }
+    if !updated {
+        return fmt.Errorf("%w: card status changed concurrently", ErrInvalidStatusTransition)
+    }
+
+    // Una tarjeta que no puede volver a activarse no necesita su CVV
+    if to != models.CardStatusActive && to != models.CardStatusFrozen {
+        s.deleteCVV(card.ID)
+    }
+    return nil
+}
+
+// requireActive impide usar tarjetas bloqueadas, caducadas, sustituidas o cerradas.
+func requireActive(card *models.Card) error {
+    if status := cardStatus(card); status != models.CardStatusActive {
+        return fmt.Errorf("%w: card is %s", ErrCardNotActive, status)
+    }
+    return nil
+}
+
+// requireOpen impide modificar tarjetas cerradas.
+func requireOpen(card *models.Card) error {
+    if cardStatus(card) == models.CardStatusClosed {
+        return fmt.Errorf("%w: card is closed", ErrCardNotActive)
+    }
+    return nil
+}
+
+// saveCard guarda los cambios de una tarjeta sin tocar su estado, que solo cambia
+// con transition. Falla si otra petición cambió el estado desde que se leyó, para
+// no editar una tarjeta que se acaba de bloquear o cerrar.
+func (s *cardService) saveCard(card *models.Card) error {
+    updated, err := s.repo.UpdateIfStatus(card, card.Status)
+    if errors.Is(err, gorm.ErrDuplicatedKey) {
+        return ErrDuplicateCard
+    }
+    if err != nil {
+        return fmt.Errorf("failed to update card: %w", err)
+    }
+    if !updated {
+        return fmt.Errorf("%w: card status changed concurrently", ErrInvalidStatusTransition)
+    }
+    return nil
+}
+
+// cardStatus trata como activas las tarjetas guardadas antes de existir el estado.
+func cardStatus(card *models.Card) models.CardStatus {
+    if card.Status == "" {
+        return models.CardStatusActive
+    }
+    return card.Status
+}
+
+func validStatusTransition(from, to models.CardStatus) bool {
+    switch from {
+    case models.CardStatusActive:
+        return to == models.CardStatusFrozen || to == models.CardStatusExpired ||
+            to == models.CardStatusReplaced || to == models.CardStatusClosed
+    case models.CardStatusFrozen:
+        return to == models.CardStatusActive || to == models.CardStatusExpired ||
+            to == models.CardStatusReplaced || to == models.CardStatusClosed
+    case models.CardStatusExpired:
+        return to == models.CardStatusReplaced || to == models.CardStatusClosed
+    case models.CardStatusReplaced:
+        return to == models.CardStatusClosed
+    }
+    return false
+}
\ No newline at end of file
@@ -0,0 +1,88 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "time"
+    "card-vault/internal/brand"
+    "card-vault/internal/cvv"
+    "card-vault/internal/models"
+
+    "github.com/google/uuid"
+)
+
+var ErrCVVStorageDisabled = errors.New("CVV storage is not configured")
+
+// StoreCVV guarda un CVV nuevo para una tarjeta del usuario durante el TTL del almacén.
+func (s *cardService) StoreCVV(cardID, userID uuid.UUID, code string) (*models.CardResponse, error) {
+    card, err := s.repo.GetByID(cardID, userID)
+    if err != nil {
+        return nil, fmt.Errorf("card not found: %w", err)
+    }
+
+    if err := requireActive(card); err != nil {
+        return nil, err
+    }
+
+    cardBrand, _ := brand.Lookup(card.CardType)
+    if err := cardBrand.ValidateCVV(code); err != nil {
+        return nil, err
+    }
+
+    if s.cvvs == nil {
+        return nil, ErrCVVStorageDisabled
+    }
+    if err := s.putCVV(card.ID, code); err != nil {
+        return nil, err
+    }
+
+    return s.toCardResponse(card), nil
+}
+
+// UseCVV entrega el CVV de una tarjeta del usuario y lo borra: tras el primer uso
+// (la autorización) ya no se puede volver a leer.
+func (s *cardService) UseCVV(cardID, userID uuid.UUID) (*models.CVVResponse, error) {
+    card, err := s.repo.GetByID(cardID, userID)
+    if err != nil {
+        return nil, fmt.Errorf("card not found: %w", err)
+    }
+    if err := requireActive(card); err != nil {
+        return nil, err
+    }
+
+    if s.cvvs == nil {
+        return nil, cvv.ErrNotFound
+    }
+    code, err := s.cvvs.Take(cardID)
+    if err != nil {
+        return nil, err
+    }
+    return &models.CVVResponse{CVV: code}, nil
+}
+
+func (s *cardService) putCVV(cardID uuid.UUID, code string) error {
+    if s.cvvs == nil || code == "" {
+        return nil
+    }
+    if _, err := s.cvvs.Put(cardID, code); err != nil {
+        return fmt.Errorf("failed to store CVV: %w", err)
+    }
+    return nil
+}
+
+func (s *cardService) deleteCVV(cardID uuid.UUID) {
+    if s.cvvs != nil {
+        s.cvvs.Delete(cardID)
+    }
+}
+
+func (s *cardService) cvvStatus(cardID uuid.UUID) (bool, *time.Time) {
+    if s.cvvs == nil {
+        return false, nil
+    }
+    expiresAt, ok := s.cvvs.ExpiresAt(cardID)
+    if !ok {
+        return false, nil
+    }
+    return true, &expiresAt
+}
\ No newline at end of file
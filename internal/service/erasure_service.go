@@ -0,0 +1,94 @@
+package service
+
+import (
+    "fmt"
+    "strings"
+    "card-vault/internal/crypto"
+    "card-vault/internal/cvv"
+    "card-vault/internal/models"
+    "card-vault/internal/repository"
+
+    "github.com/google/uuid"
+)
+
+type ErasureService interface {
+    ShredUser(actorID, userID uuid.UUID) (*models.AuditRecord, error)
+}
+
+type erasureService struct {
+    repo   repository.CardRepository
+    tokens repository.TokenRepository
+    audit  repository.AuditRepository
+    cvvs   cvv.Store
+    keyMgr *crypto.KeyManager
+}
+
+// NewErasureService crea el servicio. tokens y cvvs pueden ser nil si el servicio
+// de tarjetas tampoco los usa.
+func NewErasureService(repo repository.CardRepository, tokens repository.TokenRepository, audit repository.AuditRepository, cvvs cvv.Store, keyMgr *crypto.KeyManager) ErasureService {
+    return &erasureService{
+        repo:   repo,
+        tokens: tokens,
+        audit:  audit,
+        cvvs:   cvvs,
+        keyMgr: keyMgr,
+    }
+}
+
+// ShredUser borra de forma irreversible los datos de tarjeta de un usuario
+// (crypto-shredding). Primero se destruye su clave, con lo que cualquier copia de
+// sus tarjetas, incluidas las de las copias de seguridad, deja de poder
+// descifrarse y no se pueden crear otras; después se borran las filas, los CVVs
+// guardados y los tokens aleatorios de esas tarjetas, y se registra la operación.
+func (s *erasureService) ShredUser(actorID, userID uuid.UUID) (*models.AuditRecord, error) {
+    if err := s.keyMgr.DestroyUserKey(userID.String()); err != nil {
+        return nil, fmt.Errorf("failed to destroy user key: %w", err)
+    }
+
+    // Los IDs se leen antes de borrar las filas: con ellos se purgan CVVs y tokens
+    var deleted, purged, tokensDeleted int64
+    cardIDs, deleteErr := s.repo.ListIDsByUserID(userID)
+    if deleteErr == nil {
+        deleted, deleteErr = s.repo.DeleteByUserID(userID)
+    }
+    if s.cvvs != nil {
+        for _, cardID := range cardIDs {
+            if _, ok := s.cvvs.ExpiresAt(cardID); ok {
+                purged++
+            }
+            s.cvvs.Delete(cardID)
+        }
+    }
+    var tokenErr error
+    if s.tokens != nil {
+        tokensDeleted, tokenErr = s.tokens.DeleteByCardIDs(cardIDs)
+    }
+
+    // La clave ya no existe: la operación se audita aunque falle algún borrado
+    details := []string{"user key destroyed", fmt.Sprintf("%d cards deleted", deleted), fmt.Sprintf("%d CVVs purged", purged), fmt.Sprintf("%d tokens deleted", tokensDeleted)}
+    if deleteErr != nil {
+        details[1] = fmt.Sprintf("failed to delete cards: %v", deleteErr)
+    }
+    if tokenErr != nil {
+        details[3] = fmt.Sprintf("failed to delete tokens: %v", tokenErr)
+    }
+
+    record := &models.AuditRecord{
+        ID:        uuid.New(),
+        Action:    models.AuditActionShredUser,
+        ActorID:   actorID,
+        SubjectID: userID,
+        Details:   strings.Join(details, "; "),
+    }
+    if err := s.audit.Create(record); err != nil {
+        return nil, fmt.Errorf("failed to write audit record: %w", err)
+    }
+
+    if deleteErr != nil {
+        return record, fmt.Errorf("failed to delete cards: %w", deleteErr)
+    }
+    if tokenErr != nil {
+        return record, fmt.Errorf("failed to delete tokens: %w", tokenErr)
+    }
+    return record, nil
+}
\ No newline at end of file
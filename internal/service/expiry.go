@@ -0,0 +1,150 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "time"
+    "card-vault/internal/models"
+    "card-vault/internal/repository"
+
+    "github.com/google/uuid"
+)
+
+// Las tarjetas se emiten con una validez de pocos años; más allá de este margen
+// la fecha es casi seguro un error de captura.
+const maxExpiryYears = 20
+
+// expiryPageSize es el número de tarjetas que el trabajo de caducidad carga cada vez.
+const expiryPageSize = 100
+
+var ErrInvalidExpiry = errors.New("invalid expiry date")
+
+// ExpiryResult resume una ejecución del trabajo de caducidad.
+type ExpiryResult struct {
+    RanAt        time.Time `json:"ran_at"`
+    ExpiredCards int       `json:"expired_cards"`
+    FailedCards  int       `json:"failed_cards"`
+}
+
+// ExpireCards pasa a expired las tarjetas activas o bloqueadas cuya fecha de
+// caducidad es anterior a now. Una tarjeta caduca al terminar su mes de caducidad.
+// Las recorre por páginas de expiryPageSize sin descifrarlas: el cambio de estado
+// solo usa columnas en claro.
+func (s *cardService) ExpireCards(now time.Time) (*ExpiryResult, error) {
+    result := &ExpiryResult{RanAt: now}
+    var after *repository.CardCursor
+    for {
+        cards, err := s.repo.ListExpiring(0, monthIndex(now), after, expiryPageSize)
+        if err != nil {
+            return nil, fmt.Errorf("failed to get expired cards: %w", err)
+        }
+
+        for i := range cards {
+            if err := s.transition(&cards[i], models.CardStatusExpired, "card expiry date passed"); err != nil {
+                result.FailedCards++
+                continue
+            }
+            result.ExpiredCards++
+        }
+        if len(cards) < expiryPageSize {
+            return result, nil
+        }
+
+        last := cards[len(cards)-1]
+        after = &repository.CardCursor{ID: last.ID, Expiry: last.ExpiryYear*12 + last.ExpiryMonth - 1}
+    }
+}
+
+// GetExpiringCards devuelve las tarjetas activas o bloqueadas del usuario que
+// caducan en los próximos days días.
+func (s *cardService) GetExpiringCards(userID uuid.UUID, days int) ([]models.CardResponse, error) {
+    now := time.Now()
+    cards, err := s.repo.GetByExpiryRange(userID, monthIndex(now), monthIndex(now.AddDate(0, 0, days)))
+    if err != nil {
+        return nil, fmt.Errorf("failed to get expiring cards: %w", err)
+    }
+
+    responses := make([]models.CardResponse, len(cards))
+    for i := range cards {
+        responses[i] = *s.toCardResponse(&cards[i])
+    }
+    return responses, nil
+}
+
+// GetAllExpiringCards devuelve una página de las tarjetas activas o bloqueadas de
+// todos los usuarios que caducan en los próximos days días, de la más próxima a la
+// más lejana. Las respuestas solo llevan datos en claro: no se descifra nada.
+func (s *cardService) GetAllExpiringCards(days int, query *models.PageQuery) (*models.ExpiringCardPage, error) {
+    limit := defaultCardPageSize
+    if query.Limit != 0 {
+        if query.Limit < 1 || query.Limit > maxCardPageSize {
+            return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidCardQuery, maxCardPageSize)
+        }
+        limit = query.Limit
+    }
+
+    var after *repository.CardCursor
+    if query.Cursor != "" {
+        cursor, err := decodeCursor(query.Cursor)
+        if err != nil || cursor.Sort != models.CardSortExpiry || cursor.Order != "asc" {
+            return nil, ErrInvalidCursor
+        }
+        after = &repository.CardCursor{ID: cursor.ID, Expiry: cursor.Expiry}
+    }
+
+    // Una tarjeta de más indica si hay otra página
+    now := time.Now()
+    cards, err := s.repo.ListExpiring(monthIndex(now), monthIndex(now.AddDate(0, 0, days)), after, limit+1)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get expiring cards: %w", err)
+    }
+
+    page := &models.ExpiringCardPage{
+        Cards:      make([]models.ExpiringCard, 0, min(len(cards), limit)),
+        Pagination: models.Pagination{Limit: limit, Sort: models.CardSortExpiry, Order: "asc"},
+    }
+    if len(cards) > limit {
+        cards = cards[:limit]
+        last := cards[limit-1]
+        page.Pagination.HasMore = true
+        page.Pagination.NextCursor = encodeCursor(pageCursor{
+            Sort:   models.CardSortExpiry,
+            Order:  "asc",
+            ID:     last.ID,
+            Expiry: last.ExpiryYear*12 + last.ExpiryMonth - 1,
+        })
+    }
+
+    for i := range cards {
+        page.Cards = append(page.Cards, models.ExpiringCard{
+            ID:          cards[i].ID,
+            UserID:      cards[i].UserID,
+            Last4:       cards[i].Last4,
+            CardType:    cards[i].CardType,
+            ExpiryMonth: cards[i].ExpiryMonth,
+            ExpiryYear:  cards[i].ExpiryYear,
+            Status:      cardStatus(&cards[i]),
+        })
+    }
+    return page, nil
+}
+
+// validateExpiry rechaza las tarjetas ya caducadas en now y las fechas a más de
+// maxExpiryYears años vista.
+func validateExpiry(month, year int, now time.Time) error {
+    expiry := year*12 + month - 1
+    if expiry < monthIndex(now) {
+        return fmt.Errorf("%w: card expired in %02d/%d", ErrInvalidExpiry, month, year)
+    }
+    if expiry > monthIndex(now.AddDate(maxExpiryYears, 0, 0)) {
+        return fmt.Errorf("%w: %02d/%d is more than %d years ahead", ErrInvalidExpiry, month, year, maxExpiryYears)
+    }
+    return nil
+}
+
+// monthIndex numera los meses de forma consecutiva (año*12 + mes-1), el mismo
+// criterio con el que el repositorio compara las fechas de caducidad.
+func monthIndex(t time.Time) int {
+    t = t.UTC()
+    return t.Year()*12 + int(t.Month()) - 1
+}
\ No newline at end of file
@@ -0,0 +1,229 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "card-vault/internal/crypto"
+    "card-vault/internal/models"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+// DuplicatePolicy decide qué hacer cuando un usuario guarda un PAN que ya tiene.
+type DuplicatePolicy string
+
+const (
+    DuplicateReject DuplicatePolicy = "reject"
+    DuplicateMerge  DuplicatePolicy = "merge"
+    DuplicateAllow  DuplicatePolicy = "allow"
+)
+
+var (
+    ErrDuplicateCard             = errors.New("card is already stored for this user")
+    ErrInvalidDuplicatePolicy    = errors.New("duplicate policy must be reject, merge or allow")
+    ErrFingerprintReindexPending = errors.New("card numbers cannot be stored until the fingerprint reindex finishes")
+)
+
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+    switch policy := DuplicatePolicy(name); policy {
+    case DuplicateReject, DuplicateMerge, DuplicateAllow:
+        return policy, nil
+    }
+    return "", ErrInvalidDuplicatePolicy
+}
+
+// FingerprintReindexResult resume un recálculo de huellas.
+type FingerprintReindexResult struct {
+    KeyVersion      int   `json:"key_version"`
+    ProcessedCards  int   `json:"processed_cards"`
+    // Tarjetas que otra escritura ya guardó con una huella vigente
+    SkippedCards    int   `json:"skipped_cards"`
+    FailedCards     int   `json:"failed_cards"`
+    RetiredVersions []int `json:"retired_versions,omitempty"`
+}
+
+// fingerprintCard asigna a la tarjeta lo que permite buscarla sin descifrar su
+// PAN: la huella con la versión activa y los 4 últimos dígitos.
+func (s *cardService) fingerprintCard(card *models.Card, cardNumber string) error {
+    value, version, err := s.keyMgr.Fingerprint([]byte(cardNumber))
+    if err != nil {
+        return fmt.Errorf("failed to fingerprint card number: %w", err)
+    }
+
+    card.PANFingerprint = value
+    card.FingerprintVersion = version
+    card.Last4 = cardNumber[max(len(cardNumber)-4, 0):]
+    return nil
+}
+
+// findByPAN busca por huella, con todas las versiones vigentes de la clave, las
+// tarjetas con ese PAN sin descifrar ninguna fila.
+func (s *cardService) findByPAN(cardNumber string) ([]models.Card, error) {
+    fingerprints, err := s.keyMgr.Fingerprints([]byte(cardNumber))
+    if err != nil {
+        return nil, fmt.Errorf("failed to fingerprint card number: %w", err)
+    }
+
+    values := make([][]byte, len(fingerprints))
+    for i, fp := range fingerprints {
+        values[i] = fp.Value
+    }
+
+    cards, err := s.repo.FindByFingerprints(values)
+    if err != nil {
+        return nil, fmt.Errorf("failed to look up card: %w", err)
+    }
+    return cards, nil
+}
+
+// findDuplicate devuelve otra tarjeta del usuario con el mismo PAN, o nil. Mientras
+// quede alguna versión retirada de la clave de huellas sin destruir, falla con
+// ErrFingerprintReindexPending: el índice único solo ve duplicados con la misma
+// versión y no evitaría que dos escrituras concurrentes los guarden.
+func (s *cardService) findDuplicate(userID, exceptID uuid.UUID, cardNumber string) (*models.Card, error) {
+    if s.duplicates == DuplicateAllow {
+        return nil, nil
+    }
+
+    live := 0
+    for _, key := range s.keyMgr.FingerprintKeys() {
+        if key.State != crypto.KeyStateDestroyed {
+            live++
+        }
+    }
+    if live > 1 {
+        return nil, ErrFingerprintReindexPending
+    }
+
+    cards, err := s.findByPAN(cardNumber)
+    if err != nil {
+        return nil, err
+    }
+    for i := range cards {
+        // Una tarjeta cerrada no impide volver a guardar el mismo PAN
+        if cards[i].UserID == userID && cards[i].ID != exceptID && cards[i].Status != models.CardStatusClosed {
+            return &cards[i], nil
+        }
+    }
+    return nil, nil
+}
+
+// LookupByPAN devuelve las tarjetas de cualquier usuario con ese PAN.
+func (s *cardService) LookupByPAN(cardNumber string) ([]models.CardResponse, error) {
+    cardNumber = strings.ReplaceAll(cardNumber, " ", "")
+
+    cards, err := s.findByPAN(cardNumber)
+    if err != nil {
+        return nil, err
+    }
+
+    // Sin descifrar las tarjetas las respuestas no incluyen el titular ni la
+    // dirección de facturación
+    responses := make([]models.CardResponse, len(cards))
+    for i := range cards {
+        response := s.toCardResponse(&cards[i])
+        response.MaskedNumber = s.maskCardNumber(cardNumber)
+        response.CardholderName = ""
+        responses[i] = *response
+    }
+    return responses, nil
+}
+
+// ReindexFingerprints recalcula con la versión activa las huellas de las tarjetas
+// que no la usan (o no tienen huella ni últimos 4 dígitos), rotando antes la clave
+// si rotate es true.
+// Si no falla ninguna tarjeta, destruye las versiones retiradas que ya no usa nadie.
+func (s *cardService) ReindexFingerprints(rotate bool) (*FingerprintReindexResult, error) {
+    if rotate {
+        if err := s.keyMgr.RotateFingerprintKey(); err != nil {
+            return nil, fmt.Errorf("failed to rotate fingerprint key: %w", err)
+        }
+    }
+
+    activeVersion, err := s.keyMgr.ActiveFingerprintVersion()
+    if err != nil {
+        return nil, err
+    }
+
+    result := &FingerprintReindexResult{KeyVersion: activeVersion}
+
+    afterID := uuid.Nil
+    for {
+        cards, err := s.repo.GetPageAfter(afterID, rotationPageSize)
+        if err != nil {
+            return nil, fmt.Errorf("failed to get cards: %w", err)
+        }
+        if len(cards) == 0 {
+            break
+        }
+
+        for i := range cards {
+            card := &cards[i]
+            afterID = card.ID
+            if card.FingerprintVersion == result.KeyVersion && card.Last4 != "" {
+                continue
+            }
+
+            result.ProcessedCards++
+            updated, err := s.reindexCard(card, result.KeyVersion)
+            if err != nil {
+                result.FailedCards++
+            } else if !updated {
+                result.SkippedCards++
+            }
+        }
+    }
+
+    if result.FailedCards > 0 {
+        return result, nil
+    }
+
+    for _, key := range s.keyMgr.FingerprintKeys() {
+        if key.State == crypto.KeyStateActive || key.State == crypto.KeyStateDestroyed {
+            continue
+        }
+
+        count, err := s.repo.CountByFingerprintVersion(key.Version)
+        if err != nil || count > 0 {
+            continue
+        }
+        if err := s.keyMgr.DestroyFingerprintKey(key.Version); err == nil {
+            result.RetiredVersions = append(result.RetiredVersions, key.Version)
+        }
+    }
+    return result, nil
+}
+
+// reindexCard guarda la huella de la tarjeta con compare-and-swap sobre su DEK. Si
+// otra escritura la cambió entretanto, se relee y, si su huella no usa aún
+// activeVersion, se vuelve a calcular. Devuelve false si no hizo falta guardarla.
+func (s *cardService) reindexCard(card *models.Card, activeVersion int) (bool, error) {
+    for attempt := 0; attempt < maxSwapAttempts; attempt++ {
+        cardNumber, err := s.decryptCardNumber(card)
+        if err != nil {
+            return false, err
+        }
+        if err := s.fingerprintCard(card, cardNumber); err != nil {
+            return false, err
+        }
+
+        updated, err := s.repo.UpdateFingerprint(card)
+        if err != nil || updated {
+            return updated, err
+        }
+
+        card, err = s.repo.FindByID(card.ID)
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return false, nil
+        }
+        if err != nil {
+            return false, fmt.Errorf("failed to reload card: %w", err)
+        }
+        if card.FingerprintVersion == activeVersion && card.Last4 != "" {
+            return false, nil
+        }
+    }
+    return false, ErrConcurrentUpdate
+}
\ No newline at end of file
@@ -0,0 +1,53 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+    "card-vault/internal/repository"
+)
+
+var ErrKeyInUse = errors.New("key version is still used by stored cards")
+
+type KeyService interface {
+    ListKeys() []crypto.KeyInfo
+    SetKeyState(version int, state crypto.KeyState) error
+    ListFingerprintKeys() []crypto.KeyInfo
+}
+
+type keyService struct {
+    repo   repository.CardRepository
+    keyMgr *crypto.KeyManager
+}
+
+func NewKeyService(repo repository.CardRepository, keyMgr *crypto.KeyManager) KeyService {
+    return &keyService{
+        repo:   repo,
+        keyMgr: keyMgr,
+    }
+}
+
+func (s *keyService) ListKeys() []crypto.KeyInfo {
+    return s.keyMgr.ListKeys()
+}
+
+func (s *keyService) ListFingerprintKeys() []crypto.KeyInfo {
+    return s.keyMgr.FingerprintKeys()
+}
+
+func (s *keyService) SetKeyState(version int, state crypto.KeyState) error {
+    // Destruir una versión es irreversible: solo se permite cuando ninguna tarjeta depende de ella.
+    // Solo cuentan las tarjetas del keyring local; las de Transit tienen sus propias versiones
+    if state == crypto.KeyStateDestroyed {
+        count, err := s.repo.CountByKeyVersion([]string{kms.LocalProviderName, ""}, version)
+        if err != nil {
+            return fmt.Errorf("failed to count cards for key version: %w", err)
+        }
+        if count > 0 {
+            return fmt.Errorf("%w: %d cards on version %d", ErrKeyInUse, count, version)
+        }
+    }
+
+    return s.keyMgr.SetKeyState(version, state)
+}
\ No newline at end of file
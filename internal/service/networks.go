@@ -0,0 +1,93 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "slices"
+    "card-vault/internal/bin"
+    "card-vault/internal/brand"
+    "card-vault/internal/models"
+
+    "github.com/google/uuid"
+)
+
+var ErrUnsupportedNetwork = errors.New("card does not support the requested network")
+
+// assignNetworks calcula las redes de la tarjeta: la de su marca, las de su rango
+// de BIN y las que indique el cliente (tarjetas co-badged). La red preferida debe
+// ser una de ellas; si se deja vacía se conserva la anterior mientras siga siendo válida.
+func (s *cardService) assignNetworks(card *models.Card, info bin.Info, requested []string, preferred string) error {
+    var candidates []string
+    if card.CardType != brand.Unknown.Name {
+        candidates = append(candidates, card.CardType)
+    }
+    candidates = append(candidates, info.Networks...)
+    candidates = append(candidates, requested...)
+
+    networks, err := brand.CanonicalNetworks(candidates)
+    if err != nil {
+        return err
+    }
+    card.Networks = networks
+
+    if preferred == "" {
+        if !slices.Contains(networks, card.PreferredNetwork) {
+            card.PreferredNetwork = ""
+        }
+        return nil
+    }
+
+    network, err := s.supportedNetwork(card, preferred)
+    if err != nil {
+        return err
+    }
+    card.PreferredNetwork = network
+    return nil
+}
+
+// SetPreferredNetwork elige la red por la que el titular quiere que se procesen
+// los pagos de una tarjeta co-badged. Como las demás ediciones, no se aplica a
+// tarjetas cerradas ni si el estado cambia entretanto.
+func (s *cardService) SetPreferredNetwork(cardID, userID uuid.UUID, network string) (*models.CardResponse, error) {
+    card, err := s.repo.GetByID(cardID, userID)
+    if err != nil {
+        return nil, fmt.Errorf("card not found: %w", err)
+    }
+    if err := requireOpen(card); err != nil {
+        return nil, err
+    }
+
+    if card.PreferredNetwork, err = s.supportedNetwork(card, network); err != nil {
+        return nil, err
+    }
+
+    updated, err := s.repo.UpdatePreferredNetwork(card, card.Status)
+    if err != nil {
+        return nil, fmt.Errorf("failed to update card: %w", err)
+    }
+    if !updated {
+        return nil, fmt.Errorf("%w: card status changed concurrently", ErrInvalidStatusTransition)
+    }
+
+    return s.toCardResponse(card), nil
+}
+
+// paymentNetwork es la red por la que se procesa la tarjeta: la preferida o, si
+// no hay, la de su marca (vacía si la marca es desconocida).
+func paymentNetwork(card *models.Card) string {
+    if card.PreferredNetwork != "" || card.CardType == brand.Unknown.Name {
+        return card.PreferredNetwork
+    }
+    return card.CardType
+}
+
+func (s *cardService) supportedNetwork(card *models.Card, name string) (string, error) {
+    network, err := brand.CanonicalNetwork(name)
+    if err != nil {
+        return "", err
+    }
+    if !slices.Contains(card.Networks, network) {
+        return "", fmt.Errorf("%w: %s", ErrUnsupportedNetwork, network)
+    }
+    return network, nil
+}
\ No newline at end of file
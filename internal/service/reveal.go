@@ -0,0 +1,94 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "unicode/utf8"
+    "card-vault/internal/cvv"
+    "card-vault/internal/models"
+
+    "github.com/google/uuid"
+)
+
+// RevealPurpose es el motivo declarado por quien lee el PAN completo de una tarjeta.
+type RevealPurpose string
+
+const (
+    RevealPayment            RevealPurpose = "payment"
+    RevealRefund             RevealPurpose = "refund"
+    RevealChargeback         RevealPurpose = "chargeback"
+    RevealFraudInvestigation RevealPurpose = "fraud_investigation"
+    RevealCustomerSupport    RevealPurpose = "customer_support"
+    RevealCompliance         RevealPurpose = "compliance"
+)
+
+const maxRevealReasonLength = 255
+
+var (
+    ErrInvalidRevealPurpose = errors.New("reveal purpose must be payment, refund, chargeback, fraud_investigation, customer_support or compliance")
+    ErrRevealReasonTooLong  = errors.New("reveal reason must be at most 255 characters")
+    ErrRevealNotAudited     = errors.New("card reveal requires an audit repository")
+)
+
+func ParseRevealPurpose(name string) (RevealPurpose, error) {
+    switch purpose := RevealPurpose(name); purpose {
+    case RevealPayment, RevealRefund, RevealChargeback, RevealFraudInvestigation, RevealCustomerSupport, RevealCompliance:
+        return purpose, nil
+    }
+    return "", ErrInvalidRevealPurpose
+}
+
+// RevealCard devuelve a actorID el PAN completo y la caducidad de una tarjeta activa
+// de cualquier usuario y, con includeCVV, su CVV si aún se guarda (que se consume
+// como en UseCVV). Quien llama comprueba antes el scope cards:reveal. Cada lectura
+// queda en el registro de auditoría, con el actor y el propietario de la tarjeta,
+// antes de entregar nada: si no se puede auditar, no se revela.
+func (s *cardService) RevealCard(cardID, actorID uuid.UUID, purpose, reason string, includeCVV bool) (*models.RevealResponse, error) {
+    revealPurpose, err := ParseRevealPurpose(purpose)
+    if err != nil {
+        return nil, err
+    }
+    if utf8.RuneCountInString(reason) > maxRevealReasonLength {
+        return nil, ErrRevealReasonTooLong
+    }
+    if s.audit == nil {
+        return nil, ErrRevealNotAudited
+    }
+
+    card, err := s.repo.FindByID(cardID)
+    if err != nil {
+        return nil, fmt.Errorf("card not found: %w", err)
+    }
+    if err := requireActive(card); err != nil {
+        return nil, err
+    }
+
+    cardNumber := card.CardNumber.String()
+
+    record := &models.AuditRecord{
+        ID:        uuid.New(),
+        Action:    models.AuditActionRevealCard,
+        ActorID:   actorID,
+        SubjectID: card.ID,
+        OwnerID:   &card.UserID,
+        Details:   fmt.Sprintf("purpose=%s; cvv_requested=%t; reason=%q", revealPurpose, includeCVV, reason),
+    }
+    if err := s.audit.Create(record); err != nil {
+        return nil, fmt.Errorf("failed to write audit record: %w", err)
+    }
+
+    response := &models.RevealResponse{
+        CardID:      card.ID,
+        CardNumber:  cardNumber,
+        ExpiryMonth: card.ExpiryMonth,
+        ExpiryYear:  card.ExpiryYear,
+    }
+    if includeCVV && s.cvvs != nil {
+        code, err := s.cvvs.Take(card.ID)
+        if err != nil && !errors.Is(err, cvv.ErrNotFound) {
+            return nil, err
+        }
+        response.CVV = code
+    }
+    return response, nil
+}
\ No newline at end of file
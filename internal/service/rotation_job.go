@@ -0,0 +1,203 @@
+package service
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "time"
+    "card-vault/internal/models"
+
+    "github.com/google/uuid"
+)
+
+// Tarjetas que se rewrapean entre dos checkpoints.
+const rotationPageSize = 100
+
+// RotationJobLease es cuánto tiempo reserva un trabajo la réplica que lo ejecuta.
+// Se renueva en cada checkpoint; si vence, otra réplica puede reanudarlo.
+const RotationJobLease = 5 * time.Minute
+
+var (
+    ErrRotationInProgress  = errors.New("a key rotation job is already running")
+    ErrRotationJobNotFound = errors.New("rotation job not found")
+    errRotationJobLost     = errors.New("rotation job was claimed by another replica")
+)
+
+// RotateKeys registra un trabajo de rotación y lo ejecuta en segundo plano.
+// Devuelve el trabajo recién creado para poder consultar su progreso.
+func (s *cardService) RotateKeys() (*models.RotationJob, error) {
+    s.jobMu.Lock()
+    defer s.jobMu.Unlock()
+
+    running, err := s.jobs.GetByStatus(models.RotationJobRunning)
+    if err != nil {
+        return nil, fmt.Errorf("failed to check running jobs: %w", err)
+    }
+    if len(running) > 0 {
+        return nil, ErrRotationInProgress
+    }
+
+    lease := time.Now().Add(RotationJobLease)
+    job := &models.RotationJob{
+        ID:             uuid.New(),
+        Status:         models.RotationJobRunning,
+        KeyProvider:    s.kms.Name(),
+        Owner:          s.owner,
+        LeaseExpiresAt: &lease,
+    }
+    if err := s.jobs.Create(job); err != nil {
+        return nil, fmt.Errorf("failed to create rotation job: %w", err)
+    }
+
+    started := *job
+    go s.runRotationJob(job)
+    return &started, nil
+}
+
+func (s *cardService) GetRotationJob(id uuid.UUID) (*models.RotationJob, error) {
+    job, err := s.jobs.GetByID(id)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrRotationJobNotFound, err)
+    }
+    return job, nil
+}
+
+// ResumeRotationJobs relanza los trabajos que quedaron en curso, p.ej. tras un
+// reinicio. Solo se reanudan los que esta réplica consigue reclamar porque su lease
+// ha vencido; cada uno continúa desde su último checkpoint.
+func (s *cardService) ResumeRotationJobs() error {
+    jobs, err := s.jobs.GetByStatus(models.RotationJobRunning)
+    if err != nil {
+        return fmt.Errorf("failed to load rotation jobs: %w", err)
+    }
+
+    for i := range jobs {
+        job := &jobs[i]
+        lease := time.Now().Add(RotationJobLease)
+        claimed, err := s.jobs.Claim(job.ID, s.owner, lease)
+        if err != nil {
+            return fmt.Errorf("failed to claim rotation job %s: %w", job.ID, err)
+        }
+        if !claimed {
+            continue
+        }
+
+        job.Owner = s.owner
+        job.LeaseExpiresAt = &lease
+        log.Printf("Resuming key rotation job %s after card %s", job.ID, job.LastCardID)
+        go s.runRotationJob(job)
+    }
+    return nil
+}
+
+// runRotationJob rota la clave (una sola vez por trabajo) y rewrapea las tarjetas
+// por páginas, guardando el checkpoint tras cada página. Una caída reprocesa como
+// mucho la página en curso, lo que es inocuo porque rewrapear es idempotente.
+func (s *cardService) runRotationJob(job *models.RotationJob) {
+    if !job.KeyRotated {
+        usage, err := s.kms.KeyUsage()
+        if err != nil {
+            s.failRotationJob(job, fmt.Errorf("failed to read active key: %w", err))
+            return
+        }
+
+        // La versión a la que se rota se guarda antes de rotar: si el proceso cae
+        // antes del checkpoint siguiente, quien lo reanude ve que ya está activa
+        if job.TargetVersion == 0 {
+            job.TargetVersion = usage.Version + 1
+            if !s.checkpointRotationJob(job) {
+                return
+            }
+        }
+        if usage.Version < job.TargetVersion {
+            if err := s.kms.RotateKey(); err != nil {
+                s.failRotationJob(job, fmt.Errorf("failed to rotate key: %w", err))
+                return
+            }
+        }
+
+        job.KeyRotated = true
+        if !s.checkpointRotationJob(job) {
+            return
+        }
+    }
+
+    for {
+        cards, err := s.repo.GetPageAfter(job.LastCardID, rotationPageSize)
+        if err != nil {
+            s.failRotationJob(job, fmt.Errorf("failed to get cards: %w", err))
+            return
+        }
+        if len(cards) == 0 {
+            break
+        }
+
+        for i := range cards {
+            if err := s.rotateCard(&cards[i]); err != nil {
+                job.FailedCards++
+                job.LastError = fmt.Sprintf("card %s: %v", cards[i].ID, err)
+                continue
+            }
+            job.ProcessedCards++
+        }
+
+        job.LastCardID = cards[len(cards)-1].ID
+        if !s.checkpointRotationJob(job) {
+            return
+        }
+    }
+
+    now := time.Now()
+    job.Status = models.RotationJobCompleted
+    job.CompletedAt = &now
+    if err := s.saveRotationJob(job); err != nil {
+        log.Printf("Failed to complete key rotation job %s: %v", job.ID, err)
+    }
+}
+
+// checkpointRotationJob guarda el progreso y renueva el lease. Devuelve false si
+// el trabajo debe detenerse: porque falló el guardado o porque ya no es nuestro.
+func (s *cardService) checkpointRotationJob(job *models.RotationJob) bool {
+    err := s.saveRotationJob(job)
+    switch {
+    case err == nil:
+        return true
+    case errors.Is(err, errRotationJobLost):
+        log.Printf("Key rotation job %s stopped: %v", job.ID, err)
+    default:
+        s.failRotationJob(job, fmt.Errorf("failed to save checkpoint: %w", err))
+    }
+    return false
+}
+
+// saveRotationJob guarda el trabajo con el lease renovado, siempre que esta réplica
+// siga siendo su dueña.
+func (s *cardService) saveRotationJob(job *models.RotationJob) error {
+    lease := time.Now().Add(RotationJobLease)
+    job.LeaseExpiresAt = &lease
+
+    saved, err := s.jobs.Checkpoint(job)
+    if err != nil {
+        return err
+    }
+    if !saved {
+        return errRotationJobLost
+    }
+    return nil
+}
+
+func (s *cardService) rotateCard(card *models.Card) error {
+    return s.swapKeyMaterial(card, s.rewrapCard)
+}
+
+func (s *cardService) failRotationJob(job *models.RotationJob, err error) {
+    log.Printf("Key rotation job %s failed: %v", job.ID, err)
+
+    now := time.Now()
+    job.Status = models.RotationJobFailed
+    job.LastError = err.Error()
+    job.CompletedAt = &now
+    if err := s.saveRotationJob(job); err != nil {
+        log.Printf("Failed to save key rotation job %s: %v", job.ID, err)
+    }
+}
\ No newline at end of file
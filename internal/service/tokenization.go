@@ -0,0 +1,216 @@
+package service
+
+import (
+    "crypto/rand"
+    "errors"
+    "fmt"
+    "card-vault/internal/models"
+    "card-vault/internal/tokenization"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+)
+
+const (
+    tokenTweakSize     = 8
+    maxTokenizeRetries = 5
+)
+
+var (
+    ErrTokenNotFound         = errors.New("token not found")
+    ErrTokenVaultUnavailable = errors.New("random tokens require a token repository")
+)
+
+// TokenizeCard emite un token con formato de número de tarjeta para una tarjeta
+// del usuario. En modo determinista el mismo PAN da siempre el mismo token; en
+// modo aleatorio cada llamada emite uno nuevo y lo registra.
+func (s *cardService) TokenizeCard(cardID, userID uuid.UUID) (*models.TokenResponse, error) {
+    card, err := s.repo.GetByID(cardID, userID)
+    if err != nil {
+        return nil, fmt.Errorf("card not found: %w", err)
+    }
+    if err := requireActive(card); err != nil {
+        return nil, err
+    }
+
+    cardNumber := card.CardNumber.String()
+
+    key, version, err := s.keyMgr.TokenizationKey()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get tokenization key: %w", err)
+    }
+    tokenizer, err := tokenization.NewTokenizer(key, s.tokenCfg)
+    if err != nil {
+        return nil, err
+    }
+
+    response := &models.TokenResponse{Mode: string(s.tokenCfg.Mode), CardID: card.ID, Network: paymentNetwork(card)}
+    if s.tokenCfg.Mode == tokenization.ModeDeterministic {
+        if response.Token, err = tokenizer.Tokenize(cardNumber, nil); err != nil {
+            return nil, err
+        }
+        return response, nil
+    }
+
+    if s.tokens == nil {
+        return nil, ErrTokenVaultUnavailable
+    }
+
+    // Un tweak nuevo por emisión; se reintenta si el token coincide con el PAN o
+    // con otro ya emitido
+    for attempt := 0; attempt < maxTokenizeRetries; attempt++ {
+        tweak := make([]byte, tokenTweakSize)
+        if _, err := rand.Read(tweak); err != nil {
+            return nil, err
+        }
+
+        token, err := tokenizer.Tokenize(cardNumber, tweak)
+        if errors.Is(err, tokenization.ErrTokenCollision) {
+            continue
+        }
+        if err != nil {
+            return nil, err
+        }
+        if _, err := s.tokens.GetByToken(token); err == nil {
+            continue
+        }
+
+        record := &models.CardToken{
+            Token:         token,
+            CardID:        card.ID,
+            UserID:        card.UserID,
+            KeyVersion:    version,
+            Tweak:         tweak,
+            Mode:          string(s.tokenCfg.Mode),
+            PreserveBIN:   s.tokenCfg.PreserveBIN,
+            PreserveLast4: s.tokenCfg.PreserveLast4,
+        }
+        if err := s.tokens.Create(record); err != nil {
+            return nil, fmt.Errorf("failed to store token: %w", err)
+        }
+
+        response.Token = token
+        return response, nil
+    }
+    return nil, tokenization.ErrTokenCollision
+}
+
+// Detokenize recupera el PAN de un token emitido en cualquiera de los dos modos.
+// Solo responde mientras la tarjeta siga guardada: tras borrarla o destruir la
+// clave de su usuario el token deja de revertirse.
+func (s *cardService) Detokenize(token string) (*models.DetokenizeResponse, error) {
+    if s.tokens != nil {
+        record, err := s.tokens.GetByToken(token)
+        if err == nil {
+            return s.detokenizeRandom(record)
+        }
+        if !errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, fmt.Errorf("failed to look up token: %w", err)
+        }
+    }
+
+    cardNumber, cards, err := s.detokenizeDeterministic(token)
+    if err != nil {
+        return nil, err
+    }
+
+    // Solo se revierte hacia tarjetas activas
+    response := &models.DetokenizeResponse{CardNumber: cardNumber}
+    var inactive error
+    for _, card := range cards {
+        if err := requireActive(&card); err != nil {
+            inactive = err
+            continue
+        }
+        response.CardIDs = append(response.CardIDs, card.ID)
+    }
+    if len(response.CardIDs) == 0 {
+        return nil, inactive
+    }
+    return response, nil
+}
+
+func (s *cardService) detokenizeRandom(record *models.CardToken) (*models.DetokenizeResponse, error) {
+    card, err := s.repo.FindByID(record.CardID)
+    if err != nil {
+        return nil, ErrTokenNotFound
+    }
+    if err := requireActive(card); err != nil {
+        return nil, err
+    }
+
+    key, err := s.keyMgr.TokenizationKeyVersion(record.KeyVersion)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get tokenization key: %w", err)
+    }
+
+    // Se revierte con el formato de la emisión; los tokens guardados antes de
+    // registrarlo se emitieron con la configuración actual
+    cfg := s.tokenCfg
+    if record.Mode != "" {
+        cfg = tokenization.Config{Mode: tokenization.Mode(record.Mode), PreserveBIN: record.PreserveBIN, PreserveLast4: record.PreserveLast4}
+    }
+    tokenizer, err := tokenization.NewTokenizer(key, cfg)
+    if err != nil {
+        return nil, err
+    }
+
+    cardNumber, err := tokenizer.Detokenize(record.Token, record.Tweak)
+    if err != nil {
+        return nil, err
+    }
+    return &models.DetokenizeResponse{CardNumber: cardNumber, CardIDs: []uuid.UUID{record.CardID}}, nil
+}
+
+// detokenizeDeterministic revierte un token determinista. Como no se guarda con qué
+// se emitió, prueba las versiones de la clave que aún descifran, de la más reciente
+// a la más antigua, y con cada una la configuración actual antes que las demás.
+// Cualquier número "se revierte" a algo: solo es un token si el PAN está guardado.
+func (s *cardService) detokenizeDeterministic(token string) (string, []models.Card, error) {
+    if _, _, err := s.keyMgr.TokenizationKey(); err != nil {
+        return "", nil, fmt.Errorf("failed to get tokenization key: %w", err)
+    }
+
+    versions := s.keyMgr.TokenizationKeys()
+    for i := len(versions) - 1; i >= 0; i-- {
+        key, err := s.keyMgr.TokenizationKeyVersion(versions[i].Version)
+        if err != nil {
+            // Deshabilitada o destruida: sus tokens ya no se revierten
+            continue
+        }
+
+        for _, cfg := range deterministicConfigs(s.tokenCfg) {
+            tokenizer, err := tokenization.NewTokenizer(key, cfg)
+            if err != nil {
+                return "", nil, err
+            }
+            cardNumber, err := tokenizer.Detokenize(token, nil)
+            if err != nil {
+                continue
+            }
+
+            cards, err := s.findByPAN(cardNumber)
+            if err != nil {
+                return "", nil, err
+            }
+            if len(cards) > 0 {
+                return cardNumber, cards, nil
+            }
+        }
+    }
+    return "", nil, ErrTokenNotFound
+}
+
+// deterministicConfigs devuelve la configuración actual seguida de las demás
+// combinaciones de dígitos conservados.
+func deterministicConfigs(current tokenization.Config) []tokenization.Config {
+    configs := []tokenization.Config{{Mode: tokenization.ModeDeterministic, PreserveBIN: current.PreserveBIN, PreserveLast4: current.PreserveLast4}}
+    for _, preserveBIN := range []bool{false, true} {
+        for _, preserveLast4 := range []bool{false, true} {
+            if preserveBIN != current.PreserveBIN || preserveLast4 != current.PreserveLast4 {
+                configs = append(configs, tokenization.Config{Mode: tokenization.ModeDeterministic, PreserveBIN: preserveBIN, PreserveLast4: preserveLast4})
+            }
+        }
+    }
+    return configs
+}
\ No newline at end of file
@@ -0,0 +1,180 @@
+package shamir
+
+import (
+    "crypto/rand"
+    "errors"
+)
+
+// Reparto de secretos de Shamir sobre GF(2^8) con el polinomio de AES
+// (x^8 + x^4 + x^3 + x + 1). Cada byte del secreto es el término independiente de
+// un polinomio aleatorio de grado threshold-1; cada parte guarda la evaluación de
+// todos los polinomios en un punto x distinto, que va en su último byte.
+
+var (
+    ErrInvalidParameters = errors.New("shamir: threshold must be between 2 and shares, and shares at most 255")
+    ErrEmptySecret       = errors.New("shamir: secret must not be empty")
+    ErrInvalidShares     = errors.New("shamir: shares are malformed or inconsistent")
+)
+
+// Split divide secret en shares partes de las que bastan threshold para recuperarlo.
+func Split(secret []byte, shares, threshold int) ([][]byte, error) {
+    if threshold < 2 || shares < threshold || shares > 255 {
+        return nil, ErrInvalidParameters
+    }
+    if len(secret) == 0 {
+        return nil, ErrEmptySecret
+    }
+
+    // Puntos x distintos y no nulos (x=0 es el secreto)
+    xs, err := randomCoordinates(shares)
+    if err != nil {
+        return nil, err
+    }
+
+    out := make([][]byte, shares)
+    for i := range out {
+        out[i] = make([]byte, len(secret)+1)
+        out[i][len(secret)] = xs[i]
+    }
+
+    coefficients := make([]byte, threshold)
+    for idx, b := range secret {
+        coefficients[0] = b
+        if _, err := rand.Read(coefficients[1:]); err != nil {
+            return nil, err
+        }
+        for i, x := range xs {
+            out[i][idx] = evaluate(coefficients, x)
+        }
+    }
+
+    for i := range coefficients {
+        coefficients[i] = 0
+    }
+    return out, nil
+}
+
+// Combine reconstruye el secreto por interpolación de Lagrange en x=0. Con menos
+// partes que el umbral el resultado es un valor aleatorio, no un error: quien
+// llama debe verificar el secreto recuperado.
+func Combine(shares [][]byte) ([]byte, error) {
+    if len(shares) < 2 {
+        return nil, ErrInvalidShares
+    }
+
+    size := len(shares[0])
+    if size < 2 {
+        return nil, ErrInvalidShares
+    }
+
+    xs := make([]byte, len(shares))
+    seen := make(map[byte]bool, len(shares))
+    for i, share := range shares {
+        if len(share) != size {
+            return nil, ErrInvalidShares
+        }
+        x := share[size-1]
+        if x == 0 || seen[x] {
+            return nil, ErrInvalidShares
+        }
+        seen[x] = true
+        xs[i] = x
+    }
+
+    secret := make([]byte, size-1)
+    ys := make([]byte, len(shares))
+    for idx := range secret {
+        for i, share := range shares {
+            ys[i] = share[idx]
+        }
+        secret[idx] = interpolateAtZero(xs, ys)
+    }
+    return secret, nil
+}
+
+// X devuelve la coordenada de una parte, que la identifica entre las demás.
+func X(share []byte) (byte, bool) {
+    if len(share) < 2 || share[len(share)-1] == 0 {
+        return 0, false
+    }
+    return share[len(share)-1], true
+}
+
+func randomCoordinates(n int) ([]byte, error) {
+    // Permutación aleatoria de 1..255 (Fisher-Yates)
+    var pool [255]byte
+    for i := range pool {
+        pool[i] = byte(i + 1)
+    }
+
+    var r [1]byte
+    for i := len(pool) - 1; i > 0; i-- {
+        // Rechazo para evitar sesgo en el módulo
+        limit := 256 - 256%(i+1)
+        for {
+            if _, err := rand.Read(r[:]); err != nil {
+                return nil, err
+            }
+            if int(r[0]) < limit {
+                break
+            }
+        }
+        j := int(r[0]) % (i + 1)
+        pool[i], pool[j] = pool[j], pool[i]
+    }
+    return append([]byte(nil), pool[:n]...), nil
+}
+
+// evaluate calcula el polinomio en x por Horner.
+func evaluate(coefficients []byte, x byte) byte {
+    var result byte
+    for i := len(coefficients) - 1; i >= 0; i-- {
+        result = mul(result, x) ^ coefficients[i]
+    }
+    return result
+}
+
+func interpolateAtZero(xs, ys []byte) byte {
+    var result byte
+    for i := range xs {
+        // L_i(0) = prod_{j!=i} x_j / (x_j - x_i); en GF(2^8) la resta es xor
+        basis := byte(1)
+        for j := range xs {
+            if i == j {
+                continue
+            }
+            basis = mul(basis, div(xs[j], xs[i]^xs[j]))
+        }
+        result ^= mul(ys[i], basis)
+    }
+    return result
+}
+
+// mul multiplica en GF(2^8) sin ramas dependientes de los datos.
+func mul(a, b byte) byte {
+    var result byte
+    for i := 0; i < 8; i++ {
+        result ^= a & -(b & 1)
+        b >>= 1
+        carry := -(a >> 7)
+        a = a<<1 ^ 0x1b&carry
+    }
+    return result
+}
+
+// inverse calcula a^254 = a^-1 (a != 0).
+func inverse(a byte) byte {
+    result := byte(1)
+    base := a
+    for e := 254; e > 0; e >>= 1 {
+        if e&1 == 1 {
+            result = mul(result, base)
+        }
+        base = mul(base, base)
+    }
+    return result
+}
+
+func div(a, b byte) byte {
+    return mul(a, inverse(b))
+}
\ No newline at end of file
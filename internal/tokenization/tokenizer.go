@@ -0,0 +1,151 @@
+package tokenization
+
+import (
+    "errors"
+    "fmt"
+    "card-vault/internal/brand"
+)
+
+// Mode indica si el mismo PAN produce siempre el mismo token.
+type Mode string
+
+const (
+    // ModeDeterministic: mismo PAN, mismo token. No hace falta guardar nada para
+    // revertirlo y permite cruzar datos por token.
+    ModeDeterministic Mode = "deterministic"
+    // ModeRandom: cada emisión usa un tweak aleatorio que se guarda junto al token.
+    ModeRandom Mode = "random"
+)
+
+const (
+    binLength   = 6
+    last4Length = 4
+
+    // Límite del cycle walking; con un dominio de 10^6 la probabilidad de
+    // agotarlo es despreciable
+    maxWalkSteps = 1000
+)
+
+var (
+    ErrInvalidMode    = errors.New("tokenization mode must be deterministic or random")
+    ErrInvalidPAN     = errors.New("card number is not valid for its brand")
+    ErrInvalidToken   = errors.New("token is not a valid card-number token")
+    ErrDomainTooSmall = errors.New("card number is too short to tokenize with the preserved digits")
+    ErrTokenCollision = errors.New("token would equal the card number")
+)
+
+type Config struct {
+    Mode          Mode
+    PreserveBIN   bool
+    PreserveLast4 bool
+}
+
+func ParseMode(name string) (Mode, error) {
+    switch mode := Mode(name); mode {
+    case ModeDeterministic, ModeRandom:
+        return mode, nil
+    }
+    return "", ErrInvalidMode
+}
+
+// Tokenizer sustituye un PAN por un token de la misma longitud que también pasa
+// el algoritmo de Luhn, de modo que los sistemas que esperan un número de tarjeta
+// lo aceptan. Los PAN de marcas que no exigen Luhn y no lo cumplen dan tokens que
+// tampoco lo cumplen. Los dígitos conservados (BIN, últimos 4) quedan en claro y
+// el resto se cifra con FF1.
+type Tokenizer struct {
+    ff1 *FF1
+    cfg Config
+}
+
+func NewTokenizer(key []byte, cfg Config) (*Tokenizer, error) {
+    if _, err := ParseMode(string(cfg.Mode)); err != nil {
+        return nil, err
+    }
+
+    ff1, err := NewFF1(key, 10)
+    if err != nil {
+        return nil, err
+    }
+    return &Tokenizer{ff1: ff1, cfg: cfg}, nil
+}
+
+func (t *Tokenizer) Config() Config {
+    return t.cfg
+}
+
+// Tokenize cifra pan con el tweak indicado (vacío en modo determinista). El PAN
+// debe cumplir las reglas de su marca; el error envuelve el *brand.ValidationError.
+func (t *Tokenizer) Tokenize(pan string, tweak []byte) (string, error) {
+    if _, err := brand.Validate(pan, ""); err != nil {
+        return "", fmt.Errorf("%w: %w", ErrInvalidPAN, err)
+    }
+
+    token, err := t.walk(pan, tweak, t.ff1.Encrypt)
+    if err != nil {
+        return "", err
+    }
+    if token == pan {
+        return "", ErrTokenCollision
+    }
+    return token, nil
+}
+
+// Detokenize recupera el PAN de un token emitido con el mismo tweak.
+func (t *Tokenizer) Detokenize(token string, tweak []byte) (string, error) {
+    if !validNumber(token) {
+        return "", ErrInvalidToken
+    }
+
+    pan, err := t.walk(token, tweak, t.ff1.Decrypt)
+    if err != nil {
+        return "", err
+    }
+    // Un token que no pasa Luhn solo puede venir de una marca que no lo exige
+    if !brand.LuhnValid(pan) && !brand.Detect(pan).LuhnOptional {
+        return "", ErrInvalidToken
+    }
+    return pan, nil
+}
+
+// walk aplica fn a los dígitos no conservados hasta que el número completo vuelve
+// a pasar Luhn, o a no pasarlo si number no lo pasaba (cycle walking). Al restringir
+// la permutación de FF1 a cada uno de los dos conjuntos se obtiene otra permutación,
+// así que el proceso es reversible.
+func (t *Tokenizer) walk(number string, tweak []byte, fn func(tweak []byte, x string) (string, error)) (string, error) {
+    head, tail := 0, 0
+    if t.cfg.PreserveBIN {
+        head = binLength
+    }
+    if t.cfg.PreserveLast4 {
+        tail = last4Length
+    }
+    if len(number)-head-tail < 6 {
+        return "", ErrDomainTooSmall
+    }
+
+    valid := brand.LuhnValid(number)
+    prefix, middle, suffix := number[:head], number[head:len(number)-tail], number[len(number)-tail:]
+    for step := 0; step < maxWalkSteps; step++ {
+        var err error
+        if middle, err = fn(tweak, middle); err != nil {
+            return "", err
+        }
+        if candidate := prefix + middle + suffix; brand.LuhnValid(candidate) == valid {
+            return candidate, nil
+        }
+    }
+    return "", ErrDomainTooSmall
+}
+
+func validNumber(number string) bool {
+    if len(number) < 12 || len(number) > 19 {
+        return false
+    }
+    for _, r := range number {
+        if r < '0' || r > '9' {
+            return false
+        }
+    }
+    return true
+}
\ No newline at end of file
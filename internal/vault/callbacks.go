@@ -0,0 +1,148 @@
+package vault
+
+import (
+    "context"
+    "fmt"
+    "reflect"
+
+    "gorm.io/gorm"
+)
+
+// Keys entrega el Cipher de cada registro. SealCipher prepara el registro para
+// volver a cifrarlo (p. ej. le asigna una DEK nueva) y OpenCipher recupera la
+// clave con la que se cifró.
+type Keys interface {
+    SealCipher(record interface{}) (Cipher, error)
+    OpenCipher(record interface{}) (Cipher, error)
+}
+
+type keysContextKey struct{}
+
+// WithKeys devuelve un contexto con keys; las sentencias de GORM que lo usen
+// sellan y abren con ellas los campos cifrados de sus registros.
+func WithKeys(ctx context.Context, keys Keys) context.Context {
+    return context.WithValue(ctx, keysContextKey{}, keys)
+}
+
+func KeysFrom(ctx context.Context) (Keys, bool) {
+    if ctx == nil {
+        return nil, false
+    }
+    keys, ok := ctx.Value(keysContextKey{}).(Keys)
+    return keys, ok
+}
+
+// Register instala en db los callbacks que sellan los registros antes de crearlos o
+// actualizarlos y los abren después de leerlos. Solo actúan en las sentencias cuyo
+// contexto lleva Keys; sin ellas, el serializer sigue rechazando los valores sin sellar.
+func Register(db *gorm.DB) error {
+    if err := db.Callback().Create().Before("gorm:create").Register("vault:seal", sealCallback); err != nil {
+        return err
+    }
+    if err := db.Callback().Update().Before("gorm:update").Register("vault:seal", sealCallback); err != nil {
+        return err
+    }
+    return db.Callback().Query().After("gorm:query").Before("gorm:after_query").Register("vault:open", openCallback)
+}
+
+func sealCallback(db *gorm.DB) {
+    eachRecord(db, SealRecord)
+}
+
+func openCallback(db *gorm.DB) {
+    eachRecord(db, OpenRecord)
+}
+
+// eachRecord aplica fn, con las Keys del contexto, a cada registro de la sentencia:
+// el modelo o cada elemento del slice de destino.
+func eachRecord(db *gorm.DB, fn func(record interface{}, keys Keys) error) {
+    if db.Error != nil || db.Statement.Schema == nil {
+        return
+    }
+    keys, ok := KeysFrom(db.Statement.Context)
+    if !ok {
+        return
+    }
+
+    apply := func(value reflect.Value) {
+        value = reflect.Indirect(value)
+        if value.Kind() != reflect.Struct || !value.CanAddr() {
+            return
+        }
+        if err := fn(value.Addr().Interface(), keys); err != nil {
+            db.AddError(err)
+        }
+    }
+
+    switch value := db.Statement.ReflectValue; value.Kind() {
+    case reflect.Slice, reflect.Array:
+        for i := 0; i < value.Len() && db.Error == nil; i++ {
+            apply(value.Index(i))
+        }
+    case reflect.Struct:
+        apply(value)
+    }
+}
+
+// SealRecord sella el registro con un Cipher nuevo de keys si alguno de sus campos
+// cifrados cambió; si no, lo deja como está.
+func SealRecord(record interface{}, keys Keys) error {
+    value, fields, err := inspect(record)
+    if err != nil {
+        return err
+    }
+
+    dirty := false
+    for _, tf := range fields {
+        dirty = dirty || value.Field(tf.index).Interface().(Field).dirty
+    }
+    if !dirty {
+        return nil
+    }
+
+    cipher, err := keys.SealCipher(record)
+    if err != nil {
+        return err
+    }
+    return Seal(record, cipher)
+}
+
+// OpenRecord abre el registro con el Cipher de keys si tiene algún campo cifrado.
+func OpenRecord(record interface{}, keys Keys) error {
+    value, fields, err := inspect(record)
+    if err != nil {
+        return err
+    }
+
+    sealed := false
+    for _, tf := range fields {
+        sealed = sealed || len(value.Field(tf.index).Interface().(Field).ciphertext) > 0
+    }
+    if !sealed {
+        return nil
+    }
+
+    cipher, err := keys.OpenCipher(record)
+    if err != nil {
+        return err
+    }
+    return Open(record, cipher)
+}
+
+// MarkDirty marca como cambiados todos los campos cifrados de un registro abierto,
+// de modo que la siguiente escritura lo vuelva a sellar con una clave nueva.
+func MarkDirty(record interface{}) error {
+    value, fields, err := inspect(record)
+    if err != nil {
+        return err
+    }
+
+    for _, tf := range fields {
+        f := value.Field(tf.index).Addr().Interface().(*Field)
+        if !f.opened && len(f.ciphertext) > 0 {
+            return fmt.Errorf("%w: %s", ErrNotOpened, tf.name)
+        }
+        f.opened, f.dirty = true, true
+    }
+    return nil
+}
\ No newline at end of file
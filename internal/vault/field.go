@@ -0,0 +1,93 @@
+package vault
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "reflect"
+
+    "gorm.io/gorm/schema"
+)
+
+var ErrNotSealed = errors.New("encrypted field was modified and not sealed")
+
+func init() {
+    schema.RegisterSerializer("vault", Serializer{})
+}
+
+// Field es el valor de un campo cifrado. En la base de datos solo se guarda el
+// ciphertext; el valor en claro existe solo en memoria, tras Open o tras asignarlo
+// con Set y hasta que se guarda sellado.
+type Field struct {
+    plaintext  []byte
+    ciphertext []byte
+    opened     bool // plaintext es el valor actual del campo
+    dirty      bool // plaintext cambió y ciphertext aún no
+}
+
+// FromCiphertext crea un campo con un valor ya cifrado, como el que se lee de la base de datos.
+func FromCiphertext(ciphertext []byte) Field {
+    return Field{ciphertext: ciphertext}
+}
+
+// Set asigna un valor en claro nuevo; hay que sellar el registro antes de guardarlo.
+func (f *Field) Set(plaintext []byte) {
+    f.plaintext = plaintext
+    f.ciphertext = nil
+    f.opened, f.dirty = true, true
+}
+
+func (f *Field) SetString(plaintext string) {
+    f.Set([]byte(plaintext))
+}
+
+// Bytes devuelve el valor en claro, vacío si el campo no se ha abierto.
+func (f Field) Bytes() []byte {
+    return f.plaintext
+}
+
+func (f Field) String() string {
+    return string(f.plaintext)
+}
+
+func (f Field) Ciphertext() []byte {
+    return f.ciphertext
+}
+
+// IsEmpty indica si el campo no tiene valor, ni en claro ni cifrado.
+func (f Field) IsEmpty() bool {
+    return len(f.plaintext) == 0 && len(f.ciphertext) == 0
+}
+
+// Serializer guarda los Field como bytea con su ciphertext y los lee sin abrir. No
+// cifra (lo hacen los callbacks de Register): negarse a guardar un valor asignado y
+// no sellado evita escribir datos en claro si una sentencia no lleva Keys.
+type Serializer struct{}
+
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+    var value Field
+    switch v := dbValue.(type) {
+    case nil:
+    case []byte:
+        value.ciphertext = append([]byte(nil), v...)
+    default:
+        return fmt.Errorf("unsupported value for encrypted field %s: %T", field.Name, dbValue)
+    }
+
+    field.ReflectValueOf(ctx, dst).Set(reflect.ValueOf(value))
+    return nil
+}
+
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+    value, ok := fieldValue.(Field)
+    if !ok {
+        return nil, fmt.Errorf("field %s is not a vault.Field", field.Name)
+    }
+    if value.dirty && len(value.plaintext) > 0 {
+        return nil, fmt.Errorf("%w: %s", ErrNotSealed, field.Name)
+    }
+    if len(value.ciphertext) == 0 {
+        return nil, nil
+    }
+    return value.ciphertext, nil
+}
\ No newline at end of file
@@ -0,0 +1,164 @@
+// Package vault cifra los campos de un registro marcados con
+// vault:"encrypt,purpose=...". Con Register, GORM sella esos campos antes de cada
+// escritura y los abre después de cada lectura, con el Cipher que las Keys del
+// contexto de la sentencia dan para el registro; un modelo nuevo solo necesita
+// marcar sus campos. El serializer guarda el ciphertext y rechaza escribir un valor
+// asignado y no sellado, de modo que una sentencia sin Keys produce un error y no
+// datos en claro.
+package vault
+
+import (
+    "errors"
+    "fmt"
+    "reflect"
+    "strings"
+    "sync"
+)
+
+var ErrNotOpened = errors.New("encrypted field must be opened before sealing")
+
+// Cipher cifra y descifra los campos de un registro. purpose identifica el campo,
+// de modo que el ciphertext de uno no se pueda descifrar como otro.
+type Cipher interface {
+    Seal(plaintext []byte, purpose string) ([]byte, error)
+    Open(ciphertext []byte, purpose string) ([]byte, error)
+}
+
+// taggedField es un campo Field marcado con vault:"encrypt,purpose=...".
+type taggedField struct {
+    index   int
+    name    string
+    purpose string
+}
+
+var (
+    fieldType   = reflect.TypeOf(Field{})
+    structCache sync.Map // reflect.Type -> []taggedField
+)
+
+// Seal cifra con cipher todos los campos marcados del registro (un puntero a
+// struct). Los campos vacíos quedan sin ciphertext. Falla si algún campo con valor
+// guardado no se ha abierto ni reasignado, para no perderlo al volver a cifrar.
+func Seal(record interface{}, cipher Cipher) error {
+    value, fields, err := inspect(record)
+    if err != nil {
+        return err
+    }
+
+    for _, tf := range fields {
+        f := value.Field(tf.index).Addr().Interface().(*Field)
+        if !f.opened && len(f.ciphertext) > 0 {
+            return fmt.Errorf("%w: %s", ErrNotOpened, tf.name)
+        }
+
+        var ciphertext []byte
+        if len(f.plaintext) > 0 {
+            if ciphertext, err = cipher.Seal(f.plaintext, tf.purpose); err != nil {
+                return fmt.Errorf("failed to encrypt %s: %w", tf.purpose, err)
+            }
+        }
+        f.ciphertext = ciphertext
+        f.opened, f.dirty = true, false
+    }
+    return nil
+}
+
+// Open descifra con cipher todos los campos marcados del registro.
+func Open(record interface{}, cipher Cipher) error {
+    value, fields, err := inspect(record)
+    if err != nil {
+        return err
+    }
+
+    for _, tf := range fields {
+        f := value.Field(tf.index).Addr().Interface().(*Field)
+        var plaintext []byte
+        if len(f.ciphertext) > 0 {
+            if plaintext, err = cipher.Open(f.ciphertext, tf.purpose); err != nil {
+                return fmt.Errorf("failed to decrypt %s: %w", tf.purpose, err)
+            }
+        }
+        f.plaintext = plaintext
+        f.opened, f.dirty = true, false
+    }
+    return nil
+}
+
+// Fields devuelve los nombres de los campos cifrados del registro, p. ej. para
+// limitar a ellos una actualización.
+func Fields(record interface{}) ([]string, error) {
+    _, fields, err := inspect(record)
+    if err != nil {
+        return nil, err
+    }
+
+    names := make([]string, len(fields))
+    for i, tf := range fields {
+        names[i] = tf.name
+    }
+    return names, nil
+}
+
+func inspect(record interface{}) (reflect.Value, []taggedField, error) {
+    value := reflect.ValueOf(record)
+    if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+        return reflect.Value{}, nil, fmt.Errorf("vault: record must be a pointer to a struct, got %T", record)
+    }
+    value = value.Elem()
+
+    if cached, ok := structCache.Load(value.Type()); ok {
+        return value, cached.([]taggedField), nil
+    }
+
+    fields, err := parseFields(value.Type())
+    if err != nil {
+        return reflect.Value{}, nil, err
+    }
+    structCache.Store(value.Type(), fields)
+    return value, fields, nil
+}
+
+func parseFields(t reflect.Type) ([]taggedField, error) {
+    var fields []taggedField
+    for i := 0; i < t.NumField(); i++ {
+        sf := t.Field(i)
+        tag, tagged := sf.Tag.Lookup("vault")
+        isField := sf.Type == fieldType
+        if !tagged && !isField {
+            continue
+        }
+        if !isField {
+            return nil, fmt.Errorf("vault: %s.%s is tagged but is not a vault.Field", t.Name(), sf.Name)
+        }
+
+        purpose, err := parseTag(tag)
+        if err != nil {
+            return nil, fmt.Errorf("vault: %s.%s: %w", t.Name(), sf.Name, err)
+        }
+        fields = append(fields, taggedField{index: i, name: sf.Name, purpose: purpose})
+    }
+    return fields, nil
+}
+
+// parseTag lee una etiqueta "encrypt,purpose=pan" y devuelve el propósito.
+func parseTag(tag string) (string, error) {
+    options := strings.Split(tag, ",")
+    if strings.TrimSpace(options[0]) != "encrypt" {
+        return "", fmt.Errorf("vault tag must start with \"encrypt\", got %q", tag)
+    }
+
+    var purpose string
+    for _, option := range options[1:] {
+        key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+        switch key {
+        case "purpose":
+            purpose = value
+        default:
+            return "", fmt.Errorf("unknown vault tag option %q", key)
+        }
+    }
+    if purpose == "" {
+        return "", errors.New("vault tag requires a purpose")
+    }
+    return purpose, nil
+}
\ No newline at end of file
@@ -0,0 +1,134 @@
+package tests
+
+import (
+    "bytes"
+    "card-vault/internal/address"
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+    "card-vault/internal/models"
+    "card-vault/internal/service"
+    "testing"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+)
+
+func billingAddress() *models.BillingAddress {
+    return &models.BillingAddress{
+        Lines:      []string{"  1600 Amphitheatre Pkwy ", "Building 40"},
+        City:       "Mountain View",
+        Region:     "CA",
+        PostalCode: "94043",
+        Country:    "us",
+    }
+}
+
+func TestNormalizeBillingAddress(t *testing.T) {
+    addr, err := address.Normalize(billingAddress())
+    assert.NoError(t, err)
+    assert.Equal(t, "US", addr.Country)
+    assert.Equal(t, []string{"1600 Amphitheatre Pkwy", "Building 40"}, addr.Lines)
+
+    addr, err = address.Normalize(&models.BillingAddress{Lines: []string{"10 Downing St"}, City: "London", PostalCode: "sw1a  2aa", Country: "GB"})
+    assert.NoError(t, err)
+    assert.Equal(t, "SW1A 2AA", addr.PostalCode)
+
+    // Sin código postal solo en países que no los usan
+    _, err = address.Normalize(&models.BillingAddress{Lines: []string{"Sheikh Zayed Rd"}, City: "Dubai", Country: "AE"})
+    assert.NoError(t, err)
+    _, err = address.Normalize(&models.BillingAddress{Lines: []string{"Gran Vía 1"}, City: "Madrid", Country: "ES"})
+    assert.ErrorIs(t, err, address.ErrInvalidPostalCode)
+
+    _, err = address.Normalize(&models.BillingAddress{Lines: []string{"Gran Vía 1"}, City: "Madrid", PostalCode: "2801", Country: "ES"})
+    assert.ErrorIs(t, err, address.ErrInvalidPostalCode)
+    _, err = address.Normalize(&models.BillingAddress{Lines: []string{"Main St 1"}, City: "Nowhere", PostalCode: "12345", Country: "XX"})
+    assert.ErrorIs(t, err, address.ErrInvalidCountry)
+
+    addr, err = address.Normalize(nil)
+    assert.NoError(t, err)
+    assert.Nil(t, addr)
+}
+
+func TestMaskBillingAddress(t *testing.T) {
+    addr := &models.BillingAddress{Lines: []string{"1600 Amphitheatre Pkwy"}, City: "Mountain View", PostalCode: "94043", Country: "US"}
+
+    partial := address.Mask(addr, address.MaskPartial)
+    assert.Equal(t, []string{"160* ************ ****"}, partial.Lines)
+    assert.Equal(t, "94***", partial.PostalCode)
+    assert.Equal(t, "Mountain View", partial.City)
+
+    full := address.Mask(addr, address.MaskFull)
+    assert.Equal(t, []string{"**** ************ ****"}, full.Lines)
+    assert.Equal(t, "*****", full.PostalCode)
+
+    assert.Equal(t, addr, address.Mask(addr, address.MaskNone))
+    assert.Equal(t, "1600 Amphitheatre Pkwy", addr.Lines[0])
+
+    _, err := address.ParseMasking("hidden")
+    assert.Error(t, err)
+}
+
+func TestCardService_EncryptsBillingAddress(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardServiceWithOptions(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr), service.CardServiceOptions{AddressMasking: address.MaskNone})
+
+    userID := uuid.New()
+    req := cardRequest("John Doe", "4111111111111111")
+    req.BillingAddress = billingAddress()
+    card, err := cardSvc.CreateCard(userID, req)
+    assert.NoError(t, err)
+    if assert.NotNil(t, card.BillingAddress) {
+        assert.Equal(t, "94043", card.BillingAddress.PostalCode)
+        assert.Equal(t, "US", card.BillingAddress.Country)
+    }
+
+    stored, _ := repo.FindByID(card.ID)
+    assert.Equal(t, "Mountain View", stored.BillingCity)
+    assert.False(t, bytes.Contains(stored.BillingStreet.Ciphertext(), []byte("Amphitheatre")))
+    assert.False(t, bytes.Contains(stored.BillingPostalCode.Ciphertext(), []byte("94043")))
+
+    // Sin dirección en la petición se conserva, también al cambiar de PAN (y de DEK)
+    card, err = cardSvc.UpdateCard(card.ID, userID, cardRequest("John Doe", "5555555555554444"))
+    assert.NoError(t, err)
+    if assert.NotNil(t, card.BillingAddress) {
+        assert.Equal(t, []string{"1600 Amphitheatre Pkwy", "Building 40"}, card.BillingAddress.Lines)
+    }
+    card, err = cardSvc.GetCard(card.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, "94043", card.BillingAddress.PostalCode)
+
+    req = cardRequest("John Doe", "5555555555554444")
+    req.BillingAddress = &models.BillingAddress{Lines: []string{"1 Rue de Rivoli"}, City: "Paris", PostalCode: "75001", Country: "FR"}
+    card, err = cardSvc.UpdateCard(card.ID, userID, req)
+    assert.NoError(t, err)
+    assert.Equal(t, "Paris", card.BillingAddress.City)
+
+    req.BillingAddress.PostalCode = "7500"
+    _, err = cardSvc.UpdateCard(card.ID, userID, req)
+    assert.ErrorIs(t, err, address.ErrInvalidPostalCode)
+
+    // El cifrado está ligado a la tarjeta: la calle copiada a otra no se descifra
+    other, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    assert.Nil(t, other.BillingAddress)
+    source, _ := repo.FindByID(card.ID)
+    target, _ := repo.FindByID(other.ID)
+    target.BillingStreet, target.BillingPostalCode = source.BillingStreet, source.BillingPostalCode
+    repo.Update(target)
+    _, err = cardSvc.GetCard(other.ID, userID)
+    assert.Error(t, err)
+}
+
+func TestCardService_MasksBillingAddressByDefault(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    req := cardRequest("John Doe", "4111111111111111")
+    req.BillingAddress = billingAddress()
+    card, err := cardSvc.CreateCard(uuid.New(), req)
+    assert.NoError(t, err)
+    assert.Equal(t, "94***", card.BillingAddress.PostalCode)
+    assert.Equal(t, "160* ************ ****", card.BillingAddress.Lines[0])
+}
\ No newline at end of file
@@ -0,0 +1,106 @@
+package tests
+
+import (
+    "card-vault/internal/bin"
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+    "card-vault/internal/service"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestBINTable_MostSpecificRangeWins(t *testing.T) {
+    ranges, err := bin.ParseCSV(strings.NewReader(`start,end,issuer,country,funding,segment,level
+411111,411111,Example Bank,us,credit,consumer,classic
+45717360,45717369,Commercial Bank,SE,Debit,commercial,business
+457173,457199,Retail Bank,SE,debit,consumer,standard
+`))
+    assert.NoError(t, err)
+
+    table, err := bin.NewTable(ranges)
+    assert.NoError(t, err)
+    assert.Equal(t, 3, table.Len())
+
+    info, ok := table.Lookup("4111111111111111")
+    assert.True(t, ok)
+    assert.Equal(t, bin.Info{Issuer: "Example Bank", Country: "US", Funding: "credit", Segment: "consumer", Level: "classic"}, info)
+
+    info, ok = table.Lookup("4571736012345678")
+    assert.True(t, ok)
+    assert.Equal(t, "Commercial Bank", info.Issuer)
+    assert.Equal(t, bin.FundingDebit, info.Funding)
+
+    info, ok = table.Lookup("4571901234567890")
+    assert.True(t, ok)
+    assert.Equal(t, "Retail Bank", info.Issuer)
+
+    _, ok = table.Lookup("5555555555554444")
+    assert.False(t, ok)
+
+    // La tabla de ejemplo del repositorio es válida
+    example, err := bin.LoadFile("../bin_ranges.example.csv")
+    assert.NoError(t, err)
+    info, ok = example.Lookup("5555555555554444")
+    assert.True(t, ok)
+    assert.Equal(t, "CA", info.Country)
+}
+
+func TestBINTable_RejectsInvalidRanges(t *testing.T) {
+    invalid := [][]bin.Range{
+        {{Start: "4111", End: "411199"}},
+        {{Start: "411199", End: "411100"}},
+        {{Start: "411111", Info: bin.Info{Funding: "charge"}}},
+        {{Start: "411111", Info: bin.Info{Country: "USA"}}},
+        {{Start: "411100", End: "411150"}, {Start: "411150", End: "411199"}},
+    }
+    for _, ranges := range invalid {
+        _, err := bin.NewTable(ranges)
+        assert.ErrorIs(t, err, bin.ErrInvalidRange)
+    }
+}
+
+func TestBINDatabase_HotReload(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "bins.json")
+    os.WriteFile(path, []byte(`[{"start": "411111", "issuer": "Example Bank", "country": "US", "funding": "credit"}]`), 0600)
+
+    bins, err := bin.Open(path)
+    assert.NoError(t, err)
+
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardServiceWithOptions(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr), service.CardServiceOptions{BINs: bins})
+
+    userID := uuid.New()
+    card, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    assert.Equal(t, "Example Bank", card.IssuerName)
+    assert.Equal(t, "US", card.IssuerCountry)
+    assert.Equal(t, bin.FundingCredit, card.FundingType)
+
+    stored, _ := repo.FindByID(card.ID)
+    assert.Equal(t, "Example Bank", stored.IssuerName)
+
+    // Un fichero inválido no sustituye la tabla en uso
+    os.WriteFile(path, []byte(`[{"start": "41"}]`), 0600)
+    _, err = bins.Reload()
+    assert.Error(t, err)
+    info, _ := bins.Lookup("4111111111111111")
+    assert.Equal(t, "Example Bank", info.Issuer)
+
+    os.WriteFile(path, []byte(`[{"start": "411111", "issuer": "Renamed Bank", "country": "US", "funding": "prepaid"}]`), 0600)
+    status, err := bins.Reload()
+    assert.NoError(t, err)
+    assert.Equal(t, 1, status.Ranges)
+    assert.NotNil(t, status.LoadedAt)
+
+    // Los datos guardados se actualizan al modificar la tarjeta
+    card, err = cardSvc.UpdateCard(card.ID, userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    assert.Equal(t, "Renamed Bank", card.IssuerName)
+    assert.Equal(t, bin.FundingPrepaid, card.FundingType)
+}
\ No newline at end of file
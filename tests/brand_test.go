@@ -0,0 +1,109 @@
+package tests
+
+import (
+    "card-vault/internal/brand"
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+    "card-vault/internal/service"
+    "errors"
+    "strings"
+    "testing"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+)
+
+// withLuhn completa prefix con ceros y el dígito de control hasta length dígitos.
+func withLuhn(prefix string, length int) string {
+    body := prefix + strings.Repeat("0", length-len(prefix)-1)
+    for check := '0'; check <= '9'; check++ {
+        if brand.LuhnValid(body + string(check)) {
+            return body + string(check)
+        }
+    }
+    return ""
+}
+
+func TestBrand_Detect(t *testing.T) {
+    cases := map[string]string{
+        withLuhn("4", 16):      "Visa",
+        withLuhn("2221", 16):   "Mastercard",
+        withLuhn("55", 16):     "Mastercard",
+        withLuhn("37", 15):     "Amex",
+        withLuhn("6011", 16):   "Discover",
+        withLuhn("3530", 16):   "JCB",
+        withLuhn("36", 14):     "Diners Club",
+        withLuhn("62", 19):     "UnionPay",
+        withLuhn("6759", 12):   "Maestro",
+        withLuhn("2200", 16):   "Mir",
+        withLuhn("6521", 16):   "Discover",
+        withLuhn("652150", 16): "RuPay",
+        withLuhn("627780", 16): "Elo",
+        withLuhn("438935", 16): "Elo",
+        withLuhn("606282", 16): "Hipercard",
+        withLuhn("506100", 16): "Verve",
+        withLuhn("9792", 16):   "Troy",
+        withLuhn("1234", 16):   "Unknown",
+    }
+
+    for pan, name := range cases {
+        cardBrand, err := brand.Validate(pan, "")
+        assert.NoError(t, err, pan)
+        assert.Equal(t, name, cardBrand.Name, pan)
+    }
+}
+
+func TestBrand_ValidationErrorsNameTheRule(t *testing.T) {
+    var invalid *brand.ValidationError
+
+    _, err := brand.Validate(withLuhn("37", 16), "")
+    assert.ErrorIs(t, err, brand.ErrInvalidLength)
+    assert.True(t, errors.As(err, &invalid))
+    assert.Equal(t, brand.RuleLength, invalid.Rule)
+    assert.Equal(t, "Amex", invalid.Brand)
+    assert.Equal(t, "Amex card numbers must have 15 digits, got 16", err.Error())
+
+    _, err = brand.Validate(withLuhn("3530", 15), "")
+    assert.Equal(t, "JCB card numbers must have 16 to 19 digits, got 15", err.Error())
+
+    _, err = brand.Validate("4111111111111112", "")
+    assert.ErrorIs(t, err, brand.ErrLuhnCheck)
+    assert.Equal(t, "Visa card number fails the Luhn check", err.Error())
+
+    _, err = brand.Validate("378282246310005", "123")
+    assert.ErrorIs(t, err, brand.ErrInvalidCVVLength)
+    assert.Equal(t, "Amex CVV must have 4 digits", err.Error())
+
+    _, err = brand.Validate("4111111111111111", "1234")
+    assert.ErrorIs(t, err, brand.ErrInvalidCVVLength)
+
+    _, err = brand.Validate("4111-1111-1111-1111", "")
+    assert.ErrorIs(t, err, brand.ErrInvalidDigits)
+
+    // Algunos rangos de UnionPay no cumplen Luhn
+    _, err = brand.Validate("6200000000000001", "123")
+    assert.NoError(t, err)
+}
+
+func TestCardService_ValidatesBrandRules(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    userID := uuid.New()
+    _, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "378282246310005"))
+    assert.ErrorIs(t, err, brand.ErrInvalidCVVLength)
+
+    req := cardRequest("John Doe", "378282246310005")
+    req.CVV = "1234"
+    card, err := cardSvc.CreateCard(userID, req)
+    assert.NoError(t, err)
+    assert.Equal(t, "Amex", card.CardType)
+
+    card, err = cardSvc.CreateCard(userID, cardRequest("John Doe", "3530111333300000"))
+    assert.NoError(t, err)
+    assert.Equal(t, "JCB", card.CardType)
+
+    _, err = cardSvc.UpdateCard(card.ID, userID, cardRequest("John Doe", "3530111333300001"))
+    assert.ErrorIs(t, err, brand.ErrLuhnCheck)
+}
\ No newline at end of file
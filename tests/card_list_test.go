@@ -0,0 +1,190 @@
+package tests
+
+import (
+    "card-vault/internal/crypto"
+    "card-vault/internal/handlers"
+    "card-vault/internal/kms"
+    "card-vault/internal/middleware"
+    "card-vault/internal/models"
+    "card-vault/internal/service"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+)
+
+func newListedCards(t *testing.T) (service.CardService, *memoryCardRepository, uuid.UUID) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardServiceWithOptions(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr),
+        service.CardServiceOptions{Duplicates: service.DuplicateAllow})
+
+    userID := uuid.New()
+    for _, req := range []*models.CardRequest{
+        {CardholderName: "John Doe", CardNumber: "4111111111111111", ExpiryMonth: 12, ExpiryYear: 2030},
+        {CardholderName: "John Doe", CardNumber: "5555555555554444", ExpiryMonth: 3, ExpiryYear: 2028},
+        {CardholderName: "John Doe", CardNumber: "4012888888881881", ExpiryMonth: 7, ExpiryYear: 2029},
+        {CardholderName: "John Doe", CardNumber: "378282246310005", ExpiryMonth: 1, ExpiryYear: 2031},
+        {CardholderName: "John Doe", CardNumber: "4242424242424242", ExpiryMonth: 3, ExpiryYear: 2028},
+    } {
+        _, err := cardSvc.CreateCard(userID, req)
+        assert.NoError(t, err)
+    }
+    // Las tarjetas de otros usuarios no aparecen en el listado
+    cardSvc.CreateCard(uuid.New(), cardRequest("Jane Doe", "4111111111111111"))
+    return cardSvc, repo, userID
+}
+
+func TestCardService_PaginatesUserCards(t *testing.T) {
+    cardSvc, _, userID := newListedCards(t)
+
+    // Por defecto, de la más reciente a la más antigua
+    var seen []uuid.UUID
+    query := &models.CardListQuery{Limit: 2}
+    for pages := 0; ; pages++ {
+        page, err := cardSvc.GetUserCards(userID, query)
+        assert.NoError(t, err)
+        assert.Equal(t, "created_at", page.Pagination.Sort)
+        assert.Equal(t, "desc", page.Pagination.Order)
+        for _, card := range page.Cards {
+            seen = append(seen, card.ID)
+            assert.Equal(t, "John Doe", card.CardholderName)
+        }
+        if !page.Pagination.HasMore {
+            assert.Empty(t, page.Pagination.NextCursor)
+            assert.Equal(t, 2, pages)
+            break
+        }
+        query.Cursor = page.Pagination.NextCursor
+    }
+    if assert.Len(t, seen, 5) {
+        all, _ := cardSvc.GetUserCards(userID, &models.CardListQuery{Order: "asc"})
+        for i, card := range all.Cards {
+            assert.Equal(t, seen[4-i], card.ID)
+        }
+    }
+
+    // Por caducidad, con el ID para desempatar entre páginas
+    var expiries []int
+    query = &models.CardListQuery{Sort: "expiry", Limit: 2}
+    for {
+        page, err := cardSvc.GetUserCards(userID, query)
+        assert.NoError(t, err)
+        for _, card := range page.Cards {
+            expiries = append(expiries, card.ExpiryYear*100+card.ExpiryMonth)
+        }
+        if !page.Pagination.HasMore {
+            break
+        }
+        query.Cursor = page.Pagination.NextCursor
+    }
+    assert.Equal(t, []int{202803, 202803, 202907, 203012, 203101}, expiries)
+}
+
+func TestCardService_FiltersUserCards(t *testing.T) {
+    cardSvc, repo, userID := newListedCards(t)
+
+    count := func(query *models.CardListQuery) int {
+        page, err := cardSvc.GetUserCards(userID, query)
+        assert.NoError(t, err)
+        return len(page.Cards)
+    }
+
+    assert.Equal(t, 3, count(&models.CardListQuery{CardType: "visa"}))
+    assert.Equal(t, 2, count(&models.CardListQuery{CardType: "mastercard,amex"}))
+    assert.Equal(t, 1, count(&models.CardListQuery{Last4: "4444"}))
+    assert.Equal(t, 2, count(&models.CardListQuery{ExpiryFrom: "2028-03", ExpiryTo: "2028-03"}))
+    assert.Equal(t, 2, count(&models.CardListQuery{ExpiryFrom: "2029-07", ExpiryTo: "2030-12"}))
+    assert.Equal(t, 0, count(&models.CardListQuery{CreatedTo: "2020-01-01T00:00:00Z"}))
+    assert.Equal(t, 5, count(&models.CardListQuery{CreatedFrom: "2020-01-01T00:00:00Z"}))
+
+    page, _ := cardSvc.GetUserCards(userID, &models.CardListQuery{Last4: "1111"})
+    _, err := cardSvc.FreezeCard(page.Cards[0].ID, userID, "")
+    assert.NoError(t, err)
+    assert.Equal(t, 1, count(&models.CardListQuery{Status: "frozen"}))
+    assert.Equal(t, 5, count(&models.CardListQuery{Status: "active,frozen"}))
+
+    // Las tarjetas guardadas antes de la columna last4 la reciben al recalcular las huellas
+    legacy, _ := repo.FindByID(page.Cards[0].ID)
+    legacy.Last4 = ""
+    repo.Update(legacy)
+    assert.Equal(t, 0, count(&models.CardListQuery{Last4: "1111"}))
+    result, err := cardSvc.ReindexFingerprints(false)
+    assert.NoError(t, err)
+    assert.Equal(t, 1, result.ProcessedCards)
+    assert.Equal(t, 1, count(&models.CardListQuery{Last4: "1111"}))
+}
+
+func TestCardService_RejectsInvalidCardQueries(t *testing.T) {
+    cardSvc, _, userID := newListedCards(t)
+
+    for _, query := range []*models.CardListQuery{
+        {Sort: "cardholder_name"},
+        {Order: "random"},
+        {Limit: 101},
+        {Status: "lost"},
+        {CardType: "visa,bankcard"},
+        {ExpiryFrom: "03/2028"},
+        {CreatedTo: "yesterday"},
+        {Last4: "11a1"},
+    } {
+        _, err := cardSvc.GetUserCards(userID, query)
+        assert.ErrorIs(t, err, service.ErrInvalidCardQuery)
+    }
+
+    _, err := cardSvc.GetUserCards(userID, &models.CardListQuery{Cursor: "not-a-cursor"})
+    assert.ErrorIs(t, err, service.ErrInvalidCursor)
+
+    // Un cursor solo vale para el orden con el que se obtuvo
+    page, _ := cardSvc.GetUserCards(userID, &models.CardListQuery{Limit: 1})
+    _, err = cardSvc.GetUserCards(userID, &models.CardListQuery{Sort: "expiry", Cursor: page.Pagination.NextCursor})
+    assert.ErrorIs(t, err, service.ErrInvalidCursor)
+}
+
+func TestCardHandler_ListsCardsWithPaginationLinks(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    t.Setenv("JWT_SECRET", "test-secret")
+
+    cardSvc, _, userID := newListedCards(t)
+    r := gin.New()
+    r.GET("/cards", middleware.AuthMiddleware(), handlers.NewCardHandler(cardSvc).GetUserCards)
+    token, _ := middleware.GenerateToken(userID)
+
+    list := func(target string) (*httptest.ResponseRecorder, models.CardPage) {
+        req := httptest.NewRequest(http.MethodGet, target, nil)
+        req.Header.Set("Authorization", "Bearer "+token)
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+
+        var page models.CardPage
+        json.Unmarshal(w.Body.Bytes(), &page)
+        return w, page
+    }
+
+    w, page := list("/cards?card_type=visa&limit=2")
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.Len(t, page.Cards, 2)
+    assert.True(t, page.Pagination.HasMore)
+    assert.Equal(t, "/cards?card_type=visa&limit=2", page.Pagination.Links.Self)
+
+    next, err := url.Parse(page.Pagination.Links.Next)
+    assert.NoError(t, err)
+    assert.Equal(t, "visa", next.Query().Get("card_type"))
+    assert.Equal(t, page.Pagination.NextCursor, next.Query().Get("cursor"))
+
+    w, page = list(page.Pagination.Links.Next)
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.Len(t, page.Cards, 1)
+    assert.False(t, page.Pagination.HasMore)
+    assert.Empty(t, page.Pagination.Links.Next)
+
+    w, _ = list("/cards?limit=ten")
+    assert.Equal(t, http.StatusBadRequest, w.Code)
+    w, _ = list("/cards?sort=cardholder_name")
+    assert.Equal(t, http.StatusBadRequest, w.Code)
+}
\ No newline at end of file
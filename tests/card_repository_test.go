@@ -0,0 +1,43 @@
+package tests
+
+import (
+    "card-vault/internal/models"
+    "card-vault/internal/repository"
+    "testing"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+    "gorm.io/driver/postgres"
+    "gorm.io/gorm"
+)
+
+// dryRunDB devuelve una conexión de postgres que no llega a conectarse: las
+// sentencias se construyen sin ejecutarse y el SQL de cada actualización se
+// guarda en statements.
+func dryRunDB(t *testing.T) (*gorm.DB, *[]string) {
+    db, err := gorm.Open(postgres.Open("host=localhost"), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+    assert.NoError(t, err)
+
+    statements := []string{}
+    err = db.Callback().Update().After("gorm:update").Register("test:capture", func(tx *gorm.DB) {
+        statements = append(statements, tx.Statement.SQL.String())
+    })
+    assert.NoError(t, err)
+    return db, &statements
+}
+
+func TestCardRepository_UpdateFingerprintMatchesNullDataKeys(t *testing.T) {
+    db, statements := dryRunDB(t)
+    repo := repository.NewCardRepository(db)
+
+    // Tarjeta anterior a las DEKs: "wrapped_dek = NULL" no coincidiría nunca
+    _, err := repo.UpdateFingerprint(&models.Card{ID: uuid.New(), Last4: "1111"})
+    assert.NoError(t, err)
+    _, err = repo.UpdateFingerprint(&models.Card{ID: uuid.New(), WrappedDEK: []byte("dek"), Last4: "1111"})
+    assert.NoError(t, err)
+
+    assert.Len(t, *statements, 2)
+    assert.Contains(t, (*statements)[0], "(wrapped_dek IS NULL OR octet_length(wrapped_dek) = 0)")
+    assert.NotContains(t, (*statements)[0], "wrapped_dek = $")
+    assert.Contains(t, (*statements)[1], "wrapped_dek = $")
+}
\ No newline at end of file
@@ -2,37 +2,78 @@ package tests
 
 import (
     "card-vault/internal/crypto"
+    "card-vault/internal/kms"
     "card-vault/internal/models"
+    "card-vault/internal/repository"
     "card-vault/internal/service"
-    "crypto/rand"
+    "card-vault/internal/vault"
+    "encoding/base64"
+    "errors"
+    "sync"
     "testing"
+    "time"
 
     "github.com/google/uuid"
     "github.com/stretchr/testify/assert"
     "github.com/stretchr/testify/mock"
 )
 
-// Mock repository
+// Mock repository. Como los callbacks de vault, sella las tarjetas que recibe y
+// abre las que devuelve con las Keys del último servicio que lo usa.
 type MockCardRepository struct {
     mock.Mock
+    keys vault.Keys
+}
+
+func (m *MockCardRepository) WithKeys(keys vault.Keys) repository.CardRepository {
+    m.keys = keys
+    return m
+}
+
+func (m *MockCardRepository) open(cards ...*models.Card) error {
+    for _, card := range cards {
+        if err := vault.OpenRecord(card, m.keys); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (m *MockCardRepository) openAll(cards []models.Card, err error) ([]models.Card, error) {
+    for i := range cards {
+        if err == nil {
+            err = m.open(&cards[i])
+        }
+    }
+    return cards, err
 }
 
 func (m *MockCardRepository) Create(card *models.Card) error {
+    if err := vault.SealRecord(card, m.keys); err != nil {
+        return err
+    }
     args := m.Called(card)
     return args.Error(0)
 }
 
 func (m *MockCardRepository) GetByID(id, userID uuid.UUID) (*models.Card, error) {
     args := m.Called(id, userID)
-    return args.Get(0).(*models.Card), args.Error(1)
+    card, err := args.Get(0).(*models.Card), args.Error(1)
+    if err == nil {
+        err = m.open(card)
+    }
+    return card, err
 }
 
 func (m *MockCardRepository) GetAllByUserID(userID uuid.UUID) ([]models.Card, error) {
     args := m.Called(userID)
-    return args.Get(0).([]models.Card), args.Error(1)
+    return m.openAll(args.Get(0).([]models.Card), args.Error(1))
 }
 
 func (m *MockCardRepository) Update(card *models.Card) error {
+    if err := vault.SealRecord(card, m.keys); err != nil {
+        return err
+    }
     args := m.Called(card)
     return args.Error(0)
 }
@@ -49,7 +90,7 @@ func (m *MockCardRepository) BatchUpdate(cards []models.Card) error {
 
 func (m *MockCardRepository) GetAllCards() ([]models.Card, error) {
     args := m.Called()
-    return args.Get(0).([]models.Card), args.Error(1)
+    return m.openAll(args.Get(0).([]models.Card), args.Error(1))
 }
 
 func (m *MockCardRepository) UpdateKeyVersion(cardID uuid.UUID, version int) error {
@@ -57,26 +98,195 @@ func (m *MockCardRepository) UpdateKeyVersion(cardID uuid.UUID, version int) err
     return args.Error(0)
 }
 
+func (m *MockCardRepository) CountByKeyVersion(providers []string, version int) (int64, error) {
+    args := m.Called(providers, version)
+    return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCardRepository) GetAllByAADVersion(version int) ([]models.Card, error) {
+    args := m.Called(version)
+    return m.openAll(args.Get(0).([]models.Card), args.Error(1))
+}
+
+func (m *MockCardRepository) GetPageAfter(afterID uuid.UUID, limit int) ([]models.Card, error) {
+    args := m.Called(afterID, limit)
+    return args.Get(0).([]models.Card), args.Error(1)
+}
+
+func (m *MockCardRepository) FindByID(id uuid.UUID) (*models.Card, error) {
+    args := m.Called(id)
+    card, err := args.Get(0).(*models.Card), args.Error(1)
+    if err == nil {
+        err = m.open(card)
+    }
+    return card, err
+}
+
+func (m *MockCardRepository) UpdateDetails(card *models.Card) error {
+    args := m.Called(card)
+    return args.Error(0)
+}
+
+func (m *MockCardRepository) SwapKeyMaterial(card *models.Card, expectedDEK []byte) (bool, error) {
+    if err := vault.SealRecord(card, m.keys); err != nil {
+        return false, err
+    }
+    args := m.Called(card, expectedDEK)
+    return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCardRepository) DeleteByUserID(userID uuid.UUID) (int64, error) {
+    args := m.Called(userID)
+    return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCardRepository) ListIDsByUserID(userID uuid.UUID) ([]uuid.UUID, error) {
+    args := m.Called(userID)
+    return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockCardRepository) FindByFingerprints(fingerprints [][]byte) ([]models.Card, error) {
+    args := m.Called(fingerprints)
+    return args.Get(0).([]models.Card), args.Error(1)
+}
+
+func (m *MockCardRepository) UpdateFingerprint(card *models.Card) (bool, error) {
+    args := m.Called(card)
+    return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCardRepository) CountByFingerprintVersion(version int) (int64, error) {
+    args := m.Called(version)
+    return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCardRepository) UpdatePreferredNetwork(card *models.Card, status models.CardStatus) (bool, error) {
+    args := m.Called(card, status)
+    return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCardRepository) UpdateStatus(card *models.Card, from models.CardStatus, event *models.CardStatusEvent) (bool, error) {
+    args := m.Called(card, from, event)
+    return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCardRepository) GetStatusHistory(cardID uuid.UUID) ([]models.CardStatusEvent, error) {
+    args := m.Called(cardID)
+    return args.Get(0).([]models.CardStatusEvent), args.Error(1)
+}
+
+func (m *MockCardRepository) GetByExpiryRange(userID uuid.UUID, fromMonth, toMonth int) ([]models.Card, error) {
+    args := m.Called(userID, fromMonth, toMonth)
+    return m.openAll(args.Get(0).([]models.Card), args.Error(1))
+}
+
+func (m *MockCardRepository) UpdateIfStatus(card *models.Card, status models.CardStatus) (bool, error) {
+    if err := vault.SealRecord(card, m.keys); err != nil {
+        return false, err
+    }
+    args := m.Called(card, status)
+    return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCardRepository) ListExpiring(fromMonth, toMonth int, after *repository.CardCursor, limit int) ([]models.Card, error) {
+    args := m.Called(fromMonth, toMonth, after, limit)
+    return args.Get(0).([]models.Card), args.Error(1)
+}
+
+func (m *MockCardRepository) ListByUserID(userID uuid.UUID, filter repository.CardListFilter) ([]models.Card, error) {
+    args := m.Called(userID, filter)
+    return m.openAll(args.Get(0).([]models.Card), args.Error(1))
+}
+
+// Repositorio de trabajos de rotación en memoria; los trabajos avanzan en otra goroutine
+type memoryRotationJobs struct {
+    jobs map[uuid.UUID]models.RotationJob
+    mu   sync.Mutex
+}
+
+func newMemoryRotationJobs() *memoryRotationJobs {
+    return &memoryRotationJobs{jobs: make(map[uuid.UUID]models.RotationJob)}
+}
+
+func (r *memoryRotationJobs) Create(job *models.RotationJob) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.jobs[job.ID] = *job
+    return nil
+}
+
+func (r *memoryRotationJobs) GetByID(id uuid.UUID) (*models.RotationJob, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    job, ok := r.jobs[id]
+    if !ok {
+        return nil, errors.New("record not found")
+    }
+    return &job, nil
+}
+
+func (r *memoryRotationJobs) GetByStatus(status string) ([]models.RotationJob, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    var jobs []models.RotationJob
+    for _, job := range r.jobs {
+        if job.Status == status {
+            jobs = append(jobs, job)
+        }
+    }
+    return jobs, nil
+}
+
+func (r *memoryRotationJobs) Claim(id uuid.UUID, owner string, until time.Time) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    job, ok := r.jobs[id]
+    if !ok || job.Status != models.RotationJobRunning || job.LeaseExpiresAt != nil && job.LeaseExpiresAt.After(time.Now()) {
+        return false, nil
+    }
+    job.Owner, job.LeaseExpiresAt = owner, &until
+    r.jobs[id] = job
+    return true, nil
+}
+
+func (r *memoryRotationJobs) Checkpoint(job *models.RotationJob) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if r.jobs[job.ID].Owner != job.Owner {
+        return false, nil
+    }
+    r.jobs[job.ID] = *job
+    return true, nil
+}
+
+// waitForRotationJob espera a que el trabajo deje de estar en curso
+func waitForRotationJob(t *testing.T, cardSvc service.CardService, id uuid.UUID) *models.RotationJob {
+    var job *models.RotationJob
+    assert.Eventually(t, func() bool {
+        job, _ = cardSvc.GetRotationJob(id)
+        return job != nil && job.Status != models.RotationJobRunning
+    }, 5*time.Second, 10*time.Millisecond)
+    return job
+}
+
 func TestCardService_CreateCard(t *testing.T) {
     // Setup
     mockRepo := new(MockCardRepository)
-    key := make([]byte, 32)
-    rand.Read(key)
-    encSvc, _ := crypto.NewEncryptionService(key)
-    keyMgr := crypto.NewKeyManager()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
     
-    cardSvc := service.NewCardService(mockRepo, encSvc, keyMgr)
+    cardSvc := service.NewCardService(mockRepo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
 
     userID := uuid.New()
     cardReq := &models.CardRequest{
         CardholderName: "John Doe",
         CardNumber:     "4111111111111111",
         ExpiryMonth:    12,
-        ExpiryYear:     2025,
+        ExpiryYear:     2030,
         CVV:            "123",
     }
 
     // Mock expectations
+    mockRepo.On("FindByFingerprints", mock.Anything).Return([]models.Card{}, nil)
     mockRepo.On("Create", mock.AnythingOfType("*models.Card")).Return(nil)
 
     // Execute
@@ -90,4 +300,223 @@ func TestCardService_CreateCard(t *testing.T) {
     assert.Equal(t, "Visa", result.CardType)
 
     mockRepo.AssertExpectations(t)
+}
+
+func TestCardService_RotateKeysOnlyRewrapsDataKeys(t *testing.T) {
+    mockRepo := new(MockCardRepository)
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(mockRepo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    userID := uuid.New()
+    var stored models.Card
+    mockRepo.On("FindByFingerprints", mock.Anything).Return([]models.Card{}, nil)
+    mockRepo.On("Create", mock.AnythingOfType("*models.Card")).Run(func(args mock.Arguments) {
+        stored = *args.Get(0).(*models.Card)
+    }).Return(nil)
+
+    _, err := cardSvc.CreateCard(userID, &models.CardRequest{
+        CardholderName: "John Doe",
+        CardNumber:     "4111111111111111",
+        ExpiryMonth:    12,
+        ExpiryYear:     2030,
+        CVV:            "123",
+    })
+    assert.NoError(t, err)
+    assert.NotEmpty(t, stored.WrappedDEK)
+    assert.Equal(t, 1, stored.KeyVersion)
+
+    var rotated models.Card
+    mockRepo.On("GetPageAfter", uuid.Nil, mock.Anything).Return([]models.Card{stored}, nil)
+    mockRepo.On("GetPageAfter", stored.ID, mock.Anything).Return([]models.Card{}, nil)
+    mockRepo.On("SwapKeyMaterial", mock.AnythingOfType("*models.Card"), stored.WrappedDEK).Run(func(args mock.Arguments) {
+        rotated = *args.Get(0).(*models.Card)
+    }).Return(true, nil)
+
+    started, err := cardSvc.RotateKeys()
+    assert.NoError(t, err)
+    job := waitForRotationJob(t, cardSvc, started.ID)
+    assert.Equal(t, models.RotationJobCompleted, job.Status)
+    assert.Equal(t, 1, job.ProcessedCards)
+    assert.Equal(t, 0, job.FailedCards)
+
+    // Los datos cifrados no cambian; solo la DEK envuelta y la versión de la KEK
+    assert.Equal(t, stored.CardNumber.Ciphertext(), rotated.CardNumber.Ciphertext())
+    assert.NotEqual(t, stored.WrappedDEK, rotated.WrappedDEK)
+    assert.Equal(t, 2, rotated.KeyVersion)
+
+    mockRepo.On("GetByID", rotated.ID, userID).Return(&rotated, nil)
+    result, err := cardSvc.GetCard(rotated.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, "************1111", result.MaskedNumber)
+}
+
+func TestCardService_CiphertextIsBoundToItsCard(t *testing.T) {
+    mockRepo := new(MockCardRepository)
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(mockRepo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    var created []models.Card
+    mockRepo.On("FindByFingerprints", mock.Anything).Return([]models.Card{}, nil)
+    mockRepo.On("Create", mock.AnythingOfType("*models.Card")).Run(func(args mock.Arguments) {
+        created = append(created, *args.Get(0).(*models.Card))
+    }).Return(nil)
+
+    victimID, attackerID := uuid.New(), uuid.New()
+    _, err := cardSvc.CreateCard(victimID, &models.CardRequest{
+        CardholderName: "Victim", CardNumber: "4111111111111111", ExpiryMonth: 12, ExpiryYear: 2030, CVV: "123",
+    })
+    assert.NoError(t, err)
+    _, err = cardSvc.CreateCard(attackerID, &models.CardRequest{
+        CardholderName: "Attacker", CardNumber: "5555555555554444", ExpiryMonth: 12, ExpiryYear: 2030, CVV: "456",
+    })
+    assert.NoError(t, err)
+
+    // Copiar PAN y DEK de la víctima a la fila del atacante no permite descifrarlos
+    victim, attacker := created[0], created[1]
+    attacker.CardNumber = victim.CardNumber
+    attacker.WrappedDEK = victim.WrappedDEK
+    mockRepo.On("GetByID", attacker.ID, attackerID).Return(&attacker, nil)
+
+    _, err = cardSvc.GetCard(attacker.ID, attackerID)
+    assert.Error(t, err)
+}
+
+func TestCardService_BindAssociatedDataMigratesLegacyCards(t *testing.T) {
+    mockRepo := new(MockCardRepository)
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(mockRepo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    // Tarjeta guardada antes de los datos asociados: DEK propia pero AD nula
+    dek, wrappedDEK, keyVersion, _ := keyMgr.GenerateDataKey()
+    encSvc, _ := crypto.NewEncryptionService(dek)
+    encryptedNumber := legacyCiphertext(encSvc, "4111111111111111")
+    legacy := models.Card{
+        ID:          uuid.New(),
+        UserID:      uuid.New(),
+        CardNumber:  vault.FromCiphertext(encryptedNumber),
+        WrappedDEK:  wrappedDEK,
+        KeyProvider: kms.LocalProviderName,
+        KeyVersion:  keyVersion,
+    }
+
+    mockRepo.On("GetByID", legacy.ID, legacy.UserID).Return(&legacy, nil).Once()
+    result, err := cardSvc.GetCard(legacy.ID, legacy.UserID)
+    assert.NoError(t, err)
+    assert.Equal(t, "************1111", result.MaskedNumber)
+
+    var bound models.Card
+    mockRepo.On("GetAllByAADVersion", 0).Return([]models.Card{legacy}, nil)
+    mockRepo.On("SwapKeyMaterial", mock.AnythingOfType("*models.Card"), legacy.WrappedDEK).Run(func(args mock.Arguments) {
+        bound = *args.Get(0).(*models.Card)
+    }).Return(true, nil)
+
+    results, err := cardSvc.BindAssociatedData()
+    assert.NoError(t, err)
+    assert.Equal(t, "success", results[0].Status)
+    assert.Equal(t, 1, bound.AADVersion)
+
+    mockRepo.On("GetByID", bound.ID, bound.UserID).Return(&bound, nil)
+    result, err = cardSvc.GetCard(bound.ID, bound.UserID)
+    assert.NoError(t, err)
+    assert.Equal(t, "************1111", result.MaskedNumber)
+}
+
+func TestCardService_RotationJobResumesFromCheckpoint(t *testing.T) {
+    mockRepo := new(MockCardRepository)
+    jobs := newMemoryRotationJobs()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(mockRepo, jobs, keyMgr, kms.NewLocalProvider(keyMgr))
+
+    var created []models.Card
+    mockRepo.On("FindByFingerprints", mock.Anything).Return([]models.Card{}, nil)
+    mockRepo.On("Create", mock.AnythingOfType("*models.Card")).Run(func(args mock.Arguments) {
+        created = append(created, *args.Get(0).(*models.Card))
+    }).Return(nil)
+    for i := 0; i < 2; i++ {
+        _, err := cardSvc.CreateCard(uuid.New(), &models.CardRequest{
+            CardholderName: "John Doe", CardNumber: "4111111111111111", ExpiryMonth: 12, ExpiryYear: 2030, CVV: "123",
+        })
+        assert.NoError(t, err)
+    }
+
+    // Trabajo interrumpido tras rotar la clave y procesar la primera tarjeta
+    assert.NoError(t, keyMgr.RotateKey())
+    first, second := created[0], created[1]
+    interrupted := &models.RotationJob{
+        ID:             uuid.New(),
+        Status:         models.RotationJobRunning,
+        KeyProvider:    kms.LocalProviderName,
+        KeyRotated:     true,
+        LastCardID:     first.ID,
+        ProcessedCards: 1,
+    }
+    assert.NoError(t, jobs.Create(interrupted))
+
+    // Mientras sigue en curso no se puede lanzar otra rotación
+    _, err := cardSvc.RotateKeys()
+    assert.ErrorIs(t, err, service.ErrRotationInProgress)
+
+    var updated []models.Card
+    mockRepo.On("GetPageAfter", first.ID, mock.Anything).Return([]models.Card{second}, nil)
+    mockRepo.On("GetPageAfter", second.ID, mock.Anything).Return([]models.Card{}, nil)
+    mockRepo.On("SwapKeyMaterial", mock.AnythingOfType("*models.Card"), second.WrappedDEK).Run(func(args mock.Arguments) {
+        updated = append(updated, *args.Get(0).(*models.Card))
+    }).Return(true, nil)
+
+    assert.NoError(t, cardSvc.ResumeRotationJobs())
+    job := waitForRotationJob(t, cardSvc, interrupted.ID)
+
+    // Se reanuda desde el checkpoint sin volver a rotar la clave
+    assert.Equal(t, models.RotationJobCompleted, job.Status)
+    assert.Equal(t, 2, job.ProcessedCards)
+    assert.Equal(t, second.ID, job.LastCardID)
+    _, current := keyMgr.GetCurrentKey()
+    assert.Equal(t, 2, current)
+    assert.Len(t, updated, 1)
+    assert.Equal(t, second.ID, updated[0].ID)
+    assert.Equal(t, 2, updated[0].KeyVersion)
+    mockRepo.AssertNotCalled(t, "GetPageAfter", uuid.Nil, mock.Anything)
+}
+
+func TestCardService_ResumeClaimsRotationJobsOnce(t *testing.T) {
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    repo := newMemoryCardRepository()
+    jobs := newMemoryRotationJobs()
+    cardSvc := service.NewCardService(repo, jobs, keyMgr, kms.NewLocalProvider(keyMgr))
+    _, err := cardSvc.CreateCard(uuid.New(), cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+
+    // Otra réplica lo tiene con el lease vigente: no se toca
+    lease := time.Now().Add(time.Minute)
+    leased := &models.RotationJob{ID: uuid.New(), Status: models.RotationJobRunning, Owner: "other", LeaseExpiresAt: &lease}
+    assert.NoError(t, jobs.Create(leased))
+    assert.NoError(t, cardSvc.ResumeRotationJobs())
+    job, _ := cardSvc.GetRotationJob(leased.ID)
+    assert.Equal(t, models.RotationJobRunning, job.Status)
+    assert.Equal(t, "other", job.Owner)
+
+    // La réplica cayó tras rotar y antes del checkpoint: la versión objetivo ya
+    // está activa y no se rota otra vez
+    assert.NoError(t, keyMgr.RotateKey())
+    expired := time.Now().Add(-time.Minute)
+    leased.LeaseExpiresAt = &expired
+    leased.TargetVersion = 2
+    assert.NoError(t, jobs.Create(leased))
+    assert.NoError(t, cardSvc.ResumeRotationJobs())
+
+    job = waitForRotationJob(t, cardSvc, leased.ID)
+    assert.Equal(t, models.RotationJobCompleted, job.Status)
+    assert.True(t, job.KeyRotated)
+    assert.Equal(t, 1, job.ProcessedCards)
+    assert.NotEqual(t, "other", job.Owner)
+    _, current := keyMgr.GetCurrentKey()
+    assert.Equal(t, 2, current)
+}
+
+// legacyCiphertext reproduce el formato anterior a los sobres: base64(nonce||ciphertext)
+// en texto, que la migración a bytea decodifica a nonce||ciphertext.
+func legacyCiphertext(encSvc *crypto.EncryptionService, plaintext string) []byte {
+    encoded, _ := encSvc.Encrypt(plaintext)
+    raw, _ := base64.StdEncoding.DecodeString(encoded)
+    return raw
 }
\ No newline at end of file
@@ -0,0 +1,233 @@
+package tests
+
+import (
+    "card-vault/internal/crypto"
+    "card-vault/internal/cvv"
+    "card-vault/internal/kms"
+    "card-vault/internal/models"
+    "card-vault/internal/repository"
+    "card-vault/internal/service"
+    "card-vault/internal/vault"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestCardService_StatusLifecycle(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cvvs, _ := cvv.NewMemoryStore(time.Minute)
+    cardSvc := service.NewCardServiceWithOptions(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr), service.CardServiceOptions{CVVs: cvvs})
+
+    userID := uuid.New()
+    card, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    assert.Equal(t, models.CardStatusActive, card.Status)
+    assert.True(t, card.CVVPresent)
+
+    card, err = cardSvc.FreezeCard(card.ID, userID, "lost wallet")
+    assert.NoError(t, err)
+    assert.Equal(t, models.CardStatusFrozen, card.Status)
+    assert.Equal(t, "lost wallet", card.StatusReason)
+    assert.NotNil(t, card.StatusChangedAt)
+
+    // Una tarjeta bloqueada no se puede usar
+    _, err = cardSvc.TokenizeCard(card.ID, userID)
+    assert.ErrorIs(t, err, service.ErrCardNotActive)
+    _, err = cardSvc.UseCVV(card.ID, userID)
+    assert.ErrorIs(t, err, service.ErrCardNotActive)
+    _, err = cardSvc.StoreCVV(card.ID, userID, "456")
+    assert.ErrorIs(t, err, service.ErrCardNotActive)
+
+    _, err = cardSvc.FreezeCard(card.ID, userID, "")
+    assert.ErrorIs(t, err, service.ErrInvalidStatusTransition)
+
+    card, err = cardSvc.UnfreezeCard(card.ID, userID, "found it")
+    assert.NoError(t, err)
+    assert.Equal(t, models.CardStatusActive, card.Status)
+    _, err = cardSvc.TokenizeCard(card.ID, userID)
+    assert.NoError(t, err)
+
+    // Cerrar es definitivo y descarta el CVV
+    card, err = cardSvc.CloseCard(card.ID, userID, "customer request")
+    assert.NoError(t, err)
+    assert.Equal(t, models.CardStatusClosed, card.Status)
+    assert.False(t, card.CVVPresent)
+    _, err = cardSvc.UnfreezeCard(card.ID, userID, "")
+    assert.ErrorIs(t, err, service.ErrInvalidStatusTransition)
+
+    history, err := cardSvc.GetStatusHistory(card.ID, userID)
+    assert.NoError(t, err)
+    if assert.Len(t, history, 3) {
+        assert.Equal(t, models.CardStatusActive, history[0].FromStatus)
+        assert.Equal(t, models.CardStatusFrozen, history[0].ToStatus)
+        assert.Equal(t, "lost wallet", history[0].Reason)
+        assert.Equal(t, models.CardStatusClosed, history[2].ToStatus)
+    }
+
+    // Otro usuario no ve ni cambia la tarjeta
+    _, err = cardSvc.GetStatusHistory(card.ID, uuid.New())
+    assert.Error(t, err)
+
+    // El mismo PAN puede volver a guardarse tras cerrar la tarjeta
+    _, err = cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+}
+
+func TestCardService_DetokenizeSkipsInactiveCards(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    userID := uuid.New()
+    card, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    token, err := cardSvc.TokenizeCard(card.ID, userID)
+    assert.NoError(t, err)
+
+    _, err = cardSvc.FreezeCard(card.ID, userID, "")
+    assert.NoError(t, err)
+    _, err = cardSvc.Detokenize(token.Token)
+    assert.ErrorIs(t, err, service.ErrCardNotActive)
+
+    _, err = cardSvc.UnfreezeCard(card.ID, userID, "")
+    assert.NoError(t, err)
+    result, err := cardSvc.Detokenize(token.Token)
+    assert.NoError(t, err)
+    assert.Equal(t, []uuid.UUID{card.ID}, result.CardIDs)
+}
+
+// racingCardRepository ejecuta beforeUpdate justo antes de guardar una edición, y
+// beforeCreate antes de guardar una tarjeta nueva, como si otra petición cambiara
+// la tarjeta entre la lectura y la escritura.
+type racingCardRepository struct {
+    *memoryCardRepository
+    beforeUpdate func()
+    beforeCreate func()
+}
+
+func (r *racingCardRepository) WithKeys(keys vault.Keys) repository.CardRepository {
+    r.memoryCardRepository.WithKeys(keys)
+    return r
+}
+
+func (r *racingCardRepository) UpdateIfStatus(card *models.Card, status models.CardStatus) (bool, error) {
+    if r.beforeUpdate != nil {
+        r.beforeUpdate()
+        r.beforeUpdate = nil
+    }
+    return r.memoryCardRepository.UpdateIfStatus(card, status)
+}
+
+func (r *racingCardRepository) UpdatePreferredNetwork(card *models.Card, status models.CardStatus) (bool, error) {
+    if r.beforeUpdate != nil {
+        r.beforeUpdate()
+        r.beforeUpdate = nil
+    }
+    return r.memoryCardRepository.UpdatePreferredNetwork(card, status)
+}
+
+func (r *racingCardRepository) Create(card *models.Card) error {
+    if r.beforeCreate != nil {
+        r.beforeCreate()
+        r.beforeCreate = nil
+    }
+    return r.memoryCardRepository.Create(card)
+}
+
+func TestCardService_UpdateKeepsConcurrentStatusChanges(t *testing.T) {
+    repo := &racingCardRepository{memoryCardRepository: newMemoryCardRepository()}
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    userID := uuid.New()
+    card, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+
+    // Un bloqueo que llega mientras se edita la tarjeta no se deshace
+    repo.beforeUpdate = func() {
+        _, err := cardSvc.FreezeCard(card.ID, userID, "lost")
+        assert.NoError(t, err)
+    }
+    _, err = cardSvc.UpdateCard(card.ID, userID, cardRequest("John A. Doe", "4111111111111111"))
+    assert.ErrorIs(t, err, service.ErrInvalidStatusTransition)
+    stored, _ := cardSvc.GetCard(card.ID, userID)
+    assert.Equal(t, models.CardStatusFrozen, stored.Status)
+    assert.Equal(t, "lost", stored.StatusReason)
+    assert.Equal(t, "John Doe", stored.CardholderName)
+
+    // Una tarjeta bloqueada sí se puede editar, sin cambiar su estado
+    updated, err := cardSvc.UpdateCard(card.ID, userID, cardRequest("John A. Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    assert.Equal(t, models.CardStatusFrozen, updated.Status)
+    history, _ := cardSvc.GetStatusHistory(card.ID, userID)
+    assert.Len(t, history, 1)
+
+    // Ni cambia la red preferida de una tarjeta que se cierra entretanto
+    repo.beforeUpdate = func() {
+        _, err := cardSvc.CloseCard(card.ID, userID, "")
+        assert.NoError(t, err)
+    }
+    _, err = cardSvc.SetPreferredNetwork(card.ID, userID, "Visa")
+    assert.ErrorIs(t, err, service.ErrInvalidStatusTransition)
+
+    // Una tarjeta cerrada no
+    _, err = cardSvc.SetPreferredNetwork(card.ID, userID, "Visa")
+    assert.ErrorIs(t, err, service.ErrCardNotActive)
+    _, err = cardSvc.UpdateCard(card.ID, userID, cardRequest("Jane Doe", "4111111111111111"))
+    assert.ErrorIs(t, err, service.ErrCardNotActive)
+    expiry := 2031
+    results, _ := cardSvc.BatchUpdateCards(userID, &models.BatchUpdateRequest{Cards: []models.BatchCardUpdate{{ID: card.ID, ExpiryYear: &expiry}}})
+    assert.Equal(t, "failed", results[0].Status)
+    stored, _ = cardSvc.GetCard(card.ID, userID)
+    assert.Equal(t, "John A. Doe", stored.CardholderName)
+    assert.Equal(t, 2030, stored.ExpiryYear)
+}
+
+func TestCardService_ReplaceCard(t *testing.T) {
+    repo := &racingCardRepository{memoryCardRepository: newMemoryCardRepository()}
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cvvs, _ := cvv.NewMemoryStore(time.Minute)
+    cardSvc := service.NewCardServiceWithOptions(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr), service.CardServiceOptions{CVVs: cvvs})
+
+    userID := uuid.New()
+    old, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+
+    // La sustituta no puede repetir el PAN de la sustituida
+    _, err = cardSvc.ReplaceCard(old.ID, userID, cardRequest("John Doe", "4111111111111111"), "")
+    assert.ErrorIs(t, err, service.ErrDuplicateCard)
+
+    replacement, err := cardSvc.ReplaceCard(old.ID, userID, cardRequest("John Doe", "5555555555554444"), "card stolen")
+    assert.NoError(t, err)
+    assert.Equal(t, models.CardStatusActive, replacement.Status)
+    assert.True(t, replacement.CVVPresent)
+
+    replaced, err := cardSvc.GetCard(old.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, models.CardStatusReplaced, replaced.Status)
+    assert.Equal(t, "card stolen", replaced.StatusReason)
+    assert.Equal(t, &replacement.ID, replaced.ReplacedByID)
+    assert.False(t, replaced.CVVPresent)
+    _, err = cardSvc.TokenizeCard(old.ID, userID)
+    assert.ErrorIs(t, err, service.ErrCardNotActive)
+
+    // Una tarjeta sustituida solo puede cerrarse, y conserva el enlace
+    _, err = cardSvc.ReplaceCard(old.ID, userID, cardRequest("John Doe", "4012888888881881"), "")
+    assert.ErrorIs(t, err, service.ErrInvalidStatusTransition)
+    closed, err := cardSvc.CloseCard(old.ID, userID, "")
+    assert.NoError(t, err)
+    assert.Equal(t, &replacement.ID, closed.ReplacedByID)
+
+    // Si la anterior cambia de estado mientras se guarda la nueva, la nueva se descarta
+    repo.beforeCreate = func() {
+        _, err := cardSvc.CloseCard(replacement.ID, userID, "")
+        assert.NoError(t, err)
+    }
+    _, err = cardSvc.ReplaceCard(replacement.ID, userID, cardRequest("John Doe", "4012888888881881"), "")
+    assert.ErrorIs(t, err, service.ErrInvalidStatusTransition)
+    cards, _ := repo.ListIDsByUserID(userID)
+    assert.Len(t, cards, 2)
+}
\ No newline at end of file
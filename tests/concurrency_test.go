@@ -0,0 +1,608 @@
+package tests
+
+import (
+    "bytes"
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+    "card-vault/internal/models"
+    "card-vault/internal/repository"
+    "card-vault/internal/service"
+    "card-vault/internal/vault"
+    "fmt"
+    "math/rand"
+    "slices"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+    "gorm.io/gorm"
+)
+
+// memoryCardRepository reproduce en memoria la semántica del repositorio real,
+// incluido el compare-and-swap de SwapKeyMaterial, para tests de concurrencia.
+// Como los callbacks de vault, sella las tarjetas con las Keys del servicio al
+// guardarlas (solo guarda el ciphertext) y las abre al leerlas.
+type memoryCardRepository struct {
+    cards      map[uuid.UUID]models.Card
+    events     []models.CardStatusEvent
+    beforeSwap func(card *models.Card)
+    keys       vault.Keys
+    mu         sync.Mutex
+}
+
+func newMemoryCardRepository() *memoryCardRepository {
+    return &memoryCardRepository{cards: make(map[uuid.UUID]models.Card)}
+}
+
+func (r *memoryCardRepository) WithKeys(keys vault.Keys) repository.CardRepository {
+    r.keys = keys
+    return r
+}
+
+// seal sella la tarjeta como el callback de escritura y devuelve la copia que
+// guardaría la base de datos, sin los valores en claro.
+func (r *memoryCardRepository) seal(card *models.Card) (models.Card, error) {
+    if r.keys != nil {
+        if err := vault.SealRecord(card, r.keys); err != nil {
+            return models.Card{}, err
+        }
+    }
+    stored := *card
+    stored.CardNumber = vault.FromCiphertext(card.CardNumber.Ciphertext())
+    stored.CardholderName = vault.FromCiphertext(card.CardholderName.Ciphertext())
+    stored.BillingStreet = vault.FromCiphertext(card.BillingStreet.Ciphertext())
+    stored.BillingPostalCode = vault.FromCiphertext(card.BillingPostalCode.Ciphertext())
+    return stored, nil
+}
+
+// open abre las tarjetas leídas como el callback de lectura.
+func (r *memoryCardRepository) open(cards []models.Card) ([]models.Card, error) {
+    for i := range cards {
+        if r.keys == nil {
+            break
+        }
+        if err := vault.OpenRecord(&cards[i], r.keys); err != nil {
+            return nil, err
+        }
+    }
+    return cards, nil
+}
+
+func (r *memoryCardRepository) Create(card *models.Card) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    card.CreatedAt, card.UpdatedAt = time.Now(), time.Now()
+    stored, err := r.seal(card)
+    if err != nil {
+        return err
+    }
+    r.cards[card.ID] = stored
+    return nil
+}
+
+func (r *memoryCardRepository) GetByID(id, userID uuid.UUID) (*models.Card, error) {
+    r.mu.Lock()
+    card, ok := r.cards[id]
+    r.mu.Unlock()
+    if !ok || card.UserID != userID {
+        return nil, gorm.ErrRecordNotFound
+    }
+    opened, err := r.open([]models.Card{card})
+    if err != nil {
+        return nil, err
+    }
+    return &opened[0], nil
+}
+
+func (r *memoryCardRepository) GetAllByUserID(userID uuid.UUID) ([]models.Card, error) {
+    return r.open(r.filter(func(c models.Card) bool { return c.UserID == userID }))
+}
+
+func (r *memoryCardRepository) Update(card *models.Card) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    card.UpdatedAt = time.Now()
+    stored, err := r.seal(card)
+    if err != nil {
+        return err
+    }
+    r.cards[card.ID] = stored
+    return nil
+}
+
+func (r *memoryCardRepository) Delete(id, userID uuid.UUID) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if card, ok := r.cards[id]; ok && card.UserID == userID {
+        delete(r.cards, id)
+    }
+    return nil
+}
+
+func (r *memoryCardRepository) BatchUpdate(cards []models.Card) error {
+    for i := range cards {
+        r.Update(&cards[i])
+    }
+    return nil
+}
+
+func (r *memoryCardRepository) GetAllCards() ([]models.Card, error) {
+    return r.open(r.filter(func(models.Card) bool { return true }))
+}
+
+func (r *memoryCardRepository) UpdateKeyVersion(cardID uuid.UUID, version int) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if card, ok := r.cards[cardID]; ok {
+        card.KeyVersion = version
+        r.cards[cardID] = card
+    }
+    return nil
+}
+
+func (r *memoryCardRepository) CountByKeyVersion(providers []string, version int) (int64, error) {
+    return int64(len(r.filter(func(c models.Card) bool { return slices.Contains(providers, c.KeyProvider) && c.KeyVersion == version }))), nil
+}
+
+func (r *memoryCardRepository) GetAllByAADVersion(version int) ([]models.Card, error) {
+    return r.open(r.filter(func(c models.Card) bool { return c.AADVersion == version }))
+}
+
+func (r *memoryCardRepository) GetPageAfter(afterID uuid.UUID, limit int) ([]models.Card, error) {
+    cards := r.filter(func(c models.Card) bool { return bytes.Compare(c.ID[:], afterID[:]) > 0 })
+    if len(cards) > limit {
+        cards = cards[:limit]
+    }
+    return cards, nil
+}
+
+func (r *memoryCardRepository) FindByID(id uuid.UUID) (*models.Card, error) {
+    r.mu.Lock()
+    card, ok := r.cards[id]
+    r.mu.Unlock()
+    if !ok {
+        return nil, gorm.ErrRecordNotFound
+    }
+    opened, err := r.open([]models.Card{card})
+    if err != nil {
+        return nil, err
+    }
+    return &opened[0], nil
+}
+
+func (r *memoryCardRepository) UpdateDetails(card *models.Card) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    stored, ok := r.cards[card.ID]
+    if !ok {
+        return gorm.ErrRecordNotFound
+    }
+    stored.ExpiryMonth, stored.ExpiryYear = card.ExpiryMonth, card.ExpiryYear
+    r.cards[card.ID] = stored
+    return nil
+}
+
+func (r *memoryCardRepository) UpdatePreferredNetwork(card *models.Card, status models.CardStatus) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    stored, ok := r.cards[card.ID]
+    if !ok || stored.Status != status {
+        return false, nil
+    }
+    stored.PreferredNetwork = card.PreferredNetwork
+    r.cards[card.ID] = stored
+    return true, nil
+}
+
+func (r *memoryCardRepository) UpdateStatus(card *models.Card, from models.CardStatus, event *models.CardStatusEvent) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    stored, ok := r.cards[card.ID]
+    if !ok || stored.Status != from {
+        return false, nil
+    }
+    stored.Status, stored.StatusReason, stored.StatusChangedAt = card.Status, card.StatusReason, card.StatusChangedAt
+    if card.ReplacedByID != nil {
+        stored.ReplacedByID = card.ReplacedByID
+    }
+    r.cards[card.ID] = stored
+    event.ID = uuid.New()
+    r.events = append(r.events, *event)
+    return true, nil
+}
+
+func (r *memoryCardRepository) UpdateIfStatus(card *models.Card, status models.CardStatus) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    stored, ok := r.cards[card.ID]
+    if !ok || stored.Status != status {
+        return false, nil
+    }
+    card.UpdatedAt = time.Now()
+    updated, err := r.seal(card)
+    if err != nil {
+        return false, err
+    }
+    updated.Status, updated.StatusReason, updated.StatusChangedAt = stored.Status, stored.StatusReason, stored.StatusChangedAt
+    updated.ReplacedByID = stored.ReplacedByID
+    r.cards[card.ID] = updated
+    return true, nil
+}
+
+func (r *memoryCardRepository) GetStatusHistory(cardID uuid.UUID) ([]models.CardStatusEvent, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    events := []models.CardStatusEvent{}
+    for _, event := range r.events {
+        if event.CardID == cardID {
+            events = append(events, event)
+        }
+    }
+    return events, nil
+}
+
+func (r *memoryCardRepository) GetByExpiryRange(userID uuid.UUID, fromMonth, toMonth int) ([]models.Card, error) {
+    cards := r.filter(func(c models.Card) bool {
+        month := c.ExpiryYear*12 + c.ExpiryMonth - 1
+        return (userID == uuid.Nil || c.UserID == userID) && month >= fromMonth && month < toMonth &&
+            (c.Status == models.CardStatusActive || c.Status == models.CardStatusFrozen)
+    })
+    sort.Slice(cards, func(i, j int) bool {
+        return cards[i].ExpiryYear*12+cards[i].ExpiryMonth < cards[j].ExpiryYear*12+cards[j].ExpiryMonth
+    })
+    return r.open(cards)
+}
+
+// ListExpiring ordena por (caducidad, ID) como el repositorio real y, como él, solo
+// devuelve las columnas en claro.
+func (r *memoryCardRepository) ListExpiring(fromMonth, toMonth int, after *repository.CardCursor, limit int) ([]models.Card, error) {
+    cards := r.filter(func(c models.Card) bool {
+        month := c.ExpiryYear*12 + c.ExpiryMonth - 1
+        return month >= fromMonth && month < toMonth && (c.Status == models.CardStatusActive || c.Status == models.CardStatusFrozen)
+    })
+    key := func(c models.Card) int { return c.ExpiryYear*12 + c.ExpiryMonth - 1 }
+    sort.Slice(cards, func(i, j int) bool {
+        if key(cards[i]) != key(cards[j]) {
+            return key(cards[i]) < key(cards[j])
+        }
+        return bytes.Compare(cards[i].ID[:], cards[j].ID[:]) < 0
+    })
+
+    page := make([]models.Card, 0, limit)
+    for _, c := range cards {
+        if after != nil && (key(c) < after.Expiry || key(c) == after.Expiry && bytes.Compare(c.ID[:], after.ID[:]) <= 0) {
+            continue
+        }
+        if len(page) == limit {
+            break
+        }
+        page = append(page, models.Card{
+            ID: c.ID, UserID: c.UserID, Last4: c.Last4, CardType: c.CardType,
+            ExpiryMonth: c.ExpiryMonth, ExpiryYear: c.ExpiryYear, Status: c.Status,
+        })
+    }
+    return page, nil
+}
+
+// ListByUserID filtra y ordena como el repositorio real, comparando (orden, ID)
+// con la posición del cursor.
+func (r *memoryCardRepository) ListByUserID(userID uuid.UUID, filter repository.CardListFilter) ([]models.Card, error) {
+    sortKey := func(createdAt time.Time, expiry int) int64 {
+        if filter.Sort == models.CardSortExpiry {
+            return int64(expiry)
+        }
+        return createdAt.UnixNano()
+    }
+    // position devuelve < 0 si la tarjeta va antes que (key, id) en el listado
+    position := func(c models.Card, key int64, id uuid.UUID) int {
+        cmp := bytes.Compare(c.ID[:], id[:])
+        if k := sortKey(c.CreatedAt, c.ExpiryYear*12+c.ExpiryMonth-1); k < key {
+            cmp = -1
+        } else if k > key {
+            cmp = 1
+        }
+        if filter.Descending {
+            return -cmp
+        }
+        return cmp
+    }
+
+    cards := r.filter(func(c models.Card) bool {
+        month := c.ExpiryYear*12 + c.ExpiryMonth - 1
+        return c.UserID == userID &&
+            (len(filter.CardTypes) == 0 || slices.Contains(filter.CardTypes, c.CardType)) &&
+            (len(filter.Statuses) == 0 || slices.Contains(filter.Statuses, c.Status)) &&
+            (filter.ExpiryFrom == 0 || month >= filter.ExpiryFrom) &&
+            (filter.ExpiryTo == 0 || month < filter.ExpiryTo) &&
+            (filter.CreatedFrom.IsZero() || !c.CreatedAt.Before(filter.CreatedFrom)) &&
+            (filter.CreatedTo.IsZero() || c.CreatedAt.Before(filter.CreatedTo)) &&
+            (filter.Last4 == "" || c.Last4 == filter.Last4) &&
+            (filter.After == nil || position(c, sortKey(filter.After.CreatedAt, filter.After.Expiry), filter.After.ID) > 0)
+    })
+    sort.Slice(cards, func(i, j int) bool {
+        return position(cards[i], sortKey(cards[j].CreatedAt, cards[j].ExpiryYear*12+cards[j].ExpiryMonth-1), cards[j].ID) < 0
+    })
+
+    if len(cards) > filter.Limit {
+        cards = cards[:filter.Limit]
+    }
+    return r.open(cards)
+}
+
+func (r *memoryCardRepository) SwapKeyMaterial(card *models.Card, expectedDEK []byte) (bool, error) {
+    if r.beforeSwap != nil {
+        r.beforeSwap(card)
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    stored, ok := r.cards[card.ID]
+    if !ok || !bytes.Equal(stored.WrappedDEK, expectedDEK) {
+        return false, nil
+    }
+    sealed, err := r.seal(card)
+    if err != nil {
+        return false, err
+    }
+    stored.CardNumber, stored.WrappedDEK = sealed.CardNumber, sealed.WrappedDEK
+    stored.KeyProvider, stored.KeyVersion, stored.AADVersion = card.KeyProvider, card.KeyVersion, card.AADVersion
+    stored.UserKeyed = card.UserKeyed
+    stored.BillingStreet, stored.BillingPostalCode = sealed.BillingStreet, sealed.BillingPostalCode
+    stored.CardholderName, stored.LegacyCardholderName = sealed.CardholderName, sealed.LegacyCardholderName
+    r.cards[card.ID] = stored
+    return true, nil
+}
+
+// filter devuelve copias ordenadas por ID, como el ORDER BY id del repositorio real
+func (r *memoryCardRepository) DeleteByUserID(userID uuid.UUID) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    var deleted int64
+    for id, card := range r.cards {
+        if card.UserID == userID {
+            delete(r.cards, id)
+            deleted++
+        }
+    }
+    return deleted, nil
+}
+
+func (r *memoryCardRepository) ListIDsByUserID(userID uuid.UUID) ([]uuid.UUID, error) {
+    ids := []uuid.UUID{}
+    for _, card := range r.filter(func(c models.Card) bool { return c.UserID == userID }) {
+        ids = append(ids, card.ID)
+    }
+    return ids, nil
+}
+
+func (r *memoryCardRepository) FindByFingerprints(fingerprints [][]byte) ([]models.Card, error) {
+    return r.filter(func(c models.Card) bool {
+        for _, fp := range fingerprints {
+            if len(c.PANFingerprint) > 0 && bytes.Equal(c.PANFingerprint, fp) {
+                return true
+            }
+        }
+        return false
+    }), nil
+}
+
+func (r *memoryCardRepository) UpdateFingerprint(card *models.Card) (bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    stored, ok := r.cards[card.ID]
+    // Sin DEK coincide con las tarjetas sin DEK, como el "wrapped_dek IS NULL" del
+    // repositorio real (TestCardRepository_UpdateFingerprintMatchesNullDataKeys)
+    if !ok || !bytes.Equal(stored.WrappedDEK, card.WrappedDEK) {
+        return false, nil
+    }
+    stored.PANFingerprint, stored.FingerprintVersion, stored.Last4 = card.PANFingerprint, card.FingerprintVersion, card.Last4
+    r.cards[card.ID] = stored
+    return true, nil
+}
+
+func (r *memoryCardRepository) CountByFingerprintVersion(version int) (int64, error) {
+    return int64(len(r.filter(func(c models.Card) bool { return c.FingerprintVersion == version }))), nil
+}
+
+func (r *memoryCardRepository) filter(keep func(models.Card) bool) []models.Card {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    cards := []models.Card{}
+    for _, card := range r.cards {
+        if keep(card) {
+            cards = append(cards, card)
+        }
+    }
+    sort.Slice(cards, func(i, j int) bool { return bytes.Compare(cards[i].ID[:], cards[j].ID[:]) < 0 })
+    return cards
+}
+
+// luhnNumber genera un PAN Visa válido y aleatorio
+func luhnNumber(rng *rand.Rand) string {
+    digits := make([]int, 16)
+    digits[0] = 4
+    for i := 1; i < 15; i++ {
+        digits[i] = rng.Intn(10)
+    }
+    sum := 0
+    for i := 14; i >= 0; i-- {
+        d := digits[i]
+        if (15-i)%2 == 1 {
+            d *= 2
+            if d > 9 {
+                d -= 9
+            }
+        }
+        sum += d
+    }
+    digits[15] = (10 - sum%10) % 10
+
+    pan := ""
+    for _, d := range digits {
+        pan += fmt.Sprint(d)
+    }
+    return pan
+}
+
+func masked(pan string) string {
+    return "************" + pan[len(pan)-4:]
+}
+
+func TestCardService_RotationDoesNotOverwriteConcurrentUpdate(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    userID := uuid.New()
+    created, err := cardSvc.CreateCard(userID, &models.CardRequest{
+        CardholderName: "John Doe", CardNumber: "4111111111111111", ExpiryMonth: 12, ExpiryYear: 2030, CVV: "123",
+    })
+    assert.NoError(t, err)
+
+    // El usuario cambia el PAN justo después de que la rotación haya leído la tarjeta
+    var once sync.Once
+    repo.beforeSwap = func(*models.Card) {
+        once.Do(func() {
+            _, err := cardSvc.UpdateCard(created.ID, userID, &models.CardRequest{
+                CardholderName: "John Doe", CardNumber: "5555555555554444", ExpiryMonth: 12, ExpiryYear: 2030, CVV: "456",
+            })
+            assert.NoError(t, err)
+        })
+    }
+
+    started, err := cardSvc.RotateKeys()
+    assert.NoError(t, err)
+    job := waitForRotationJob(t, cardSvc, started.ID)
+    assert.Equal(t, models.RotationJobCompleted, job.Status)
+    assert.Equal(t, 0, job.FailedCards)
+
+    // El rewrap de la versión leída pierde el compare-and-swap y se repite sobre la nueva
+    result, err := cardSvc.GetCard(created.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, "************4444", result.MaskedNumber)
+
+    stored, _ := repo.FindByID(created.ID)
+    header, _ := crypto.ParseEnvelopeHeader(stored.WrappedDEK)
+    assert.Equal(t, 2, stored.KeyVersion)
+    assert.Equal(t, uint32(2), header.KeyVersion)
+}
+
+func TestCardService_OnlineRotationWithConcurrentWrites(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    const writers, cardsPerWriter = 4, 10
+
+    type ownedCard struct {
+        id, userID uuid.UUID
+        pan        string
+    }
+
+    // Cada escritor es dueño de sus tarjetas, así el PAN esperado es el último que escribió
+    owned := make([][]ownedCard, writers)
+    rng := rand.New(rand.NewSource(1))
+    for w := range owned {
+        for i := 0; i < cardsPerWriter; i++ {
+            userID, pan := uuid.New(), luhnNumber(rng)
+            card, err := cardSvc.CreateCard(userID, &models.CardRequest{
+                CardholderName: "John Doe", CardNumber: pan, ExpiryMonth: 12, ExpiryYear: 2030, CVV: "123",
+            })
+            assert.NoError(t, err)
+            owned[w] = append(owned[w], ownedCard{id: card.ID, userID: userID, pan: pan})
+        }
+    }
+
+    // Los lectores recorren una copia: el PAN esperado solo lo toca su escritor
+    readable := make([][]ownedCard, writers)
+    for w := range owned {
+        readable[w] = append([]ownedCard(nil), owned[w]...)
+    }
+
+    stop := make(chan struct{})
+    var readErrors, writeErrors atomic.Int64
+    var wg sync.WaitGroup
+
+    for w := 0; w < writers; w++ {
+        wg.Add(2)
+
+        go func(w int) {
+            defer wg.Done()
+            rng := rand.New(rand.NewSource(int64(w) + 100))
+            for {
+                select {
+                case <-stop:
+                    return
+                default:
+                }
+
+                i := rng.Intn(cardsPerWriter)
+                pan := luhnNumber(rng)
+                if _, err := cardSvc.UpdateCard(owned[w][i].id, owned[w][i].userID, &models.CardRequest{
+                    CardholderName: "John Doe", CardNumber: pan, ExpiryMonth: 12, ExpiryYear: 2030, CVV: "123",
+                }); err != nil {
+                    writeErrors.Add(1)
+                    continue
+                }
+                owned[w][i].pan = pan
+
+                if _, err := cardSvc.CreateCard(uuid.New(), &models.CardRequest{
+                    CardholderName: "Jane Doe", CardNumber: luhnNumber(rng), ExpiryMonth: 12, ExpiryYear: 2030, CVV: "123",
+                }); err != nil {
+                    writeErrors.Add(1)
+                }
+            }
+        }(w)
+
+        // Las lecturas nunca deben fallar mientras se rota
+        go func(w int) {
+            defer wg.Done()
+            for {
+                select {
+                case <-stop:
+                    return
+                default:
+                }
+
+                for _, card := range readable[(w+1)%writers] {
+                    if _, err := cardSvc.GetCard(card.id, card.userID); err != nil {
+                        readErrors.Add(1)
+                    }
+                }
+            }
+        }(w)
+    }
+
+    for round := 0; round < 3; round++ {
+        started, err := cardSvc.RotateKeys()
+        assert.NoError(t, err)
+        job := waitForRotationJob(t, cardSvc, started.ID)
+        assert.Equal(t, models.RotationJobCompleted, job.Status)
+        assert.Equal(t, 0, job.FailedCards, job.LastError)
+    }
+
+    close(stop)
+    wg.Wait()
+
+    assert.Zero(t, readErrors.Load())
+    assert.Zero(t, writeErrors.Load())
+
+    // Cada tarjeta está envuelta con la versión que declara y conserva su último PAN
+    all, _ := repo.GetAllCards()
+    for _, card := range all {
+        header, ok := crypto.ParseEnvelopeHeader(card.WrappedDEK)
+        assert.True(t, ok)
+        assert.Equal(t, uint32(card.KeyVersion), header.KeyVersion)
+    }
+    for w := range owned {
+        for _, card := range owned[w] {
+            result, err := cardSvc.GetCard(card.id, card.userID)
+            assert.NoError(t, err)
+            assert.Equal(t, masked(card.pan), result.MaskedNumber)
+        }
+    }
+}
\ No newline at end of file
@@ -3,6 +3,9 @@ package tests
 import (
     "card-vault/internal/crypto"
     "crypto/rand"
+    "encoding/base64"
+    "encoding/hex"
+    "path/filepath"
     "testing"
 
     "github.com/stretchr/testify/assert"
@@ -31,14 +34,15 @@ func TestEncryptionService(t *testing.T) {
 }
 
 func TestKeyManager(t *testing.T) {
-    km := crypto.NewKeyManager()
+    km, err := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    assert.NoError(t, err)
 
     currentKey, version := km.GetCurrentKey()
     assert.NotNil(t, currentKey)
     assert.Equal(t, 1, version)
 
     // Test key rotation
-    err := km.RotateKey()
+    err = km.RotateKey()
     assert.NoError(t, err)
 
     newKey, newVersion := km.GetCurrentKey()
@@ -46,6 +50,229 @@ func TestKeyManager(t *testing.T) {
     assert.Equal(t, 2, newVersion)
     assert.NotEqual(t, currentKey, newKey)
 
-    previousKey := km.GetPreviousKey()
+    previousKey, err := km.GetKey(1)
+    assert.NoError(t, err)
     assert.Equal(t, currentKey, previousKey)
+}
+
+func TestKeyManager_KeyStates(t *testing.T) {
+    km, err := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    assert.NoError(t, err)
+
+    firstKey, _ := km.GetCurrentKey()
+    assert.NoError(t, km.RotateKey())
+    assert.NoError(t, km.RotateKey())
+
+    // Tras dos rotaciones la versión 1 sigue descifrando
+    key, err := km.GetKey(1)
+    assert.NoError(t, err)
+    assert.Equal(t, firstKey, key)
+
+    states := map[int]crypto.KeyState{}
+    for _, info := range km.ListKeys() {
+        states[info.Version] = info.State
+    }
+    assert.Equal(t, crypto.KeyStateDecryptOnly, states[1])
+    assert.Equal(t, crypto.KeyStateDecryptOnly, states[2])
+    assert.Equal(t, crypto.KeyStateActive, states[3])
+
+    // La versión activa solo se retira rotando
+    assert.ErrorIs(t, km.SetKeyState(3, crypto.KeyStateDisabled), crypto.ErrInvalidKeyTransition)
+
+    assert.NoError(t, km.SetKeyState(1, crypto.KeyStateDisabled))
+    _, err = km.GetKey(1)
+    assert.ErrorIs(t, err, crypto.ErrKeyDisabled)
+
+    assert.NoError(t, km.SetKeyState(1, crypto.KeyStateDecryptOnly))
+    _, err = km.GetKey(1)
+    assert.NoError(t, err)
+
+    assert.NoError(t, km.SetKeyState(1, crypto.KeyStateDestroyed))
+    _, err = km.GetKey(1)
+    assert.ErrorIs(t, err, crypto.ErrKeyDestroyed)
+    assert.ErrorIs(t, km.SetKeyState(1, crypto.KeyStateDecryptOnly), crypto.ErrInvalidKeyTransition)
+}
+
+func TestFileKeyStore_PersistsAcrossRestarts(t *testing.T) {
+    masterKey := make([]byte, 32)
+    rand.Read(masterKey)
+    path := filepath.Join(t.TempDir(), "keyring.json")
+
+    store, err := crypto.NewFileKeyStore(path, masterKey)
+    assert.NoError(t, err)
+
+    km, err := crypto.NewKeyManager(store)
+    assert.NoError(t, err)
+    firstKey, _ := km.GetCurrentKey()
+    assert.NoError(t, km.RotateKey())
+    secondKey, secondVersion := km.GetCurrentKey()
+
+    // Simula un reinicio: nuevo store y nuevo KeyManager sobre el mismo fichero
+    restartedStore, err := crypto.NewFileKeyStore(path, masterKey)
+    assert.NoError(t, err)
+    restarted, err := crypto.NewKeyManager(restartedStore)
+    assert.NoError(t, err)
+
+    key, version := restarted.GetCurrentKey()
+    assert.Equal(t, secondVersion, version)
+    assert.Equal(t, secondKey, key)
+
+    oldKey, err := restarted.GetKey(1)
+    assert.NoError(t, err)
+    assert.Equal(t, firstKey, oldKey)
+
+    // Una master key distinta no puede abrir el keyring
+    wrongKey := make([]byte, 32)
+    rand.Read(wrongKey)
+    wrongStore, err := crypto.NewFileKeyStore(path, wrongKey)
+    assert.NoError(t, err)
+    _, err = crypto.NewKeyManager(wrongStore)
+    assert.Error(t, err)
+}
+
+func TestKeyManager_PicksUpRotationFromOtherReplica(t *testing.T) {
+    store := crypto.NewMemoryKeyStore()
+
+    replicaA, err := crypto.NewKeyManager(store)
+    assert.NoError(t, err)
+    replicaB, err := crypto.NewKeyManager(store)
+    assert.NoError(t, err)
+
+    assert.NoError(t, replicaA.RotateKey())
+    newKey, newVersion := replicaA.GetCurrentKey()
+
+    key, err := replicaB.GetKey(newVersion)
+    assert.NoError(t, err)
+    assert.Equal(t, newKey, key)
+}
+
+func TestEncryptionService_Envelope(t *testing.T) {
+    key := make([]byte, 32)
+    rand.Read(key)
+    encSvc, err := crypto.NewEncryptionService(key)
+    assert.NoError(t, err)
+
+    ad := []byte("card=1|field=pan")
+    sealed, err := encSvc.Seal([]byte("4111111111111111"), 7, ad)
+    assert.NoError(t, err)
+
+    header, ok := crypto.ParseEnvelopeHeader(sealed)
+    assert.True(t, ok)
+    assert.Equal(t, crypto.EnvelopeFormatV1, header.Format)
+    assert.Equal(t, crypto.AlgAES256GCM, header.Algorithm)
+    assert.Equal(t, uint32(7), header.KeyVersion)
+
+    plaintext, err := encSvc.Open(sealed, ad)
+    assert.NoError(t, err)
+    assert.Equal(t, "4111111111111111", string(plaintext))
+
+    // La cabecera está autenticada: cambiar la versión invalida el sobre
+    tampered := append([]byte(nil), sealed...)
+    tampered[7] = 8
+    _, err = encSvc.Open(tampered, ad)
+    assert.Error(t, err)
+
+    // Los valores sin cabecera (formato anterior) siguen abriéndose
+    legacy, err := encSvc.EncryptWithAD("4111111111111111", ad)
+    assert.NoError(t, err)
+    raw, _ := base64.StdEncoding.DecodeString(legacy)
+    _, ok = crypto.ParseEnvelopeHeader(raw)
+    assert.False(t, ok)
+    plaintext, err = encSvc.Open(raw, ad)
+    assert.NoError(t, err)
+    assert.Equal(t, "4111111111111111", string(plaintext))
+}
+
+func TestGCMSIV_RFC8452Vectors(t *testing.T) {
+    // Apéndice C.2 del RFC 8452 (AEAD_AES_256_GCM_SIV)
+    vectors := []struct {
+        plaintext, aad, result string
+    }{
+        {"", "", "07f5f4169bbf55a8400cd47ea6fd400f"},
+        {"0100000000000000", "", "c2ef328e5c71c83b843122130f7364b761e0b97427e3df28"},
+        {"010000000000000000000000", "", "9aab2aeb3faa0a34aea8e2b18ca50da9ae6559e48fd10f6e5c9ca17e"},
+        {"0200000000000000", "01", "1de22967237a813291213f267e3b452f02d01ae33e4ec854"},
+    }
+
+    key, _ := hex.DecodeString("0100000000000000000000000000000000000000000000000000000000000000")
+    nonce, _ := hex.DecodeString("030000000000000000000000")
+    aead, err := crypto.NewGCMSIV(key)
+    assert.NoError(t, err)
+
+    for _, v := range vectors {
+        plaintext, _ := hex.DecodeString(v.plaintext)
+        aad, _ := hex.DecodeString(v.aad)
+
+        sealed := aead.Seal(nil, nonce, plaintext, aad)
+        assert.Equal(t, v.result, hex.EncodeToString(sealed))
+
+        opened, err := aead.Open(nil, nonce, sealed, aad)
+        assert.NoError(t, err)
+        assert.Equal(t, plaintext, append([]byte{}, opened...))
+
+        sealed[0] ^= 1
+        _, err = aead.Open(nil, nonce, sealed, aad)
+        assert.Error(t, err)
+    }
+}
+
+func TestEncryptionService_Algorithms(t *testing.T) {
+    key := make([]byte, 32)
+    rand.Read(key)
+    ad := []byte("card=1|field=pan")
+
+    gcmSvc, err := crypto.NewEncryptionService(key)
+    assert.NoError(t, err)
+
+    for _, alg := range []crypto.AlgorithmID{crypto.AlgAES256GCM, crypto.AlgAES256GCMSIV, crypto.AlgXChaCha20Poly1305} {
+        encSvc, err := crypto.NewEncryptionServiceWithAlgorithm(alg, key)
+        assert.NoError(t, err)
+
+        sealed, err := encSvc.Seal([]byte("4111111111111111"), 1, ad)
+        assert.NoError(t, err)
+
+        header, _ := crypto.ParseEnvelopeHeader(sealed)
+        assert.Equal(t, alg, header.Algorithm)
+
+        // El algoritmo sale de la cabecera, no del configurado en el servicio
+        plaintext, err := gcmSvc.Open(sealed, ad)
+        assert.NoError(t, err, alg.String())
+        assert.Equal(t, "4111111111111111", string(plaintext))
+
+        parsed, err := crypto.ParseAlgorithm(alg.String())
+        assert.NoError(t, err)
+        assert.Equal(t, alg, parsed)
+    }
+
+    _, err = crypto.ParseAlgorithm("des")
+    assert.ErrorIs(t, err, crypto.ErrUnknownAlgorithm)
+}
+
+func TestKeyManager_AlgorithmPerKeyVersion(t *testing.T) {
+    store := crypto.NewMemoryKeyStore()
+    km, err := crypto.NewKeyManager(store)
+    assert.NoError(t, err)
+
+    dek, wrappedV1, _, err := km.GenerateDataKey()
+    assert.NoError(t, err)
+
+    // Se cambia la configuración: las versiones nuevas usan XChaCha20-Poly1305
+    km, err = crypto.NewKeyManagerWithAlgorithm(store, crypto.AlgXChaCha20Poly1305)
+    assert.NoError(t, err)
+    assert.NoError(t, km.RotateKey())
+
+    keys := km.ListKeys()
+    assert.Equal(t, "aes-256-gcm", keys[0].Algorithm)
+    assert.Equal(t, "xchacha20-poly1305", keys[1].Algorithm)
+
+    _, wrappedV2, version, err := km.GenerateDataKey()
+    assert.NoError(t, err)
+    assert.Equal(t, 2, version)
+    header, _ := crypto.ParseEnvelopeHeader(wrappedV2)
+    assert.Equal(t, crypto.AlgXChaCha20Poly1305, header.Algorithm)
+
+    // La DEK envuelta con la versión 1 sigue abriéndose con AES-256-GCM
+    unwrapped, err := km.UnwrapDataKey(wrappedV1, 1)
+    assert.NoError(t, err)
+    assert.Equal(t, dek, unwrapped)
 }
\ No newline at end of file
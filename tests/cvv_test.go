@@ -0,0 +1,105 @@
+package tests
+
+import (
+    "card-vault/internal/crypto"
+    "card-vault/internal/cvv"
+    "card-vault/internal/kms"
+    "card-vault/internal/service"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestCVVStore_ExpiresAndIsSingleUse(t *testing.T) {
+    store, err := cvv.NewMemoryStore(time.Minute)
+    assert.NoError(t, err)
+
+    cardID := uuid.New()
+    expiresAt, err := store.Put(cardID, "123")
+    assert.NoError(t, err)
+    assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, time.Second)
+
+    _, ok := store.ExpiresAt(cardID)
+    assert.True(t, ok)
+
+    code, err := store.Take(cardID)
+    assert.NoError(t, err)
+    assert.Equal(t, "123", code)
+
+    // Solo se puede usar una vez
+    _, err = store.Take(cardID)
+    assert.ErrorIs(t, err, cvv.ErrNotFound)
+    _, ok = store.ExpiresAt(cardID)
+    assert.False(t, ok)
+
+    // El barrido borra los caducados y conserva los vigentes
+    other := uuid.New()
+    store.Put(cardID, "456")
+    store.Put(other, "789")
+    assert.Equal(t, 0, store.Sweep(time.Now()))
+    assert.Equal(t, 2, store.Sweep(time.Now().Add(2*time.Minute)))
+    _, err = store.Take(other)
+    assert.ErrorIs(t, err, cvv.ErrNotFound)
+}
+
+func TestCVVStore_ExpiredIsNotReturned(t *testing.T) {
+    store, _ := cvv.NewMemoryStore(10 * time.Millisecond)
+
+    cardID := uuid.New()
+    store.Put(cardID, "123")
+    time.Sleep(20 * time.Millisecond)
+
+    _, ok := store.ExpiresAt(cardID)
+    assert.False(t, ok)
+    _, err := store.Take(cardID)
+    assert.ErrorIs(t, err, cvv.ErrNotFound)
+}
+
+func TestCardService_CVVIsEphemeral(t *testing.T) {
+    repo := newMemoryCardRepository()
+    store, _ := cvv.NewMemoryStore(time.Minute)
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardServiceWithOptions(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr), service.CardServiceOptions{CVVs: store})
+
+    userID := uuid.New()
+    created, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    assert.True(t, created.CVVPresent)
+    assert.NotNil(t, created.CVVExpiresAt)
+
+    // Solo el propietario puede usarlo
+    _, err = cardSvc.UseCVV(created.ID, uuid.New())
+    assert.Error(t, err)
+
+    used, err := cardSvc.UseCVV(created.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, "123", used.CVV)
+
+    card, err := cardSvc.GetCard(created.ID, userID)
+    assert.NoError(t, err)
+    assert.False(t, card.CVVPresent)
+    assert.Nil(t, card.CVVExpiresAt)
+    _, err = cardSvc.UseCVV(created.ID, userID)
+    assert.ErrorIs(t, err, cvv.ErrNotFound)
+
+    // Un CVV nuevo para otro cargo
+    card, err = cardSvc.StoreCVV(created.ID, userID, "456")
+    assert.NoError(t, err)
+    assert.True(t, card.CVVPresent)
+
+    // Cambiar el PAN sin CVV descarta el del PAN anterior
+    req := cardRequest("John Doe", "5555555555554444")
+    req.CVV = ""
+    card, err = cardSvc.UpdateCard(created.ID, userID, req)
+    assert.NoError(t, err)
+    assert.False(t, card.CVVPresent)
+
+    // Una tarjeta sin CVV es válida
+    req = cardRequest("John Doe", "4000056655665556")
+    req.CVV = ""
+    other, err := cardSvc.CreateCard(userID, req)
+    assert.NoError(t, err)
+    assert.False(t, other.CVVPresent)
+}
\ No newline at end of file
@@ -0,0 +1,226 @@
+package tests
+
+import (
+    "card-vault/internal/crypto"
+    "card-vault/internal/cvv"
+    "card-vault/internal/handlers"
+    "card-vault/internal/kms"
+    "card-vault/internal/middleware"
+    "card-vault/internal/models"
+    "card-vault/internal/service"
+    "card-vault/internal/tokenization"
+    "card-vault/internal/vault"
+    "crypto/rand"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+)
+
+type memoryAuditRepository struct {
+    records []models.AuditRecord
+    mu      sync.Mutex
+}
+
+func (r *memoryAuditRepository) Create(record *models.AuditRecord) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.records = append(r.records, *record)
+    return nil
+}
+
+func (r *memoryAuditRepository) GetBySubject(subjectID uuid.UUID) ([]models.AuditRecord, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    var records []models.AuditRecord
+    for _, record := range r.records {
+        if record.SubjectID == subjectID {
+            records = append(records, record)
+        }
+    }
+    return records, nil
+}
+
+func TestErasureService_ShredUser(t *testing.T) {
+    masterKey := make([]byte, 32)
+    rand.Read(masterKey)
+    store, _ := crypto.NewFileKeyStore(filepath.Join(t.TempDir(), "keyring.json"), masterKey)
+    keyMgr, err := crypto.NewKeyManager(store)
+    assert.NoError(t, err)
+
+    repo := newMemoryCardRepository()
+    audit := &memoryAuditRepository{}
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+    erasureSvc := service.NewErasureService(repo, nil, audit, nil, keyMgr)
+
+    shredded, kept := uuid.New(), uuid.New()
+    request := &models.CardRequest{CardholderName: "John Doe", CardNumber: "4111111111111111", ExpiryMonth: 12, ExpiryYear: 2030, CVV: "123"}
+    shreddedCard, err := cardSvc.CreateCard(shredded, request)
+    assert.NoError(t, err)
+    _, err = cardSvc.CreateCard(shredded, &models.CardRequest{CardholderName: "John Doe", CardNumber: "5555555555554444", ExpiryMonth: 12, ExpiryYear: 2030, CVV: "456"})
+    assert.NoError(t, err)
+    keptCard, err := cardSvc.CreateCard(kept, request)
+    assert.NoError(t, err)
+
+    // Copia de seguridad de la base de datos anterior al borrado
+    backup := newMemoryCardRepository()
+    for id, card := range repo.cards {
+        backup.cards[id] = card
+    }
+
+    admin := uuid.New()
+    record, err := erasureSvc.ShredUser(admin, shredded)
+    assert.NoError(t, err)
+    assert.Equal(t, models.AuditActionShredUser, record.Action)
+    assert.Equal(t, admin, record.ActorID)
+    assert.Equal(t, shredded, record.SubjectID)
+    assert.Contains(t, record.Details, "2 cards deleted")
+
+    records, _ := audit.GetBySubject(shredded)
+    assert.Len(t, records, 1)
+
+    cards, _ := repo.GetAllByUserID(shredded)
+    assert.Empty(t, cards)
+
+    // Restaurar la copia no devuelve los datos: la clave del usuario ya no existe,
+    // tampoco tras reiniciar con el mismo keyring
+    restarted, err := crypto.NewKeyManager(store)
+    assert.NoError(t, err)
+    restoredSvc := service.NewCardService(backup, newMemoryRotationJobs(), restarted, kms.NewLocalProvider(restarted))
+    _, err = restoredSvc.GetCard(shreddedCard.ID, shredded)
+    assert.Error(t, err)
+    _, err = restarted.UserKey(shredded.String())
+    assert.ErrorIs(t, err, crypto.ErrUserKeyDestroyed)
+
+    // Los demás usuarios no se ven afectados
+    card, err := restoredSvc.GetCard(keptCard.ID, kept)
+    assert.NoError(t, err)
+    assert.Equal(t, "************1111", card.MaskedNumber)
+
+    // No se vuelve a crear una clave para el usuario borrado
+    _, err = cardSvc.CreateCard(shredded, request)
+    assert.ErrorIs(t, err, crypto.ErrUserKeyDestroyed)
+}
+
+func TestErasureService_ShredUserPurgesCVVsAndTokens(t *testing.T) {
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    repo := newMemoryCardRepository()
+    tokens := newMemoryTokenRepository()
+    cvvs, _ := cvv.NewMemoryStore(time.Minute)
+    cardSvc := service.NewCardServiceWithOptions(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr), service.CardServiceOptions{
+        Tokenization: tokenization.Config{Mode: tokenization.ModeRandom},
+        Tokens:       tokens,
+        CVVs:         cvvs,
+    })
+    audit := &memoryAuditRepository{}
+    erasureSvc := service.NewErasureService(repo, tokens, audit, cvvs, keyMgr)
+
+    shredded, kept := uuid.New(), uuid.New()
+    card, err := cardSvc.CreateCard(shredded, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    token, err := cardSvc.TokenizeCard(card.ID, shredded)
+    assert.NoError(t, err)
+    keptCard, err := cardSvc.CreateCard(kept, cardRequest("Jane Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    keptToken, err := cardSvc.TokenizeCard(keptCard.ID, kept)
+    assert.NoError(t, err)
+
+    record, err := erasureSvc.ShredUser(uuid.New(), shredded)
+    assert.NoError(t, err)
+    assert.Contains(t, record.Details, "1 cards deleted; 1 CVVs purged; 1 tokens deleted")
+
+    // Ni el CVV ni el token de la tarjeta borrada sobreviven
+    _, ok := cvvs.ExpiresAt(card.ID)
+    assert.False(t, ok)
+    _, err = tokens.GetByToken(token.Token)
+    assert.Error(t, err)
+    _, err = cardSvc.Detokenize(token.Token)
+    assert.Error(t, err)
+
+    // Los del otro usuario siguen ahí
+    _, ok = cvvs.ExpiresAt(keptCard.ID)
+    assert.True(t, ok)
+    _, err = tokens.GetByToken(keptToken.Token)
+    assert.NoError(t, err)
+}
+
+func TestErasureHandler_RequiresAdminScope(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    t.Setenv("JWT_SECRET", "test-secret")
+
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    repo := newMemoryCardRepository()
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+    victim := uuid.New()
+    card, err := cardSvc.CreateCard(victim, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+
+    r := gin.New()
+    admin := r.Group("/admin", middleware.AuthMiddleware(), middleware.RequireScope(middleware.ScopeAdmin))
+    admin.POST("/users/:id/shred", handlers.NewErasureHandler(service.NewErasureService(repo, nil, &memoryAuditRepository{}, nil, keyMgr)).ShredUser)
+
+    shred := func(scopes []string) int {
+        token, _ := middleware.GenerateTokenWithScopes(uuid.New(), scopes)
+        req := httptest.NewRequest(http.MethodPost, "/admin/users/"+victim.String()+"/shred", nil)
+        req.Header.Set("Authorization", "Bearer "+token)
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        return w.Code
+    }
+
+    // Un usuario sin el scope admin no puede borrar a otro
+    assert.Equal(t, http.StatusForbidden, shred(nil))
+    assert.Equal(t, http.StatusForbidden, shred([]string{middleware.ScopeRevealCard}))
+    _, err = cardSvc.GetCard(card.ID, victim)
+    assert.NoError(t, err)
+
+    assert.Equal(t, http.StatusOK, shred([]string{middleware.ScopeAdmin}))
+    _, err = cardSvc.GetCard(card.ID, victim)
+    assert.Error(t, err)
+}
+
+func TestCardService_RotationMigratesCardsToUserKeys(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    userID := uuid.New()
+    created, err := cardSvc.CreateCard(userID, &models.CardRequest{
+        CardholderName: "John Doe", CardNumber: "4111111111111111", ExpiryMonth: 12, ExpiryYear: 2030, CVV: "123",
+    })
+    assert.NoError(t, err)
+
+    // Tarjeta guardada antes de las claves por usuario: DEK sin derivar
+    dek, wrappedDEK, version, _ := keyMgr.GenerateDataKey()
+    encSvc, _ := crypto.NewEncryptionService(dek)
+    legacy := models.Card{ID: uuid.New(), UserID: userID, LegacyCardholderName: "Jane Doe", ExpiryMonth: 1, ExpiryYear: 2031,
+        WrappedDEK: wrappedDEK, KeyProvider: kms.LocalProviderName, KeyVersion: version}
+    ciphertext, _ := encSvc.Seal([]byte("5555555555554444"), crypto.RecordKeyVersion, nil)
+    legacy.CardNumber = vault.FromCiphertext(ciphertext)
+    repo.Create(&legacy)
+
+    card, err := cardSvc.GetCard(legacy.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, "************4444", card.MaskedNumber)
+
+    started, err := cardSvc.RotateKeys()
+    assert.NoError(t, err)
+    job := waitForRotationJob(t, cardSvc, started.ID)
+    assert.Equal(t, 0, job.FailedCards)
+
+    migrated, _ := repo.FindByID(legacy.ID)
+    assert.True(t, migrated.UserKeyed)
+    card, err = cardSvc.GetCard(legacy.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, "************4444", card.MaskedNumber)
+
+    card, err = cardSvc.GetCard(created.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, "************1111", card.MaskedNumber)
+}
\ No newline at end of file
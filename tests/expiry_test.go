@@ -0,0 +1,232 @@
+package tests
+
+import (
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+    "card-vault/internal/models"
+    "card-vault/internal/repository"
+    "card-vault/internal/scheduler"
+    "card-vault/internal/service"
+    "card-vault/internal/vault"
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+)
+
+// expiringRequest crea una petición para una tarjeta que caduca months meses después del actual
+func expiringRequest(number string, months int) *models.CardRequest {
+    expiry := time.Now().UTC().AddDate(0, 0, 1-time.Now().UTC().Day()).AddDate(0, months, 0)
+    req := cardRequest("John Doe", number)
+    req.ExpiryMonth, req.ExpiryYear = int(expiry.Month()), expiry.Year()
+    return req
+}
+
+func TestCardService_ValidatesExpiryAgainstToday(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+    userID := uuid.New()
+
+    _, err := cardSvc.CreateCard(userID, expiringRequest("4111111111111111", -1))
+    assert.ErrorIs(t, err, service.ErrInvalidExpiry)
+    _, err = cardSvc.CreateCard(userID, expiringRequest("4111111111111111", 25*12))
+    assert.ErrorIs(t, err, service.ErrInvalidExpiry)
+
+    // Una tarjeta es válida hasta el final de su mes de caducidad
+    card, err := cardSvc.CreateCard(userID, expiringRequest("4111111111111111", 0))
+    assert.NoError(t, err)
+
+    past := time.Now().Year() - 1
+    results, _ := cardSvc.BatchUpdateCards(userID, &models.BatchUpdateRequest{Cards: []models.BatchCardUpdate{{ID: card.ID, ExpiryYear: &past}}})
+    assert.Equal(t, "failed", results[0].Status)
+}
+
+func TestCardService_ExpireCards(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    alice, bob := uuid.New(), uuid.New()
+    soon, err := cardSvc.CreateCard(alice, expiringRequest("4111111111111111", 0))
+    assert.NoError(t, err)
+    later, err := cardSvc.CreateCard(alice, expiringRequest("5555555555554444", 36))
+    assert.NoError(t, err)
+    frozen, err := cardSvc.CreateCard(bob, expiringRequest("4000056655665556", 0))
+    assert.NoError(t, err)
+    _, err = cardSvc.FreezeCard(frozen.ID, bob, "")
+    assert.NoError(t, err)
+    closed, err := cardSvc.CreateCard(bob, expiringRequest("5200828282828210", 0))
+    assert.NoError(t, err)
+    _, err = cardSvc.CloseCard(closed.ID, bob, "")
+    assert.NoError(t, err)
+
+    // 40 días siempre llegan al final del mes actual
+    expiring, err := cardSvc.GetExpiringCards(alice, 40)
+    assert.NoError(t, err)
+    if assert.Len(t, expiring, 1) {
+        assert.Equal(t, soon.ID, expiring[0].ID)
+    }
+    all, err := cardSvc.GetAllExpiringCards(40, &models.PageQuery{})
+    assert.NoError(t, err)
+    assert.Len(t, all.Cards, 2)
+
+    // Aún no ha caducado ninguna
+    result, err := cardSvc.ExpireCards(time.Now())
+    assert.NoError(t, err)
+    assert.Equal(t, 0, result.ExpiredCards)
+
+    result, err = cardSvc.ExpireCards(time.Now().AddDate(0, 1, 1))
+    assert.NoError(t, err)
+    assert.Equal(t, 2, result.ExpiredCards)
+    assert.Equal(t, 0, result.FailedCards)
+
+    card, _ := cardSvc.GetCard(soon.ID, alice)
+    assert.Equal(t, models.CardStatusExpired, card.Status)
+    card, _ = cardSvc.GetCard(frozen.ID, bob)
+    assert.Equal(t, models.CardStatusExpired, card.Status)
+    card, _ = cardSvc.GetCard(later.ID, alice)
+    assert.Equal(t, models.CardStatusActive, card.Status)
+    card, _ = cardSvc.GetCard(closed.ID, bob)
+    assert.Equal(t, models.CardStatusClosed, card.Status)
+
+    history, _ := cardSvc.GetStatusHistory(soon.ID, alice)
+    if assert.Len(t, history, 1) {
+        assert.Equal(t, models.CardStatusExpired, history[0].ToStatus)
+    }
+
+    _, err = cardSvc.TokenizeCard(soon.ID, alice)
+    assert.ErrorIs(t, err, service.ErrCardNotActive)
+
+    // Una segunda ejecución no vuelve a tocarlas
+    result, err = cardSvc.ExpireCards(time.Now().AddDate(0, 1, 1))
+    assert.NoError(t, err)
+    assert.Equal(t, 0, result.ExpiredCards)
+}
+
+func TestCardService_PaginatesExpiringCardsWithoutDecrypting(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    for months, number := range []string{"4111111111111111", "5555555555554444", "4012888888881881", "4000056655665556", "4242424242424242"} {
+        _, err := cardSvc.CreateCard(uuid.New(), expiringRequest(number, months%2))
+        assert.NoError(t, err)
+    }
+    // Fuera del plazo
+    _, err := cardSvc.CreateCard(uuid.New(), expiringRequest("5200828282828210", 36))
+    assert.NoError(t, err)
+
+    var last4 []string
+    query := &models.PageQuery{Limit: 2}
+    for pages := 0; ; pages++ {
+        page, err := cardSvc.GetAllExpiringCards(70, query)
+        assert.NoError(t, err)
+        for _, card := range page.Cards {
+            last4 = append(last4, card.Last4)
+        }
+        if !page.Pagination.HasMore {
+            assert.Equal(t, 2, pages)
+            break
+        }
+        query.Cursor = page.Pagination.NextCursor
+    }
+    assert.ElementsMatch(t, []string{"1111", "4444", "1881", "5556", "4242"}, last4)
+
+    _, err = cardSvc.GetAllExpiringCards(70, &models.PageQuery{Limit: 101})
+    assert.ErrorIs(t, err, service.ErrInvalidCardQuery)
+    _, err = cardSvc.GetAllExpiringCards(70, &models.PageQuery{Cursor: "not-a-cursor"})
+    assert.ErrorIs(t, err, service.ErrInvalidCursor)
+
+    // Sin claves se sigue respondiendo: el informe no descifra ninguna tarjeta
+    masterKey := make([]byte, 32)
+    sealed := newSealedKeyManager(t)
+    assert.NoError(t, sealed.Initialize(masterKey))
+    assert.NoError(t, sealed.Seal())
+    page, err := service.NewCardService(repo, newMemoryRotationJobs(), sealed, kms.NewLocalProvider(sealed)).GetAllExpiringCards(70, &models.PageQuery{})
+    assert.NoError(t, err)
+    assert.Len(t, page.Cards, 5)
+}
+
+// pagingCardRepository registra las páginas que se piden a ListExpiring y rechaza
+// la carga completa de las tarjetas caducadas de todos los usuarios.
+type pagingCardRepository struct {
+    *memoryCardRepository
+    pages []int
+}
+
+func (r *pagingCardRepository) WithKeys(keys vault.Keys) repository.CardRepository {
+    r.memoryCardRepository.WithKeys(keys)
+    return r
+}
+
+func (r *pagingCardRepository) GetByExpiryRange(userID uuid.UUID, fromMonth, toMonth int) ([]models.Card, error) {
+    if userID == uuid.Nil {
+        return nil, errors.New("unbounded expiry query")
+    }
+    return r.memoryCardRepository.GetByExpiryRange(userID, fromMonth, toMonth)
+}
+
+func (r *pagingCardRepository) ListExpiring(fromMonth, toMonth int, after *repository.CardCursor, limit int) ([]models.Card, error) {
+    cards, err := r.memoryCardRepository.ListExpiring(fromMonth, toMonth, after, limit)
+    r.pages = append(r.pages, len(cards))
+    return cards, err
+}
+
+func TestCardService_ExpireCardsInPages(t *testing.T) {
+    repo := &pagingCardRepository{memoryCardRepository: newMemoryCardRepository()}
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    for i := 0; i < 150; i++ {
+        _, err := cardSvc.CreateCard(uuid.New(), expiringRequest("4111111111111111", 0))
+        assert.NoError(t, err)
+    }
+
+    result, err := cardSvc.ExpireCards(time.Now().AddDate(0, 1, 1))
+    assert.NoError(t, err)
+    assert.Equal(t, 150, result.ExpiredCards)
+    assert.Equal(t, 0, result.FailedCards)
+    assert.Equal(t, []int{100, 50}, repo.pages)
+}
+
+type countingExpirer struct {
+    runs []time.Time
+}
+
+func (e *countingExpirer) ExpireCards(now time.Time) (*service.ExpiryResult, error) {
+    e.runs = append(e.runs, now)
+    return &service.ExpiryResult{RanAt: now}, nil
+}
+
+func TestExpiryScheduler_RunsOnSchedule(t *testing.T) {
+    schedule, err := scheduler.ParseCron("@daily")
+    assert.NoError(t, err)
+
+    expirer := &countingExpirer{}
+    s := scheduler.NewExpiryScheduler(schedule, expirer, time.Minute)
+
+    status := s.Status()
+    assert.True(t, status.Enabled)
+    assert.NotNil(t, status.NextRun)
+    next := *status.NextRun
+
+    s.Check(next.Add(-time.Minute))
+    assert.Empty(t, expirer.runs)
+
+    s.Check(next)
+    assert.Equal(t, []time.Time{next}, expirer.runs)
+    assert.Equal(t, next.AddDate(0, 0, 1), *s.Status().NextRun)
+    assert.Equal(t, next, s.Status().LastRun.RanAt)
+
+    // Sin expresión cron solo se ejecuta a mano
+    manual := scheduler.NewExpiryScheduler(nil, expirer, time.Minute)
+    assert.False(t, manual.Status().Enabled)
+    manual.Check(next.AddDate(1, 0, 0))
+    assert.Len(t, expirer.runs, 1)
+    _, err = manual.Run(next)
+    assert.NoError(t, err)
+    assert.Len(t, expirer.runs, 2)
+}
\ No newline at end of file
@@ -0,0 +1,291 @@
+package tests
+
+import (
+    "bytes"
+    "card-vault/internal/crypto"
+    "card-vault/internal/handlers"
+    "card-vault/internal/kms"
+    "card-vault/internal/middleware"
+    "card-vault/internal/models"
+    "card-vault/internal/repository"
+    "card-vault/internal/service"
+    "card-vault/internal/vault"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+    "gorm.io/gorm"
+)
+
+func cardRequest(name, number string) *models.CardRequest {
+    return &models.CardRequest{CardholderName: name, CardNumber: number, ExpiryMonth: 12, ExpiryYear: 2030, CVV: "123"}
+}
+
+func TestCardService_RejectsDuplicateCards(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    alice, bob := uuid.New(), uuid.New()
+    first, err := cardSvc.CreateCard(alice, cardRequest("Alice", "4111111111111111"))
+    assert.NoError(t, err)
+
+    _, err = cardSvc.CreateCard(alice, cardRequest("Alice", "4111 1111 1111 1111"))
+    assert.ErrorIs(t, err, service.ErrDuplicateCard)
+
+    // El mismo PAN en otro usuario no es un duplicado
+    _, err = cardSvc.CreateCard(bob, cardRequest("Bob", "4111111111111111"))
+    assert.NoError(t, err)
+
+    // Tampoco se puede cambiar el PAN de otra tarjeta por uno que ya tiene
+    other, err := cardSvc.CreateCard(alice, cardRequest("Alice", "5555555555554444"))
+    assert.NoError(t, err)
+    _, err = cardSvc.UpdateCard(other.ID, alice, cardRequest("Alice", "4111111111111111"))
+    assert.ErrorIs(t, err, service.ErrDuplicateCard)
+
+    // Actualizar la tarjeta manteniendo su propio PAN sí es válido
+    _, err = cardSvc.UpdateCard(first.ID, alice, cardRequest("Alice Smith", "4111111111111111"))
+    assert.NoError(t, err)
+
+    holders, err := cardSvc.LookupByPAN("4111111111111111")
+    assert.NoError(t, err)
+    assert.Len(t, holders, 2)
+    users := []uuid.UUID{holders[0].UserID, holders[1].UserID}
+    assert.ElementsMatch(t, []uuid.UUID{alice, bob}, users)
+}
+
+// uniqueCardRepository aplica el índice único de huellas como la base de datos y
+// no ve las tarjetas ya guardadas al buscar duplicados, como una petición
+// concurrente que aún no ve la inserción de la otra.
+type uniqueCardRepository struct {
+    *memoryCardRepository
+}
+
+func (r *uniqueCardRepository) WithKeys(keys vault.Keys) repository.CardRepository {
+    r.memoryCardRepository.WithKeys(keys)
+    return r
+}
+
+func (r *uniqueCardRepository) FindByFingerprints(fingerprints [][]byte) ([]models.Card, error) {
+    return nil, nil
+}
+
+func (r *uniqueCardRepository) Create(card *models.Card) error {
+    if r.conflicts(card) {
+        return gorm.ErrDuplicatedKey
+    }
+    return r.memoryCardRepository.Create(card)
+}
+
+func (r *uniqueCardRepository) UpdateIfStatus(card *models.Card, status models.CardStatus) (bool, error) {
+    if r.conflicts(card) {
+        return false, gorm.ErrDuplicatedKey
+    }
+    return r.memoryCardRepository.UpdateIfStatus(card, status)
+}
+
+func (r *uniqueCardRepository) conflicts(card *models.Card) bool {
+    return len(r.filter(func(c models.Card) bool {
+        return c.ID != card.ID && c.UserID == card.UserID && c.Status != models.CardStatusClosed &&
+            bytes.Equal(c.PANFingerprint, card.PANFingerprint) && c.FingerprintVersion == card.FingerprintVersion
+    })) > 0
+}
+
+func TestCardService_UniqueIndexRejectsConcurrentDuplicates(t *testing.T) {
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(&uniqueCardRepository{newMemoryCardRepository()}, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    userID := uuid.New()
+    first, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    _, err = cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.ErrorIs(t, err, service.ErrDuplicateCard)
+
+    other, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "5555555555554444"))
+    assert.NoError(t, err)
+    _, err = cardSvc.UpdateCard(other.ID, userID, cardRequest("John Doe", "4111111111111111"))
+    assert.ErrorIs(t, err, service.ErrDuplicateCard)
+
+    // Una tarjeta cerrada no cuenta para el índice
+    _, err = cardSvc.CloseCard(first.ID, userID, "")
+    assert.NoError(t, err)
+    _, err = cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+}
+
+func TestCardService_MergesDuplicateCards(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardServiceWithOptions(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr), service.CardServiceOptions{Duplicates: service.DuplicateMerge})
+
+    userID := uuid.New()
+    first, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+
+    merged, err := cardSvc.CreateCard(userID, &models.CardRequest{
+        CardholderName: "John A. Doe", CardNumber: "4111111111111111", ExpiryMonth: 6, ExpiryYear: 2032, CVV: "999",
+    })
+    assert.NoError(t, err)
+    assert.Equal(t, first.ID, merged.ID)
+    assert.Equal(t, "John A. Doe", merged.CardholderName)
+    assert.Equal(t, 2032, merged.ExpiryYear)
+
+    page, _ := cardSvc.GetUserCards(userID, &models.CardListQuery{})
+    assert.Len(t, page.Cards, 1)
+}
+
+func TestCardService_ReindexFingerprintsRotatesKey(t *testing.T) {
+    repo := newMemoryCardRepository()
+    store := crypto.NewMemoryKeyStore()
+    keyMgr, _ := crypto.NewKeyManager(store)
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    userID := uuid.New()
+    created, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    before, _ := repo.FindByID(created.ID)
+    assert.Equal(t, 1, before.FingerprintVersion)
+    assert.NotEmpty(t, before.PANFingerprint)
+
+    // Tarjeta anterior a las huellas
+    legacy, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "5555555555554444"))
+    assert.NoError(t, err)
+    stored := repo.cards[legacy.ID]
+    stored.PANFingerprint, stored.FingerprintVersion = nil, 0
+    repo.cards[legacy.ID] = stored
+
+    result, err := cardSvc.ReindexFingerprints(true)
+    assert.NoError(t, err)
+    assert.Equal(t, 2, result.KeyVersion)
+    assert.Equal(t, 2, result.ProcessedCards)
+    assert.Equal(t, 0, result.FailedCards)
+    assert.Equal(t, []int{1}, result.RetiredVersions)
+
+    after, _ := repo.FindByID(created.ID)
+    assert.Equal(t, 2, after.FingerprintVersion)
+    assert.NotEqual(t, before.PANFingerprint, after.PANFingerprint)
+
+    // La clave de huellas se persiste en el keyring: otra réplica calcula lo mismo
+    replica, _ := crypto.NewKeyManager(store)
+    value, version, err := replica.Fingerprint([]byte("4111111111111111"))
+    assert.NoError(t, err)
+    assert.Equal(t, 2, version)
+    assert.Equal(t, after.PANFingerprint, value)
+
+    for _, number := range []string{"4111111111111111", "5555555555554444"} {
+        holders, err := cardSvc.LookupByPAN(number)
+        assert.NoError(t, err)
+        assert.Len(t, holders, 1)
+    }
+
+    keys := keyMgr.FingerprintKeys()
+    assert.Equal(t, crypto.KeyStateDestroyed, keys[0].State)
+    assert.Equal(t, crypto.KeyStateActive, keys[1].State)
+}
+
+// racingFingerprintRepository ejecuta beforeFingerprint justo antes de guardar una
+// huella recalculada, como si otra petición editara la tarjeta entretanto.
+type racingFingerprintRepository struct {
+    *memoryCardRepository
+    beforeFingerprint func()
+}
+
+func (r *racingFingerprintRepository) WithKeys(keys vault.Keys) repository.CardRepository {
+    r.memoryCardRepository.WithKeys(keys)
+    return r
+}
+
+func (r *racingFingerprintRepository) UpdateFingerprint(card *models.Card) (bool, error) {
+    if r.beforeFingerprint != nil {
+        r.beforeFingerprint()
+        r.beforeFingerprint = nil
+    }
+    return r.memoryCardRepository.UpdateFingerprint(card)
+}
+
+func TestCardService_ReindexRetriesCardsChangedConcurrently(t *testing.T) {
+    repo := &racingFingerprintRepository{memoryCardRepository: newMemoryCardRepository()}
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    userID := uuid.New()
+    card, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+
+    // Cambiar el titular vuelve a cifrar la tarjeta con otra DEK: el recálculo
+    // pierde el compare-and-swap, relee la tarjeta y vuelve a guardar su huella
+    name := "John A. Doe"
+    repo.beforeFingerprint = func() {
+        results, err := cardSvc.BatchUpdateCards(userID, &models.BatchUpdateRequest{Cards: []models.BatchCardUpdate{{ID: card.ID, CardholderName: &name}}})
+        assert.NoError(t, err)
+        assert.Equal(t, "success", results[0].Status)
+    }
+    result, err := cardSvc.ReindexFingerprints(true)
+    assert.NoError(t, err)
+    assert.Equal(t, 1, result.ProcessedCards)
+    assert.Equal(t, 0, result.SkippedCards)
+    assert.Equal(t, 0, result.FailedCards)
+    assert.Equal(t, []int{1}, result.RetiredVersions)
+
+    stored, _ := repo.FindByID(card.ID)
+    assert.Equal(t, 2, stored.FingerprintVersion)
+    assert.Equal(t, "John A. Doe", stored.CardholderName.String())
+}
+
+func TestCardService_RejectsCardNumbersUntilFingerprintsAreReindexed(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    userID := uuid.New()
+    card, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+
+    // Con dos versiones vivas el índice único no vería el duplicado
+    assert.NoError(t, keyMgr.RotateFingerprintKey())
+    _, err = cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.ErrorIs(t, err, service.ErrFingerprintReindexPending)
+    _, err = cardSvc.UpdateCard(card.ID, userID, cardRequest("John Doe", "5555555555554444"))
+    assert.ErrorIs(t, err, service.ErrFingerprintReindexPending)
+
+    _, err = cardSvc.ReindexFingerprints(false)
+    assert.NoError(t, err)
+    _, err = cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.ErrorIs(t, err, service.ErrDuplicateCard)
+}
+
+func TestCardHandler_LookupRequiresAdminScope(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    t.Setenv("JWT_SECRET", "test-secret")
+
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(newMemoryCardRepository(), newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+    cardSvc.CreateCard(uuid.New(), cardRequest("John Doe", "4111111111111111"))
+
+    cardHandler := handlers.NewCardHandler(cardSvc)
+    r := gin.New()
+    admin := r.Group("/admin", middleware.AuthMiddleware(), middleware.RequireScope(middleware.ScopeAdmin))
+    admin.POST("/cards/lookup", cardHandler.LookupCards)
+    admin.POST("/cards/reindex-fingerprints", cardHandler.ReindexFingerprints)
+
+    post := func(path, body string, scopes []string) int {
+        token, _ := middleware.GenerateTokenWithScopes(uuid.New(), scopes)
+        req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+        req.Header.Set("Authorization", "Bearer "+token)
+        req.Header.Set("Content-Type", "application/json")
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        return w.Code
+    }
+
+    // Sin el scope admin no se puede saber qué otros usuarios guardan un PAN
+    lookup := `{"card_number": "4111111111111111"}`
+    assert.Equal(t, http.StatusForbidden, post("/admin/cards/lookup", lookup, nil))
+    assert.Equal(t, http.StatusForbidden, post("/admin/cards/reindex-fingerprints", "", nil))
+    assert.Equal(t, http.StatusOK, post("/admin/cards/lookup", lookup, []string{middleware.ScopeAdmin}))
+    assert.Equal(t, http.StatusOK, post("/admin/cards/reindex-fingerprints", "", []string{middleware.ScopeAdmin}))
+}
\ No newline at end of file
@@ -0,0 +1,79 @@
+package tests
+
+import (
+    "card-vault/internal/crypto"
+    "card-vault/internal/handlers"
+    "card-vault/internal/kms"
+    "card-vault/internal/middleware"
+    "card-vault/internal/models"
+    "card-vault/internal/service"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestKeyHandler_RequiresAdminScope(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    t.Setenv("JWT_SECRET", "test-secret")
+
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    assert.NoError(t, keyMgr.RotateKey())
+    repo := newMemoryCardRepository()
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    r := gin.New()
+    admin := r.Group("/admin", middleware.AuthMiddleware(), middleware.RequireScope(middleware.ScopeAdmin))
+    admin.PUT("/keys/:version/state", handlers.NewKeyHandler(service.NewKeyService(repo, keyMgr)).UpdateKeyState)
+    admin.POST("/cards/rotate-keys", handlers.NewCardHandler(cardSvc).RotateKeys)
+
+    send := func(method, path, body string, scopes []string) int {
+        token, _ := middleware.GenerateTokenWithScopes(uuid.New(), scopes)
+        req := httptest.NewRequest(method, path, strings.NewReader(body))
+        req.Header.Set("Authorization", "Bearer "+token)
+        req.Header.Set("Content-Type", "application/json")
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        return w.Code
+    }
+
+    // Sin el scope admin no se pueden destruir versiones ni iniciar rotaciones
+    destroy := `{"state": "destroyed"}`
+    assert.Equal(t, http.StatusForbidden, send(http.MethodPut, "/admin/keys/1/state", destroy, nil))
+    assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/admin/cards/rotate-keys", "", nil))
+    _, err := keyMgr.GetKey(1)
+    assert.NoError(t, err)
+
+    assert.Equal(t, http.StatusOK, send(http.MethodPut, "/admin/keys/1/state", destroy, []string{middleware.ScopeAdmin}))
+    _, err = keyMgr.GetKey(1)
+    assert.ErrorIs(t, err, crypto.ErrKeyDestroyed)
+}
+
+func TestKeyService_DestroyCountsOnlyLocalCards(t *testing.T) {
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    repo := newMemoryCardRepository()
+    keySvc := service.NewKeyService(repo, keyMgr)
+    assert.NoError(t, keyMgr.RotateKey())
+    assert.NoError(t, keyMgr.RotateKey())
+
+    // Las versiones de Transit se numeran aparte: una tarjeta en su versión 1 no
+    // impide destruir la versión 1 del keyring local
+    repo.Create(&models.Card{ID: uuid.New(), UserID: uuid.New(), KeyProvider: kms.TransitProviderName, KeyVersion: 1})
+    assert.NoError(t, keySvc.SetKeyState(1, crypto.KeyStateDestroyed))
+
+    // Las tarjetas locales, también las anteriores a key_provider, sí la impiden
+    repo.Create(&models.Card{ID: uuid.New(), UserID: uuid.New(), KeyProvider: "", KeyVersion: 2})
+    assert.ErrorIs(t, keySvc.SetKeyState(2, crypto.KeyStateDestroyed), service.ErrKeyInUse)
+    repo.Create(&models.Card{ID: uuid.New(), UserID: uuid.New(), KeyProvider: kms.TransitProviderName, KeyVersion: 3})
+    local := &models.Card{ID: uuid.New(), UserID: uuid.New(), KeyProvider: kms.LocalProviderName, KeyVersion: 3}
+    repo.Create(local)
+    assert.NoError(t, keyMgr.RotateKey())
+    assert.ErrorIs(t, keySvc.SetKeyState(3, crypto.KeyStateDestroyed), service.ErrKeyInUse)
+
+    repo.Delete(local.ID, local.UserID)
+    assert.NoError(t, keySvc.SetKeyState(3, crypto.KeyStateDestroyed))
+}
\ No newline at end of file
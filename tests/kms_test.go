@@ -0,0 +1,123 @@
+package tests
+
+import (
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+    "card-vault/internal/models"
+    "card-vault/internal/service"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/mock"
+)
+
+func newTransitProvider(t *testing.T) *kms.TransitProvider {
+    server := httptest.NewServer(kms.NewTransitServer("test-token", nil))
+    t.Cleanup(server.Close)
+
+    provider, err := kms.NewTransitProvider(kms.TransitConfig{
+        Address: server.URL,
+        Token:   "test-token",
+        KeyName: "card-vault",
+    })
+    assert.NoError(t, err)
+    return provider
+}
+
+func TestTransitProvider(t *testing.T) {
+    provider := newTransitProvider(t)
+
+    dek, wrapped, version, err := provider.GenerateDataKey()
+    assert.NoError(t, err)
+    assert.Len(t, dek, 32)
+    assert.Equal(t, 1, version)
+    assert.Contains(t, string(wrapped), "vault:v1:")
+
+    unwrapped, err := provider.UnwrapDataKey(wrapped, version)
+    assert.NoError(t, err)
+    assert.Equal(t, dek, unwrapped)
+
+    assert.NoError(t, provider.RotateKey())
+
+    rewrapped, newVersion, err := provider.RewrapDataKey(wrapped, version)
+    assert.NoError(t, err)
+    assert.Equal(t, 2, newVersion)
+    assert.Contains(t, string(rewrapped), "vault:v2:")
+
+    unwrapped, err = provider.UnwrapDataKey(rewrapped, newVersion)
+    assert.NoError(t, err)
+    assert.Equal(t, dek, unwrapped)
+
+    // Las DEKs envueltas con la versión anterior siguen abriéndose
+    unwrapped, err = provider.UnwrapDataKey(wrapped, version)
+    assert.NoError(t, err)
+    assert.Equal(t, dek, unwrapped)
+
+    usage, err := provider.KeyUsage()
+    assert.NoError(t, err)
+    assert.Equal(t, 2, usage.Version)
+    assert.Equal(t, uint64(1), usage.Encryptions)
+    assert.WithinDuration(t, time.Now(), usage.CreatedAt, time.Minute)
+}
+
+func TestTransitProvider_RejectsBadToken(t *testing.T) {
+    server := httptest.NewServer(kms.NewTransitServer("test-token", nil))
+    defer server.Close()
+
+    provider, err := kms.NewTransitProvider(kms.TransitConfig{
+        Address: server.URL,
+        Token:   "wrong-token",
+        KeyName: "card-vault",
+    })
+    assert.NoError(t, err)
+
+    _, _, _, err = provider.GenerateDataKey()
+    assert.ErrorContains(t, err, "permission denied")
+}
+
+func TestCardService_RotateKeysMigratesLocalDataKeysToTransit(t *testing.T) {
+    mockRepo := new(MockCardRepository)
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    userID := uuid.New()
+
+    // Tarjeta creada mientras el proveedor era el keyring local
+    var stored models.Card
+    mockRepo.On("FindByFingerprints", mock.Anything).Return([]models.Card{}, nil)
+    mockRepo.On("Create", mock.AnythingOfType("*models.Card")).Run(func(args mock.Arguments) {
+        stored = *args.Get(0).(*models.Card)
+    }).Return(nil)
+
+    localSvc := service.NewCardService(mockRepo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+    _, err := localSvc.CreateCard(userID, &models.CardRequest{
+        CardholderName: "John Doe",
+        CardNumber:     "4111111111111111",
+        ExpiryMonth:    12,
+        ExpiryYear:     2030,
+        CVV:            "123",
+    })
+    assert.NoError(t, err)
+    assert.Equal(t, kms.LocalProviderName, stored.KeyProvider)
+
+    var migrated models.Card
+    mockRepo.On("GetPageAfter", uuid.Nil, mock.Anything).Return([]models.Card{stored}, nil)
+    mockRepo.On("GetPageAfter", stored.ID, mock.Anything).Return([]models.Card{}, nil)
+    mockRepo.On("SwapKeyMaterial", mock.AnythingOfType("*models.Card"), stored.WrappedDEK).Run(func(args mock.Arguments) {
+        migrated = *args.Get(0).(*models.Card)
+    }).Return(true, nil)
+
+    transitSvc := service.NewCardService(mockRepo, newMemoryRotationJobs(), keyMgr, newTransitProvider(t))
+    started, err := transitSvc.RotateKeys()
+    assert.NoError(t, err)
+    job := waitForRotationJob(t, transitSvc, started.ID)
+    assert.Equal(t, 1, job.ProcessedCards)
+    assert.Equal(t, kms.TransitProviderName, migrated.KeyProvider)
+    assert.Equal(t, stored.CardNumber.Ciphertext(), migrated.CardNumber.Ciphertext())
+
+    mockRepo.On("GetByID", migrated.ID, userID).Return(&migrated, nil)
+    result, err := transitSvc.GetCard(migrated.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, "************1111", result.MaskedNumber)
+}
\ No newline at end of file
@@ -0,0 +1,126 @@
+package tests
+
+import (
+    "card-vault/internal/crypto"
+    "card-vault/internal/handlers"
+    "card-vault/internal/kms"
+    "card-vault/internal/masking"
+    "card-vault/internal/middleware"
+    "card-vault/internal/models"
+    "card-vault/internal/service"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestMasking_NamedPolicies(t *testing.T) {
+    tests := []struct {
+        spec     string
+        pan      string
+        expected string
+    }{
+        {"last4", "4111111111111111", "************1111"},
+        {"bin6_last4", "4111111111111111", "411111******1111"},
+        {"bin8_last4", "4111111111111111", "41111111****1111"},
+        {"full", "4111111111111111", "****************"},
+        {"bin6_last4;char=#", "4111111111111111", "411111######1111"},
+        {"bin6_last4;group=4", "4111111111111111", "4111 11** **** 1111"},
+        {"last4;char=x;group=4", "378282246310005", "xxxx xxxx xxx0 005"},
+        // Con menos de 16 dígitos PCI DSS solo permite mostrar el BIN de 6
+        {"bin8_last4", "378282246310005", "378282*****0005"},
+        // Nunca quedan menos de 4 dígitos ocultos
+        {"bin6_last4", "4111111111", "41****1111"},
+        {"last4", "41111", "****1"},
+        {"last4", "", ""},
+    }
+
+    for _, tt := range tests {
+        policy, err := masking.Parse(tt.spec)
+        assert.NoError(t, err, tt.spec)
+        assert.Equal(t, tt.expected, policy.Mask(tt.pan), tt.spec)
+    }
+}
+
+func TestMasking_ParseRejectsInvalidPolicies(t *testing.T) {
+    for _, spec := range []string{"", "last6", "last4;char=", "last4;char=7", "last4;char=ab", "last4;group=-1", "last4;group=9", "last4;color=red"} {
+        _, err := masking.Parse(spec)
+        assert.ErrorIs(t, err, masking.ErrInvalidPolicy, spec)
+    }
+
+    _, err := masking.ParseAssignments("support")
+    assert.ErrorIs(t, err, masking.ErrInvalidPolicy)
+    _, err = masking.ParseAssignments("support=bin4")
+    assert.ErrorIs(t, err, masking.ErrInvalidPolicy)
+}
+
+func TestMasking_ResolvesClientThenRoleThenDefault(t *testing.T) {
+    roles, err := masking.ParseAssignments("fraud=bin6_last4, support=last4")
+    assert.NoError(t, err)
+    clients, err := masking.ParseAssignments("reporting=full")
+    assert.NoError(t, err)
+    policies := masking.Policies{Default: masking.Default, Roles: roles, Clients: clients}
+
+    assert.Equal(t, masking.BIN6Last4, policies.Resolve("fraud", "").Name)
+    assert.Equal(t, masking.Full, policies.Resolve("fraud", "reporting").Name)
+    assert.Equal(t, masking.Last4, policies.Resolve("auditor", "backoffice").Name)
+    assert.Equal(t, masking.Last4, masking.Policies{}.Resolve("", "").Name)
+}
+
+func TestCardService_WithMasking(t *testing.T) {
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    bin6, _ := masking.Parse("bin6_last4")
+    cardSvc := service.NewCardServiceWithOptions(newMemoryCardRepository(), newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr),
+        service.CardServiceOptions{PANMasking: bin6})
+
+    userID := uuid.New()
+    card, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    assert.Equal(t, "411111******1111", card.MaskedNumber)
+
+    // La política del llamante no cambia la del servicio compartido
+    full, _ := masking.Parse("full")
+    page, err := cardSvc.WithMasking(full).GetUserCards(userID, &models.CardListQuery{})
+    assert.NoError(t, err)
+    assert.Equal(t, "****************", page.Cards[0].MaskedNumber)
+    card, _ = cardSvc.GetCard(card.ID, userID)
+    assert.Equal(t, "411111******1111", card.MaskedNumber)
+}
+
+func TestCardHandler_AppliesCallerMaskingPolicy(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    t.Setenv("JWT_SECRET", "test-secret")
+
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(newMemoryCardRepository(), newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+    userID := uuid.New()
+    card, _ := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+
+    roles, _ := masking.ParseAssignments("fraud=bin6_last4;group=4")
+    clients, _ := masking.ParseAssignments("reporting=full")
+    r := gin.New()
+    r.GET("/cards/:id", middleware.AuthMiddleware(), middleware.PANMasking(masking.Policies{Default: masking.Default, Roles: roles, Clients: clients}),
+        handlers.NewCardHandler(cardSvc).GetCard)
+
+    maskedNumber := func(claims middleware.Claims) string {
+        claims.UserID = userID
+        token, _ := middleware.SignToken(claims)
+        req := httptest.NewRequest(http.MethodGet, "/cards/"+card.ID.String(), nil)
+        req.Header.Set("Authorization", "Bearer "+token)
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+
+        var response models.CardResponse
+        assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+        return response.MaskedNumber
+    }
+
+    assert.Equal(t, "************1111", maskedNumber(middleware.Claims{}))
+    assert.Equal(t, "4111 11** **** 1111", maskedNumber(middleware.Claims{Role: "fraud"}))
+    assert.Equal(t, "****************", maskedNumber(middleware.Claims{Role: "fraud", ClientID: "reporting"}))
+}
\ No newline at end of file
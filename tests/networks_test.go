@@ -0,0 +1,74 @@
+package tests
+
+import (
+    "card-vault/internal/bin"
+    "card-vault/internal/brand"
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+    "card-vault/internal/service"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestCardService_CoBadgedNetworks(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "bins.csv")
+    os.WriteFile(path, []byte("start,issuer,country,networks\n497010,Banque Exemple,FR,cartes_bancaires|Visa\n"), 0600)
+    bins, err := bin.Open(path)
+    assert.NoError(t, err)
+
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardServiceWithOptions(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr), service.CardServiceOptions{BINs: bins})
+
+    userID := uuid.New()
+    pan := withLuhn("497010", 16)
+    card, err := cardSvc.CreateCard(userID, cardRequest("Jean Dupont", pan))
+    assert.NoError(t, err)
+    assert.Equal(t, "Visa", card.CardType)
+    assert.Equal(t, []string{"Visa", "Cartes Bancaires"}, card.Networks)
+    assert.Empty(t, card.PreferredNetwork)
+
+    card, err = cardSvc.SetPreferredNetwork(card.ID, userID, "cartes-bancaires")
+    assert.NoError(t, err)
+    assert.Equal(t, "Cartes Bancaires", card.PreferredNetwork)
+
+    _, err = cardSvc.SetPreferredNetwork(card.ID, userID, "Mastercard")
+    assert.ErrorIs(t, err, service.ErrUnsupportedNetwork)
+    _, err = cardSvc.SetPreferredNetwork(card.ID, userID, "Carte Bleue")
+    assert.ErrorIs(t, err, brand.ErrUnknownNetwork)
+
+    stored, _ := repo.FindByID(card.ID)
+    assert.Equal(t, "Cartes Bancaires", stored.PreferredNetwork)
+
+    token, err := cardSvc.TokenizeCard(card.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, "Cartes Bancaires", token.Network)
+
+    // Actualizar sin indicar preferencia la conserva mientras la red siga disponible
+    card, err = cardSvc.UpdateCard(card.ID, userID, cardRequest("Jean Dupont", pan))
+    assert.NoError(t, err)
+    assert.Equal(t, "Cartes Bancaires", card.PreferredNetwork)
+
+    card, err = cardSvc.UpdateCard(card.ID, userID, cardRequest("Jean Dupont", "4111111111111111"))
+    assert.NoError(t, err)
+    assert.Equal(t, []string{"Visa"}, card.Networks)
+    assert.Empty(t, card.PreferredNetwork)
+
+    // Redes indicadas por el cliente
+    req := cardRequest("Jan Jansen", "5555555555554444")
+    req.Networks = []string{"bancontact", "Mastercard"}
+    req.PreferredNetwork = "Bancontact"
+    card, err = cardSvc.CreateCard(userID, req)
+    assert.NoError(t, err)
+    assert.Equal(t, []string{"Mastercard", "Bancontact"}, card.Networks)
+    assert.Equal(t, "Bancontact", card.PreferredNetwork)
+
+    req = cardRequest("Jan Jansen", "4000056655665556")
+    req.PreferredNetwork = "Bancontact"
+    _, err = cardSvc.CreateCard(userID, req)
+    assert.ErrorIs(t, err, service.ErrUnsupportedNetwork)
+}
\ No newline at end of file
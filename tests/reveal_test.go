@@ -0,0 +1,177 @@
+package tests
+
+import (
+    "card-vault/internal/crypto"
+    "card-vault/internal/cvv"
+    "card-vault/internal/handlers"
+    "card-vault/internal/kms"
+    "card-vault/internal/middleware"
+    "card-vault/internal/models"
+    "card-vault/internal/service"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+    "gorm.io/gorm"
+)
+
+type failingAuditRepository struct {
+    memoryAuditRepository
+}
+
+func (r *failingAuditRepository) Create(record *models.AuditRecord) error {
+    return errors.New("audit store unavailable")
+}
+
+func newRevealService(audit *memoryAuditRepository) (service.CardService, *cvv.MemoryStore) {
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cvvs, _ := cvv.NewMemoryStore(time.Minute)
+    opts := service.CardServiceOptions{CVVs: cvvs}
+    if audit != nil {
+        opts.Audit = audit
+    }
+    return service.NewCardServiceWithOptions(newMemoryCardRepository(), newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr), opts), cvvs
+}
+
+func TestCardService_RevealCard(t *testing.T) {
+    audit := &memoryAuditRepository{}
+    cardSvc, _ := newRevealService(audit)
+
+    userID := uuid.New()
+    card, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+
+    _, err = cardSvc.RevealCard(card.ID, userID, "curiosity", "", false)
+    assert.ErrorIs(t, err, service.ErrInvalidRevealPurpose)
+    _, err = cardSvc.RevealCard(card.ID, userID, "refund", strings.Repeat("x", 256), false)
+    assert.ErrorIs(t, err, service.ErrRevealReasonTooLong)
+    records, _ := audit.GetBySubject(card.ID)
+    assert.Empty(t, records)
+
+    // Sin el scope de CVV no se entrega ni se consume
+    revealed, err := cardSvc.RevealCard(card.ID, userID, "customer_support", "ticket 42", false)
+    assert.NoError(t, err)
+    assert.Equal(t, "4111111111111111", revealed.CardNumber)
+    assert.Equal(t, 12, revealed.ExpiryMonth)
+    assert.Equal(t, 2030, revealed.ExpiryYear)
+    assert.Empty(t, revealed.CVV)
+
+    revealed, err = cardSvc.RevealCard(card.ID, userID, "payment", "", true)
+    assert.NoError(t, err)
+    assert.Equal(t, "123", revealed.CVV)
+    revealed, err = cardSvc.RevealCard(card.ID, userID, "payment", "", true)
+    assert.NoError(t, err)
+    assert.Empty(t, revealed.CVV)
+
+    records, _ = audit.GetBySubject(card.ID)
+    if assert.Len(t, records, 3) {
+        assert.Equal(t, models.AuditActionRevealCard, records[0].Action)
+        assert.Equal(t, userID, records[0].ActorID)
+        assert.Equal(t, &userID, records[0].OwnerID)
+        assert.Contains(t, records[0].Details, "purpose=customer_support")
+        assert.Contains(t, records[0].Details, `reason="ticket 42"`)
+    }
+
+    // Cualquier llamante puede revelar la tarjeta; queda como actor junto al propietario
+    agentID := uuid.New()
+    revealed, err = cardSvc.RevealCard(card.ID, agentID, "fraud_investigation", "case 7", false)
+    assert.NoError(t, err)
+    assert.Equal(t, "4111111111111111", revealed.CardNumber)
+    records, _ = audit.GetBySubject(card.ID)
+    if assert.Len(t, records, 4) {
+        assert.Equal(t, agentID, records[3].ActorID)
+        assert.Equal(t, &userID, records[3].OwnerID)
+    }
+
+    _, err = cardSvc.RevealCard(uuid.New(), agentID, "payment", "", false)
+    assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+    _, err = cardSvc.FreezeCard(card.ID, userID, "")
+    assert.NoError(t, err)
+    _, err = cardSvc.RevealCard(card.ID, userID, "payment", "", false)
+    assert.ErrorIs(t, err, service.ErrCardNotActive)
+}
+
+func TestCardService_RevealRequiresAudit(t *testing.T) {
+    cardSvc, _ := newRevealService(nil)
+    userID := uuid.New()
+    card, _ := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    _, err := cardSvc.RevealCard(card.ID, userID, "payment", "", false)
+    assert.ErrorIs(t, err, service.ErrRevealNotAudited)
+
+    // Si no se puede auditar no se revela nada, ni se consume el CVV
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cvvs, _ := cvv.NewMemoryStore(time.Minute)
+    failing := service.NewCardServiceWithOptions(newMemoryCardRepository(), newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr),
+        service.CardServiceOptions{CVVs: cvvs, Audit: &failingAuditRepository{}})
+    card, _ = failing.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    revealed, err := failing.RevealCard(card.ID, userID, "payment", "", true)
+    assert.Error(t, err)
+    assert.Nil(t, revealed)
+    _, ok := cvvs.ExpiresAt(card.ID)
+    assert.True(t, ok)
+}
+
+func TestCardHandler_RevealCard(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    t.Setenv("JWT_SECRET", "test-secret")
+
+    audit := &memoryAuditRepository{}
+    cardSvc, _ := newRevealService(audit)
+    userID := uuid.New()
+    card, _ := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+
+    r := gin.New()
+    r.POST("/cards/:id/reveal", middleware.AuthMiddleware(), middleware.RequireScope(middleware.ScopeRevealCard), handlers.NewCardHandler(cardSvc).RevealCard)
+
+    plain, _ := middleware.GenerateTokenWithScopes(userID, nil)
+    scoped, _ := middleware.GenerateTokenWithScopes(userID, []string{middleware.ScopeRevealCard})
+    withCVV, _ := middleware.GenerateTokenWithScopes(userID, []string{middleware.ScopeRevealCard, middleware.ScopeUseCVV})
+
+    reveal := func(token, purpose string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodPost, "/cards/"+card.ID.String()+"/reveal", nil)
+        req.Header.Set("Authorization", "Bearer "+token)
+        if purpose != "" {
+            req.Header.Set("X-Reveal-Purpose", purpose)
+        }
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        return w
+    }
+
+    assert.Equal(t, http.StatusForbidden, reveal(plain, "payment").Code)
+    assert.Equal(t, http.StatusBadRequest, reveal(scoped, "").Code)
+    assert.Equal(t, http.StatusBadRequest, reveal(scoped, "curiosity").Code)
+
+    w := reveal(scoped, "payment")
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+    var revealed models.RevealResponse
+    assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &revealed))
+    assert.Equal(t, "4111111111111111", revealed.CardNumber)
+    assert.Empty(t, revealed.CVV)
+
+    w = reveal(withCVV, "payment")
+    assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &revealed))
+    assert.Equal(t, "123", revealed.CVV)
+
+    // Otro usuario con el scope revela la tarjeta y queda auditado como actor
+    agentID := uuid.New()
+    agent, _ := middleware.GenerateTokenWithScopes(agentID, []string{middleware.ScopeRevealCard})
+    w = reveal(agent, "customer_support")
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &revealed))
+    assert.Equal(t, "4111111111111111", revealed.CardNumber)
+    records, _ := audit.GetBySubject(card.ID)
+    if assert.Len(t, records, 3) {
+        assert.Equal(t, agentID, records[2].ActorID)
+        assert.Equal(t, &userID, records[2].OwnerID)
+    }
+}
\ No newline at end of file
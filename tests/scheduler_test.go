@@ -0,0 +1,191 @@
+package tests
+
+import (
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+    "card-vault/internal/models"
+    "card-vault/internal/scheduler"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+)
+
+// fakeRotator rota el keyring directamente y registra los trabajos lanzados. Con
+// now asignado, las versiones que crea constan creadas en ese instante (ver clockedProvider).
+type fakeRotator struct {
+    keyMgr    *crypto.KeyManager
+    jobs      map[uuid.UUID]*models.RotationJob
+    now       time.Time
+    createdAt map[int]time.Time
+}
+
+func newFakeRotator(keyMgr *crypto.KeyManager) *fakeRotator {
+    return &fakeRotator{keyMgr: keyMgr, jobs: make(map[uuid.UUID]*models.RotationJob), createdAt: make(map[int]time.Time)}
+}
+
+func (r *fakeRotator) RotateKeys() (*models.RotationJob, error) {
+    if err := r.keyMgr.RotateKey(); err != nil {
+        return nil, err
+    }
+    if !r.now.IsZero() {
+        _, version := r.keyMgr.GetCurrentKey()
+        r.createdAt[version] = r.now
+    }
+    job := &models.RotationJob{ID: uuid.New(), Status: models.RotationJobCompleted}
+    r.jobs[job.ID] = job
+    return job, nil
+}
+
+// clockedProvider da como fecha de creación de cada versión la que registró el
+// rotador, para que las comprobaciones en instantes simulados sean coherentes.
+type clockedProvider struct {
+    kms.Provider
+    rotator *fakeRotator
+}
+
+func (p clockedProvider) KeyUsage() (kms.KeyUsage, error) {
+    usage, err := p.Provider.KeyUsage()
+    if createdAt, ok := p.rotator.createdAt[usage.Version]; ok {
+        usage.CreatedAt = createdAt
+    }
+    return usage, err
+}
+
+func (r *fakeRotator) GetRotationJob(id uuid.UUID) (*models.RotationJob, error) {
+    return r.jobs[id], nil
+}
+
+func TestParseCron(t *testing.T) {
+    base := time.Date(2025, time.March, 12, 10, 7, 30, 0, time.UTC) // miércoles
+
+    cases := []struct {
+        expr string
+        next time.Time
+    }{
+        {"*/15 * * * *", time.Date(2025, time.March, 12, 10, 15, 0, 0, time.UTC)},
+        {"0 3 * * 1", time.Date(2025, time.March, 17, 3, 0, 0, 0, time.UTC)},
+        {"30 2 1-7 * 0", time.Date(2025, time.March, 16, 2, 30, 0, 0, time.UTC)}, // día 1-7 o domingo
+        {"0 0 1 */3 *", time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)},
+        {"@monthly", time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)},
+        {"0 12 29 2 *", time.Date(2028, time.February, 29, 12, 0, 0, 0, time.UTC)},
+    }
+    for _, c := range cases {
+        schedule, err := scheduler.ParseCron(c.expr)
+        assert.NoError(t, err, c.expr)
+        assert.Equal(t, c.next, schedule.Next(base), c.expr)
+    }
+
+    for _, expr := range []string{"* * * *", "60 * * * *", "* * * 13 *", "*/0 * * * *", "a * * * *"} {
+        _, err := scheduler.ParseCron(expr)
+        assert.Error(t, err, expr)
+    }
+}
+
+func TestRotationScheduler_MaxAge(t *testing.T) {
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    rotator := newFakeRotator(keyMgr)
+    sched := scheduler.NewRotationScheduler(scheduler.Policy{MaxAge: time.Hour}, rotator, kms.NewLocalProvider(keyMgr))
+
+    now := time.Now()
+    sched.Check(now)
+    status := sched.Status()
+    assert.True(t, status.Enabled)
+    assert.Nil(t, status.LastRun)
+    assert.Equal(t, scheduler.TriggerMaxAge, status.NextTrigger)
+    assert.WithinDuration(t, now.Add(time.Hour), *status.NextRotation, time.Minute)
+
+    sched.Check(now.Add(2 * time.Hour))
+    status = sched.Status()
+    assert.NotNil(t, status.LastRun)
+    assert.Equal(t, scheduler.TriggerMaxAge, status.LastRun.Trigger)
+    assert.Equal(t, models.RotationJobCompleted, status.LastRun.Status)
+
+    _, version := keyMgr.GetCurrentKey()
+    assert.Equal(t, 2, version)
+}
+
+func TestRotationScheduler_MaxEncryptions(t *testing.T) {
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    rotator := newFakeRotator(keyMgr)
+    sched := scheduler.NewRotationScheduler(scheduler.Policy{MaxEncryptions: 3}, rotator, kms.NewLocalProvider(keyMgr))
+
+    for i := 0; i < 2; i++ {
+        keyMgr.GenerateDataKey()
+    }
+    sched.Check(time.Now())
+    assert.Nil(t, sched.Status().LastRun)
+    assert.Equal(t, uint64(2), sched.Status().ActiveKey.Encryptions)
+
+    keyMgr.GenerateDataKey()
+    sched.Check(time.Now())
+    status := sched.Status()
+    assert.Equal(t, scheduler.TriggerMaxEncryptions, status.LastRun.Trigger)
+
+    // La versión nueva empieza con el contador a cero
+    sched.Check(time.Now())
+    assert.Equal(t, 2, sched.Status().ActiveKey.Version)
+    assert.Equal(t, uint64(0), sched.Status().ActiveKey.Encryptions)
+}
+
+func TestRotationScheduler_Cron(t *testing.T) {
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    rotator := newFakeRotator(keyMgr)
+    cron, _ := scheduler.ParseCron("0 3 * * *")
+    provider := clockedProvider{Provider: kms.NewLocalProvider(keyMgr), rotator: rotator}
+    // Dos réplicas con el mismo keyring
+    replicaA := scheduler.NewRotationScheduler(scheduler.Policy{Cron: cron}, rotator, provider)
+    replicaB := scheduler.NewRotationScheduler(scheduler.Policy{Cron: cron}, rotator, provider)
+
+    replicaA.Check(time.Now())
+    next := *replicaA.Status().NextRotation
+    assert.Equal(t, scheduler.TriggerCron, replicaA.Status().NextTrigger)
+
+    replicaA.Check(next.Add(-time.Minute))
+    assert.Nil(t, replicaA.Status().LastRun)
+
+    rotator.now = next
+    replicaA.Check(next)
+    status := replicaA.Status()
+    assert.Equal(t, scheduler.TriggerCron, status.LastRun.Trigger)
+    assert.Equal(t, next.Add(24*time.Hour), *status.NextRotation)
+
+    // La otra réplica ve la clave nueva y no vuelve a rotar por el mismo instante
+    replicaB.Check(next.Add(time.Minute))
+    assert.Nil(t, replicaB.Status().LastRun)
+    _, version := keyMgr.GetCurrentKey()
+    assert.Equal(t, 2, version)
+
+    rotator.now = next.Add(24 * time.Hour)
+    replicaB.Check(next.Add(24 * time.Hour))
+    assert.Equal(t, scheduler.TriggerCron, replicaB.Status().LastRun.Trigger)
+    _, version = keyMgr.GetCurrentKey()
+    assert.Equal(t, 3, version)
+}
+
+func TestKeyManager_EncryptionCountsArePersisted(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "keyring.json")
+    masterKey := make([]byte, 32)
+
+    store, _ := crypto.NewFileKeyStore(path, masterKey)
+    replicaA, err := crypto.NewKeyManager(store)
+    assert.NoError(t, err)
+    replicaB, err := crypto.NewKeyManager(store)
+    assert.NoError(t, err)
+
+    for i := 0; i < 3; i++ {
+        replicaA.GenerateDataKey()
+    }
+    replicaB.GenerateDataKey()
+    assert.NoError(t, replicaA.FlushUsage())
+    assert.NoError(t, replicaB.FlushUsage())
+
+    restartedStore, _ := crypto.NewFileKeyStore(path, masterKey)
+    restarted, err := crypto.NewKeyManager(restartedStore)
+    assert.NoError(t, err)
+    usage, err := restarted.ActiveKeyUsage()
+    assert.NoError(t, err)
+    assert.Equal(t, uint64(4), usage.Encryptions)
+}
\ No newline at end of file
@@ -0,0 +1,219 @@
+package tests
+
+import (
+    "card-vault/internal/crypto"
+    "card-vault/internal/handlers"
+    "card-vault/internal/middleware"
+    "card-vault/internal/seal"
+    "card-vault/internal/shamir"
+    "crypto/rand"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestShamir_SplitAndCombine(t *testing.T) {
+    secret := make([]byte, 32)
+    rand.Read(secret)
+
+    shares, err := shamir.Split(secret, 5, 3)
+    assert.NoError(t, err)
+    assert.Len(t, shares, 5)
+
+    // Cualquier subconjunto de al menos el umbral reconstruye el secreto
+    for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+        picked := make([][]byte, 0, len(subset))
+        for _, i := range subset {
+            picked = append(picked, shares[i])
+        }
+        combined, err := shamir.Combine(picked)
+        assert.NoError(t, err)
+        assert.Equal(t, secret, combined)
+    }
+
+    // Con menos partes del umbral no se obtiene el secreto
+    combined, err := shamir.Combine(shares[:2])
+    assert.NoError(t, err)
+    assert.NotEqual(t, secret, combined)
+
+    _, err = shamir.Combine([][]byte{shares[0], shares[0]})
+    assert.ErrorIs(t, err, shamir.ErrInvalidShares)
+
+    _, err = shamir.Split(secret, 2, 3)
+    assert.ErrorIs(t, err, shamir.ErrInvalidParameters)
+}
+
+func newSealedKeyManager(t *testing.T) *crypto.KeyManager {
+    path := filepath.Join(t.TempDir(), "keyring.json")
+    km, err := crypto.NewSealedKeyManager(func(masterKey []byte) (crypto.KeyStore, error) {
+        return crypto.NewFileKeyStore(path, masterKey)
+    }, crypto.DefaultAlgorithm)
+    assert.NoError(t, err)
+    return km
+}
+
+func TestSealManager_UnsealCeremony(t *testing.T) {
+    km := newSealedKeyManager(t)
+    assert.True(t, km.Sealed())
+
+    _, _, err := km.WrapDataKey(make([]byte, 32))
+    assert.ErrorIs(t, err, crypto.ErrSealed)
+
+    mgr, err := seal.NewManager(km, 3)
+    assert.NoError(t, err)
+
+    unsealed := make(chan struct{}, 2)
+    mgr.OnUnseal(func() { unsealed <- struct{}{} })
+
+    shares, err := mgr.Initialize(5)
+    assert.NoError(t, err)
+    assert.Len(t, shares, 5)
+    assert.False(t, mgr.Sealed())
+    <-unsealed
+
+    _, err = mgr.Initialize(5)
+    assert.ErrorIs(t, err, crypto.ErrAlreadyInitialized)
+
+    dek := make([]byte, 32)
+    rand.Read(dek)
+    wrapped, version, err := km.WrapDataKey(dek)
+    assert.NoError(t, err)
+
+    // Sellar descarta las claves de memoria
+    assert.NoError(t, mgr.Seal())
+    assert.True(t, mgr.Sealed())
+    _, err = km.UnwrapDataKey(wrapped, version)
+    assert.ErrorIs(t, err, crypto.ErrSealed)
+
+    status, err := mgr.Unseal(shares[4])
+    assert.NoError(t, err)
+    assert.Equal(t, seal.Status{Sealed: true, Threshold: 3, Progress: 1}, status)
+
+    _, err = mgr.Unseal(shares[4])
+    assert.ErrorIs(t, err, seal.ErrDuplicateShare)
+
+    _, err = mgr.Unseal(shares[1])
+    assert.NoError(t, err)
+    status, err = mgr.Unseal(shares[2])
+    assert.NoError(t, err)
+    assert.Equal(t, seal.Status{Sealed: false, Threshold: 3, Progress: 0}, status)
+    <-unsealed
+
+    unwrapped, err := km.UnwrapDataKey(wrapped, version)
+    assert.NoError(t, err)
+    assert.Equal(t, dek, unwrapped)
+
+    _, err = mgr.Unseal(shares[0])
+    assert.ErrorIs(t, err, seal.ErrNotSealed)
+}
+
+func TestSealManager_WrongSharesKeepVaultSealed(t *testing.T) {
+    km := newSealedKeyManager(t)
+    mgr, err := seal.NewManager(km, 2)
+    assert.NoError(t, err)
+
+    shares, err := mgr.Initialize(3)
+    assert.NoError(t, err)
+    assert.NoError(t, mgr.Seal())
+
+    // Partes de otra master key: se combinan pero no abren el keyring
+    other := make([]byte, 32)
+    rand.Read(other)
+    foreign, err := shamir.Split(other, 3, 2)
+    assert.NoError(t, err)
+
+    mgr.Unseal(foreign[0])
+    _, err = mgr.Unseal(foreign[1])
+    assert.ErrorIs(t, err, seal.ErrUnsealFailed)
+    assert.True(t, mgr.Sealed())
+    assert.Equal(t, 0, mgr.Status().Progress)
+
+    _, err = mgr.Unseal(shares[0][:10])
+    assert.NoError(t, err)
+    _, err = mgr.Unseal(shares[1])
+    assert.ErrorIs(t, err, seal.ErrInvalidShare)
+
+    mgr.ResetProgress()
+    mgr.Unseal(shares[2])
+    _, err = mgr.Unseal(shares[0])
+    assert.NoError(t, err)
+    assert.False(t, mgr.Sealed())
+}
+
+func TestKeyManager_UnsealWithMasterKey(t *testing.T) {
+    masterKey := make([]byte, 32)
+    rand.Read(masterKey)
+
+    km := newSealedKeyManager(t)
+    assert.ErrorIs(t, km.Unseal(masterKey), crypto.ErrNotInitialized)
+    assert.NoError(t, km.Initialize(masterKey))
+    assert.NoError(t, km.RotateKey())
+    assert.NoError(t, km.Seal())
+
+    wrong := make([]byte, 32)
+    rand.Read(wrong)
+    assert.ErrorIs(t, km.Unseal(wrong), crypto.ErrWrongMasterKey)
+    assert.True(t, km.Sealed())
+
+    assert.NoError(t, km.Unseal(masterKey))
+    _, version := km.GetCurrentKey()
+    assert.Equal(t, 2, version)
+}
+
+func TestKeyManager_SealKeepsKeysHandedOut(t *testing.T) {
+    masterKey := make([]byte, 32)
+    rand.Read(masterKey)
+
+    km := newSealedKeyManager(t)
+    assert.NoError(t, km.Initialize(masterKey))
+    current, version := km.GetCurrentKey()
+    old, err := km.GetKey(version)
+    assert.NoError(t, err)
+    dek, wrapped, _, err := km.GenerateDataKey()
+    assert.NoError(t, err)
+
+    // Seal borra sus propias claves, no las que ya usa quien las pidió
+    assert.NoError(t, km.Seal())
+    assert.NotEqual(t, make([]byte, len(current)), current)
+    assert.Equal(t, current, old)
+
+    assert.NoError(t, km.Unseal(masterKey))
+    unwrapped, err := km.UnwrapDataKey(wrapped, version)
+    assert.NoError(t, err)
+    assert.Equal(t, dek, unwrapped)
+    reopened, _ := km.GetCurrentKey()
+    assert.Equal(t, current, reopened)
+}
+
+func TestSysHandler_SealRequiresAdminScope(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    t.Setenv("JWT_SECRET", "test-secret")
+
+    mgr, err := seal.NewManager(newSealedKeyManager(t), 3)
+    assert.NoError(t, err)
+    _, err = mgr.Initialize(5)
+    assert.NoError(t, err)
+
+    r := gin.New()
+    r.POST("/sys/seal", middleware.AuthMiddleware(), middleware.RequireScope(middleware.ScopeAdmin), handlers.NewSysHandler(mgr).Seal)
+
+    sealVault := func(scopes []string) int {
+        token, _ := middleware.GenerateTokenWithScopes(uuid.New(), scopes)
+        req := httptest.NewRequest(http.MethodPost, "/sys/seal", nil)
+        req.Header.Set("Authorization", "Bearer "+token)
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        return w.Code
+    }
+
+    // Un usuario cualquiera no puede dejar el servicio sellado
+    assert.Equal(t, http.StatusForbidden, sealVault(nil))
+    assert.False(t, mgr.Sealed())
+    assert.Equal(t, http.StatusOK, sealVault([]string{middleware.ScopeAdmin}))
+    assert.True(t, mgr.Sealed())
+}
\ No newline at end of file
@@ -0,0 +1,315 @@
+package tests
+
+import (
+    "card-vault/internal/brand"
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+    "card-vault/internal/middleware"
+    "card-vault/internal/models"
+    "card-vault/internal/service"
+    "card-vault/internal/tokenization"
+    "encoding/hex"
+    "net/http"
+    "net/http/httptest"
+    "slices"
+    "sync"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+    "gorm.io/gorm"
+)
+
+type memoryTokenRepository struct {
+    tokens map[string]models.CardToken
+    mu     sync.Mutex
+}
+
+func newMemoryTokenRepository() *memoryTokenRepository {
+    return &memoryTokenRepository{tokens: make(map[string]models.CardToken)}
+}
+
+func (r *memoryTokenRepository) Create(token *models.CardToken) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.tokens[token.Token] = *token
+    return nil
+}
+
+func (r *memoryTokenRepository) GetByToken(token string) (*models.CardToken, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    record, ok := r.tokens[token]
+    if !ok {
+        return nil, gorm.ErrRecordNotFound
+    }
+    return &record, nil
+}
+
+func (r *memoryTokenRepository) DeleteByCardIDs(cardIDs []uuid.UUID) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    var deleted int64
+    for token, record := range r.tokens {
+        if slices.Contains(cardIDs, record.CardID) {
+            delete(r.tokens, token)
+            deleted++
+        }
+    }
+    return deleted, nil
+}
+
+func luhnValid(number string) bool {
+    sum := 0
+    for i := len(number) - 1; i >= 0; i-- {
+        d := int(number[i] - '0')
+        if (len(number)-i)%2 == 0 {
+            d *= 2
+            if d > 9 {
+                d -= 9
+            }
+        }
+        sum += d
+    }
+    return sum%10 == 0
+}
+
+// Vectores de NIST SP 800-38G (FF1-AES128, samples 1 a 3)
+func TestFF1_NISTVectors(t *testing.T) {
+    key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+    tweak, _ := hex.DecodeString("39383736353433323130")
+
+    vectors := []struct {
+        tweak      []byte
+        plaintext  string
+        ciphertext string
+    }{
+        {nil, "0123456789", "2433477484"},
+        {tweak, "0123456789", "6124200773"},
+    }
+
+    ff1, err := tokenization.NewFF1(key, 10)
+    assert.NoError(t, err)
+
+    for _, v := range vectors {
+        ciphertext, err := ff1.Encrypt(v.tweak, v.plaintext)
+        assert.NoError(t, err)
+        assert.Equal(t, v.ciphertext, ciphertext)
+
+        plaintext, err := ff1.Decrypt(v.tweak, ciphertext)
+        assert.NoError(t, err)
+        assert.Equal(t, v.plaintext, plaintext)
+    }
+
+    radix36, err := tokenization.NewFF1(key, 36)
+    assert.NoError(t, err)
+    tweak36, _ := hex.DecodeString("3737373770717273373737")
+    ciphertext, err := radix36.Encrypt(tweak36, "0123456789abcdefghi")
+    assert.NoError(t, err)
+    assert.Equal(t, "a9tv40mll9kdu509eum", ciphertext)
+}
+
+func TestTokenizer_PreservesFormat(t *testing.T) {
+    key := make([]byte, 32)
+    tokenizer, err := tokenization.NewTokenizer(key, tokenization.Config{
+        Mode: tokenization.ModeDeterministic, PreserveBIN: true, PreserveLast4: true,
+    })
+    assert.NoError(t, err)
+
+    pan := "4111111111111111"
+    token, err := tokenizer.Tokenize(pan, nil)
+    assert.NoError(t, err)
+    assert.Len(t, token, 16)
+    assert.NotEqual(t, pan, token)
+    assert.Equal(t, pan[:6], token[:6])
+    assert.Equal(t, pan[12:], token[12:])
+    assert.True(t, luhnValid(token))
+
+    again, _ := tokenizer.Tokenize(pan, nil)
+    assert.Equal(t, token, again)
+
+    recovered, err := tokenizer.Detokenize(token, nil)
+    assert.NoError(t, err)
+    assert.Equal(t, pan, recovered)
+
+    // Con BIN y últimos 4 conservados, a un PAN de 13 dígitos le quedan solo 3 libres
+    _, err = tokenizer.Tokenize("4222222222222", nil)
+    assert.ErrorIs(t, err, tokenization.ErrDomainTooSmall)
+
+    _, err = tokenizer.Tokenize("4111111111111112", nil)
+    assert.ErrorIs(t, err, tokenization.ErrInvalidPAN)
+    assert.ErrorIs(t, err, brand.ErrLuhnCheck)
+}
+
+func TestTokenizer_FollowsTheBrandLuhnRule(t *testing.T) {
+    key := make([]byte, 32)
+    tokenizer, err := tokenization.NewTokenizer(key, tokenization.Config{Mode: tokenization.ModeDeterministic, PreserveBIN: true})
+    assert.NoError(t, err)
+
+    // UnionPay no exige Luhn: el token tampoco lo cumple y se revierte igual
+    pan := "6212345678901234"
+    token, err := tokenizer.Tokenize(pan, nil)
+    assert.NoError(t, err)
+    assert.Equal(t, "621234", token[:6])
+    assert.False(t, luhnValid(token))
+
+    recovered, err := tokenizer.Detokenize(token, nil)
+    assert.NoError(t, err)
+    assert.Equal(t, pan, recovered)
+
+    // Las demás marcas siguen exigiéndolo, también a la longitud de la marca
+    _, err = tokenizer.Tokenize("621234567890", nil)
+    assert.ErrorIs(t, err, brand.ErrInvalidLength)
+    _, err = tokenizer.Detokenize("4111111111111112", nil)
+    assert.ErrorIs(t, err, tokenization.ErrInvalidToken)
+}
+
+func TestCardService_DeterministicTokenRoundTrip(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardServiceWithOptions(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr), service.CardServiceOptions{
+        Tokenization: tokenization.Config{Mode: tokenization.ModeDeterministic, PreserveLast4: true},
+    })
+
+    userID := uuid.New()
+    card, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+
+    first, err := cardSvc.TokenizeCard(card.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, "deterministic", first.Mode)
+    assert.True(t, luhnValid(first.Token))
+    assert.Equal(t, "1111", first.Token[12:])
+
+    second, err := cardSvc.TokenizeCard(card.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, first.Token, second.Token)
+
+    result, err := cardSvc.Detokenize(first.Token)
+    assert.NoError(t, err)
+    assert.Equal(t, "4111111111111111", result.CardNumber)
+    assert.Equal(t, []uuid.UUID{card.ID}, result.CardIDs)
+
+    // Solo el propietario puede tokenizar su tarjeta
+    _, err = cardSvc.TokenizeCard(card.ID, uuid.New())
+    assert.Error(t, err)
+
+    // Tras borrar la tarjeta el token deja de revertirse
+    assert.NoError(t, cardSvc.DeleteCard(card.ID, userID))
+    _, err = cardSvc.Detokenize(first.Token)
+    assert.ErrorIs(t, err, service.ErrTokenNotFound)
+}
+
+func TestCardService_TokenizesCardsWithoutLuhn(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardServiceWithOptions(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr), service.CardServiceOptions{
+        Tokenization: tokenization.Config{Mode: tokenization.ModeDeterministic},
+    })
+
+    userID := uuid.New()
+    card, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "6212345678901234"))
+    assert.NoError(t, err)
+
+    token, err := cardSvc.TokenizeCard(card.ID, userID)
+    assert.NoError(t, err)
+    result, err := cardSvc.Detokenize(token.Token)
+    assert.NoError(t, err)
+    assert.Equal(t, "6212345678901234", result.CardNumber)
+    assert.Equal(t, []uuid.UUID{card.ID}, result.CardIDs)
+}
+
+func TestCardService_RandomTokenRoundTrip(t *testing.T) {
+    repo := newMemoryCardRepository()
+    tokens := newMemoryTokenRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardServiceWithOptions(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr), service.CardServiceOptions{
+        Tokenization: tokenization.Config{Mode: tokenization.ModeRandom, PreserveBIN: true},
+        Tokens:       tokens,
+    })
+
+    userID := uuid.New()
+    card, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "5555555555554444"))
+    assert.NoError(t, err)
+
+    first, err := cardSvc.TokenizeCard(card.ID, userID)
+    assert.NoError(t, err)
+    second, err := cardSvc.TokenizeCard(card.ID, userID)
+    assert.NoError(t, err)
+    assert.NotEqual(t, first.Token, second.Token)
+    assert.Equal(t, "555555", first.Token[:6])
+    assert.Len(t, tokens.tokens, 2)
+
+    for _, token := range []string{first.Token, second.Token} {
+        result, err := cardSvc.Detokenize(token)
+        assert.NoError(t, err)
+        assert.Equal(t, "5555555555554444", result.CardNumber)
+        assert.Equal(t, []uuid.UUID{card.ID}, result.CardIDs)
+    }
+
+    _, err = cardSvc.Detokenize("4111111111111111")
+    assert.ErrorIs(t, err, service.ErrTokenNotFound)
+
+    assert.NoError(t, cardSvc.DeleteCard(card.ID, userID))
+    _, err = cardSvc.Detokenize(first.Token)
+    assert.ErrorIs(t, err, service.ErrTokenNotFound)
+}
+
+func TestCardService_DetokenizesAfterConfigChangeAndKeyRotation(t *testing.T) {
+    repo := newMemoryCardRepository()
+    tokens := newMemoryTokenRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    newService := func(cfg tokenization.Config) service.CardService {
+        return service.NewCardServiceWithOptions(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr),
+            service.CardServiceOptions{Tokenization: cfg, Tokens: tokens})
+    }
+
+    userID := uuid.New()
+    cardSvc := newService(tokenization.Config{Mode: tokenization.ModeDeterministic, PreserveLast4: true})
+    card, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    deterministic, err := cardSvc.TokenizeCard(card.ID, userID)
+    assert.NoError(t, err)
+    random, err := newService(tokenization.Config{Mode: tokenization.ModeRandom, PreserveBIN: true}).TokenizeCard(card.ID, userID)
+    assert.NoError(t, err)
+
+    // Otra clave y otro formato no impiden revertir los tokens ya emitidos
+    assert.NoError(t, keyMgr.RotateTokenizationKey())
+    cardSvc = newService(tokenization.Config{Mode: tokenization.ModeRandom})
+    for _, token := range []string{deterministic.Token, random.Token} {
+        result, err := cardSvc.Detokenize(token)
+        assert.NoError(t, err)
+        assert.Equal(t, "4111111111111111", result.CardNumber)
+        assert.Equal(t, []uuid.UUID{card.ID}, result.CardIDs)
+    }
+
+    // Los nuevos se emiten con la versión activa
+    reissued, err := newService(tokenization.Config{Mode: tokenization.ModeDeterministic, PreserveLast4: true}).TokenizeCard(card.ID, userID)
+    assert.NoError(t, err)
+    assert.NotEqual(t, deterministic.Token, reissued.Token)
+    stored := tokens.tokens[random.Token]
+    assert.Equal(t, 1, stored.KeyVersion)
+    assert.True(t, stored.PreserveBIN)
+}
+
+func TestRequireScope(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    t.Setenv("JWT_SECRET", "test-secret")
+
+    r := gin.New()
+    r.POST("/detokenize", middleware.AuthMiddleware(), middleware.RequireScope(middleware.ScopeDetokenize), func(c *gin.Context) {
+        c.Status(http.StatusOK)
+    })
+
+    plain, _ := middleware.GenerateToken(uuid.New())
+    scoped, _ := middleware.GenerateTokenWithScopes(uuid.New(), []string{middleware.ScopeDetokenize})
+
+    for token, status := range map[string]int{plain: http.StatusForbidden, scoped: http.StatusOK} {
+        req := httptest.NewRequest(http.MethodPost, "/detokenize", nil)
+        req.Header.Set("Authorization", "Bearer "+token)
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        assert.Equal(t, status, w.Code)
+    }
+}
\ No newline at end of file
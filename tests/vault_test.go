@@ -0,0 +1,200 @@
+package tests
+
+import (
+    "bytes"
+    "card-vault/internal/crypto"
+    "card-vault/internal/kms"
+    "card-vault/internal/models"
+    "card-vault/internal/service"
+    "card-vault/internal/vault"
+    "context"
+    "reflect"
+    "testing"
+
+    "github.com/google/uuid"
+    "github.com/stretchr/testify/assert"
+    "gorm.io/gorm/schema"
+)
+
+// purposeCipher cifra con una clave fija y usa el propósito como datos asociados.
+type purposeCipher struct {
+    enc *crypto.EncryptionService
+}
+
+func (c purposeCipher) Seal(plaintext []byte, purpose string) ([]byte, error) {
+    return c.enc.Seal(plaintext, crypto.RecordKeyVersion, []byte(purpose))
+}
+
+func (c purposeCipher) Open(ciphertext []byte, purpose string) ([]byte, error) {
+    return c.enc.Open(ciphertext, []byte(purpose))
+}
+
+type sealedRecord struct {
+    Name  vault.Field `vault:"encrypt,purpose=name"`
+    Notes vault.Field `vault:"encrypt,purpose=notes"`
+    Plain string
+}
+
+func newPurposeCipher(t *testing.T) purposeCipher {
+    enc, err := crypto.NewEncryptionService(bytes.Repeat([]byte{7}, 32))
+    assert.NoError(t, err)
+    return purposeCipher{enc: enc}
+}
+
+func TestVault_SealsTaggedFields(t *testing.T) {
+    cipher := newPurposeCipher(t)
+
+    record := &sealedRecord{Plain: "visible"}
+    record.Name.SetString("John Doe")
+    assert.NoError(t, vault.Seal(record, cipher))
+    assert.NotEmpty(t, record.Name.Ciphertext())
+    assert.False(t, bytes.Contains(record.Name.Ciphertext(), []byte("John")))
+    assert.Empty(t, record.Notes.Ciphertext())
+
+    fields, err := vault.Fields(record)
+    assert.NoError(t, err)
+    assert.Equal(t, []string{"Name", "Notes"}, fields)
+
+    // Lo que se lee de la base de datos es solo el ciphertext
+    loaded := &sealedRecord{Name: vault.FromCiphertext(record.Name.Ciphertext())}
+    assert.Equal(t, "", loaded.Name.String())
+    assert.NoError(t, vault.Open(loaded, cipher))
+    assert.Equal(t, "John Doe", loaded.Name.String())
+
+    // Sin abrir no se puede volver a cifrar: se perdería el valor guardado
+    unopened := &sealedRecord{Name: vault.FromCiphertext(record.Name.Ciphertext())}
+    assert.ErrorIs(t, vault.Seal(unopened, cipher), vault.ErrNotOpened)
+
+    // El ciphertext de un campo no se descifra como otro
+    swapped := &sealedRecord{Notes: vault.FromCiphertext(record.Name.Ciphertext())}
+    assert.Error(t, vault.Open(swapped, cipher))
+
+    type badRecord struct {
+        Name string `vault:"encrypt,purpose=name"`
+    }
+    assert.Error(t, vault.Seal(&badRecord{}, cipher))
+    assert.Error(t, vault.Seal(sealedRecord{}, cipher))
+}
+
+// countingKeys da siempre el mismo Cipher y cuenta cuántas veces se vuelve a sellar.
+type countingKeys struct {
+    cipher purposeCipher
+    seals  int
+}
+
+func (k *countingKeys) SealCipher(record interface{}) (vault.Cipher, error) {
+    k.seals++
+    return k.cipher, nil
+}
+
+func (k *countingKeys) OpenCipher(record interface{}) (vault.Cipher, error) {
+    return k.cipher, nil
+}
+
+func TestVault_RecordKeysSealOnlyChangedRecords(t *testing.T) {
+    keys := &countingKeys{cipher: newPurposeCipher(t)}
+    ctx := vault.WithKeys(context.Background(), keys)
+    found, ok := vault.KeysFrom(ctx)
+    assert.True(t, ok)
+    assert.Equal(t, keys, found)
+
+    record := &sealedRecord{}
+    record.Name.SetString("John Doe")
+    assert.NoError(t, vault.SealRecord(record, keys))
+    assert.Equal(t, 1, keys.seals)
+
+    // Sin cambios no se vuelve a sellar
+    assert.NoError(t, vault.SealRecord(record, keys))
+    assert.Equal(t, 1, keys.seals)
+
+    loaded := &sealedRecord{Name: vault.FromCiphertext(record.Name.Ciphertext())}
+    assert.ErrorIs(t, vault.MarkDirty(loaded), vault.ErrNotOpened)
+    assert.NoError(t, vault.OpenRecord(loaded, keys))
+    assert.Equal(t, "John Doe", loaded.Name.String())
+
+    // MarkDirty fuerza a volver a sellar un registro abierto
+    assert.NoError(t, vault.MarkDirty(loaded))
+    assert.NoError(t, vault.SealRecord(loaded, keys))
+    assert.Equal(t, 2, keys.seals)
+    assert.NotEqual(t, record.Name.Ciphertext(), loaded.Name.Ciphertext())
+}
+
+func TestVault_SerializerRefusesUnsealedValues(t *testing.T) {
+    cipher := newPurposeCipher(t)
+    serializer := vault.Serializer{}
+    field := &schema.Field{Name: "Name"}
+
+    var value vault.Field
+    value.SetString("John Doe")
+    _, err := serializer.Value(context.Background(), field, reflect.Value{}, value)
+    assert.ErrorIs(t, err, vault.ErrNotSealed)
+
+    record := &sealedRecord{Name: value}
+    assert.NoError(t, vault.Seal(record, cipher))
+    stored, err := serializer.Value(context.Background(), field, reflect.Value{}, record.Name)
+    assert.NoError(t, err)
+    assert.Equal(t, record.Name.Ciphertext(), stored)
+
+    empty, err := serializer.Value(context.Background(), field, reflect.Value{}, record.Notes)
+    assert.NoError(t, err)
+    assert.Nil(t, empty)
+}
+
+func TestCardService_EncryptsCardholderName(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    userID := uuid.New()
+    card, err := cardSvc.CreateCard(userID, cardRequest("John Doe", "4111111111111111"))
+    assert.NoError(t, err)
+    assert.Equal(t, "John Doe", card.CardholderName)
+
+    stored, _ := repo.FindByID(card.ID)
+    assert.NotEmpty(t, stored.CardholderName.Ciphertext())
+    assert.False(t, bytes.Contains(stored.CardholderName.Ciphertext(), []byte("John")))
+
+    // Cambiar el titular en lote vuelve a cifrar la tarjeta con una DEK nueva
+    name := "John A. Doe"
+    results, err := cardSvc.BatchUpdateCards(userID, &models.BatchUpdateRequest{Cards: []models.BatchCardUpdate{{ID: card.ID, CardholderName: &name}}})
+    assert.NoError(t, err)
+    assert.Equal(t, "success", results[0].Status)
+    updated, _ := repo.FindByID(card.ID)
+    assert.NotEqual(t, stored.WrappedDEK, updated.WrappedDEK)
+    card, err = cardSvc.GetCard(card.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, "John A. Doe", card.CardholderName)
+    assert.Equal(t, "************1111", card.MaskedNumber)
+}
+
+func TestCardService_RotationEncryptsLegacyCardholderNames(t *testing.T) {
+    repo := newMemoryCardRepository()
+    keyMgr, _ := crypto.NewKeyManager(crypto.NewMemoryKeyStore())
+    cardSvc := service.NewCardService(repo, newMemoryRotationJobs(), keyMgr, kms.NewLocalProvider(keyMgr))
+
+    userID := uuid.New()
+    card, err := cardSvc.CreateCard(userID, cardRequest("Jane Doe", "4111111111111111"))
+    assert.NoError(t, err)
+
+    // Tarjeta guardada antes de cifrar el titular: el nombre está en claro
+    legacy, _ := repo.FindByID(card.ID)
+    legacy.CardholderName = vault.Field{}
+    legacy.LegacyCardholderName = "Jane Doe"
+    repo.Update(legacy)
+
+    card, err = cardSvc.GetCard(card.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, "Jane Doe", card.CardholderName)
+
+    started, err := cardSvc.RotateKeys()
+    assert.NoError(t, err)
+    job := waitForRotationJob(t, cardSvc, started.ID)
+    assert.Equal(t, 0, job.FailedCards)
+
+    migrated, _ := repo.FindByID(card.ID)
+    assert.Empty(t, migrated.LegacyCardholderName)
+    assert.NotEmpty(t, migrated.CardholderName.Ciphertext())
+    card, err = cardSvc.GetCard(card.ID, userID)
+    assert.NoError(t, err)
+    assert.Equal(t, "Jane Doe", card.CardholderName)
+}
\ No newline at end of file